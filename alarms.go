@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/performance"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// alarmTargets are the object types whose alarms are exported
+var alarmTargets = map[string]bool{
+	"Datastore":              true,
+	"StoragePod":             true,
+	"ClusterComputeResource": true,
+}
+
+// AlarmDefinitionInfo leaves out keys and modification times, so exports of different
+// vCenters can be diffed
+type AlarmDefinitionInfo struct {
+	Name        string `json:"name"`
+	SystemName  string `json:"system_name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Enabled     bool   `json:"enabled"`
+	// DefinedOn is the object the alarm is defined on, it applies to the objects below it
+	DefinedOn  string   `json:"defined_on"`
+	Target     string   `json:"target"`
+	Expression string   `json:"expression"`
+	Actions    []string `json:"actions"`
+	// ActionFrequency is the interval in seconds the actions repeat at, 0 to run them once
+	ActionFrequency int32 `json:"action_frequency,omitempty"`
+}
+
+type AlarmsReport struct {
+	Datacenter string                `json:"datacenter"`
+	Alarms     []AlarmDefinitionInfo `json:"alarms"`
+}
+
+// reportAlarms exports the alarm definitions for datastores, datastore clusters and clusters
+// that are defined in vCenter, on the datacenter or on the objects in it, with their
+// expressions and actions, so the alarm configuration can be diffed between vCenters and
+// tracked in git with -o json
+func reportAlarms(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	manager := client.ServiceContent.AlarmManager
+	if manager == nil {
+		return fmt.Errorf("the alarm manager is not available, alarm definitions need vCenter")
+	}
+
+	var entities []mo.ManagedEntity
+	for _, kind := range []string{"Folder", "ClusterComputeResource", "StoragePod", "Datastore"} {
+		var found []mo.ManagedEntity
+		err := retrieveAll(ctx, client, dc, kind, []string{"name"}, &found)
+		if err != nil {
+			return fmt.Errorf("retrieving inventory: %s", err)
+		}
+		for _, e := range found {
+			if e.Self.Type == "ClusterComputeResource" && !cfg.clusterSelected(e.Name) {
+				continue
+			}
+			entities = append(entities, e)
+		}
+	}
+	root := mo.ManagedEntity{Name: "vCenter"}
+	root.Self = client.ServiceContent.RootFolder
+	dcEntity := mo.ManagedEntity{Name: dc.Name()}
+	dcEntity.Self = dc.Reference()
+	entities = append([]mo.ManagedEntity{root, dcEntity}, entities...)
+
+	definedOn := make(map[string]string)
+	var refs []types.ManagedObjectReference
+	for _, e := range entities {
+		entity := e.Self
+		res, err := methods.GetAlarm(ctx, client.Client, &types.GetAlarm{This: *manager, Entity: &entity})
+		if err != nil {
+			return fmt.Errorf("getting alarms of %s: %s", e.Name, err)
+		}
+		for _, ref := range res.Returnval {
+			if _, ok := definedOn[ref.Value]; !ok {
+				definedOn[ref.Value] = e.Name
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	var alarms []mo.Alarm
+	if len(refs) > 0 {
+		err := property.DefaultCollector(client.Client).Retrieve(ctx, refs, []string{"info"}, &alarms)
+		if err != nil {
+			return fmt.Errorf("retrieving alarms: %s", err)
+		}
+	}
+
+	// metric alarms refer to performance counters by key
+	counters, err := performance.NewManager(client.Client).CounterInfoByKey(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieving performance counters: %s", err)
+	}
+
+	report := AlarmsReport{
+		Datacenter: dc.Name(),
+		Alarms:     make([]AlarmDefinitionInfo, 0),
+	}
+
+	for _, alarm := range alarms {
+		target := alarmTarget(alarm.Info.Expression)
+		if !alarmTargets[target] {
+			continue
+		}
+
+		info := AlarmDefinitionInfo{
+			Name:            alarm.Info.Name,
+			SystemName:      alarm.Info.SystemName,
+			Description:     alarm.Info.Description,
+			Enabled:         alarm.Info.Enabled,
+			DefinedOn:       definedOn[alarm.Self.Value],
+			Target:          target,
+			Expression:      alarmExpression(alarm.Info.Expression, counters),
+			Actions:         alarmActions(alarm.Info.Action),
+			ActionFrequency: alarm.Info.ActionFrequency,
+		}
+		report.Alarms = append(report.Alarms, info)
+	}
+	sort.Slice(report.Alarms, func(i, j int) bool {
+		a, b := report.Alarms[i], report.Alarms[j]
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.DefinedOn < b.DefinedOn
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nAlarm definitions: %d\n", len(report.Alarms))
+	for _, a := range report.Alarms {
+		state := "enabled"
+		if !a.Enabled {
+			state = "disabled"
+		}
+		fmt.Printf("\n  %s (%s, %s)\n", a.Name, a.Target, state)
+		fmt.Printf("    Defined on: %s\n", a.DefinedOn)
+		fmt.Printf("    Expression: %s\n", a.Expression)
+		fmt.Printf("    Actions: %s\n", valueOrNone(strings.Join(a.Actions, "; ")))
+	}
+
+	return nil
+}
+
+// alarmTarget returns the object type an alarm expression applies to
+func alarmTarget(expression types.BaseAlarmExpression) string {
+	switch e := expression.(type) {
+	case *types.OrAlarmExpression:
+		for _, sub := range e.Expression {
+			if target := alarmTarget(sub); target != "" {
+				return target
+			}
+		}
+	case *types.AndAlarmExpression:
+		for _, sub := range e.Expression {
+			if target := alarmTarget(sub); target != "" {
+				return target
+			}
+		}
+	case *types.StateAlarmExpression:
+		return e.Type
+	case *types.MetricAlarmExpression:
+		return e.Type
+	case *types.EventAlarmExpression:
+		return e.ObjectType
+	}
+	return ""
+}
+
+// alarmExpression formats an alarm expression with its yellow and red conditions
+func alarmExpression(expression types.BaseAlarmExpression, counters map[int32]*types.PerfCounterInfo) string {
+	join := func(subs []types.BaseAlarmExpression, op string) string {
+		parts := make([]string, 0, len(subs))
+		for _, sub := range subs {
+			parts = append(parts, alarmExpression(sub, counters))
+		}
+		if len(parts) == 1 {
+			return parts[0]
+		}
+		return "(" + strings.Join(parts, " "+op+" ") + ")"
+	}
+
+	switch e := expression.(type) {
+	case *types.OrAlarmExpression:
+		return join(e.Expression, "OR")
+	case *types.AndAlarmExpression:
+		return join(e.Expression, "AND")
+	case *types.StateAlarmExpression:
+		var conditions []string
+		if e.Yellow != "" {
+			conditions = append(conditions, "yellow "+e.Yellow)
+		}
+		if e.Red != "" {
+			conditions = append(conditions, "red "+e.Red)
+		}
+		return fmt.Sprintf("state %s %s [%s]", e.StatePath, e.Operator, strings.Join(conditions, ", "))
+	case *types.MetricAlarmExpression:
+		metric := fmt.Sprint(e.Metric.CounterId)
+		if counter, ok := counters[e.Metric.CounterId]; ok {
+			metric = counter.Name()
+		}
+		if e.Metric.Instance != "" {
+			metric += "[" + e.Metric.Instance + "]"
+		}
+		var conditions []string
+		condition := func(color string, value, interval int32) string {
+			if interval > 0 {
+				return fmt.Sprintf("%s %d for %ds", color, value, interval)
+			}
+			return fmt.Sprintf("%s %d", color, value)
+		}
+		if e.Yellow != 0 {
+			conditions = append(conditions, condition("yellow", e.Yellow, e.YellowInterval))
+		}
+		if e.Red != 0 {
+			conditions = append(conditions, condition("red", e.Red, e.RedInterval))
+		}
+		return fmt.Sprintf("metric %s %s [%s]", metric, e.Operator, strings.Join(conditions, ", "))
+	case *types.EventAlarmExpression:
+		event := e.EventType
+		if e.EventTypeId != "" {
+			event = e.EventTypeId
+		}
+		s := "event " + event
+		for _, c := range e.Comparisons {
+			s += fmt.Sprintf(" %s %s %s", c.AttributeName, c.Operator, c.Value)
+		}
+		if e.Status != "" {
+			s += " sets " + string(e.Status)
+		}
+		return s
+	}
+	return "unknown"
+}
+
+// alarmActions formats the actions of an alarm with the status changes that trigger them
+func alarmActions(action types.BaseAlarmAction) []string {
+	actions := make([]string, 0)
+	switch a := action.(type) {
+	case *types.GroupAlarmAction:
+		for _, sub := range a.Action {
+			actions = append(actions, alarmActions(sub)...)
+		}
+	case *types.AlarmTriggeringAction:
+		var s string
+		switch t := a.Action.(type) {
+		case *types.SendEmailAction:
+			s = "email to " + t.ToList
+		case *types.SendSNMPAction:
+			s = "SNMP trap"
+		case *types.RunScriptAction:
+			s = "run script " + t.Script
+		case *types.MethodAction:
+			s = "run " + strings.TrimSuffix(t.Name, "_Task")
+		case *types.CreateTaskAction:
+			s = "create task " + t.TaskTypeId
+		default:
+			s = "unknown action"
+		}
+		var transitions []string
+		for _, spec := range a.TransitionSpecs {
+			transition := fmt.Sprintf("%s to %s", spec.StartState, spec.FinalState)
+			if spec.Repeats {
+				transition += " repeating"
+			}
+			transitions = append(transitions, transition)
+		}
+		if len(transitions) > 0 {
+			s += " on " + strings.Join(transitions, ", ")
+		}
+		actions = append(actions, s)
+	}
+	return actions
+}
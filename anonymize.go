@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const (
+	kindDatacenter       = "datacenter"
+	kindDatastoreCluster = "datastore-cluster"
+)
+
+// pseudonym replaces an inventory name with a stable pseudonym when -anonymize is set.
+// The same name always gets the same pseudonym, so the structure of a report is kept.
+// Hashing with GODCINFO_ANONYMIZE_KEY, which -anonymize requires, keeps others from guessing
+// names by hashing candidates. 64 bits keep distinct names of large inventories apart.
+func (cfg *Config) pseudonym(kind, name string) string {
+	if !cfg.Anonymize || name == "" {
+		return name
+	}
+	mac := hmac.New(sha256.New, []byte(cfg.AnonymizeKey))
+	mac.Write([]byte(kind + "\x00" + name))
+	return fmt.Sprintf("%s-%x", kind, mac.Sum(nil)[:8])
+}
+
+// anonymizeGroupBy pseudonymizes the tag category or attribute name of -group-by
+func (cfg *Config) anonymizeGroupBy(groupBy string) string {
+	kind, key, ok := strings.Cut(groupBy, ":")
+	if !cfg.Anonymize || !ok {
+		return groupBy
+	}
+	if kind == "tag" {
+		return kind + ":" + cfg.pseudonym("tag-category", key)
+	}
+	return kind + ":" + cfg.pseudonym(kind, key)
+}
+
+// anonymizeError returns the message of an error for the report. With -anonymize only the
+// fault type is kept, as vCenter faults name the objects and paths they are about.
+func (cfg *Config) anonymizeError(err error) string {
+	if !cfg.Anonymize {
+		return err.Error()
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		switch {
+		case soap.IsSoapFault(e):
+			if fault := soap.ToSoapFault(e).VimFault(); fault != nil {
+				return "fault " + faultName(fault)
+			}
+			return "SOAP fault"
+		case soap.IsVimFault(e):
+			return "fault " + faultName(soap.ToVimFault(e))
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	return "error details removed by -anonymize"
+}
+
+// faultName returns the vSphere type of a fault, like ManagedObjectNotFound
+func faultName(fault interface{}) string {
+	t := reflect.TypeOf(fault)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// anonymizeReason keeps the inaccessible reasons vSphere defines and drops anything else
+func (cfg *Config) anonymizeReason(reason string) string {
+	if !cfg.Anonymize || reason == "" {
+		return reason
+	}
+	known := make([]string, 0, 1)
+	for _, r := range strings.Split(reason, ", ") {
+		switch types.HostMountInfoInaccessibleReason(r) {
+		case types.HostMountInfoInaccessibleReasonAllPathsDown_Start,
+			types.HostMountInfoInaccessibleReasonAllPathsDown_Timeout,
+			types.HostMountInfoInaccessibleReasonPermanentDeviceLoss:
+			known = append(known, r)
+		default:
+			known = append(known, "other")
+		}
+	}
+	return strings.Join(known, ", ")
+}
+
+// anonymizeDatastore pseudonymizes the name of a datastore and strips the
+// addresses and paths of its vVol storage, sizes and states are kept
+func (cfg *Config) anonymizeDatastore(info DatastoreInfo) DatastoreInfo {
+	if !cfg.Anonymize {
+		return info
+	}
+	info.Name = cfg.pseudonym(kindDatastore, info.Name)
+	info.InaccessibleReason = cfg.anonymizeReason(info.InaccessibleReason)
+	if info.VVol == nil {
+		return info
+	}
+
+	vvol := &VVolInfo{
+		StorageContainer:  cfg.pseudonym("storage-container", info.VVol.StorageContainer),
+		StorageArrays:     make([]string, 0, len(info.VVol.StorageArrays)),
+		VASAProviders:     make([]VASAProviderInfo, 0, len(info.VVol.VASAProviders)),
+		ProtocolEndpoints: make([]ProtocolEndpointInfo, 0, len(info.VVol.ProtocolEndpoints)),
+	}
+	for _, array := range info.VVol.StorageArrays {
+		vvol.StorageArrays = append(vvol.StorageArrays, cfg.pseudonym("storage-array", array))
+	}
+	for _, vp := range info.VVol.VASAProviders {
+		vvol.VASAProviders = append(vvol.VASAProviders, VASAProviderInfo{
+			Name:   cfg.pseudonym("vasa-provider", vp.Name),
+			Active: vp.Active,
+		})
+	}
+	for _, pe := range info.VVol.ProtocolEndpoints {
+		hosts := make([]string, 0, len(pe.Hosts))
+		for _, host := range pe.Hosts {
+			hosts = append(hosts, cfg.pseudonym(kindHost, host))
+		}
+		vvol.ProtocolEndpoints = append(vvol.ProtocolEndpoints, ProtocolEndpointInfo{
+			ID:      cfg.pseudonym("protocol-endpoint", pe.ID),
+			Type:    pe.Type,
+			Backing: cfg.pseudonym("backing", pe.Backing),
+			Hosts:   hosts,
+		})
+	}
+	info.VVol = vvol
+
+	return info
+}
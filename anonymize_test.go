@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestAnonymizeError(t *testing.T) {
+	cfg := &Config{Anonymize: true, AnonymizeKey: "secret"}
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"vim fault", soap.WrapVimFault(&types.ManagedObjectNotFound{}), "fault ManagedObjectNotFound"},
+		{"wrapped vim fault", fmt.Errorf("listing /DC0/datastore/pod1: %w", soap.WrapVimFault(&types.NoPermission{})), "fault NoPermission"},
+		{"timeout", fmt.Errorf("retrieving: %w", context.DeadlineExceeded), "timeout"},
+		{"other", errors.New("folder /DC0/datastore/secret-name not found"), "error details removed by -anonymize"},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.anonymizeError(tt.err); got != tt.want {
+			t.Errorf("%s: anonymizeError = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	plain := &Config{}
+	err := errors.New("folder /DC0/datastore/pod1 not found")
+	if got := plain.anonymizeError(err); got != err.Error() {
+		t.Errorf("anonymizeError without -anonymize = %q, want %q", got, err.Error())
+	}
+}
+
+func TestAnonymizeReason(t *testing.T) {
+	cfg := &Config{Anonymize: true, AnonymizeKey: "secret"}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"AllPathsDown_Start", "AllPathsDown_Start"},
+		{"AllPathsDown_Timeout, PermanentDeviceLoss", "AllPathsDown_Timeout, PermanentDeviceLoss"},
+		{"naa.600508b1001c at esx01.example.com", "other"},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.anonymizeReason(tt.in); got != tt.want {
+			t.Errorf("anonymizeReason(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPseudonym(t *testing.T) {
+	cfg := &Config{Anonymize: true, AnonymizeKey: "secret"}
+	a := cfg.pseudonym(kindDatastore, "ds-1")
+	if a != cfg.pseudonym(kindDatastore, "ds-1") {
+		t.Errorf("pseudonym is not stable")
+	}
+	if len(a) != len(kindDatastore)+1+16 {
+		t.Errorf("pseudonym %q has no 64 bit hash", a)
+	}
+	if a == cfg.pseudonym(kindDatastore, "ds-2") || a == cfg.pseudonym(kindHost, "ds-1") {
+		t.Errorf("pseudonyms of different names or kinds are equal")
+	}
+	other := &Config{Anonymize: true, AnonymizeKey: "other"}
+	if a == other.pseudonym(kindDatastore, "ds-1") {
+		t.Errorf("pseudonym doesn't depend on the key")
+	}
+}
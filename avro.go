@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// avroSnapshotSchema is the Avro schema of the published snapshots. It carries the datastore
+// inventory and capacity of -o json, the vVol, Tanzu and compute details are only part of JSON.
+const avroSnapshotSchema = `{
+  "type": "record",
+  "name": "godcinfo.Snapshot",
+  "fields": [
+    {"name": "datacenter", "type": "string"},
+    {"name": "scanned_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "content_hash", "type": "string"},
+    {"name": "clusters", "type": {"type": "array", "items": {
+      "type": "record",
+      "name": "godcinfo.Cluster",
+      "fields": [
+        {"name": "name", "type": "string"},
+        {"name": "datastore_clusters", "type": {"type": "array", "items": {
+          "type": "record",
+          "name": "godcinfo.DatastoreCluster",
+          "fields": [
+            {"name": "name", "type": "string"},
+            {"name": "total_capacity_gb", "type": "double"},
+            {"name": "total_free_space_gb", "type": "double"},
+            {"name": "used_pct", "type": "double"},
+            {"name": "datastores", "type": {"type": "array", "items": {
+              "type": "record",
+              "name": "godcinfo.Datastore",
+              "fields": [
+                {"name": "name", "type": "string"},
+                {"name": "type", "type": "string"},
+                {"name": "capacity_gb", "type": "double"},
+                {"name": "free_space_gb", "type": "double"},
+                {"name": "used_pct", "type": "double"},
+                {"name": "status", "type": "string"},
+                {"name": "shared", "type": "boolean"},
+                {"name": "mounted_host_count", "type": "int"},
+                {"name": "accessible", "type": "boolean"},
+                {"name": "inaccessible_reason", "type": ["null", "string"]}
+              ]
+            }}}
+          ]
+        }}},
+        {"name": "standalone_datastores", "type": {"type": "array", "items": "godcinfo.Datastore"}},
+        {"name": "totals", "type": {
+          "type": "record",
+          "name": "godcinfo.CapacityTotals",
+          "fields": [
+            {"name": "datastore_count", "type": "int"},
+            {"name": "shared_datastore_count", "type": "int"},
+            {"name": "capacity_gb", "type": "double"},
+            {"name": "free_space_gb", "type": "double"},
+            {"name": "used_pct", "type": "double"}
+          ]
+        }}
+      ]
+    }}},
+    {"name": "totals", "type": "godcinfo.CapacityTotals"},
+    {"name": "inaccessible_datastores", "type": {"type": "array", "items": "string"}},
+    {"name": "errors", "type": {"type": "array", "items": {
+      "type": "record",
+      "name": "godcinfo.ScanError",
+      "fields": [
+        {"name": "object", "type": "string"},
+        {"name": "operation", "type": "string"},
+        {"name": "message", "type": "string"}
+      ]
+    }}}
+  ]
+}`
+
+// avroChangeSchema is the Avro schema of the published change events
+const avroChangeSchema = `{
+  "type": "record",
+  "name": "godcinfo.ChangeEvent",
+  "fields": [
+    {"name": "datacenter", "type": "string"},
+    {"name": "detected_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "change", "type": "string"},
+    {"name": "object_type", "type": "string"},
+    {"name": "object", "type": "string"},
+    {"name": "cluster", "type": ["null", "string"]},
+    {"name": "field", "type": ["null", "string"]},
+    {"name": "old", "type": ["null", "string"]},
+    {"name": "new", "type": ["null", "string"]}
+  ]
+}`
+
+// Avro messages use the single object encoding: a marker, the fingerprint of the schema and
+// the binary encoded record, so consumers can tell the schema without a registry
+var (
+	avroSnapshotHeader = avroHeader(avroSnapshotSchema)
+	avroChangeHeader   = avroHeader(avroChangeSchema)
+)
+
+// printAvroSchemas prints the Avro schemas of the published snapshots and change events
+func printAvroSchemas(cfg *Config) error {
+	fmt.Println(avroSnapshotSchema)
+	fmt.Println(avroChangeSchema)
+	return nil
+}
+
+// avroSnapshot encodes a report with avroSnapshotSchema
+func avroSnapshot(infra InfrastructureInfo) []byte {
+	w := &avroWriter{buf: append([]byte(nil), avroSnapshotHeader...)}
+
+	scanned, hash := time.Now(), ""
+	if infra.Meta != nil {
+		scanned, hash = infra.Meta.FinishedAt, infra.Meta.ContentHash
+	}
+	w.string(infra.Datacenter)
+	w.long(scanned.UnixMilli())
+	w.string(hash)
+
+	datastore := func(ds DatastoreInfo) {
+		w.string(ds.Name)
+		w.string(ds.Type)
+		w.double(ds.Capacity)
+		w.double(ds.FreeSpace)
+		w.double(ds.UsedPct)
+		w.string(ds.Status)
+		w.boolean(ds.Shared)
+		w.long(int64(ds.MountedHostCount))
+		w.boolean(ds.Accessible)
+		w.optionalString(ds.InaccessibleReason)
+	}
+	totals := func(t CapacityTotals) {
+		w.long(int64(t.DatastoreCount))
+		w.long(int64(t.SharedDatastores))
+		w.double(t.Capacity)
+		w.double(t.FreeSpace)
+		w.double(t.UsedPct)
+	}
+
+	w.array(len(infra.Clusters), func(i int) {
+		cluster := infra.Clusters[i]
+		w.string(cluster.Name)
+		w.array(len(cluster.DatastoreClusters), func(i int) {
+			pod := cluster.DatastoreClusters[i]
+			w.string(pod.Name)
+			w.double(pod.TotalCapacity)
+			w.double(pod.TotalFreeSpace)
+			w.double(pod.UsedPct)
+			w.array(len(pod.Datastores), func(i int) { datastore(pod.Datastores[i]) })
+		})
+		w.array(len(cluster.StandaloneDatastores), func(i int) { datastore(cluster.StandaloneDatastores[i]) })
+		totals(cluster.Totals)
+	})
+	totals(infra.Totals)
+	w.array(len(infra.InaccessibleDatastores), func(i int) { w.string(infra.InaccessibleDatastores[i]) })
+	w.array(len(infra.Errors), func(i int) {
+		w.string(infra.Errors[i].Object)
+		w.string(infra.Errors[i].Operation)
+		w.string(infra.Errors[i].Message)
+	})
+
+	return w.buf
+}
+
+// avroChange encodes a change event with avroChangeSchema
+func avroChange(change ChangeEvent) []byte {
+	w := &avroWriter{buf: append([]byte(nil), avroChangeHeader...)}
+	w.string(change.Datacenter)
+	w.long(change.DetectedAt.UnixMilli())
+	w.string(change.Change)
+	w.string(change.ObjectType)
+	w.string(change.Object)
+	w.optionalString(change.Cluster)
+	w.optionalString(change.Field)
+	w.optionalString(change.Old)
+	w.optionalString(change.New)
+	return w.buf
+}
+
+// avroWriter writes the Avro binary encoding
+type avroWriter struct {
+	buf []byte
+}
+
+// long writes int and long values as zigzag varint
+func (w *avroWriter) long(v int64) {
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *avroWriter) string(s string) {
+	w.long(int64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *avroWriter) double(v float64) {
+	w.buf = binary.LittleEndian.AppendUint64(w.buf, math.Float64bits(v))
+}
+
+func (w *avroWriter) boolean(v bool) {
+	if v {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// optionalString writes a ["null", "string"] union, empty strings as null
+func (w *avroWriter) optionalString(s string) {
+	if s == "" {
+		w.long(0)
+		return
+	}
+	w.long(1)
+	w.string(s)
+}
+
+// array writes n items as a single block
+func (w *avroWriter) array(n int, item func(i int)) {
+	if n > 0 {
+		w.long(int64(n))
+		for i := 0; i < n; i++ {
+			item(i)
+		}
+	}
+	w.long(0)
+}
+
+// avroHeader returns the single object encoding marker and the CRC-64-AVRO fingerprint of
+// the parsing canonical form of the schema
+func avroHeader(schema string) []byte {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(schema), &parsed); err != nil {
+		panic(fmt.Sprintf("invalid Avro schema: %s", err))
+	}
+	var canonical bytes.Buffer
+	writeCanonicalSchema(&canonical, parsed)
+
+	header := []byte{0xc3, 0x01}
+	return binary.LittleEndian.AppendUint64(header, avroFingerprint(canonical.Bytes()))
+}
+
+// writeCanonicalSchema writes the parsing canonical form of a schema with full names: only the
+// attributes that matter for the encoding in a fixed order, primitives as plain names
+func writeCanonicalSchema(w *bytes.Buffer, schema interface{}) {
+	switch s := schema.(type) {
+	case map[string]interface{}:
+		if _, named := s["name"]; !named {
+			if t, ok := s["type"].(string); ok && t != "record" && t != "enum" && t != "array" && t != "map" && t != "fixed" {
+				writeCanonicalSchema(w, t)
+				return
+			}
+		}
+		w.WriteByte('{')
+		first := true
+		for _, key := range []string{"name", "type", "fields", "symbols", "items", "values", "size"} {
+			value, ok := s[key]
+			if !ok {
+				continue
+			}
+			if !first {
+				w.WriteByte(',')
+			}
+			first = false
+			fmt.Fprintf(w, "%q:", key)
+			writeCanonicalSchema(w, value)
+		}
+		w.WriteByte('}')
+	case []interface{}:
+		w.WriteByte('[')
+		for i, item := range s {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeCanonicalSchema(w, item)
+		}
+		w.WriteByte(']')
+	case float64:
+		fmt.Fprintf(w, "%d", int64(s))
+	default:
+		data, _ := json.Marshal(s)
+		w.Write(data)
+	}
+}
+
+// avroFingerprint is the CRC-64-AVRO (Rabin) fingerprint of the Avro specification
+func avroFingerprint(data []byte) uint64 {
+	const empty = 0xc15d213aa4d7a795
+	var table [256]uint64
+	for i := range table {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (empty & -(fp & 1))
+		}
+		table[i] = fp
+	}
+
+	fp := uint64(empty)
+	for _, b := range data {
+		fp = (fp >> 8) ^ table[byte(fp)^b]
+	}
+	return fp
+}
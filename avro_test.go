@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestAvroChange(t *testing.T) {
+	change := ChangeEvent{
+		Datacenter: "DC0",
+		DetectedAt: time.UnixMilli(1),
+		Change:     changeChanged,
+		ObjectType: objectDatastore,
+		Object:     "ds",
+		Field:      "status",
+		Old:        statusOK,
+		New:        statusWarning,
+	}
+	got := avroChange(change)
+
+	if !bytes.Equal(got[:2], []byte{0xc3, 0x01}) {
+		t.Fatalf("marker = %x, want c301", got[:2])
+	}
+	if !bytes.Equal(got[:10], avroChangeHeader) {
+		t.Errorf("header = %x, want %x", got[:10], avroChangeHeader)
+	}
+	want := []byte{
+		6, 'D', 'C', '0',
+		2, // 1 as zigzag
+		14, 'c', 'h', 'a', 'n', 'g', 'e', 'd',
+		18, 'd', 'a', 't', 'a', 's', 't', 'o', 'r', 'e',
+		4, 'd', 's',
+		0,                                   // no cluster
+		2, 12, 's', 't', 'a', 't', 'u', 's', // field
+		2, 4, 'o', 'k',
+		2, 14, 'w', 'a', 'r', 'n', 'i', 'n', 'g',
+	}
+	if !bytes.Equal(got[10:], want) {
+		t.Errorf("record = %v, want %v", got[10:], want)
+	}
+}
+
+func TestCanonicalSchema(t *testing.T) {
+	tests := []struct {
+		schema string
+		want   string
+	}{
+		{`"string"`, `"string"`},
+		{`{"type": "long", "logicalType": "timestamp-millis"}`, `"long"`},
+		{`{"type": "array", "items": "string"}`, `{"type":"array","items":"string"}`},
+		{
+			`{"fields": [{"type": ["null", "string"], "name": "a", "doc": "x"}], "name": "godcinfo.R", "type": "record"}`,
+			`{"name":"godcinfo.R","type":"record","fields":[{"name":"a","type":["null","string"]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		var schema interface{}
+		if err := json.Unmarshal([]byte(tt.schema), &schema); err != nil {
+			t.Fatal(err)
+		}
+		var got bytes.Buffer
+		writeCanonicalSchema(&got, schema)
+		if got.String() != tt.want {
+			t.Errorf("canonical form of %s = %s, want %s", tt.schema, got.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// bench phases in the order of a scan
+const (
+	benchLogin      = "login"
+	benchTraversal  = "folder traversal"
+	benchDatastores = "datastore properties per batch"
+	benchVMs        = "VM properties per batch"
+	benchRetrieval  = "property retrieval"
+	benchRun        = "run"
+)
+
+var benchPhases = []string{benchLogin, benchTraversal, benchDatastores, benchVMs, benchRetrieval, benchRun}
+
+// BenchPhaseInfo holds the timing percentiles of one scan phase over all runs in milliseconds
+type BenchPhaseInfo struct {
+	Name    string  `json:"name"`
+	Samples int     `json:"samples"`
+	Min     float64 `json:"min_ms"`
+	P50     float64 `json:"p50_ms"`
+	P90     float64 `json:"p90_ms"`
+	P99     float64 `json:"p99_ms"`
+	Max     float64 `json:"max_ms"`
+}
+
+type BenchReport struct {
+	Datacenter            string           `json:"datacenter"`
+	Runs                  int              `json:"runs"`
+	BatchSize             int              `json:"batch_size"`
+	MaxConcurrentRequests int              `json:"max_concurrent_requests"`
+	Datastores            int              `json:"datastore_count"`
+	VMs                   int              `json:"vm_count"`
+	Phases                []BenchPhaseInfo `json:"phases"`
+}
+
+// benchTimings collects the durations of the phases, the batches are timed concurrently
+type benchTimings struct {
+	mu      sync.Mutex
+	samples map[string][]int64
+}
+
+func (t *benchTimings) add(phase string, started time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples[phase] = append(t.samples[phase], int64(time.Since(started)))
+}
+
+// runBench times the phases of a scan over -bench-runs runs: a fresh login, the traversal of
+// the clusters and datastore folders, and the retrieval of the datastore and VM properties in
+// batches of -bench-batch-size objects, at most -max-concurrent-requests at once. The
+// percentiles show which phase dominates and how batch size and parallelism change it.
+func runBench(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	if cfg.BenchRuns < 1 {
+		return fmt.Errorf("-bench-runs must be at least 1")
+	}
+	if cfg.BenchBatchSize < 1 {
+		return fmt.Errorf("-bench-batch-size must be at least 1")
+	}
+
+	report := BenchReport{
+		Datacenter:            dc.Name(),
+		Runs:                  cfg.BenchRuns,
+		BatchSize:             cfg.BenchBatchSize,
+		MaxConcurrentRequests: cfg.MaxConcurrentRequests,
+		Phases:                make([]BenchPhaseInfo, 0, len(benchPhases)),
+	}
+	timings := &benchTimings{samples: make(map[string][]int64)}
+
+	for run := 1; run <= cfg.BenchRuns; run++ {
+		if !cfg.OutputJSON {
+			fmt.Printf("Run %d of %d\n", run, cfg.BenchRuns)
+		}
+		datastores, vms, err := benchRunOnce(ctx, cfg, dc, timings)
+		if err != nil {
+			return fmt.Errorf("run %d: %s", run, err)
+		}
+		report.Datastores, report.VMs = datastores, vms
+	}
+
+	for _, phase := range benchPhases {
+		samples := timings.samples[phase]
+		if len(samples) == 0 {
+			continue
+		}
+		ms := func(p float64) float64 {
+			return float64(percentile(samples, p)) / float64(time.Millisecond)
+		}
+		report.Phases = append(report.Phases, BenchPhaseInfo{
+			Name:    phase,
+			Samples: len(samples),
+			Min:     ms(0),
+			P50:     ms(50),
+			P90:     ms(90),
+			P99:     ms(99),
+			Max:     ms(100),
+		})
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\n%d runs against %s: %d datastores, %d VMs, batches of %d, %d concurrent requests\n",
+		report.Runs, report.Datacenter, report.Datastores, report.VMs, report.BatchSize, report.MaxConcurrentRequests)
+	fmt.Printf("  %-32s %7s %9s %9s %9s %9s %9s\n", "Phase", "Samples", "Min", "p50", "p90", "p99", "Max")
+	for _, phase := range report.Phases {
+		fmt.Printf("  %-32s %7d %7.0fms %7.0fms %7.0fms %7.0fms %7.0fms\n",
+			phase.Name, phase.Samples, phase.Min, phase.P50, phase.P90, phase.P99, phase.Max)
+	}
+
+	return nil
+}
+
+// benchRunOnce runs and times one scan with its own session, it returns the number of
+// datastores and VMs retrieved
+func benchRunOnce(ctx context.Context, cfg *Config, dc *object.Datacenter, timings *benchTimings) (int, int, error) {
+	started := time.Now()
+
+	client, err := connectToVSphere(ctx, cfg)
+	if err != nil {
+		return 0, 0, fmt.Errorf("login: %s", err)
+	}
+	defer client.Logout(ctx)
+	timings.add(benchLogin, started)
+
+	// the datacenter of this session
+	path := dc.InventoryPath
+	dc = object.NewDatacenter(client.Client, dc.Reference())
+	dc.InventoryPath = path
+
+	traversal := time.Now()
+	finder := find.NewFinder(client.Client, true)
+	finder.SetDatacenter(dc)
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return 0, 0, fmt.Errorf("getting clusters: %s", err)
+	}
+	folders, err := dc.Folders(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("getting datacenter folders: %s", err)
+	}
+	if _, err := folders.DatastoreFolder.Children(ctx); err != nil {
+		return 0, 0, fmt.Errorf("getting datastore folder: %s", err)
+	}
+	timings.add(benchTraversal, traversal)
+
+	// the references are collected up front, so the batches only time the properties
+	m := view.NewManager(client.Client)
+	dsRefs, err := benchRefs(ctx, m, dc.Reference(), "Datastore")
+	if err != nil {
+		return 0, 0, fmt.Errorf("listing datastores: %s", err)
+	}
+	var vmRefs []types.ManagedObjectReference
+	for _, cluster := range clusters {
+		refs, err := benchRefs(ctx, m, cluster.Reference(), "VirtualMachine")
+		if err != nil {
+			return 0, 0, fmt.Errorf("listing VMs of cluster %s: %s", cluster.Name(), err)
+		}
+		vmRefs = append(vmRefs, refs...)
+	}
+
+	retrieval := time.Now()
+	pc := property.DefaultCollector(client.Client)
+	var mu sync.Mutex
+	var firstErr error
+	batches := func(phase string, refs []types.ManagedObjectReference, props []string, dst func() interface{}) {
+		n := (len(refs) + cfg.BenchBatchSize - 1) / cfg.BenchBatchSize
+		forEachConcurrently(cfg, n, func(i int) {
+			batch := refs[i*cfg.BenchBatchSize:]
+			if len(batch) > cfg.BenchBatchSize {
+				batch = batch[:cfg.BenchBatchSize]
+			}
+			started := time.Now()
+			if err := pc.Retrieve(ctx, batch, props, dst()); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %s", phase, err)
+				}
+				mu.Unlock()
+				return
+			}
+			timings.add(phase, started)
+		})
+	}
+	batches(benchDatastores, dsRefs, []string{"name", "summary", "info", "host"}, func() interface{} { return &[]mo.Datastore{} })
+	batches(benchVMs, vmRefs, []string{"name", "config.hardware", "runtime", "summary.storage"}, func() interface{} { return &[]mo.VirtualMachine{} })
+	if firstErr != nil {
+		return 0, 0, firstErr
+	}
+	timings.add(benchRetrieval, retrieval)
+	timings.add(benchRun, started)
+
+	return len(dsRefs), len(vmRefs), nil
+}
+
+// benchRefs returns the references of the objects of one kind below a container
+func benchRefs(ctx context.Context, m *view.Manager, container types.ManagedObjectReference, kind string) ([]types.ManagedObjectReference, error) {
+	v, err := m.CreateContainerView(ctx, container, []string{kind}, true)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Destroy(ctx)
+
+	return v.Find(ctx, []string{kind}, nil)
+}
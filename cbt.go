@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type DiskCBTInfo struct {
+	Disk     string `json:"disk"`
+	ChangeID string `json:"change_id"`
+}
+
+type VMCBTInfo struct {
+	Name    string        `json:"name"`
+	Enabled bool          `json:"enabled"`
+	Status  string        `json:"status"`
+	Disks   []DiskCBTInfo `json:"disks"`
+}
+
+type CBTReport struct {
+	Datacenter string      `json:"datacenter"`
+	Enabled    int         `json:"enabled"`
+	Disabled   int         `json:"disabled"`
+	Reset      int         `json:"reset"`
+	VMs        []VMCBTInfo `json:"vms"`
+}
+
+const (
+	cbtStatusEnabled  = "enabled"
+	cbtStatusDisabled = "disabled"
+	cbtStatusReset    = "reset"
+)
+
+// reportCBT reports Changed Block Tracking per VM and disk. A VM with CBT enabled
+// but disks without a change ID has had its tracking reset and needs a full backup.
+func reportCBT(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	var vms []mo.VirtualMachine
+	err := retrieveAll(ctx, client, dc, "VirtualMachine", []string{"name", "config.template", "config.changeTrackingEnabled", "config.hardware.device"}, &vms)
+	if err != nil {
+		return fmt.Errorf("retrieving VMs: %s", err)
+	}
+
+	report := CBTReport{
+		Datacenter: dc.Name(),
+		VMs:        make([]VMCBTInfo, 0, len(vms)),
+	}
+
+	for _, vm := range vms {
+		if vm.Config == nil || vm.Config.Template {
+			continue
+		}
+
+		info := VMCBTInfo{
+			Name:    vm.Name,
+			Enabled: vm.Config.ChangeTrackingEnabled != nil && *vm.Config.ChangeTrackingEnabled,
+			Status:  cbtStatusDisabled,
+			Disks:   make([]DiskCBTInfo, 0),
+		}
+
+		reset := false
+		for _, device := range vm.Config.Hardware.Device {
+			disk, ok := device.(*types.VirtualDisk)
+			if !ok {
+				continue
+			}
+
+			label := fmt.Sprintf("disk-%d", disk.Key)
+			if d := disk.GetVirtualDevice().DeviceInfo; d != nil {
+				label = d.GetDescription().Label
+			}
+
+			changeID := diskChangeID(disk.Backing)
+			if changeID == "" {
+				reset = true
+			}
+			info.Disks = append(info.Disks, DiskCBTInfo{Disk: label, ChangeID: changeID})
+		}
+
+		switch {
+		case !info.Enabled:
+			report.Disabled++
+		case reset:
+			info.Status = cbtStatusReset
+			report.Reset++
+		default:
+			info.Status = cbtStatusEnabled
+			report.Enabled++
+		}
+
+		report.VMs = append(report.VMs, info)
+	}
+
+	sort.Slice(report.VMs, func(i, j int) bool {
+		return report.VMs[i].Name < report.VMs[j].Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nCBT enabled: %d, disabled: %d, reset: %d\n", report.Enabled, report.Disabled, report.Reset)
+
+	for _, status := range []string{cbtStatusDisabled, cbtStatusReset} {
+		header := false
+		for _, vm := range report.VMs {
+			if vm.Status != status {
+				continue
+			}
+			if !header {
+				fmt.Printf("\nVMs with CBT %s:\n", status)
+				header = true
+			}
+			fmt.Printf("  - %s\n", vm.Name)
+			if status == cbtStatusReset {
+				for _, disk := range vm.Disks {
+					if disk.ChangeID == "" {
+						fmt.Printf("      %s has no change ID\n", disk.Disk)
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// diskChangeID returns the CBT change ID of a virtual disk backing
+func diskChangeID(backing types.BaseVirtualDeviceBackingInfo) string {
+	switch b := backing.(type) {
+	case *types.VirtualDiskFlatVer2BackingInfo:
+		return b.ChangeId
+	case *types.VirtualDiskSeSparseBackingInfo:
+		return b.ChangeId
+	case *types.VirtualDiskSparseVer2BackingInfo:
+		return b.ChangeId
+	case *types.VirtualDiskRawDiskMappingVer1BackingInfo:
+		return b.ChangeId
+	case *types.VirtualDiskRawDiskVer2BackingInfo:
+		return b.ChangeId
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/sts"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// loadClientCertificate reads the -cert and -key files, the key may be part of the
+// certificate file
+func loadClientCertificate(cfg *Config) (tls.Certificate, error) {
+	key := cfg.PrivateKey
+	if key == "" {
+		key = cfg.Certificate
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.Certificate, key)
+	if err != nil {
+		return cert, fmt.Errorf("loading -cert %s and -key %s: %s", cfg.Certificate, key, err)
+	}
+	return cert, nil
+}
+
+// certificateLogin logs in with the client certificate set on the vim client. With -username
+// the certificate is the one of the vCenter extension with that key (LoginExtensionByCertificate),
+// without it the certificate belongs to a solution user and a holder-of-key token is issued
+// by the Security Token Service for LoginByToken.
+func certificateLogin(ctx context.Context, client *govmomi.Client, cfg *Config) error {
+	if cfg.Username != "" {
+		return client.SessionManager.LoginExtensionByCertificate(ctx, cfg.Username)
+	}
+
+	signer, err := issueToken(ctx, client, cfg)
+	if err != nil {
+		return fmt.Errorf("issuing a holder-of-key token: %s", err)
+	}
+
+	// LoginByToken only accepts the versions vCenter announces in vimServiceVersions.xml
+	if client.Client.Version == vim25.Version {
+		_ = client.Client.UseServiceVersion()
+	}
+	header := soap.Header{Security: signer}
+	return client.SessionManager.LoginByToken(client.Client.WithHeader(ctx, header))
+}
+
+// issueToken issues a token from the vCenter Security Token Service, a bearer token for
+// -username and -password or a holder-of-key token for the solution user certificate
+func issueToken(ctx context.Context, client *govmomi.Client, cfg *Config) (*sts.Signer, error) {
+	var req sts.TokenRequest
+	switch {
+	case cfg.Username != "" && cfg.Password != "":
+		req.Userinfo = url.UserPassword(cfg.Username, cfg.Password)
+	case cfg.Certificate != "" && cfg.Username == "":
+		req.Certificate = client.Client.Certificate()
+		req.Delegatable = true
+	default:
+		return nil, fmt.Errorf("needs -username and -password or a solution user -cert")
+	}
+
+	c, err := sts.NewClient(ctx, client.Client)
+	if err != nil {
+		return nil, err
+	}
+	c.Client.Transport = vcenterTransport(cfg, c.Client.Transport)
+
+	return c.Issue(ctx, req)
+}
+
+// restLogin logs in to the vSphere Automation REST endpoint with the password or, for a
+// solution user certificate, a holder-of-key token
+func restLogin(ctx context.Context, rc *rest.Client, client *govmomi.Client, cfg *Config) error {
+	if cfg.Password != "" || cfg.Certificate == "" {
+		return rc.Login(ctx, url.UserPassword(cfg.Username, cfg.Password))
+	}
+	if cfg.Username != "" {
+		return fmt.Errorf("the vSphere REST API doesn't accept extension certificates, use -username and -password")
+	}
+
+	signer, err := issueToken(ctx, client, cfg)
+	if err != nil {
+		return fmt.Errorf("issuing a holder-of-key token: %s", err)
+	}
+	return rc.LoginByToken(rc.WithSigner(ctx, signer))
+}
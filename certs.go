@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+type CertificateInfo struct {
+	Subject  string     `json:"subject,omitempty"`
+	Issuer   string     `json:"issuer,omitempty"`
+	NotAfter *time.Time `json:"not_after,omitempty"`
+	DaysLeft *int       `json:"days_left,omitempty"`
+}
+
+type HostCertificateInfo struct {
+	Name        string           `json:"name"`
+	Cluster     string           `json:"cluster"`
+	Certificate *CertificateInfo `json:"certificate,omitempty"`
+	Warnings    []string         `json:"warnings"`
+}
+
+// VCenterCertificateInfo is a certificate of vCenter itself, the machine SSL certificate
+// served on the API endpoint or the STS signing certificate
+type VCenterCertificateInfo struct {
+	Name        string           `json:"name"`
+	Certificate *CertificateInfo `json:"certificate,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+type CertificateReport struct {
+	Datacenter  string                   `json:"datacenter"`
+	WarningDays int                      `json:"warning_days"`
+	VCenter     []VCenterCertificateInfo `json:"vcenter"`
+	Hosts       []HostCertificateInfo    `json:"hosts"`
+	// hosts whose certificate expires within the warning window or has expired
+	Expiring []string `json:"expiring"`
+}
+
+// reportCertificates shows the expiry of the vCenter certificates and of the TLS certificate
+// of every host and flags certificates expiring within -cert-warning-days, expired host
+// certificates break the connection to vCenter
+func reportCertificates(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+	now := time.Now()
+
+	report := CertificateReport{
+		Datacenter:  dc.Name(),
+		WarningDays: cfg.CertWarningDays,
+		VCenter:     vcenterCertificates(ctx, client, cfg, now),
+		Hosts:       make([]HostCertificateInfo, 0),
+		Expiring:    make([]string, 0),
+	}
+
+	for _, info := range report.VCenter {
+		if info.Certificate != nil && certificateWarning(info.Certificate, cfg.CertWarningDays) != "" {
+			report.Expiring = append(report.Expiring, "vCenter "+info.Name)
+		}
+	}
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+		if len(clusterMo.Host) == 0 {
+			continue
+		}
+
+		var hosts []mo.HostSystem
+		err = pc.Retrieve(ctx, clusterMo.Host, []string{"name", "configManager.certificateManager"}, &hosts)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+
+		for _, host := range hosts {
+			info := HostCertificateInfo{
+				Name:     host.Name,
+				Cluster:  cluster.Name(),
+				Warnings: make([]string, 0),
+			}
+
+			// hosts that can't tell their certificate are reported without expiry
+			if ref := host.ConfigManager.CertificateManager; ref != nil {
+				var cm mo.HostCertificateManager
+				err = pc.RetrieveOne(ctx, *ref, []string{"certificateInfo"}, &cm)
+				if err == nil {
+					cert := cm.CertificateInfo
+					info.Certificate = newCertificateInfo(cert.Subject, cert.Issuer, cert.NotAfter, now)
+				}
+			}
+
+			if info.Certificate == nil {
+				info.Warnings = append(info.Warnings, "host doesn't report its certificate")
+			} else if warning := certificateWarning(info.Certificate, cfg.CertWarningDays); warning != "" {
+				info.Warnings = append(info.Warnings, warning)
+				report.Expiring = append(report.Expiring, host.Name)
+			}
+
+			report.Hosts = append(report.Hosts, info)
+		}
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Println("\nvCenter")
+	fmt.Println("-------")
+	for _, info := range report.VCenter {
+		fmt.Printf("  %s:\n", vcenterCertificateTitles[info.Name])
+		if info.Error != "" {
+			fmt.Printf("    Certificate: not retrievable (%s)\n", info.Error)
+			continue
+		}
+		printCertificate(info.Certificate)
+		if warning := certificateWarning(info.Certificate, cfg.CertWarningDays); warning != "" {
+			fmt.Printf("    WARNING: %s\n", warning)
+		}
+	}
+
+	cluster := ""
+	for _, info := range report.Hosts {
+		if info.Cluster != cluster {
+			cluster = info.Cluster
+			fmt.Printf("\nCluster: %s\n", cluster)
+			fmt.Println(strings.Repeat("-", len(cluster)+9))
+		}
+
+		fmt.Printf("  Host: %s\n", info.Name)
+		printCertificate(info.Certificate)
+		for _, warning := range info.Warnings {
+			fmt.Printf("    WARNING: %s\n", warning)
+		}
+	}
+
+	fmt.Printf("\nCertificates expiring within %d days: %d\n", report.WarningDays, len(report.Expiring))
+	for _, name := range report.Expiring {
+		fmt.Printf("  - %s\n", name)
+	}
+
+	return nil
+}
+
+// newCertificateInfo returns the expiry details of a certificate
+func newCertificateInfo(subject, issuer string, notAfter *time.Time, now time.Time) *CertificateInfo {
+	info := &CertificateInfo{Subject: subject, Issuer: issuer, NotAfter: notAfter}
+	if notAfter != nil {
+		days := int(notAfter.Sub(now).Hours() / 24)
+		info.DaysLeft = &days
+	}
+	return info
+}
+
+// certificateWarning tells whether a certificate has expired or expires within warningDays
+func certificateWarning(cert *CertificateInfo, warningDays int) string {
+	switch {
+	case cert.DaysLeft == nil:
+		return "certificate has no expiry date"
+	case cert.NotAfter.Before(time.Now()):
+		return fmt.Sprintf("certificate expired on %s", cert.NotAfter.Format("2006-01-02"))
+	case *cert.DaysLeft < warningDays:
+		return fmt.Sprintf("certificate expires in %d days", *cert.DaysLeft)
+	}
+	return ""
+}
+
+// printCertificate prints the subject, issuer and expiry of a certificate
+func printCertificate(cert *CertificateInfo) {
+	if cert == nil {
+		fmt.Println("    Certificate: not reported")
+		return
+	}
+	fmt.Printf("    Subject: %s\n", valueOrNone(cert.Subject))
+	fmt.Printf("    Issuer: %s\n", valueOrNone(cert.Issuer))
+	if cert.NotAfter != nil {
+		fmt.Printf("    Expires: %s (%d days)\n", cert.NotAfter.Format("2006-01-02"), *cert.DaysLeft)
+	}
+}
+
+// names of the vCenter certificates
+const (
+	vcenterMachineSSL = "machine_ssl"
+	vcenterSTSSigning = "sts_signing"
+)
+
+// certificateTimeout bounds the TLS connection made to read the machine SSL certificate
+const certificateTimeout = 10 * time.Second
+
+var vcenterCertificateTitles = map[string]string{
+	vcenterMachineSSL: "Machine SSL certificate",
+	vcenterSTSSigning: "STS signing certificate",
+}
+
+// vcenterCertificates returns the machine SSL certificate of the vCenter endpoint and the
+// STS signing certificate, certificates that can't be retrieved are reported with the error
+func vcenterCertificates(ctx context.Context, client *govmomi.Client, cfg *Config, now time.Time) []VCenterCertificateInfo {
+	retrieve := []struct {
+		name string
+		fn   func() (*x509.Certificate, error)
+	}{
+		{vcenterMachineSSL, func() (*x509.Certificate, error) { return machineSSLCertificate(ctx, client.URL()) }},
+		{vcenterSTSSigning, func() (*x509.Certificate, error) { return stsSigningCertificate(ctx, client, cfg) }},
+	}
+
+	infos := make([]VCenterCertificateInfo, 0, len(retrieve))
+	for _, r := range retrieve {
+		info := VCenterCertificateInfo{Name: r.name}
+		cert, err := r.fn()
+		if err != nil {
+			info.Error = err.Error()
+		} else {
+			info.Certificate = newCertificateInfo(cert.Subject.String(), cert.Issuer.String(), &cert.NotAfter, now)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// machineSSLCertificate returns the certificate vCenter serves on its API endpoint. The
+// certificate is only inspected, so it is not verified.
+func machineSSLCertificate(ctx context.Context, u *url.URL) (*x509.Certificate, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	dialer := tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: certificateTimeout},
+		Config:    &tls.Config{InsecureSkipVerify: true},
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s sent no certificate", host)
+	}
+	return certs[0], nil
+}
+
+// samlCertificate matches the signing certificate embedded in the signature of a SAML token
+var samlCertificate = regexp.MustCompile(`<(?:ds:)?X509Certificate>([^<]+)</(?:ds:)?X509Certificate>`)
+
+// stsSigningCertificate issues a token from the vCenter Security Token Service and returns
+// the certificate the token is signed with
+func stsSigningCertificate(ctx context.Context, client *govmomi.Client, cfg *Config) (*x509.Certificate, error) {
+	signer, err := issueToken(ctx, client, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	match := samlCertificate.FindStringSubmatch(signer.Token)
+	if match == nil {
+		return nil, fmt.Errorf("token has no signing certificate")
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(match[1]), ""))
+	if err != nil {
+		return nil, fmt.Errorf("decoding signing certificate: %s", err)
+	}
+	return x509.ParseCertificate(der)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// usedPctBucket is the width of the used percentage buckets in the content hash, free space
+// changes on almost every scan of a live vCenter
+const usedPctBucket = 5
+
+// hashedDatastore is the part of a datastore the content hash covers: its identity, mounts and
+// status, with the capacity in whole GB and the used percentage in buckets
+type hashedDatastore struct {
+	Name             string    `json:"name"`
+	Type             string    `json:"type"`
+	CapacityGB       int64     `json:"capacity_gb"`
+	UsedBucket       int       `json:"used_bucket"`
+	Status           string    `json:"status"`
+	Shared           bool      `json:"shared"`
+	MountedHostCount int       `json:"mounted_host_count"`
+	Accessible       bool      `json:"accessible"`
+	VVol             *VVolInfo `json:"vvol,omitempty"`
+}
+
+type hashedDatastoreCluster struct {
+	Name       string            `json:"name"`
+	CapacityGB int64             `json:"capacity_gb"`
+	Datastores []hashedDatastore `json:"datastores"`
+}
+
+type hashedCluster struct {
+	Name                 string                   `json:"name"`
+	DatastoreClusters    []hashedDatastoreCluster `json:"datastore_clusters"`
+	StandaloneDatastores []hashedDatastore        `json:"standalone_datastores"`
+	Supervisor           bool                     `json:"supervisor"`
+}
+
+type hashedInventory struct {
+	Datacenter string          `json:"datacenter"`
+	Clusters   []hashedCluster `json:"clusters"`
+	// the objects that couldn't be read, without the messages
+	Errors []string `json:"errors"`
+}
+
+// contentHash returns the SHA-256 of the normalized inventory. It leaves out the scan metadata
+// and the utilization figures, so it only changes with the inventory or a real change in usage.
+func contentHash(infra InfrastructureInfo) string {
+	data, err := json.Marshal(normalizeInventory(infra))
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// normalizeInventory returns the clusters, datastore clusters and datastores sorted by name with
+// their types, mounts and status, the capacity rounded to GB and the used percentage in buckets
+// of usedPctBucket
+func normalizeInventory(infra InfrastructureInfo) hashedInventory {
+	datastores := func(infos []DatastoreInfo) []hashedDatastore {
+		hashed := make([]hashedDatastore, 0, len(infos))
+		for _, info := range infos {
+			hashed = append(hashed, hashedDatastore{
+				Name:             info.Name,
+				Type:             info.Type,
+				CapacityGB:       int64(math.Round(info.Capacity)),
+				UsedBucket:       int(info.UsedPct) / usedPctBucket,
+				Status:           info.Status,
+				Shared:           info.Shared,
+				MountedHostCount: info.MountedHostCount,
+				Accessible:       info.Accessible,
+				VVol:             info.VVol,
+			})
+		}
+		sort.Slice(hashed, func(i, j int) bool { return hashed[i].Name < hashed[j].Name })
+		return hashed
+	}
+
+	inventory := hashedInventory{
+		Datacenter: infra.Datacenter,
+		Clusters:   make([]hashedCluster, 0, len(infra.Clusters)),
+		Errors:     make([]string, 0, len(infra.Errors)),
+	}
+	for _, cluster := range infra.Clusters {
+		hashed := hashedCluster{
+			Name:                 cluster.Name,
+			DatastoreClusters:    make([]hashedDatastoreCluster, 0, len(cluster.DatastoreClusters)),
+			StandaloneDatastores: datastores(cluster.StandaloneDatastores),
+			Supervisor:           cluster.Supervisor != nil,
+		}
+		for _, pod := range cluster.DatastoreClusters {
+			hashed.DatastoreClusters = append(hashed.DatastoreClusters, hashedDatastoreCluster{
+				Name:       pod.Name,
+				CapacityGB: int64(math.Round(pod.TotalCapacity)),
+				Datastores: datastores(pod.Datastores),
+			})
+		}
+		sort.Slice(hashed.DatastoreClusters, func(i, j int) bool {
+			return hashed.DatastoreClusters[i].Name < hashed.DatastoreClusters[j].Name
+		})
+		inventory.Clusters = append(inventory.Clusters, hashed)
+	}
+	sort.Slice(inventory.Clusters, func(i, j int) bool { return inventory.Clusters[i].Name < inventory.Clusters[j].Name })
+	for _, e := range infra.Errors {
+		inventory.Errors = append(inventory.Errors, e.Operation+" "+e.Object)
+	}
+	sort.Strings(inventory.Errors)
+
+	return inventory
+}
+
+// changeDetection tracks -changed-only for the datacenter being scanned. The datastores command
+// sets the content hash and whether it matches the last scan, runDatacenter stores the hash once
+// the report was delivered.
+var changeDetection struct {
+	key       string
+	hash      string
+	unchanged bool
+}
+
+// fingerprintsFile holds the content hash of the last scan per vCenter and datacenter
+func fingerprintsFile() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "godcinfo", "fingerprints.json"), nil
+}
+
+func loadFingerprints() (map[string]string, error) {
+	path, err := fingerprintsFile()
+	if err != nil {
+		return nil, err
+	}
+	fingerprints := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fingerprints, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fingerprints); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	return fingerprints, nil
+}
+
+// checkChanged compares the content hash of a scan with the last one of the same vCenter
+// and datacenter
+func checkChanged(endpoint, datacenter, hash string) error {
+	fingerprints, err := loadFingerprints()
+	if err != nil {
+		return fmt.Errorf("reading the last content hash: %s", err)
+	}
+	changeDetection.key = endpoint + "/" + datacenter
+	changeDetection.hash = hash
+	changeDetection.unchanged = hash != "" && fingerprints[changeDetection.key] == hash
+	return nil
+}
+
+// saveFingerprint stores the content hash of the scan checked with checkChanged
+func saveFingerprint() error {
+	if changeDetection.key == "" || changeDetection.unchanged {
+		return nil
+	}
+	fingerprints, err := loadFingerprints()
+	if err != nil {
+		return err
+	}
+	fingerprints[changeDetection.key] = changeDetection.hash
+
+	path, err := fingerprintsFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(fingerprints, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestContentHash(t *testing.T) {
+	inventory := func(edit func(*InfrastructureInfo)) InfrastructureInfo {
+		infra := InfrastructureInfo{
+			Datacenter: "DC0",
+			Clusters: []ClusterInfo{
+				{
+					Name: "cluster-a",
+					StandaloneDatastores: []DatastoreInfo{
+						{Name: "ds-1", Type: "VMFS", Capacity: 1000, FreeSpace: 400, UsedPct: 60, Status: statusOK, Accessible: true},
+						{Name: "ds-2", Type: "NFS", Capacity: 500, FreeSpace: 100, UsedPct: 80, Status: statusWarning, Shared: true, Accessible: true},
+					},
+				},
+				{Name: "cluster-b"},
+			},
+		}
+		if edit != nil {
+			edit(&infra)
+		}
+		return infra
+	}
+	base := contentHash(inventory(nil))
+
+	tests := []struct {
+		name    string
+		edit    func(*InfrastructureInfo)
+		changed bool
+	}{
+		{"free space within the bucket", func(infra *InfrastructureInfo) {
+			ds := &infra.Clusters[0].StandaloneDatastores[0]
+			ds.FreeSpace, ds.UsedPct = 380, 62
+		}, false},
+		{"capacity below a GB", func(infra *InfrastructureInfo) {
+			infra.Clusters[0].StandaloneDatastores[0].Capacity = 1000.3
+		}, false},
+		{"scan metadata", func(infra *InfrastructureInfo) {
+			infra.Clusters[0].Totals.FreeSpace = 123
+		}, false},
+		{"clusters reordered", func(infra *InfrastructureInfo) {
+			infra.Clusters[0], infra.Clusters[1] = infra.Clusters[1], infra.Clusters[0]
+		}, false},
+		{"datastores reordered", func(infra *InfrastructureInfo) {
+			ds := infra.Clusters[0].StandaloneDatastores
+			ds[0], ds[1] = ds[1], ds[0]
+		}, false},
+		{"used percentage in the next bucket", func(infra *InfrastructureInfo) {
+			infra.Clusters[0].StandaloneDatastores[0].UsedPct = 66
+		}, true},
+		{"datastore added", func(infra *InfrastructureInfo) {
+			infra.Clusters[1].StandaloneDatastores = []DatastoreInfo{{Name: "ds-3", Type: "VMFS", Capacity: 100, Accessible: true}}
+		}, true},
+		{"status changed", func(infra *InfrastructureInfo) {
+			infra.Clusters[0].StandaloneDatastores[1].Status = statusCritical
+		}, true},
+		{"datastore inaccessible", func(infra *InfrastructureInfo) {
+			infra.Clusters[0].StandaloneDatastores[0].Accessible = false
+		}, true},
+		{"scan error", func(infra *InfrastructureInfo) {
+			infra.Errors = []ScanError{{Object: "cluster-b", Operation: "getting datastores of cluster", Message: "timeout"}}
+		}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash := contentHash(inventory(tt.edit))
+			if changed := hash != base; changed != tt.changed {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
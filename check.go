@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+)
+
+// Nagios plugin exit statuses
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+// checkLabel starts the status line of the check command
+const checkLabel = "DATASTORES"
+
+// checkCertificatesLabel starts the status line of the check command with -check-certs
+const checkCertificatesLabel = "CERTIFICATES"
+
+var checkStatusNames = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// statusError ends godcinfo with a specific exit status after the output has been printed
+type statusError struct {
+	status int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("exit status %d", e.status)
+}
+
+// exitStatus returns the exit status requested by a command error, 0 if it doesn't request one
+func exitStatus(err error) int {
+	var se statusError
+	if errors.As(err, &se) {
+		return se.status
+	}
+	return 0
+}
+
+// runCheck implements the Nagios plugin contract: a single status line with perfdata for
+// the used percentage and free space of every datastore, and the exit status of the worst
+// datastore. With -aggregate the datacenter totals are checked instead.
+func runCheck(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	if cfg.CheckCertificates {
+		return checkCertificates(ctx, client, cfg)
+	}
+
+	applyCheckThresholds(cfg)
+
+	topo, err := collectTopology(ctx, client, dc, cfg)
+	if err != nil {
+		fmt.Printf("%s UNKNOWN - %s\n", checkLabel, err)
+		return statusError{checkUnknown}
+	}
+
+	infos := make([]DatastoreInfo, 0, len(topo.Datastores))
+	tags := make(map[string][]string)
+	for ref, ds := range topo.Datastores {
+		infos = append(infos, ds)
+		tags[ds.Name] = topo.Tags[ref]
+	}
+
+	return checkDatastores(dc.Name(), infos, tags, cfg)
+}
+
+// applyCheckThresholds lets -w and -c override the thresholds of the config file
+func applyCheckThresholds(cfg *Config) {
+	if cfg.CheckWarningPct > 0 {
+		cfg.Thresholds.WarningPct = cfg.CheckWarningPct
+	}
+	if cfg.CheckCriticalPct > 0 {
+		cfg.Thresholds.CriticalPct = cfg.CheckCriticalPct
+	}
+}
+
+// checkDatastores prints the status line of the given datastores, tags holds the
+// tags per datastore name for threshold overrides
+func checkDatastores(datacenter string, infos []DatastoreInfo, tags map[string][]string, cfg *Config) error {
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	if len(infos) == 0 {
+		fmt.Printf("%s UNKNOWN - no datastores found\n", checkLabel)
+		return statusError{checkUnknown}
+	}
+
+	var status int
+	var summary string
+	var perfdata []string
+
+	if cfg.CheckAggregate {
+		var capacity, free float64
+		for _, ds := range infos {
+			capacity += ds.Capacity
+			free += ds.FreeSpace
+		}
+		used := usedPct(capacity, free)
+		status = checkStatus(cfg.Thresholds.status("", nil, used))
+		summary = fmt.Sprintf("%s %.1f%% used of %.2f GB on %d datastores", datacenter, used, capacity, len(infos))
+		perfdata = checkPerfdata(datacenter, used, free, capacity, cfg.Thresholds.WarningPct, cfg.Thresholds.CriticalPct)
+	} else {
+		var warning, critical []string
+		for _, ds := range infos {
+			dsStatus := checkStatus(ds.Status)
+			if !ds.Accessible {
+				dsStatus = checkCritical
+			}
+			switch dsStatus {
+			case checkCritical:
+				critical = append(critical, checkDatastoreSummary(ds))
+			case checkWarning:
+				warning = append(warning, checkDatastoreSummary(ds))
+			}
+			if dsStatus > status {
+				status = dsStatus
+			}
+
+			warn, crit := cfg.Thresholds.limits(ds.Name, tags[ds.Name])
+			perfdata = append(perfdata, checkPerfdata(ds.Name, ds.UsedPct, ds.FreeSpace, ds.Capacity, warn, crit)...)
+		}
+
+		summary = fmt.Sprintf("%d datastores, %d warning, %d critical", len(infos), len(warning), len(critical))
+		if problems := append(critical, warning...); len(problems) > 0 {
+			summary += ": " + strings.Join(problems, ", ")
+		}
+	}
+
+	sendAlerts(cfg, datastoreAlerts(datacenter, infos))
+
+	fmt.Printf("%s %s - %s | %s\n", checkLabel, checkStatusNames[status], summary, strings.Join(perfdata, " "))
+
+	if status != checkOK {
+		return statusError{status}
+	}
+	return nil
+}
+
+// checkStatus maps a threshold status to a plugin exit status
+func checkStatus(status string) int {
+	switch status {
+	case statusCritical:
+		return checkCritical
+	case statusWarning:
+		return checkWarning
+	}
+	return checkOK
+}
+
+func checkDatastoreSummary(ds DatastoreInfo) string {
+	if !ds.Accessible {
+		return ds.Name + " inaccessible"
+	}
+	return fmt.Sprintf("%s %.1f%%", ds.Name, ds.UsedPct)
+}
+
+// checkPerfdata returns the used percentage and free space perfdata of a datastore or datacenter
+func checkPerfdata(name string, used, free, capacity, warning, critical float64) []string {
+	label := strings.NewReplacer("'", "", "=", "_").Replace(name)
+	return []string{
+		fmt.Sprintf("'%s used'=%.2f%%;%g;%g;0;100", label, used, warning, critical),
+		fmt.Sprintf("'%s free'=%.2fGB;;;0;%.2f", label, free, capacity),
+	}
+}
+
+// checkCertificates prints the status line of the vCenter machine SSL and STS signing
+// certificates: CRITICAL when one has expired, WARNING when one expires within
+// -cert-warning-days and UNKNOWN when the machine SSL certificate can't be read. An STS
+// signing certificate that can't be retrieved is only mentioned.
+func checkCertificates(ctx context.Context, client *govmomi.Client, cfg *Config) error {
+	now := time.Now()
+
+	var status int
+	var problems, perfdata []string
+	for _, info := range vcenterCertificates(ctx, client, cfg, now) {
+		if info.Error != "" {
+			if info.Name == vcenterMachineSSL {
+				fmt.Printf("%s UNKNOWN - reading the machine SSL certificate: %s\n", checkCertificatesLabel, info.Error)
+				return statusError{checkUnknown}
+			}
+			problems = append(problems, info.Name+" not retrievable")
+			continue
+		}
+
+		cert := info.Certificate
+		if warning := certificateWarning(cert, cfg.CertWarningDays); warning != "" {
+			certStatus := checkWarning
+			if cert.NotAfter == nil || cert.NotAfter.Before(now) {
+				certStatus = checkCritical
+			}
+			if certStatus > status {
+				status = certStatus
+			}
+			problems = append(problems, info.Name+" "+warning)
+		}
+		if cert.DaysLeft != nil {
+			// the thresholds are ranges, so the days left alert below them and not above
+			perfdata = append(perfdata, fmt.Sprintf("'%s days_left'=%d;%d:;0:", info.Name, *cert.DaysLeft, cfg.CertWarningDays))
+		}
+	}
+
+	summary := fmt.Sprintf("vCenter certificates valid for at least %d days", cfg.CertWarningDays)
+	if len(problems) > 0 {
+		summary = strings.Join(problems, ", ")
+	}
+
+	fmt.Printf("%s %s - %s | %s\n", checkCertificatesLabel, checkStatusNames[status], summary, strings.Join(perfdata, " "))
+
+	if status != checkOK {
+		return statusError{status}
+	}
+	return nil
+}
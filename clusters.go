@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// admission control policies as reported in the clusters report
+const (
+	admissionFailoverLevel  = "host-failures"
+	admissionResourcePct    = "resource-percentage"
+	admissionDedicatedHosts = "dedicated-failover-hosts"
+)
+
+type AdmissionControlInfo struct {
+	HAEnabled bool   `json:"ha_enabled"`
+	Enabled   bool   `json:"enabled"`
+	Policy    string `json:"policy,omitempty"`
+	// configured failover capacity
+	FailoverLevel     int32    `json:"failover_level,omitempty"`
+	CPUFailoverPct    *int32   `json:"cpu_failover_pct,omitempty"`
+	MemoryFailoverPct *int32   `json:"memory_failover_pct,omitempty"`
+	FailoverHosts     []string `json:"failover_hosts,omitempty"`
+	// current failover capacity and the headroom above the configured one
+	CurrentFailoverLevel     *int32 `json:"current_failover_level,omitempty"`
+	CurrentCPUFailoverPct    *int32 `json:"current_cpu_failover_pct,omitempty"`
+	CurrentMemoryFailoverPct *int32 `json:"current_memory_failover_pct,omitempty"`
+	CPUHeadroomPct           *int32 `json:"cpu_headroom_pct,omitempty"`
+	MemoryHeadroomPct        *int32 `json:"memory_headroom_pct,omitempty"`
+}
+
+type HealthProviderInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+type ProactiveHAInfo struct {
+	Enabled bool `json:"enabled"`
+	// AutomationLevel is Manual or Automated
+	AutomationLevel     string               `json:"automation_level,omitempty"`
+	ModerateRemediation string               `json:"moderate_remediation,omitempty"`
+	SevereRemediation   string               `json:"severe_remediation,omitempty"`
+	Providers           []HealthProviderInfo `json:"providers"`
+}
+
+type DPMHostOverrideInfo struct {
+	Host            string `json:"host"`
+	Enabled         bool   `json:"enabled"`
+	AutomationLevel string `json:"automation_level,omitempty"`
+}
+
+type DPMInfo struct {
+	Enabled bool `json:"enabled"`
+	// AutomationLevel is manual or automated
+	AutomationLevel string `json:"automation_level,omitempty"`
+	// PowerActionRate is the DPM threshold from 1 (conservative) to 5 (aggressive)
+	PowerActionRate int32                 `json:"power_action_rate,omitempty"`
+	HostOverrides   []DPMHostOverrideInfo `json:"host_overrides"`
+}
+
+type ClusterComputeInfo struct {
+	Name             string               `json:"name"`
+	AdmissionControl AdmissionControlInfo `json:"admission_control"`
+	ProactiveHA      ProactiveHAInfo      `json:"proactive_ha"`
+	DPM              DPMInfo              `json:"dpm"`
+	Warnings         []string             `json:"warnings"`
+}
+
+type ClustersReport struct {
+	Datacenter string               `json:"datacenter"`
+	Clusters   []ClusterComputeInfo `json:"clusters"`
+}
+
+// reportClusters shows the HA admission control policy of every cluster with the configured
+// and current failover capacity, so capacity planning covers compute as well as storage, the
+// Proactive HA settings with the health providers of the cluster and the DPM configuration
+func reportClusters(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := ClustersReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterComputeInfo, 0, len(clusters)),
+	}
+
+	// names of the health update providers, looked up once
+	providerNames := make(map[string]string)
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"name", "configurationEx", "summary"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting details of cluster %s: %s", cluster.Name(), err)
+		}
+
+		info := ClusterComputeInfo{
+			Name:        clusterMo.Name,
+			ProactiveHA: ProactiveHAInfo{Providers: make([]HealthProviderInfo, 0)},
+			DPM:         DPMInfo{HostOverrides: make([]DPMHostOverrideInfo, 0)},
+			Warnings:    make([]string, 0),
+		}
+
+		if ex, ok := clusterMo.ConfigurationEx.(*types.ClusterConfigInfoEx); ok {
+			info.AdmissionControl, err = admissionControl(ctx, pc, ex.DasConfig, clusterMo.Summary)
+			if err != nil {
+				return fmt.Errorf("getting failover hosts of cluster %s: %s", clusterMo.Name, err)
+			}
+
+			if ha := ex.InfraUpdateHaConfig; ha != nil {
+				info.ProactiveHA.Enabled = ha.Enabled != nil && *ha.Enabled
+				info.ProactiveHA.AutomationLevel = ha.Behavior
+				info.ProactiveHA.ModerateRemediation = ha.ModerateRemediation
+				info.ProactiveHA.SevereRemediation = ha.SevereRemediation
+				for _, id := range ha.Providers {
+					name := healthProviderName(ctx, client, providerNames, id)
+					info.ProactiveHA.Providers = append(info.ProactiveHA.Providers, HealthProviderInfo{ID: id, Name: name})
+				}
+			}
+
+			info.DPM, err = dpmConfig(ctx, pc, ex)
+			if err != nil {
+				return fmt.Errorf("getting DPM host overrides of cluster %s: %s", clusterMo.Name, err)
+			}
+		}
+
+		ac := info.AdmissionControl
+		switch {
+		case ac.HAEnabled && !ac.Enabled:
+			info.Warnings = append(info.Warnings, "HA admission control is disabled, failover capacity is not reserved")
+		case ac.CurrentFailoverLevel != nil && *ac.CurrentFailoverLevel < ac.FailoverLevel:
+			info.Warnings = append(info.Warnings, fmt.Sprintf("cluster tolerates %d host failures, %d configured", *ac.CurrentFailoverLevel, ac.FailoverLevel))
+		}
+		if ac.CPUHeadroomPct != nil && *ac.CPUHeadroomPct < 0 {
+			info.Warnings = append(info.Warnings, "CPU failover capacity is below the configured percentage")
+		}
+		if ac.MemoryHeadroomPct != nil && *ac.MemoryHeadroomPct < 0 {
+			info.Warnings = append(info.Warnings, "memory failover capacity is below the configured percentage")
+		}
+		if info.ProactiveHA.Enabled && len(info.ProactiveHA.Providers) == 0 {
+			info.Warnings = append(info.Warnings, "Proactive HA is enabled without a health provider")
+		}
+
+		report.Clusters = append(report.Clusters, info)
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, info := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", info.Name)
+		fmt.Println(strings.Repeat("-", len(info.Name)+9))
+
+		ac := info.AdmissionControl
+		switch {
+		case !ac.HAEnabled:
+			fmt.Println("  HA admission control: HA disabled")
+		case !ac.Enabled:
+			fmt.Println("  HA admission control: disabled")
+		default:
+			fmt.Printf("  HA admission control: %s\n", ac.Policy)
+		}
+		if ac.FailoverLevel > 0 && ac.CurrentFailoverLevel != nil {
+			fmt.Printf("    Host failures tolerated: %d (current: %d)\n", ac.FailoverLevel, *ac.CurrentFailoverLevel)
+		} else if ac.FailoverLevel > 0 {
+			fmt.Printf("    Host failures tolerated: %d\n", ac.FailoverLevel)
+		}
+		printFailoverPct("CPU", ac.CPUFailoverPct, ac.CurrentCPUFailoverPct, ac.CPUHeadroomPct)
+		printFailoverPct("Memory", ac.MemoryFailoverPct, ac.CurrentMemoryFailoverPct, ac.MemoryHeadroomPct)
+		if len(ac.FailoverHosts) > 0 {
+			fmt.Printf("    Failover hosts: %s\n", strings.Join(ac.FailoverHosts, ", "))
+		}
+
+		pha := info.ProactiveHA
+		if pha.Enabled {
+			fmt.Printf("  Proactive HA: enabled (%s, moderate: %s, severe: %s)\n", pha.AutomationLevel, pha.ModerateRemediation, pha.SevereRemediation)
+		} else {
+			fmt.Println("  Proactive HA: disabled")
+		}
+		for _, provider := range pha.Providers {
+			name := provider.Name
+			if name == "" {
+				name = "unknown"
+			}
+			fmt.Printf("    - Health provider: %s (%s)\n", name, provider.ID)
+		}
+
+		dpm := info.DPM
+		if dpm.Enabled {
+			fmt.Printf("  DPM: enabled (%s, threshold %d)\n", dpm.AutomationLevel, dpm.PowerActionRate)
+		} else {
+			fmt.Println("  DPM: disabled")
+		}
+		for _, override := range dpm.HostOverrides {
+			level := override.AutomationLevel
+			if !override.Enabled {
+				level = "disabled"
+			}
+			fmt.Printf("    - Host override: %s (%s)\n", override.Host, level)
+		}
+
+		for _, w := range info.Warnings {
+			fmt.Printf("  WARNING: %s\n", w)
+		}
+	}
+
+	return nil
+}
+
+// admissionControl reads the HA admission control policy and the current failover capacity of a cluster
+func admissionControl(ctx context.Context, pc *property.Collector, das types.ClusterDasConfigInfo, summary types.BaseComputeResourceSummary) (AdmissionControlInfo, error) {
+	info := AdmissionControlInfo{
+		HAEnabled: das.Enabled != nil && *das.Enabled,
+		Enabled:   das.AdmissionControlEnabled != nil && *das.AdmissionControlEnabled,
+	}
+	if !info.HAEnabled || !info.Enabled {
+		return info, nil
+	}
+
+	switch policy := das.AdmissionControlPolicy.(type) {
+	case *types.ClusterFailoverLevelAdmissionControlPolicy:
+		info.Policy = admissionFailoverLevel
+		info.FailoverLevel = policy.FailoverLevel
+	case *types.ClusterFailoverResourcesAdmissionControlPolicy:
+		info.Policy = admissionResourcePct
+		info.FailoverLevel = policy.FailoverLevel
+		info.CPUFailoverPct = &policy.CpuFailoverResourcesPercent
+		info.MemoryFailoverPct = &policy.MemoryFailoverResourcesPercent
+	case *types.ClusterFailoverHostAdmissionControlPolicy:
+		info.Policy = admissionDedicatedHosts
+		info.FailoverLevel = policy.FailoverLevel
+		if len(policy.FailoverHosts) > 0 {
+			var hosts []mo.HostSystem
+			if err := pc.Retrieve(ctx, policy.FailoverHosts, []string{"name"}, &hosts); err != nil {
+				return info, err
+			}
+			for _, host := range hosts {
+				info.FailoverHosts = append(info.FailoverHosts, host.Name)
+			}
+		}
+	}
+
+	clusterSummary, ok := summary.(*types.ClusterComputeResourceSummary)
+	if !ok {
+		return info, nil
+	}
+
+	switch current := clusterSummary.AdmissionControlInfo.(type) {
+	case *types.ClusterFailoverLevelAdmissionControlInfo:
+		info.CurrentFailoverLevel = &current.CurrentFailoverLevel
+	case *types.ClusterFailoverResourcesAdmissionControlInfo:
+		info.CurrentCPUFailoverPct = &current.CurrentCpuFailoverResourcesPercent
+		info.CurrentMemoryFailoverPct = &current.CurrentMemoryFailoverResourcesPercent
+		if info.CPUFailoverPct != nil {
+			headroom := current.CurrentCpuFailoverResourcesPercent - *info.CPUFailoverPct
+			info.CPUHeadroomPct = &headroom
+		}
+		if info.MemoryFailoverPct != nil {
+			headroom := current.CurrentMemoryFailoverResourcesPercent - *info.MemoryFailoverPct
+			info.MemoryHeadroomPct = &headroom
+		}
+	default:
+		if info.Policy != admissionResourcePct {
+			info.CurrentFailoverLevel = &clusterSummary.CurrentFailoverLevel
+		}
+	}
+
+	return info, nil
+}
+
+// dpmConfig reads the Distributed Power Management settings of a cluster and its host overrides
+func dpmConfig(ctx context.Context, pc *property.Collector, ex *types.ClusterConfigInfoEx) (DPMInfo, error) {
+	info := DPMInfo{HostOverrides: make([]DPMHostOverrideInfo, 0, len(ex.DpmHostConfig))}
+	if dpm := ex.DpmConfigInfo; dpm != nil {
+		info.Enabled = dpm.Enabled != nil && *dpm.Enabled
+		info.AutomationLevel = string(dpm.DefaultDpmBehavior)
+		info.PowerActionRate = dpm.HostPowerActionRate
+	}
+	if len(ex.DpmHostConfig) == 0 {
+		return info, nil
+	}
+
+	refs := make([]types.ManagedObjectReference, 0, len(ex.DpmHostConfig))
+	for _, host := range ex.DpmHostConfig {
+		refs = append(refs, host.Key)
+	}
+	var hosts []mo.HostSystem
+	if err := pc.Retrieve(ctx, refs, []string{"name"}, &hosts); err != nil {
+		return info, err
+	}
+	names := make(map[string]string, len(hosts))
+	for _, host := range hosts {
+		names[host.Self.Value] = host.Name
+	}
+
+	for _, host := range ex.DpmHostConfig {
+		name, ok := names[host.Key.Value]
+		if !ok {
+			name = host.Key.Value
+		}
+		info.HostOverrides = append(info.HostOverrides, DPMHostOverrideInfo{
+			Host:            name,
+			Enabled:         host.Enabled != nil && *host.Enabled,
+			AutomationLevel: string(host.Behavior),
+		})
+	}
+	return info, nil
+}
+
+// healthProviderName returns the name of a health update provider, empty when vCenter can't
+// resolve it, e.g. because the provider was unregistered. Names are cached in names.
+func healthProviderName(ctx context.Context, client *govmomi.Client, names map[string]string, id string) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+
+	var name string
+	if m := client.ServiceContent.HealthUpdateManager; m != nil {
+		res, err := methods.QueryProviderName(ctx, client.Client, &types.QueryProviderName{This: *m, Id: id})
+		if err == nil {
+			name = res.Returnval
+		}
+	}
+	names[id] = name
+	return name
+}
+
+// printFailoverPct prints the configured and current failover capacity of a resource
+func printFailoverPct(resource string, configured, current, headroom *int32) {
+	if configured == nil {
+		return
+	}
+	if current == nil || headroom == nil {
+		fmt.Printf("    %s failover capacity: %d%% reserved\n", resource, *configured)
+		return
+	}
+	fmt.Printf("    %s failover capacity: %d%% reserved, %d%% available (headroom %+d%%)\n", resource, *configured, *current, *headroom)
+}
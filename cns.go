@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/cns"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// cnsPageSize is the number of volumes fetched per CNS query
+const cnsPageSize = 100
+
+type CNSVolumeInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Type is BLOCK for first class disks or FILE for vSAN file shares
+	Type              string  `json:"type"`
+	Size              float64 `json:"size_gb"`
+	KubernetesCluster string  `json:"kubernetes_cluster,omitempty"`
+	Namespace         string  `json:"namespace,omitempty"`
+	PVC               string  `json:"pvc,omitempty"`
+	PV                string  `json:"pv,omitempty"`
+	Health            string  `json:"health,omitempty"`
+}
+
+type DatastoreCNSVolumesInfo struct {
+	Name      string          `json:"name"`
+	TotalSize float64         `json:"total_size_gb"`
+	Volumes   []CNSVolumeInfo `json:"volumes"`
+}
+
+type CNSReport struct {
+	Datacenter string                    `json:"datacenter"`
+	TotalSize  float64                   `json:"total_size_gb"`
+	Datastores []DatastoreCNSVolumesInfo `json:"datastores"`
+}
+
+// reportCNSVolumes lists the container volumes CNS manages for Kubernetes clusters using the
+// vSphere CSI driver, per datastore with their size and PVC, these volumes don't show up
+// among the VM disks
+func reportCNSVolumes(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	var datastores []mo.Datastore
+	err := retrieveAll(ctx, client, dc, "Datastore", []string{"name", "summary.url"}, &datastores)
+	if err != nil {
+		return fmt.Errorf("retrieving datastores: %s", err)
+	}
+	datastores = withoutExcludedDatastores(cfg, datastores)
+
+	report := CNSReport{
+		Datacenter: dc.Name(),
+		Datastores: make([]DatastoreCNSVolumesInfo, 0),
+	}
+	if len(datastores) == 0 {
+		return printCNSReport(report, cfg)
+	}
+
+	c, err := connectToCNS(ctx, client, cfg)
+	if err != nil {
+		return fmt.Errorf("connecting to CNS: %s", err)
+	}
+
+	names := make(map[string]string, len(datastores))
+	refs := make([]types.ManagedObjectReference, 0, len(datastores))
+	for _, ds := range datastores {
+		names[ds.Summary.Url] = ds.Name
+		refs = append(refs, ds.Self)
+	}
+
+	volumes := make(map[string][]CNSVolumeInfo)
+	filter := cnstypes.CnsQueryFilter{
+		Datastores: refs,
+		Cursor:     &cnstypes.CnsCursor{Limit: cnsPageSize},
+	}
+	for {
+		res, err := c.QueryVolume(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("querying CNS volumes: %s", err)
+		}
+		for _, volume := range res.Volumes {
+			name := names[volume.DatastoreUrl]
+			if name == "" {
+				name = volume.DatastoreUrl
+			}
+			volumes[name] = append(volumes[name], newCNSVolumeInfo(volume))
+		}
+		if len(res.Volumes) == 0 || res.Cursor.Offset <= filter.Cursor.Offset || res.Cursor.Offset >= res.Cursor.TotalRecords {
+			break
+		}
+		filter.Cursor = &cnstypes.CnsCursor{Offset: res.Cursor.Offset, Limit: cnsPageSize}
+	}
+
+	for name, infos := range volumes {
+		sort.Slice(infos, func(i, j int) bool {
+			return infos[i].Name < infos[j].Name
+		})
+		dsInfo := DatastoreCNSVolumesInfo{Name: name, Volumes: infos}
+		for _, info := range infos {
+			dsInfo.TotalSize += info.Size
+		}
+		report.TotalSize += dsInfo.TotalSize
+		report.Datastores = append(report.Datastores, dsInfo)
+	}
+	sort.Slice(report.Datastores, func(i, j int) bool {
+		return report.Datastores[i].Name < report.Datastores[j].Name
+	})
+
+	return printCNSReport(report, cfg)
+}
+
+func printCNSReport(report CNSReport, cfg *Config) error {
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, ds := range report.Datastores {
+		fmt.Printf("\nDatastore: %s (%d volumes, %s)\n", ds.Name, len(ds.Volumes), formatGB(ds.TotalSize))
+		fmt.Println(strings.Repeat("-", len(ds.Name)+11))
+		for _, v := range ds.Volumes {
+			fmt.Printf("  %s (%s, %s)\n", v.Name, v.Type, formatGB(v.Size))
+			if v.PVC != "" {
+				fmt.Printf("    PVC: %s/%s\n", valueOrNone(v.Namespace), v.PVC)
+			}
+			if v.KubernetesCluster != "" {
+				fmt.Printf("    Kubernetes cluster: %s\n", v.KubernetesCluster)
+			}
+			if v.Health != "" && v.Health != "green" {
+				fmt.Printf("    WARNING: volume health is %s\n", v.Health)
+			}
+		}
+	}
+
+	fmt.Printf("\nCNS volumes total: %s\n", formatGB(report.TotalSize))
+
+	return nil
+}
+
+// newCNSVolumeInfo takes the size and the Kubernetes cluster, PV and PVC of a CNS volume
+func newCNSVolumeInfo(volume cnstypes.CnsVolume) CNSVolumeInfo {
+	info := CNSVolumeInfo{
+		ID:                volume.VolumeId.Id,
+		Name:              volume.Name,
+		Type:              volume.VolumeType,
+		KubernetesCluster: volume.Metadata.ContainerCluster.ClusterId,
+		Health:            volume.HealthStatus,
+	}
+	if details := volume.BackingObjectDetails; details != nil {
+		info.Size = float64(details.GetCnsBackingObjectDetails().CapacityInMb) / 1024
+	}
+
+	for _, entity := range volume.Metadata.EntityMetadata {
+		k8s, ok := entity.(*cnstypes.CnsKubernetesEntityMetadata)
+		if !ok {
+			continue
+		}
+		switch k8s.EntityType {
+		case string(cnstypes.CnsKubernetesEntityTypePVC):
+			info.PVC = k8s.EntityName
+			info.Namespace = k8s.Namespace
+		case string(cnstypes.CnsKubernetesEntityTypePV):
+			info.PV = k8s.EntityName
+		}
+	}
+
+	return info
+}
+
+// connectToCNS returns a client of the Cloud Native Storage API of vCenter
+func connectToCNS(ctx context.Context, client *govmomi.Client, cfg *Config) (*cns.Client, error) {
+	c, err := cns.NewClient(ctx, client.Client)
+	if err != nil {
+		return nil, err
+	}
+	c.Client.Transport = vcenterTransport(cfg, c.Client.Transport)
+	return c, nil
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/pbm"
+	"github.com/vmware/govmomi/pbm/methods"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// complianceBatchSize limits the number of entities sent per compliance check
+const complianceBatchSize = 100
+
+type PolicyViolation struct {
+	Capability string `json:"capability"`
+	Required   string `json:"required"`
+	Current    string `json:"current"`
+}
+
+type ComplianceInfo struct {
+	VM             string            `json:"vm"`
+	Object         string            `json:"object"`
+	Type           string            `json:"type"`
+	Status         string            `json:"status"`
+	CurrentPolicy  string            `json:"current_policy"`
+	RequiredPolicy string            `json:"required_policy"`
+	Violations     []PolicyViolation `json:"violations"`
+}
+
+type ComplianceReport struct {
+	Datacenter   string           `json:"datacenter"`
+	Checked      int              `json:"checked"`
+	NonCompliant []ComplianceInfo `json:"non_compliant"`
+}
+
+// complianceEntity ties a PBM object reference back to the VM and disk it was built from
+type complianceEntity struct {
+	vm     string
+	object string
+	kind   string
+}
+
+// reportCompliance runs SPBM compliance checks for all VMs and their disks and reports the
+// objects that do not comply with their assigned policy, with the policy they are associated
+// with now and the one the check requires
+func reportCompliance(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	pbmClient, err := connectToPBM(ctx, client, cfg)
+	if err != nil {
+		return fmt.Errorf("connecting to the storage policy service: %s", err)
+	}
+
+	var vms []mo.VirtualMachine
+	err = retrieveAll(ctx, client, dc, "VirtualMachine", []string{"name", "config.template", "config.hardware.device"}, &vms)
+	if err != nil {
+		return fmt.Errorf("retrieving VMs: %s", err)
+	}
+
+	// Build the list of PBM entities: every VM home and every virtual disk
+	var refs []pbmtypes.PbmServerObjectRef
+	entities := make(map[string]complianceEntity)
+	for _, vm := range vms {
+		if vm.Config == nil || vm.Config.Template {
+			continue
+		}
+
+		refs = append(refs, pbmtypes.PbmServerObjectRef{
+			ObjectType: string(pbmtypes.PbmObjectTypeVirtualMachine),
+			Key:        vm.Self.Value,
+		})
+		entities[vm.Self.Value] = complianceEntity{vm: vm.Name, object: vm.Name, kind: "vm"}
+
+		for _, device := range vm.Config.Hardware.Device {
+			disk, ok := device.(*types.VirtualDisk)
+			if !ok {
+				continue
+			}
+			key := fmt.Sprintf("%s:%d", vm.Self.Value, disk.Key)
+			label := fmt.Sprintf("disk-%d", disk.Key)
+			if info := disk.GetVirtualDevice().DeviceInfo; info != nil {
+				label = info.GetDescription().Label
+			}
+			refs = append(refs, pbmtypes.PbmServerObjectRef{
+				ObjectType: string(pbmtypes.PbmObjectTypeVirtualDiskId),
+				Key:        key,
+			})
+			entities[key] = complianceEntity{vm: vm.Name, object: label, kind: "disk"}
+		}
+	}
+
+	var results []pbmtypes.PbmComplianceResult
+	var nonCompliant []pbmtypes.PbmServerObjectRef
+	for start := 0; start < len(refs); start += complianceBatchSize {
+		end := start + complianceBatchSize
+		if end > len(refs) {
+			end = len(refs)
+		}
+
+		req := pbmtypes.PbmCheckCompliance{
+			This:     pbmClient.ServiceContent.ComplianceManager,
+			Entities: refs[start:end],
+		}
+		res, err := methods.PbmCheckCompliance(ctx, pbmClient, &req)
+		if err != nil {
+			return fmt.Errorf("checking compliance: %s", err)
+		}
+		results = append(results, res.Returnval...)
+		for _, result := range res.Returnval {
+			if result.ComplianceStatus == string(pbmtypes.PbmComplianceStatusNonCompliant) {
+				nonCompliant = append(nonCompliant, result.Entity)
+			}
+		}
+	}
+
+	policyNames, err := storagePolicyNames(ctx, pbmClient)
+	if err != nil {
+		return fmt.Errorf("retrieving storage policies: %s", err)
+	}
+	policyName := func(id string) string {
+		if name := policyNames[id]; name != "" {
+			return name
+		}
+		return id
+	}
+
+	// the policies the non-compliant objects are associated with now
+	currentPolicies := make(map[string]string)
+	for start := 0; start < len(nonCompliant); start += complianceBatchSize {
+		end := start + complianceBatchSize
+		if end > len(nonCompliant) {
+			end = len(nonCompliant)
+		}
+
+		req := pbmtypes.PbmQueryAssociatedProfiles{
+			This:     pbmClient.ServiceContent.ProfileManager,
+			Entities: nonCompliant[start:end],
+		}
+		res, err := methods.PbmQueryAssociatedProfiles(ctx, pbmClient, &req)
+		if err != nil {
+			return fmt.Errorf("querying the associated storage policies: %s", err)
+		}
+		for _, result := range res.Returnval {
+			names := make([]string, 0, len(result.ProfileId))
+			for _, id := range result.ProfileId {
+				names = append(names, policyName(id.UniqueId))
+			}
+			currentPolicies[result.Object.Key] = strings.Join(names, ", ")
+		}
+	}
+
+	report := ComplianceReport{
+		Datacenter:   dc.Name(),
+		Checked:      len(refs),
+		NonCompliant: make([]ComplianceInfo, 0),
+	}
+
+	for _, result := range results {
+		if result.ComplianceStatus != string(pbmtypes.PbmComplianceStatusNonCompliant) {
+			continue
+		}
+
+		entity := entities[result.Entity.Key]
+		info := ComplianceInfo{
+			VM:            entity.vm,
+			Object:        entity.object,
+			Type:          entity.kind,
+			Status:        result.ComplianceStatus,
+			CurrentPolicy: currentPolicies[result.Entity.Key],
+			Violations:    make([]PolicyViolation, 0),
+		}
+		if result.Profile != nil {
+			info.RequiredPolicy = policyName(result.Profile.UniqueId)
+		}
+
+		for _, violated := range result.ViolatedPolicies {
+			required := capabilityValues(&violated.ExpectedValue)
+			current := capabilityValues(violated.CurrentValue)
+			for id, value := range required {
+				info.Violations = append(info.Violations, PolicyViolation{
+					Capability: id,
+					Required:   value,
+					Current:    current[id],
+				})
+			}
+		}
+
+		sort.Slice(info.Violations, func(i, j int) bool {
+			return info.Violations[i].Capability < info.Violations[j].Capability
+		})
+
+		report.NonCompliant = append(report.NonCompliant, info)
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nChecked %d objects, %d non-compliant\n", report.Checked, len(report.NonCompliant))
+	for _, info := range report.NonCompliant {
+		if info.Type == "disk" {
+			fmt.Printf("  - %s / %s\n", info.VM, info.Object)
+		} else {
+			fmt.Printf("  - %s\n", info.VM)
+		}
+		fmt.Printf("      Current policy: %s\n", valueOrNone(info.CurrentPolicy))
+		fmt.Printf("      Required policy: %s\n", info.RequiredPolicy)
+		for _, violation := range info.Violations {
+			current := violation.Current
+			if current == "" {
+				current = "(not set)"
+			}
+			fmt.Printf("      %s: required %s, current %s\n", violation.Capability, violation.Required, current)
+		}
+	}
+
+	return nil
+}
+
+// storagePolicyNames maps storage policy IDs to their names
+func storagePolicyNames(ctx context.Context, c *pbm.Client) (map[string]string, error) {
+	rtype := pbmtypes.PbmProfileResourceType{
+		ResourceType: string(pbmtypes.PbmProfileResourceTypeEnumSTORAGE),
+	}
+	ids, err := c.QueryProfile(ctx, rtype, string(pbmtypes.PbmProfileCategoryEnumREQUIREMENT))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	profiles, err := c.RetrieveContent(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range profiles {
+		profile := p.GetPbmProfile()
+		names[profile.ProfileId.UniqueId] = profile.Name
+	}
+
+	return names, nil
+}
+
+// capabilityValues flattens a capability instance into "namespace.property" -> value pairs
+func capabilityValues(c *pbmtypes.PbmCapabilityInstance) map[string]string {
+	values := make(map[string]string)
+	if c == nil {
+		return values
+	}
+
+	for _, constraint := range c.Constraint {
+		for _, prop := range constraint.PropertyInstance {
+			id := prop.Id
+			if c.Id.Namespace != "" {
+				id = strings.Join([]string{c.Id.Namespace, prop.Id}, ".")
+			}
+			values[id] = fmt.Sprintf("%v", prop.Value)
+		}
+	}
+
+	return values
+}
+
+// connectToPBM connects to the storage policy service, like pbm.NewClient but
+// with the requests going through the -record or -replay transport
+func connectToPBM(ctx context.Context, client *govmomi.Client, cfg *Config) (*pbm.Client, error) {
+	sc := client.Client.NewServiceClient(pbm.Path, pbm.Namespace)
+	sc.Transport = vcenterTransport(cfg, sc.Transport)
+
+	req := pbmtypes.PbmRetrieveServiceContent{
+		This: pbm.ServiceInstance,
+	}
+	res, err := methods.PbmRetrieveServiceContent(ctx, sc, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pbm.Client{Client: sc, ServiceContent: res.Returnval, RoundTripper: sc}, nil
+}
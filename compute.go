@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ComputeCapacityInfo is the CPU and memory capacity of a cluster next to what its powered on
+// VMs are allocated and have reserved
+type ComputeCapacityInfo struct {
+	HostCount int `json:"host_count"`
+	CPUCores  int `json:"cpu_cores"`
+	// CPUCapacity is the total CPU of the hosts in MHz
+	CPUCapacity    int64   `json:"cpu_capacity_mhz"`
+	MemoryCapacity float64 `json:"memory_capacity_gb"`
+	PoweredOnVMs   int     `json:"powered_on_vm_count"`
+	VCPUs          int     `json:"vcpus"`
+	VRAM           float64 `json:"vram_gb"`
+	// CPUReservation is the CPU reserved by the VMs in MHz
+	CPUReservation    int64   `json:"cpu_reservation_mhz"`
+	MemoryReservation float64 `json:"memory_reservation_gb"`
+	// CPUOvercommit is the number of vCPUs per physical core, MemoryOvercommit the vRAM
+	// per GB of host memory
+	CPUOvercommit    float64 `json:"cpu_overcommit_ratio"`
+	MemoryOvercommit float64 `json:"memory_overcommit_ratio"`
+}
+
+// clusterCompute sums up the CPU and memory of the hosts of a cluster and the vCPUs, vRAM and
+// reservations of its powered on VMs, powered off VMs don't take resources from the hosts
+func clusterCompute(ctx context.Context, client *govmomi.Client, cluster *object.ClusterComputeResource) (*ComputeCapacityInfo, error) {
+	var clusterMo mo.ClusterComputeResource
+	err := property.DefaultCollector(client.Client).RetrieveOne(ctx, cluster.Reference(), []string{"summary"}, &clusterMo)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ComputeCapacityInfo{}
+	if clusterMo.Summary != nil {
+		summary := clusterMo.Summary.GetComputeResourceSummary()
+		info.HostCount = int(summary.NumHosts)
+		info.CPUCores = int(summary.NumCpuCores)
+		info.CPUCapacity = int64(summary.TotalCpu)
+		info.MemoryCapacity = bytesToGB(summary.TotalMemory)
+	}
+
+	var vms []mo.VirtualMachine
+	err = retrieveClusterVMs(ctx, client, cluster, []string{"config.template", "runtime.powerState", "config.hardware", "config.cpuAllocation", "config.memoryAllocation"}, &vms)
+	if err != nil {
+		return nil, err
+	}
+
+	var vramMB, reservedMB int64
+	for _, vm := range vms {
+		if vm.Config == nil || vm.Config.Template || vm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+			continue
+		}
+		info.PoweredOnVMs++
+		info.VCPUs += int(vm.Config.Hardware.NumCPU)
+		vramMB += int64(vm.Config.Hardware.MemoryMB)
+		if r := vm.Config.CpuAllocation; r != nil && r.Reservation != nil {
+			info.CPUReservation += *r.Reservation
+		}
+		if r := vm.Config.MemoryAllocation; r != nil && r.Reservation != nil {
+			reservedMB += *r.Reservation
+		}
+	}
+	info.VRAM = bytesToGB(vramMB * 1024 * 1024)
+	info.MemoryReservation = bytesToGB(reservedMB * 1024 * 1024)
+	if info.CPUCores > 0 {
+		info.CPUOvercommit = float64(info.VCPUs) / float64(info.CPUCores)
+	}
+	if info.MemoryCapacity > 0 {
+		info.MemoryOvercommit = info.VRAM / info.MemoryCapacity
+	}
+
+	return info, nil
+}
+
+func printComputeCapacity(info *ComputeCapacityInfo) {
+	fmt.Printf("  Compute: %d hosts, %d cores (%d MHz), %s memory\n", info.HostCount, info.CPUCores, info.CPUCapacity, formatGB(info.MemoryCapacity))
+	fmt.Printf("    Allocated to %d powered on VMs: %d vCPUs (%.2f per core), %s vRAM (%.2f:1)\n",
+		info.PoweredOnVMs, info.VCPUs, info.CPUOvercommit, formatGB(info.VRAM), info.MemoryOvercommit)
+	fmt.Printf("    Reserved: %d MHz CPU, %s memory\n", info.CPUReservation, formatGB(info.MemoryReservation))
+}
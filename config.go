@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// FileConfig holds the settings read from the -config file
+type FileConfig struct {
+	Thresholds ThresholdConfig  `json:"thresholds"`
+	Naming     NamingConfig     `json:"naming"`
+	ServiceNow ServiceNowConfig `json:"servicenow"`
+	Device42   Device42Config   `json:"device42"`
+	Syslog     SyslogConfig     `json:"syslog"`
+	NATS       NATSConfig       `json:"nats"`
+	Kafka      KafkaConfig      `json:"kafka"`
+	HostConfig HostConfigPolicy `json:"host_config"`
+	Signing    SigningConfig    `json:"signing"`
+	// Hooks enrich or transform the datastores report, run in order
+	Hooks []HookConfig `json:"hooks"`
+	// Profiles are selected with -profile by name
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// ThresholdConfig sets the used percentage at which a datastore is reported as
+// warning or critical. Overrides are checked in order, the first match wins.
+type ThresholdConfig struct {
+	WarningPct  float64             `json:"warning_pct"`
+	CriticalPct float64             `json:"critical_pct"`
+	Overrides   []ThresholdOverride `json:"overrides"`
+}
+
+// ThresholdOverride applies different thresholds to datastores matching a name,
+// a regular expression or a vSphere tag. Unset thresholds fall back to the defaults.
+type ThresholdOverride struct {
+	Name        string  `json:"name,omitempty"`
+	Regex       string  `json:"regex,omitempty"`
+	Tag         string  `json:"tag,omitempty"`
+	WarningPct  float64 `json:"warning_pct,omitempty"`
+	CriticalPct float64 `json:"critical_pct,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// NamingConfig holds the regular expressions that cluster, datastore cluster and
+// datastore names have to match, checked by the lint command. Empty patterns are not checked.
+type NamingConfig struct {
+	Cluster          string `json:"cluster,omitempty"`
+	DatastoreCluster string `json:"datastore_cluster,omitempty"`
+	Datastore        string `json:"datastore,omitempty"`
+
+	cluster, datastoreCluster, datastore *regexp.Regexp
+}
+
+// HostConfigPolicy is the reference NTP, DNS and remote syslog configuration of the hosts,
+// checked by the hostconfig command. Settings left empty are not checked.
+type HostConfigPolicy struct {
+	NTPServers    []string `json:"ntp_servers,omitempty"`
+	DNSServers    []string `json:"dns_servers,omitempty"`
+	SearchDomains []string `json:"search_domains,omitempty"`
+	SyslogHosts   []string `json:"syslog_hosts,omitempty"`
+}
+
+const (
+	defaultWarningPct  = 80
+	defaultCriticalPct = 90
+)
+
+// defaultFileConfig returns the settings used when no config file is given
+func defaultFileConfig() FileConfig {
+	return FileConfig{
+		Thresholds: ThresholdConfig{
+			WarningPct:  defaultWarningPct,
+			CriticalPct: defaultCriticalPct,
+		},
+	}
+}
+
+// loadConfigFile reads a JSON config file, settings missing from the file keep their defaults
+func loadConfigFile(path string) (FileConfig, error) {
+	fc := defaultFileConfig()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fc, err
+	}
+
+	if err := json.Unmarshal(data, &fc); err != nil {
+		return fc, fmt.Errorf("parsing %s: %s", path, err)
+	}
+
+	t := fc.Thresholds
+	if t.WarningPct > t.CriticalPct {
+		return fc, fmt.Errorf("warning_pct %g is above critical_pct %g", t.WarningPct, t.CriticalPct)
+	}
+	for i := range fc.Thresholds.Overrides {
+		o := &fc.Thresholds.Overrides[i]
+		if o.Name == "" && o.Regex == "" && o.Tag == "" {
+			return fc, fmt.Errorf("threshold override %d needs a name, regex or tag", i+1)
+		}
+		// thresholds left out fall back to the defaults, the result has to stay in order
+		warning, critical := t.WarningPct, t.CriticalPct
+		if o.WarningPct > 0 {
+			warning = o.WarningPct
+		}
+		if o.CriticalPct > 0 {
+			critical = o.CriticalPct
+		}
+		if warning > critical {
+			return fc, fmt.Errorf("threshold override %d: warning_pct %g is above critical_pct %g, set both", i+1, warning, critical)
+		}
+		if o.Regex != "" {
+			o.re, err = regexp.Compile(o.Regex)
+			if err != nil {
+				return fc, fmt.Errorf("threshold override %d: %s", i+1, err)
+			}
+		}
+	}
+
+	if err := validateFormat(fc.NATS.Format); err != nil {
+		return fc, fmt.Errorf("nats: %s", err)
+	}
+	if fc.Kafka.Brokers != nil || fc.Kafka.Topic != "" {
+		if err := fc.Kafka.validate(); err != nil {
+			return fc, fmt.Errorf("kafka: %s", err)
+		}
+	}
+
+	if err := fc.Signing.validate(); err != nil {
+		return fc, fmt.Errorf("signing: %s", err)
+	}
+
+	for i, hook := range fc.Hooks {
+		if err := hook.validate(); err != nil {
+			return fc, fmt.Errorf("hook %d: %s", i+1, err)
+		}
+	}
+
+	if fc.ServiceNow.Password == "" {
+		fc.ServiceNow.Password = os.Getenv("SERVICENOW_PASSWORD")
+	}
+	if fc.Device42.Password == "" {
+		fc.Device42.Password = os.Getenv("DEVICE42_PASSWORD")
+	}
+	if fc.Kafka.Password == "" {
+		fc.Kafka.Password = os.Getenv("KAFKA_PASSWORD")
+	}
+
+	n := &fc.Naming
+	for _, p := range []struct {
+		name    string
+		pattern string
+		re      **regexp.Regexp
+	}{
+		{"cluster", n.Cluster, &n.cluster},
+		{"datastore_cluster", n.DatastoreCluster, &n.datastoreCluster},
+		{"datastore", n.Datastore, &n.datastore},
+	} {
+		if p.pattern == "" {
+			continue
+		}
+		*p.re, err = regexp.Compile(p.pattern)
+		if err != nil {
+			return fc, fmt.Errorf("naming policy %s: %s", p.name, err)
+		}
+	}
+
+	return fc, nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+)
+
+// stringList is a flag that can be repeated, every use adds a value
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// DatacenterReport is the report of one datacenter when several are selected
+type DatacenterReport struct {
+	Datacenter string          `json:"datacenter"`
+	Report     json.RawMessage `json:"report,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// MultiDatacenterReport groups the JSON reports of several datacenters
+type MultiDatacenterReport struct {
+	Datacenters []DatacenterReport `json:"datacenters"`
+}
+
+// isDatacenterPattern reports whether a -datacenter value is a glob or a re: regular expression
+func isDatacenterPattern(s string) bool {
+	return strings.HasPrefix(s, "re:") || strings.ContainsAny(s, "*?[")
+}
+
+// matchDatacenter matches a datacenter name against a glob or a re: regular expression
+func matchDatacenter(pattern, name string) (bool, error) {
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return false, fmt.Errorf("invalid datacenter pattern %s: %s", pattern, err)
+		}
+		return re.MatchString(name), nil
+	}
+
+	ok, err := path.Match(pattern, name)
+	if err != nil {
+		return false, fmt.Errorf("invalid datacenter pattern %s: %s", pattern, err)
+	}
+	return ok, nil
+}
+
+// selectDatacenters finds the datacenters selected with -datacenter, in the order given and
+// each once. multi is set when the selection can match more than one datacenter.
+func selectDatacenters(ctx context.Context, finder *find.Finder, selectors []string) (dcs []*object.Datacenter, multi bool, err error) {
+	if len(selectors) == 0 {
+		dc, err := finder.DefaultDatacenter(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		return []*object.Datacenter{dc}, false, nil
+	}
+
+	if len(selectors) == 1 && !isDatacenterPattern(selectors[0]) {
+		dc, err := finder.Datacenter(ctx, selectors[0])
+		if err != nil {
+			return nil, false, err
+		}
+		return []*object.Datacenter{dc}, false, nil
+	}
+
+	all, err := finder.DatacenterList(ctx, "*")
+	if err != nil {
+		return nil, true, err
+	}
+
+	seen := make(map[string]bool)
+	for _, selector := range selectors {
+		found := false
+		for _, dc := range all {
+			ok := dc.Name() == selector
+			if isDatacenterPattern(selector) {
+				if ok, err = matchDatacenter(selector, dc.Name()); err != nil {
+					return nil, true, err
+				}
+			}
+			if !ok {
+				continue
+			}
+			found = true
+			if ref := dc.Reference().Value; !seen[ref] {
+				seen[ref] = true
+				dcs = append(dcs, dc)
+			}
+		}
+		if !found {
+			return nil, true, fmt.Errorf("no datacenter matches %s", selector)
+		}
+	}
+
+	return dcs, true, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestMatchDatacenter(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+		wantErr bool
+	}{
+		{"DC0", "DC0", true, false},
+		{"DC0", "DC01", false, false},
+		{"DC*", "DC01", true, false},
+		{"*-prod", "eu-west-prod", true, false},
+		{"*-prod", "eu-west-test", false, false},
+		{"DC?", "DC1", true, false},
+		{"DC?", "DC12", false, false},
+		{"DC[0-2]", "DC2", true, false},
+		{"DC[0-2]", "DC3", false, false},
+		{"DC[", "DC1", false, true},
+		{"re:^eu-", "eu-west", true, false},
+		{"re:^eu-", "us-east", false, false},
+		{"re:west|east", "us-east", true, false},
+		{"re:prod$", "prod-eu", false, false},
+		{"re:(", "DC0", false, true},
+	}
+
+	for _, tt := range tests {
+		got, err := matchDatacenter(tt.pattern, tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("matchDatacenter(%q, %q) error = %v, want error %v", tt.pattern, tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("matchDatacenter(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsDatacenterPattern(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"DC0", false},
+		{"my datacenter", false},
+		{"DC*", true},
+		{"DC?", true},
+		{"DC[01]", true},
+		{"re:DC", true},
+	}
+
+	for _, tt := range tests {
+		if got := isDatacenterPattern(tt.s); got != tt.want {
+			t.Errorf("isDatacenterPattern(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
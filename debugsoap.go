@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/vmware/govmomi/vim25/debug"
+)
+
+// soapSecrets are the credentials, session cookies and tokens removed from the -debug-soap
+// dumps, the text around them is kept
+var soapSecrets = []*regexp.Regexp{
+	regexp.MustCompile(`(?s)(<password>).*?(</password>)`),
+	regexp.MustCompile(`(?s)(<vcSessionCookie>).*?(</vcSessionCookie>)`),
+	regexp.MustCompile(`(?s)(<(?:saml2?:)?Assertion\b).*?(</(?:saml2?:)?Assertion>)`),
+	regexp.MustCompile(`(?s)(<(?:\w+:)?BinarySecurityToken\b[^>]*>).*?(</(?:\w+:)?BinarySecurityToken>)`),
+	regexp.MustCompile(`(?mi)^((?:Cookie|Set-Cookie|Authorization|Vmware-Api-Session-Id):\s*)[^\r\n]*()`),
+	// the REST login responds with the session id as {"value":"..."}
+	regexp.MustCompile(`^(\{"value":")[^"]*("\}\s*)$`),
+}
+
+// redactSOAP replaces the secrets in a dumped request or response
+func redactSOAP(b []byte) []byte {
+	for _, re := range soapSecrets {
+		b = re.ReplaceAll(b, []byte("${1}********${2}"))
+	}
+	return b
+}
+
+// soapDumper is the govmomi debug provider of -debug-soap. The request and response files
+// are redacted as a whole when govmomi closes them, as a secret can span several writes,
+// the client logs only hold timings and are written through.
+type soapDumper struct {
+	dir string
+
+	mu    sync.Mutex
+	files []io.Closer
+}
+
+func newSOAPDumper(dir string) (*soapDumper, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &soapDumper{dir: dir}, nil
+}
+
+func (d *soapDumper) NewFile(name string) io.WriteCloser {
+	f, err := os.OpenFile(filepath.Join(d.dir, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		// dumping is best effort, a full disk shouldn't break the scan
+		return nopWriteCloser{io.Discard}
+	}
+
+	if !strings.HasSuffix(name, ".log") {
+		return &redactingFile{f: f}
+	}
+	// govmomi keeps the client logs open until Flush
+	d.mu.Lock()
+	d.files = append(d.files, f)
+	d.mu.Unlock()
+	return f
+}
+
+func (d *soapDumper) Flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, f := range d.files {
+		f.Close()
+	}
+	d.files = nil
+}
+
+// redactingFile collects a dump and writes it redacted on Close
+type redactingFile struct {
+	f      *os.File
+	buf    bytes.Buffer
+	once   sync.Once
+	closed error
+}
+
+func (r *redactingFile) Write(p []byte) (int, error) {
+	return r.buf.Write(p)
+}
+
+func (r *redactingFile) Close() error {
+	r.once.Do(func() {
+		if _, err := r.f.Write(redactSOAP(r.buf.Bytes())); err != nil {
+			r.closed = err
+		}
+		r.buf = bytes.Buffer{}
+		if err := r.f.Close(); err != nil && r.closed == nil {
+			r.closed = err
+		}
+	})
+	return r.closed
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// enableSOAPDump makes govmomi dump every vCenter request and response to dir
+func enableSOAPDump(dir string) error {
+	d, err := newSOAPDumper(dir)
+	if err != nil {
+		return err
+	}
+	debug.SetProvider(d)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestRedactSOAP(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"login password",
+			"<Login><userName>admin</userName><password>s3cret</password></Login>",
+			"<Login><userName>admin</userName><password>********</password></Login>",
+		},
+		{
+			"password across lines",
+			"<password>line1\nline2</password>",
+			"<password>********</password>",
+		},
+		{
+			"session cookie",
+			"<vcSessionCookie>abc123</vcSessionCookie>",
+			"<vcSessionCookie>********</vcSessionCookie>",
+		},
+		{
+			"saml assertion",
+			`<saml2:Assertion ID="x"><saml2:Subject>admin</saml2:Subject></saml2:Assertion>`,
+			"<saml2:Assertion********</saml2:Assertion>",
+		},
+		{
+			"binary security token",
+			`<wsse:BinarySecurityToken EncodingType="base64">MIIC</wsse:BinarySecurityToken>`,
+			`<wsse:BinarySecurityToken EncodingType="base64">********</wsse:BinarySecurityToken>`,
+		},
+		{
+			"headers",
+			"POST /sdk HTTP/1.1\r\nCookie: vmware_soap_session=abc\r\nAuthorization: Basic dXNlcjpwYXNz\r\nContent-Type: text/xml\r\n",
+			"POST /sdk HTTP/1.1\r\nCookie: ********\r\nAuthorization: ********\r\nContent-Type: text/xml\r\n",
+		},
+		{
+			"rest session id",
+			`{"value":"b00db39f2bd2f0fdd4b4e0e4a9b1"}`,
+			`{"value":"********"}`,
+		},
+		{
+			"other rest values",
+			`{"value":[{"name":"VM0"}]}`,
+			`{"value":[{"name":"VM0"}]}`,
+		},
+		{
+			"no secrets",
+			"<RetrieveProperties><specSet>datastore</specSet></RetrieveProperties>",
+			"<RetrieveProperties><specSet>datastore</specSet></RetrieveProperties>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(redactSOAP([]byte(tt.in))); got != tt.want {
+				t.Errorf("redactSOAP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type DeltaDiskInfo struct {
+	VM          string `json:"vm"`
+	Disk        string `json:"disk"`
+	File        string `json:"file"`
+	BaseDisk    string `json:"base_disk"`
+	ChainDepth  int    `json:"chain_depth"`
+	Snapshot    bool   `json:"snapshot"`
+	LinkedClone bool   `json:"linked_clone"`
+}
+
+type DatastoreDeltaDisks struct {
+	Datastore string          `json:"datastore"`
+	Disks     []DeltaDiskInfo `json:"disks"`
+}
+
+type DeltaDiskReport struct {
+	Datacenter string                `json:"datacenter"`
+	Datastores []DatastoreDeltaDisks `json:"datastores"`
+}
+
+// reportDeltaDisks lists VM disks running on snapshot deltas or linked clones per datastore
+// together with the depth of their disk chain
+func reportDeltaDisks(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	var vms []mo.VirtualMachine
+	err := retrieveAll(ctx, client, dc, "VirtualMachine", []string{"name", "config.template", "config.hardware.device", "snapshot"}, &vms)
+	if err != nil {
+		return fmt.Errorf("retrieving VMs: %s", err)
+	}
+
+	var disks []DeltaDiskInfo
+	// number of VMs sharing a base disk, more than one means linked clones
+	baseUsers := make(map[string]map[string]bool)
+
+	for _, vm := range vms {
+		if vm.Config == nil {
+			continue
+		}
+
+		for _, device := range vm.Config.Hardware.Device {
+			disk, ok := device.(*types.VirtualDisk)
+			if !ok {
+				continue
+			}
+
+			chain := diskChain(disk.Backing)
+			if len(chain) == 0 {
+				continue
+			}
+
+			base := chain[len(chain)-1]
+			if baseUsers[base] == nil {
+				baseUsers[base] = make(map[string]bool)
+			}
+			baseUsers[base][vm.Self.Value] = true
+
+			// templates count as users of their base disks, so the only linked clone of a
+			// template is flagged, but their own disks are not listed
+			if vm.Config.Template || len(chain) < 2 {
+				continue
+			}
+
+			label := fmt.Sprintf("disk-%d", disk.Key)
+			if info := disk.GetVirtualDevice().DeviceInfo; info != nil {
+				label = info.GetDescription().Label
+			}
+
+			disks = append(disks, DeltaDiskInfo{
+				VM:         vm.Name,
+				Disk:       label,
+				File:       chain[0],
+				BaseDisk:   base,
+				ChainDepth: len(chain) - 1,
+				Snapshot:   vm.Snapshot != nil,
+			})
+		}
+	}
+
+	perDatastore := make(map[string][]DeltaDiskInfo)
+	for _, disk := range disks {
+		disk.LinkedClone = len(baseUsers[disk.BaseDisk]) > 1
+
+		var p object.DatastorePath
+		p.FromString(disk.File)
+		perDatastore[p.Datastore] = append(perDatastore[p.Datastore], disk)
+	}
+
+	report := DeltaDiskReport{
+		Datacenter: dc.Name(),
+		Datastores: make([]DatastoreDeltaDisks, 0, len(perDatastore)),
+	}
+	for name, disks := range perDatastore {
+		sort.Slice(disks, func(i, j int) bool {
+			return disks[i].ChainDepth > disks[j].ChainDepth
+		})
+		report.Datastores = append(report.Datastores, DatastoreDeltaDisks{Datastore: name, Disks: disks})
+	}
+	sort.Slice(report.Datastores, func(i, j int) bool {
+		return report.Datastores[i].Datastore < report.Datastores[j].Datastore
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	if len(report.Datastores) == 0 {
+		fmt.Println("\nNo VMs running on delta disks found")
+		return nil
+	}
+
+	for _, ds := range report.Datastores {
+		fmt.Printf("\nDatastore: %s\n", ds.Datastore)
+		for _, disk := range ds.Disks {
+			kind := "delta"
+			switch {
+			case disk.LinkedClone && disk.Snapshot:
+				kind = "linked clone, snapshot"
+			case disk.LinkedClone:
+				kind = "linked clone"
+			case disk.Snapshot:
+				kind = "snapshot"
+			}
+			fmt.Printf("  - %s / %s (chain depth %d, %s)\n", disk.VM, disk.Disk, disk.ChainDepth, kind)
+			fmt.Printf("      Base disk: %s\n", disk.BaseDisk)
+		}
+	}
+
+	return nil
+}
+
+// diskChain returns the files of a virtual disk chain, starting with the
+// running disk and ending with the base disk
+func diskChain(backing types.BaseVirtualDeviceBackingInfo) []string {
+	var chain []string
+
+	switch b := backing.(type) {
+	case *types.VirtualDiskFlatVer2BackingInfo:
+		for ; b != nil; b = b.Parent {
+			chain = append(chain, b.FileName)
+		}
+	case *types.VirtualDiskSeSparseBackingInfo:
+		for ; b != nil; b = b.Parent {
+			chain = append(chain, b.FileName)
+		}
+	case *types.VirtualDiskSparseVer2BackingInfo:
+		for ; b != nil; b = b.Parent {
+			chain = append(chain, b.FileName)
+		}
+	case *types.VirtualDiskRawDiskMappingVer1BackingInfo:
+		for ; b != nil; b = b.Parent {
+			chain = append(chain, b.FileName)
+		}
+	case *types.VirtualDiskFlatVer1BackingInfo:
+		for ; b != nil; b = b.Parent {
+			chain = append(chain, b.FileName)
+		}
+	case *types.VirtualDiskSparseVer1BackingInfo:
+		for ; b != nil; b = b.Parent {
+			chain = append(chain, b.FileName)
+		}
+	}
+
+	return chain
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+
+	// the endpoints beside the vim API, for the libraries, tags, tanzu (and its storage
+	// policy names), cns, certs and vsan commands
+	_ "github.com/vmware/govmomi/cns/simulator"
+	_ "github.com/vmware/govmomi/lookup/simulator"
+	_ "github.com/vmware/govmomi/pbm/simulator"
+	_ "github.com/vmware/govmomi/sts/simulator"
+	_ "github.com/vmware/govmomi/vapi/namespace/simulator"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	_ "github.com/vmware/govmomi/vsan/simulator"
+)
+
+// demoUnsupportedCommands are the commands whose vSphere APIs the simulator doesn't implement
+var demoUnsupportedCommands = map[string]string{
+	"compliance": "the simulator has no storage policy compliance manager",
+	"alarms":     "the simulator can't read alarm definitions",
+}
+
+// startDemo starts an in-process vCenter simulator (vcsim) with two clusters of three hosts,
+// a standalone host, a datastore cluster holding two of the three datastores, a vApp and a
+// few VMs per host, and points cfg at it. The returned function stops the simulator and
+// removes its datastore directories.
+func startDemo(cfg *Config) (func(), error) {
+	model := simulator.VPX()
+	model.Cluster = 2
+	model.ClusterHost = 3
+	model.Host = 1
+	model.Datastore = 3
+	model.Pod = 1
+	model.App = 1
+	model.Portgroup = 2
+	model.Machine = 3
+
+	if err := model.Create(); err != nil {
+		return nil, fmt.Errorf("creating the demo inventory: %s", err)
+	}
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+
+	if err := fillDemoPod(server); err != nil {
+		server.Close()
+		model.Remove()
+		return nil, fmt.Errorf("creating the demo datastore cluster: %s", err)
+	}
+
+	u := *server.URL
+	cfg.Username = u.User.Username()
+	cfg.Password, _ = u.User.Password()
+	u.User = nil
+	cfg.URL = u.String()
+	// the simulator certificate is self-signed
+	cfg.Insecure = true
+	if !cfg.OutputJSON {
+		fmt.Fprintf(os.Stderr, "Demo: running against a simulated vCenter at %s\n", cfg.URL)
+	}
+
+	return func() {
+		server.Close()
+		model.Remove()
+	}, nil
+}
+
+// fillDemoPod moves the first two datastores into the datastore cluster, the simulator
+// creates it empty
+func fillDemoPod(server *simulator.Server) error {
+	ctx := context.Background()
+	client, err := govmomi.NewClient(ctx, server.URL, true)
+	if err != nil {
+		return err
+	}
+	defer client.Logout(ctx)
+
+	finder := find.NewFinder(client.Client)
+	dc, err := finder.DefaultDatacenter(ctx)
+	if err != nil {
+		return err
+	}
+	finder.SetDatacenter(dc)
+	pods, err := finder.DatastoreClusterList(ctx, "*")
+	if err != nil {
+		return err
+	}
+	datastores, err := finder.DatastoreList(ctx, "*")
+	if err != nil {
+		return err
+	}
+	if len(datastores) > 2 {
+		datastores = datastores[:2]
+	}
+
+	refs := make([]types.ManagedObjectReference, 0, len(datastores))
+	for _, ds := range datastores {
+		refs = append(refs, ds.Reference())
+	}
+	task, err := pods[0].MoveInto(ctx, refs)
+	if err != nil {
+		return err
+	}
+	return task.Wait(ctx)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type HostDensityInfo struct {
+	Name        string  `json:"name"`
+	VMCount     int     `json:"vm_count"`
+	VCPUs       int     `json:"vcpus"`
+	CPUCores    int     `json:"cpu_cores"`
+	VCPUPerCore float64 `json:"vcpu_per_core"`
+}
+
+type ClusterDensityInfo struct {
+	Name      string `json:"name"`
+	HostCount int    `json:"host_count"`
+	// VMCount, VCPUs and the ratios only count powered on VMs
+	VMCount     int               `json:"vm_count"`
+	VCPUs       int               `json:"vcpus"`
+	CPUCores    int               `json:"cpu_cores"`
+	VMsPerHost  float64           `json:"vms_per_host"`
+	VCPUPerCore float64           `json:"vcpu_per_core"`
+	Hosts       []HostDensityInfo `json:"hosts"`
+	// Warnings flag the cluster and hosts above -max-vms-per-host or -max-vcpu-per-core
+	Warnings []string `json:"warnings"`
+}
+
+type DensityReport struct {
+	Datacenter     string               `json:"datacenter"`
+	MaxVMsPerHost  int                  `json:"max_vms_per_host"`
+	MaxVCPUPerCore float64              `json:"max_vcpu_per_core"`
+	Clusters       []ClusterDensityInfo `json:"clusters"`
+}
+
+// reportDensity shows the powered on VMs per host and vCPUs per physical core of every cluster
+// and its hosts, warning when they exceed -max-vms-per-host or -max-vcpu-per-core
+func reportDensity(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := DensityReport{
+		Datacenter:     dc.Name(),
+		MaxVMsPerHost:  cfg.MaxVMsPerHost,
+		MaxVCPUPerCore: cfg.MaxVCPUPerCore,
+		Clusters:       make([]ClusterDensityInfo, 0, len(clusters)),
+	}
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+		var hosts []mo.HostSystem
+		if len(clusterMo.Host) > 0 {
+			err = pc.Retrieve(ctx, clusterMo.Host, []string{"name", "summary.hardware"}, &hosts)
+			if err != nil {
+				return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+			}
+		}
+
+		var vms []mo.VirtualMachine
+		err = retrieveClusterVMs(ctx, client, cluster, []string{"config.template", "config.hardware.numCPU", "runtime.powerState", "runtime.host"}, &vms)
+		if err != nil {
+			return fmt.Errorf("getting VMs of cluster %s: %s", cluster.Name(), err)
+		}
+
+		info := ClusterDensityInfo{
+			Name:      cluster.Name(),
+			HostCount: len(hosts),
+			Hosts:     make([]HostDensityInfo, 0, len(hosts)),
+			Warnings:  make([]string, 0),
+		}
+
+		perHost := make(map[string]*HostDensityInfo, len(hosts))
+		for _, host := range hosts {
+			hostInfo := &HostDensityInfo{Name: host.Name}
+			if host.Summary.Hardware != nil {
+				hostInfo.CPUCores = int(host.Summary.Hardware.NumCpuCores)
+			}
+			perHost[host.Self.Value] = hostInfo
+		}
+
+		for _, vm := range vms {
+			if vm.Config == nil || vm.Config.Template || vm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+				continue
+			}
+			info.VMCount++
+			info.VCPUs += int(vm.Config.Hardware.NumCPU)
+			if vm.Runtime.Host == nil {
+				continue
+			}
+			if hostInfo, ok := perHost[vm.Runtime.Host.Value]; ok {
+				hostInfo.VMCount++
+				hostInfo.VCPUs += int(vm.Config.Hardware.NumCPU)
+			}
+		}
+
+		for _, hostInfo := range perHost {
+			info.CPUCores += hostInfo.CPUCores
+			if hostInfo.CPUCores > 0 {
+				hostInfo.VCPUPerCore = float64(hostInfo.VCPUs) / float64(hostInfo.CPUCores)
+			}
+			info.Hosts = append(info.Hosts, *hostInfo)
+		}
+		sort.Slice(info.Hosts, func(i, j int) bool {
+			return info.Hosts[i].Name < info.Hosts[j].Name
+		})
+		if info.HostCount > 0 {
+			info.VMsPerHost = float64(info.VMCount) / float64(info.HostCount)
+		}
+		if info.CPUCores > 0 {
+			info.VCPUPerCore = float64(info.VCPUs) / float64(info.CPUCores)
+		}
+
+		if cfg.MaxVMsPerHost > 0 && info.VMsPerHost > float64(cfg.MaxVMsPerHost) {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("%.1f VMs per host, more than %d", info.VMsPerHost, cfg.MaxVMsPerHost))
+		}
+		if cfg.MaxVCPUPerCore > 0 && info.VCPUPerCore > cfg.MaxVCPUPerCore {
+			info.Warnings = append(info.Warnings, fmt.Sprintf("%.2f vCPUs per core, more than %.2f", info.VCPUPerCore, cfg.MaxVCPUPerCore))
+		}
+		for _, host := range info.Hosts {
+			if cfg.MaxVMsPerHost > 0 && host.VMCount > cfg.MaxVMsPerHost {
+				info.Warnings = append(info.Warnings, fmt.Sprintf("host %s runs %d VMs, more than %d", host.Name, host.VMCount, cfg.MaxVMsPerHost))
+			}
+			if cfg.MaxVCPUPerCore > 0 && host.VCPUPerCore > cfg.MaxVCPUPerCore {
+				info.Warnings = append(info.Warnings, fmt.Sprintf("host %s has %.2f vCPUs per core, more than %.2f", host.Name, host.VCPUPerCore, cfg.MaxVCPUPerCore))
+			}
+		}
+
+		report.Clusters = append(report.Clusters, info)
+	}
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		return report.Clusters[i].Name < report.Clusters[j].Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", cluster.Name)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		fmt.Printf("  %d powered on VMs on %d hosts: %.1f VMs per host, %d vCPUs on %d cores: %.2f vCPUs per core\n",
+			cluster.VMCount, cluster.HostCount, cluster.VMsPerHost, cluster.VCPUs, cluster.CPUCores, cluster.VCPUPerCore)
+		for _, host := range cluster.Hosts {
+			fmt.Printf("    Host %s: %d VMs, %d vCPUs on %d cores (%.2f per core)\n", host.Name, host.VMCount, host.VCPUs, host.CPUCores, host.VCPUPerCore)
+		}
+		for _, warning := range cluster.Warnings {
+			fmt.Printf("  WARNING: %s\n", warning)
+		}
+	}
+
+	return nil
+}
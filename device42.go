@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Device42Config holds the Device42 instance the sync command writes to
+type Device42Config struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	// Password can also be set with the DEVICE42_PASSWORD env var
+	Password string `json:"password,omitempty"`
+	// CustomFields lists the attributes per inventory kind that are stored as device
+	// custom fields, missing kinds use the defaults
+	CustomFields map[string][]string `json:"custom_fields,omitempty"`
+	// Names sets the attribute per inventory kind used as device name, missing kinds use the
+	// defaults
+	Names map[string]string `json:"names,omitempty"`
+}
+
+// device42Types maps inventory kinds to Device42 device types
+var device42Types = map[string]string{
+	kindCluster:   "cluster",
+	kindHost:      "physical",
+	kindDatastore: "other",
+}
+
+var defaultDevice42CustomFields = map[string][]string{
+	kindCluster:   {"datacenter", "host_count"},
+	kindHost:      {"datacenter", "cluster", "datastore_count"},
+	kindDatastore: {"datacenter", "type", "capacity_gb", "free_space_gb", "used_pct", "shared"},
+}
+
+// device names are qualified with the datacenter for clusters and datastores, whose names are
+// only unique within a datacenter
+var defaultDevice42Names = map[string]string{
+	kindCluster:   "qualified_name",
+	kindHost:      "name",
+	kindDatastore: "qualified_name",
+}
+
+// nameAttribute returns the attribute of an inventory kind used as device name
+func (c Device42Config) nameAttribute(kind string) string {
+	if attr, ok := c.Names[kind]; ok {
+		return attr
+	}
+	return defaultDevice42Names[kind]
+}
+
+// customFields returns the attributes of an inventory kind stored as custom fields
+func (c Device42Config) customFields(kind string) []string {
+	if fields, ok := c.CustomFields[kind]; ok {
+		return fields
+	}
+	return defaultDevice42CustomFields[kind]
+}
+
+// syncDevice42 creates or updates a Device42 device for every inventory record and sets
+// the capacity attributes as custom fields. Device42 matches devices by name, so clusters and
+// datastores are named <datacenter>/<name> unless names selects another attribute.
+func syncDevice42(ctx context.Context, cfg *Config, records map[string][]inventoryRecord) error {
+	d42 := cfg.Device42
+	if d42.URL == "" || d42.Username == "" || d42.Password == "" {
+		return fmt.Errorf("device42 url, username and password must be set in the -config file")
+	}
+
+	c := &device42Client{config: d42, http: http.DefaultClient}
+
+	var devices int
+	for _, kind := range inventoryKinds {
+		nameAttr := d42.nameAttribute(kind)
+		for _, record := range records[kind] {
+			value, ok := record[nameAttr]
+			if !ok {
+				return fmt.Errorf("device42 names: unknown %s attribute %s", kind, nameAttr)
+			}
+			name := fmt.Sprint(value)
+			if !cfg.OutputJSON {
+				fmt.Printf("  device %s (%s)\n", name, device42Types[kind])
+			}
+			if cfg.DryRun {
+				continue
+			}
+
+			err := c.post(ctx, http.MethodPost, "/api/1.0/devices/", url.Values{
+				"name": {name},
+				"type": {device42Types[kind]},
+			})
+			if err != nil {
+				return fmt.Errorf("saving %s %s: %s", kind, name, err)
+			}
+
+			for _, attr := range c.config.customFields(kind) {
+				value, ok := record[attr]
+				if !ok {
+					continue
+				}
+				err = c.post(ctx, http.MethodPut, "/api/1.0/device/custom_field/", url.Values{
+					"name":  {name},
+					"key":   {attr},
+					"value": {fmt.Sprint(value)},
+				})
+				if err != nil {
+					return fmt.Errorf("setting %s of %s %s: %s", attr, kind, name, err)
+				}
+			}
+			devices++
+		}
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(map[string]int{"devices": devices})
+	}
+
+	if cfg.DryRun {
+		fmt.Println("\nDry run, nothing was written to Device42")
+	} else {
+		fmt.Printf("\nDevice42: %d devices saved\n", devices)
+	}
+
+	return nil
+}
+
+// device42Client talks to the Device42 REST API, which takes form encoded requests
+type device42Client struct {
+	config Device42Config
+	http   *http.Client
+}
+
+func (c *device42Client) post(ctx context.Context, method, path string, form url.Values) error {
+	u := strings.TrimSuffix(c.config.URL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, u, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.config.Username, c.config.Password)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s %s", method, path, res.Status, strings.TrimSpace(string(body)))
+	}
+
+	// Device42 reports some errors with a success status, e.g. {"code": 1, "msg": [...]}
+	var result struct {
+		Code int             `json:"code"`
+		Msg  json.RawMessage `json:"msg"`
+	}
+	if json.Unmarshal(body, &result) == nil && result.Code != 0 {
+		return fmt.Errorf("%s %s: %s", method, path, string(result.Msg))
+	}
+
+	return nil
+}
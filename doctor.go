@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const doctorTimeout = 10 * time.Second
+
+// doctor privilege scopes, the entities the privileges are checked on
+const (
+	scopeDatacenter = "datacenter"
+	scopeRoot       = "root folder"
+	scopeDatastores = "datastores"
+	scopeClusters   = "clusters"
+	scopeHosts      = "hosts"
+	scopeVMs        = "virtual machines"
+)
+
+// doctorScopeTypes are the managed object types of the scopes that list the datacenter's objects
+var doctorScopeTypes = map[string]string{
+	scopeDatastores: "Datastore",
+	scopeClusters:   "ClusterComputeResource",
+	scopeHosts:      "HostSystem",
+	scopeVMs:        "VirtualMachine",
+}
+
+// doctorModules are the collection modules with the privileges they need beyond logging in,
+// missing privileges of the inventory module are critical, of the others warnings
+var doctorModules = []struct {
+	name       string
+	commands   string
+	scope      string
+	privileges []string
+}{
+	{"inventory", "all commands", scopeDatacenter, []string{"System.View", "System.Read"}},
+	{"datastore browsing", "isos, unregistered", scopeDatastores, []string{"Datastore.Browse"}},
+	{"storage policies", "compliance", scopeRoot, []string{"StorageProfile.View"}},
+	{"CNS volumes", "cns", scopeRoot, []string{"Cns.Searchable"}},
+	{"content library storage", "libraries", scopeRoot, []string{"ContentLibrary.ReadStorage"}},
+	{"key providers", "encryption", scopeRoot, []string{"Cryptographer.ReadKeyServersInfo"}},
+	{"vSAN health and space", "vsan", scopeClusters, []string{"System.Read"}},
+	{"supervisor clusters", "tanzu", scopeClusters, []string{"System.Read"}},
+	{"VM events", "snapshots, powerstate", scopeVMs, []string{"System.Read"}},
+	{"host advanced options", "security", scopeHosts, []string{"System.Read"}},
+}
+
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+type DoctorReport struct {
+	URL    string        `json:"url"`
+	Checks []DoctorCheck `json:"checks"`
+}
+
+func (r *DoctorReport) add(name, status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, DoctorCheck{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// runDoctor checks step by step that godcinfo can collect from vCenter: the TCP connection,
+// the trust of the TLS certificate, the login and the privileges of the account for every
+// collection module, naming the missing privileges instead of failing mid-scan. It exits
+// nonzero when a check is critical.
+func runDoctor(cfg *Config) error {
+	ctx := context.Background()
+	// doctor runs without the connection checks of the other commands, so it can report them
+	if cfg.Certificate == "" {
+		keychainPassword(cfg)
+	}
+
+	report := DoctorReport{URL: cfg.URL, Checks: make([]DoctorCheck, 0)}
+	doctorChecks(ctx, cfg, &report)
+
+	if cfg.OutputJSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("\nChecking %s\n", valueOrNone(report.URL))
+		for _, check := range report.Checks {
+			fmt.Printf("  [%s] %s: %s\n", strings.ToUpper(check.Status), check.Name, check.Detail)
+		}
+	}
+
+	failed := 0
+	for _, check := range report.Checks {
+		if check.Status == statusCritical {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(report.Checks))
+	}
+	return nil
+}
+
+// doctorChecks runs the checks in order, a failed step skips the steps depending on it
+func doctorChecks(ctx context.Context, cfg *Config, report *DoctorReport) {
+	if cfg.URL == "" {
+		report.add("configuration", statusCritical, "no vCenter URL, set -url, VSPHERE_URL or GOVC_URL")
+		return
+	}
+	u, err := soap.ParseURL(cfg.URL)
+	if err != nil {
+		report.add("configuration", statusCritical, "invalid URL: %s", err)
+		return
+	}
+	if cfg.Certificate == "" && (cfg.Username == "" || cfg.Password == "") {
+		report.add("configuration", statusCritical, "no credentials, set -username and -password, store the password with godcinfo login or use -cert and -key")
+		return
+	}
+
+	address := u.Host
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	conn, err := net.DialTimeout("tcp", address, doctorTimeout)
+	if err != nil {
+		report.add("connectivity", statusCritical, "%s", err)
+		return
+	}
+	conn.Close()
+	report.add("connectivity", statusOK, "reached %s", address)
+
+	dialer := &net.Dialer{Timeout: doctorTimeout}
+	tlsConn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: u.Hostname()})
+	switch {
+	case err == nil:
+		tlsConn.Close()
+		report.add("TLS trust", statusOK, "the certificate of %s is trusted", u.Hostname())
+	case cfg.Insecure:
+		report.add("TLS trust", statusWarning, "%s, accepted because of -insecure", err)
+	default:
+		report.add("TLS trust", statusCritical, "%s, add the vCenter CA to the system trust store", err)
+		return
+	}
+
+	client, err := connectToVSphere(ctx, cfg)
+	if err != nil {
+		report.add("login", statusCritical, "%s", err)
+		return
+	}
+	defer client.Logout(ctx)
+
+	userSession, err := client.SessionManager.UserSession(ctx)
+	if err != nil || userSession == nil {
+		report.add("login", statusCritical, "no session after login: %v", err)
+		return
+	}
+	about := client.ServiceContent.About
+	report.add("login", statusOK, "logged in as %s to %s", userSession.UserName, about.FullName)
+	if about.ApiType != "VirtualCenter" {
+		report.add("vCenter", statusWarning, "%s is no vCenter, the cluster commands need one", about.FullName)
+	}
+
+	finder := find.NewFinder(client.Client, true)
+	dcs, _, err := selectDatacenters(ctx, finder, cfg.Datacenters)
+	if err != nil {
+		report.add("datacenter", statusCritical, "%s", err)
+		return
+	}
+	for _, dc := range dcs {
+		report.add("datacenter", statusOK, "found %s", dc.Name())
+		if err := doctorPrivileges(ctx, client, dc, userSession.Key, report); err != nil {
+			report.add("privileges", statusCritical, "checking the privileges on %s: %s", dc.Name(), err)
+		}
+	}
+
+	if _, err := connectToREST(ctx, client, cfg); err != nil {
+		report.add("REST API", statusWarning, "login failed, the libraries, tanzu and tag filters need it: %s", err)
+	} else {
+		report.add("REST API", statusOK, "logged in")
+	}
+}
+
+// doctorPrivileges checks the privileges of every module on its entities in the datacenter
+func doctorPrivileges(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, sessionKey string, report *DoctorReport) error {
+	names := map[string]string{dc.Reference().Value: dc.Name(), client.ServiceContent.RootFolder.Value: "root folder"}
+	scopeRefs := make(map[string][]types.ManagedObjectReference)
+	for scope, kind := range doctorScopeTypes {
+		var objects []mo.ManagedEntity
+		if err := retrieveAll(ctx, client, dc, kind, []string{"name"}, &objects); err != nil {
+			return err
+		}
+		for _, obj := range objects {
+			scopeRefs[scope] = append(scopeRefs[scope], obj.Self)
+			names[obj.Self.Value] = obj.Name
+		}
+	}
+
+	for _, module := range doctorModules {
+		var entities []types.ManagedObjectReference
+		switch module.scope {
+		case scopeDatacenter:
+			entities = []types.ManagedObjectReference{dc.Reference()}
+		case scopeRoot:
+			entities = []types.ManagedObjectReference{client.ServiceContent.RootFolder}
+		default:
+			entities = scopeRefs[module.scope]
+		}
+		name := fmt.Sprintf("%s (%s)", module.name, module.commands)
+		where := fmt.Sprintf("%s of %s", module.scope, dc.Name())
+		if module.scope == scopeRoot {
+			where = "root folder"
+		}
+		if len(entities) == 0 {
+			report.add(name, statusOK, "no %s in %s", module.scope, dc.Name())
+			continue
+		}
+
+		missing, err := missingPrivileges(ctx, client, entities, sessionKey, module.privileges)
+		if err != nil {
+			return err
+		}
+		if len(missing) == 0 {
+			report.add(name, statusOK, "%s granted on the %s", strings.Join(module.privileges, ", "), where)
+			continue
+		}
+
+		status := statusWarning
+		if module.scope == scopeDatacenter {
+			status = statusCritical
+		}
+		details := make([]string, 0, len(missing))
+		for privilege, refs := range missing {
+			objects := make([]string, 0, len(refs))
+			for _, ref := range refs {
+				objects = append(objects, names[ref.Value])
+			}
+			sort.Strings(objects)
+			details = append(details, fmt.Sprintf("%s missing on %s", privilege, strings.Join(objects, ", ")))
+		}
+		sort.Strings(details)
+		report.add(name, status, "%s", strings.Join(details, "; "))
+	}
+
+	return nil
+}
+
+// missingPrivileges returns the entities the session lacks each of the privileges on
+func missingPrivileges(ctx context.Context, client *govmomi.Client, entities []types.ManagedObjectReference, sessionKey string, privileges []string) (map[string][]types.ManagedObjectReference, error) {
+	if client.ServiceContent.AuthorizationManager == nil {
+		return nil, errors.New("no authorization manager")
+	}
+	req := types.HasPrivilegeOnEntities{
+		This:      *client.ServiceContent.AuthorizationManager,
+		Entity:    entities,
+		SessionId: sessionKey,
+		PrivId:    privileges,
+	}
+	res, err := methods.HasPrivilegeOnEntities(ctx, client.Client, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := make(map[string][]types.ManagedObjectReference)
+	for _, entity := range res.Returnval {
+		for _, p := range entity.PrivAvailability {
+			if !p.IsGranted {
+				missing[p.PrivId] = append(missing[p.PrivId], entity.Entity)
+			}
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type DRSActionInfo struct {
+	// Type is the DRS action type, like MigrationV1 or PowerOn
+	Type        string `json:"type"`
+	VM          string `json:"vm,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	// Target is the object of actions that are no VM migrations, like the host DPM powers off
+	Target string `json:"target,omitempty"`
+}
+
+type DRSRecommendationInfo struct {
+	Key string `json:"key"`
+	// Rating goes from 1 to 5, 5 are the recommendations vCenter shows with priority 1
+	Rating  int32           `json:"rating"`
+	Reason  string          `json:"reason"`
+	Warning string          `json:"warning,omitempty"`
+	Time    time.Time       `json:"time"`
+	Actions []DRSActionInfo `json:"actions"`
+}
+
+type ClusterDRSRecommendationsInfo struct {
+	Name string `json:"name"`
+	// AutomationLevel is the DRS automation level, recommendations wait for approval with
+	// manual and partiallyAutomated
+	AutomationLevel string                  `json:"automation_level,omitempty"`
+	Recommendations []DRSRecommendationInfo `json:"recommendations"`
+}
+
+type DRSRecommendationsReport struct {
+	Datacenter string                          `json:"datacenter"`
+	Clusters   []ClusterDRSRecommendationsInfo `json:"clusters"`
+}
+
+// reportDRSRecommendations lists the pending DRS recommendations of every cluster with the VMs
+// they move and the source and target hosts, the highest rated first, so clusters that
+// aren't fully automated can be reviewed without the vSphere Client
+func reportDRSRecommendations(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := DRSRecommendationsReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterDRSRecommendationsInfo, 0, len(clusters)),
+	}
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"configurationEx", "recommendation", "host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting recommendations of cluster %s: %s", cluster.Name(), err)
+		}
+
+		info := ClusterDRSRecommendationsInfo{
+			Name:            cluster.Name(),
+			Recommendations: make([]DRSRecommendationInfo, 0, len(clusterMo.Recommendation)),
+		}
+		if config, ok := clusterMo.ConfigurationEx.(*types.ClusterConfigInfoEx); ok && config.DrsConfig.Enabled != nil && *config.DrsConfig.Enabled {
+			info.AutomationLevel = string(config.DrsConfig.DefaultVmBehavior)
+		}
+
+		if len(clusterMo.Recommendation) > 0 {
+			names, err := drsObjectNames(ctx, client, pc, cluster, clusterMo.Host)
+			if err != nil {
+				return fmt.Errorf("getting inventory of cluster %s: %s", cluster.Name(), err)
+			}
+			name := func(ref *types.ManagedObjectReference) string {
+				if ref == nil {
+					return ""
+				}
+				if name, ok := names[ref.Value]; ok {
+					return name
+				}
+				return ref.Value
+			}
+
+			for _, r := range clusterMo.Recommendation {
+				rec := DRSRecommendationInfo{
+					Key:     r.Key,
+					Rating:  r.Rating,
+					Reason:  r.ReasonText,
+					Warning: r.WarningText,
+					Time:    r.Time,
+					Actions: make([]DRSActionInfo, 0, len(r.Action)),
+				}
+				if rec.Reason == "" {
+					rec.Reason = r.Reason
+				}
+
+				for _, a := range r.Action {
+					base := a.GetClusterAction()
+					action := DRSActionInfo{Type: base.Type}
+					switch a := a.(type) {
+					case *types.ClusterMigrationAction:
+						action.VM = name(base.Target)
+						if m := a.DrsMigration; m != nil {
+							action.VM = name(&m.Vm)
+							action.Source = name(&m.Source)
+							action.Destination = name(&m.Destination)
+						}
+					case *types.PlacementAction:
+						action.VM = name(a.Vm)
+						action.Destination = name(a.TargetHost)
+					default:
+						action.Target = name(base.Target)
+					}
+					rec.Actions = append(rec.Actions, action)
+				}
+
+				info.Recommendations = append(info.Recommendations, rec)
+			}
+		}
+		sort.Slice(info.Recommendations, func(i, j int) bool {
+			a, b := info.Recommendations[i], info.Recommendations[j]
+			if a.Rating != b.Rating {
+				return a.Rating > b.Rating
+			}
+			return a.Time.Before(b.Time)
+		})
+
+		report.Clusters = append(report.Clusters, info)
+	}
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		return report.Clusters[i].Name < report.Clusters[j].Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, cluster := range report.Clusters {
+		drs := "DRS disabled"
+		if cluster.AutomationLevel != "" {
+			drs = "DRS " + cluster.AutomationLevel
+		}
+		header := fmt.Sprintf("Cluster: %s (%s)", cluster.Name, drs)
+		fmt.Printf("\n%s\n", header)
+		fmt.Println(strings.Repeat("-", len(header)))
+		if len(cluster.Recommendations) == 0 {
+			fmt.Println("  No pending recommendations")
+		}
+		for _, rec := range cluster.Recommendations {
+			fmt.Printf("  Priority %d: %s (%s)\n", 6-rec.Rating, rec.Reason, rec.Time.Local().Format("2006-01-02 15:04"))
+			for _, a := range rec.Actions {
+				switch {
+				case a.VM != "" && a.Source != "":
+					fmt.Printf("    Migrate %s from %s to %s\n", a.VM, a.Source, valueOrNone(a.Destination))
+				case a.VM != "":
+					fmt.Printf("    %s %s on %s\n", a.Type, a.VM, valueOrNone(a.Destination))
+				default:
+					fmt.Printf("    %s %s\n", a.Type, valueOrNone(a.Target))
+				}
+			}
+			if rec.Warning != "" {
+				fmt.Printf("    WARNING: %s\n", rec.Warning)
+			}
+		}
+	}
+
+	return nil
+}
+
+// drsObjectNames returns the names of the hosts and VMs of a cluster by reference
+func drsObjectNames(ctx context.Context, client *govmomi.Client, pc *property.Collector, cluster *object.ClusterComputeResource, hostRefs []types.ManagedObjectReference) (map[string]string, error) {
+	names := make(map[string]string)
+	if len(hostRefs) > 0 {
+		var hosts []mo.HostSystem
+		if err := pc.Retrieve(ctx, hostRefs, []string{"name"}, &hosts); err != nil {
+			return nil, err
+		}
+		for _, host := range hosts {
+			names[host.Self.Value] = host.Name
+		}
+	}
+
+	var vms []mo.VirtualMachine
+	if err := retrieveClusterVMs(ctx, client, cluster, []string{"name"}, &vms); err != nil {
+		return nil, err
+	}
+	for _, vm := range vms {
+		names[vm.Self.Value] = vm.Name
+	}
+	return names, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// DRS rule types
+const (
+	drsRuleAffinity           = "vm-affinity"
+	drsRuleAntiAffinity       = "vm-anti-affinity"
+	drsRuleVMHostAffinity     = "vm-host-affinity"
+	drsRuleVMHostAntiAffinity = "vm-host-anti-affinity"
+	drsRuleDependency         = "vm-dependency"
+)
+
+type DRSGroupInfo struct {
+	Name string `json:"name"`
+	// Type is vm or host
+	Type    string   `json:"type"`
+	Members []string `json:"members"`
+}
+
+type DRSRuleInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+	// Mandatory rules must be followed, the others should be
+	Mandatory bool `json:"mandatory"`
+	// VMs are the members of VM affinity and anti-affinity rules
+	VMs []string `json:"vms,omitempty"`
+	// VMGroup, HostGroup and DependsOn are the groups of VM-Host and dependency rules
+	VMGroup   string `json:"vm_group,omitempty"`
+	HostGroup string `json:"host_group,omitempty"`
+	DependsOn string `json:"depends_on,omitempty"`
+}
+
+type ClusterDRSRulesInfo struct {
+	Name   string         `json:"name"`
+	Rules  []DRSRuleInfo  `json:"rules"`
+	Groups []DRSGroupInfo `json:"groups"`
+	// Warnings flag rules and groups referencing VMs, hosts or groups that are not in the
+	// cluster anymore
+	Warnings []string `json:"warnings"`
+}
+
+type DRSRulesReport struct {
+	Datacenter string                `json:"datacenter"`
+	Clusters   []ClusterDRSRulesInfo `json:"clusters"`
+}
+
+// reportDRSRules lists the DRS affinity and anti-affinity rules and the VM and host groups
+// of every cluster, flagging rules and groups that reference VMs or hosts which were removed
+// or moved out of the cluster, and rules referencing groups that don't exist
+func reportDRSRules(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := DRSRulesReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterDRSRulesInfo, 0, len(clusters)),
+	}
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"configurationEx", "host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting configuration of cluster %s: %s", cluster.Name(), err)
+		}
+
+		// the names of the hosts and VMs in the cluster, references to others are stale
+		names := make(map[string]string)
+		var hosts []mo.HostSystem
+		if len(clusterMo.Host) > 0 {
+			err = pc.Retrieve(ctx, clusterMo.Host, []string{"name"}, &hosts)
+			if err != nil {
+				return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+			}
+		}
+		for _, host := range hosts {
+			names[host.Self.Value] = host.Name
+		}
+		var vms []mo.VirtualMachine
+		err = retrieveClusterVMs(ctx, client, cluster, []string{"name"}, &vms)
+		if err != nil {
+			return fmt.Errorf("getting VMs of cluster %s: %s", cluster.Name(), err)
+		}
+		for _, vm := range vms {
+			names[vm.Self.Value] = vm.Name
+		}
+
+		info := ClusterDRSRulesInfo{
+			Name:     cluster.Name(),
+			Rules:    make([]DRSRuleInfo, 0),
+			Groups:   make([]DRSGroupInfo, 0),
+			Warnings: make([]string, 0),
+		}
+
+		members := func(kind, owner string, refs []types.ManagedObjectReference) []string {
+			list := make([]string, 0, len(refs))
+			for _, ref := range refs {
+				name, ok := names[ref.Value]
+				if !ok {
+					info.Warnings = append(info.Warnings, fmt.Sprintf("%s references %s %s which is not in the cluster", owner, kind, ref.Value))
+					name = ref.Value
+				}
+				list = append(list, name)
+			}
+			sort.Strings(list)
+			return list
+		}
+
+		config, ok := clusterMo.ConfigurationEx.(*types.ClusterConfigInfoEx)
+		if !ok {
+			report.Clusters = append(report.Clusters, info)
+			continue
+		}
+
+		groups := make(map[string]bool)
+		for _, g := range config.Group {
+			var group DRSGroupInfo
+			switch g := g.(type) {
+			case *types.ClusterVmGroup:
+				group = DRSGroupInfo{Name: g.Name, Type: "vm", Members: members("VM", "group "+g.Name, g.Vm)}
+			case *types.ClusterHostGroup:
+				group = DRSGroupInfo{Name: g.Name, Type: "host", Members: members("host", "group "+g.Name, g.Host)}
+			default:
+				continue
+			}
+			groups[group.Name] = true
+			info.Groups = append(info.Groups, group)
+		}
+
+		for _, r := range config.Rule {
+			base := r.GetClusterRuleInfo()
+			rule := DRSRuleInfo{Name: base.Name}
+			if base.Enabled != nil {
+				rule.Enabled = *base.Enabled
+			}
+			if base.Mandatory != nil {
+				rule.Mandatory = *base.Mandatory
+			}
+
+			var referenced []string
+			switch r := r.(type) {
+			case *types.ClusterAffinityRuleSpec:
+				rule.Type = drsRuleAffinity
+				rule.VMs = members("VM", "rule "+rule.Name, r.Vm)
+			case *types.ClusterAntiAffinityRuleSpec:
+				rule.Type = drsRuleAntiAffinity
+				rule.VMs = members("VM", "rule "+rule.Name, r.Vm)
+			case *types.ClusterVmHostRuleInfo:
+				rule.Type = drsRuleVMHostAffinity
+				rule.HostGroup = r.AffineHostGroupName
+				if r.AntiAffineHostGroupName != "" {
+					rule.Type = drsRuleVMHostAntiAffinity
+					rule.HostGroup = r.AntiAffineHostGroupName
+				}
+				rule.VMGroup = r.VmGroupName
+				referenced = []string{rule.VMGroup, rule.HostGroup}
+			case *types.ClusterDependencyRuleInfo:
+				rule.Type = drsRuleDependency
+				rule.VMGroup = r.VmGroup
+				rule.DependsOn = r.DependsOnVmGroup
+				referenced = []string{rule.VMGroup, rule.DependsOn}
+			default:
+				continue
+			}
+			for _, group := range referenced {
+				if !groups[group] {
+					info.Warnings = append(info.Warnings, fmt.Sprintf("rule %s references group %s which doesn't exist", rule.Name, group))
+				}
+			}
+
+			info.Rules = append(info.Rules, rule)
+		}
+		sort.Slice(info.Rules, func(i, j int) bool {
+			return info.Rules[i].Name < info.Rules[j].Name
+		})
+		sort.Slice(info.Groups, func(i, j int) bool {
+			return info.Groups[i].Name < info.Groups[j].Name
+		})
+		sort.Strings(info.Warnings)
+
+		report.Clusters = append(report.Clusters, info)
+	}
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		return report.Clusters[i].Name < report.Clusters[j].Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", cluster.Name)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		if len(cluster.Rules) == 0 && len(cluster.Groups) == 0 {
+			fmt.Println("  No DRS rules or groups")
+		}
+		for _, rule := range cluster.Rules {
+			state := "enabled"
+			if !rule.Enabled {
+				state = "disabled"
+			}
+			strength := "should"
+			if rule.Mandatory {
+				strength = "must"
+			}
+			fmt.Printf("  Rule %s (%s, %s, %s)\n", rule.Name, rule.Type, strength, state)
+			switch rule.Type {
+			case drsRuleAffinity, drsRuleAntiAffinity:
+				fmt.Printf("    VMs: %s\n", valueOrNone(strings.Join(rule.VMs, ", ")))
+			case drsRuleVMHostAffinity:
+				fmt.Printf("    VMs of %s %s run on hosts of %s\n", rule.VMGroup, strength, rule.HostGroup)
+			case drsRuleVMHostAntiAffinity:
+				fmt.Printf("    VMs of %s %s not run on hosts of %s\n", rule.VMGroup, strength, rule.HostGroup)
+			case drsRuleDependency:
+				fmt.Printf("    VMs of %s start after the VMs of %s\n", rule.VMGroup, rule.DependsOn)
+			}
+		}
+		for _, group := range cluster.Groups {
+			fmt.Printf("  Group %s (%s): %s\n", group.Name, group.Type, valueOrNone(strings.Join(group.Members, ", ")))
+		}
+		for _, warning := range cluster.Warnings {
+			fmt.Printf("  WARNING: %s\n", warning)
+		}
+	}
+
+	return nil
+}
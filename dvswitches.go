@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type DVPortgroupInfo struct {
+	Name string `json:"name"`
+	// VLAN is the VLAN ID, trunk ranges or private VLAN of the portgroup, "none" when untagged
+	VLAN string `json:"vlan"`
+}
+
+type DVSwitchInfo struct {
+	Name       string            `json:"name"`
+	MTU        int32             `json:"mtu"`
+	Uplinks    []string          `json:"uplinks"`
+	HostCount  int               `json:"host_count"`
+	Clusters   []string          `json:"clusters"`
+	Portgroups []DVPortgroupInfo `json:"portgroups"`
+}
+
+// VLANMismatchInfo is a portgroup name configured with different VLANs on the switches
+// serving a cluster
+type VLANMismatchInfo struct {
+	Cluster   string `json:"cluster"`
+	Portgroup string `json:"portgroup"`
+	// VLANs holds the VLAN configuration per switch, standard switches are named host/vSwitch
+	VLANs map[string]string `json:"vlans"`
+}
+
+type DVSwitchReport struct {
+	Datacenter string             `json:"datacenter"`
+	Switches   []DVSwitchInfo     `json:"switches"`
+	Mismatches []VLANMismatchInfo `json:"vlan_mismatches"`
+}
+
+// reportDistributedSwitches shows the uplinks, MTU and portgroup VLANs of every distributed
+// switch and flags portgroups that have different VLANs on the distributed and standard
+// switches serving the same cluster
+func reportDistributedSwitches(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	var switches []mo.DistributedVirtualSwitch
+	err := retrieveAll(ctx, client, dc, "DistributedVirtualSwitch", []string{"name", "config", "portgroup"}, &switches)
+	if err != nil {
+		return fmt.Errorf("retrieving distributed switches: %s", err)
+	}
+
+	var portgroups []mo.DistributedVirtualPortgroup
+	err = retrieveAll(ctx, client, dc, "DistributedVirtualPortgroup", []string{"name", "config"}, &portgroups)
+	if err != nil {
+		return fmt.Errorf("retrieving distributed portgroups: %s", err)
+	}
+	byRef := make(map[string]mo.DistributedVirtualPortgroup, len(portgroups))
+	for _, pg := range portgroups {
+		byRef[pg.Self.Value] = pg
+	}
+
+	var clusters []mo.ClusterComputeResource
+	err = retrieveAll(ctx, client, dc, "ClusterComputeResource", []string{"name", "network", "host"}, &clusters)
+	if err != nil {
+		return fmt.Errorf("retrieving clusters: %s", err)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+
+	// the clusters using each portgroup
+	portgroupClusters := make(map[string][]string)
+	for _, cluster := range clusters {
+		if !cfg.clusterSelected(cluster.Name) {
+			continue
+		}
+		for _, ref := range cluster.Network {
+			portgroupClusters[ref.Value] = append(portgroupClusters[ref.Value], cluster.Name)
+		}
+	}
+
+	report := DVSwitchReport{
+		Datacenter: dc.Name(),
+		Switches:   make([]DVSwitchInfo, 0, len(switches)),
+		Mismatches: make([]VLANMismatchInfo, 0),
+	}
+
+	// VLANs per cluster, portgroup name and switch
+	vlans := make(map[string]map[string]map[string]string)
+	addVLAN := func(cluster, portgroup, sw, vlan string) {
+		if vlans[cluster] == nil {
+			vlans[cluster] = make(map[string]map[string]string)
+		}
+		if vlans[cluster][portgroup] == nil {
+			vlans[cluster][portgroup] = make(map[string]string)
+		}
+		vlans[cluster][portgroup][sw] = vlan
+	}
+
+	// distributed portgroup names are unique within a datacenter, the standard switches of
+	// the hosts are compared as well to catch portgroups that differ between hosts or
+	// between a standard and a distributed switch
+	var hosts []mo.HostSystem
+	err = retrieveAll(ctx, client, dc, "HostSystem", []string{"name", "config.network.portgroup"}, &hosts)
+	if err != nil {
+		return fmt.Errorf("retrieving hosts: %s", err)
+	}
+	hostsByRef := make(map[string]mo.HostSystem, len(hosts))
+	for _, host := range hosts {
+		hostsByRef[host.Self.Value] = host
+	}
+	for _, cluster := range clusters {
+		if !cfg.clusterSelected(cluster.Name) {
+			continue
+		}
+		for _, ref := range cluster.Host {
+			host, ok := hostsByRef[ref.Value]
+			if !ok || host.Config == nil || host.Config.Network == nil {
+				continue
+			}
+			for _, pg := range host.Config.Network.Portgroup {
+				addVLAN(cluster.Name, pg.Spec.Name, host.Name+"/"+pg.Spec.VswitchName, standardPortgroupVLAN(pg.Spec.VlanId))
+			}
+		}
+	}
+
+	sort.Slice(switches, func(i, j int) bool {
+		return switches[i].Name < switches[j].Name
+	})
+	for _, dvs := range switches {
+		info := DVSwitchInfo{
+			Name:       dvs.Name,
+			Uplinks:    make([]string, 0),
+			Clusters:   make([]string, 0),
+			Portgroups: make([]DVPortgroupInfo, 0),
+		}
+
+		uplinkPortgroups := make(map[string]bool)
+		if dvs.Config != nil {
+			config := dvs.Config.GetDVSConfigInfo()
+			info.HostCount = len(config.Host)
+			for _, ref := range config.UplinkPortgroup {
+				uplinkPortgroups[ref.Value] = true
+			}
+			if policy, ok := config.UplinkPortPolicy.(*types.DVSNameArrayUplinkPortPolicy); ok {
+				info.Uplinks = append(info.Uplinks, policy.UplinkPortName...)
+			}
+			if vmware, ok := dvs.Config.(*types.VMwareDVSConfigInfo); ok {
+				info.MTU = vmware.MaxMtu
+			}
+		}
+
+		served := make(map[string]bool)
+		for _, ref := range dvs.Portgroup {
+			pg, ok := byRef[ref.Value]
+			if !ok || uplinkPortgroups[ref.Value] || (pg.Config.Uplink != nil && *pg.Config.Uplink) {
+				continue
+			}
+
+			pgInfo := DVPortgroupInfo{Name: pg.Name, VLAN: portgroupVLAN(pg.Config.DefaultPortConfig)}
+			info.Portgroups = append(info.Portgroups, pgInfo)
+
+			for _, cluster := range portgroupClusters[ref.Value] {
+				served[cluster] = true
+				addVLAN(cluster, pg.Name, dvs.Name, pgInfo.VLAN)
+			}
+		}
+		sort.Slice(info.Portgroups, func(i, j int) bool {
+			return info.Portgroups[i].Name < info.Portgroups[j].Name
+		})
+		for cluster := range served {
+			info.Clusters = append(info.Clusters, cluster)
+		}
+		sort.Strings(info.Clusters)
+		if len(cfg.Clusters) > 0 && len(info.Clusters) == 0 {
+			continue
+		}
+
+		report.Switches = append(report.Switches, info)
+	}
+
+	for _, cluster := range clusters {
+		names := make([]string, 0, len(vlans[cluster.Name]))
+		for name := range vlans[cluster.Name] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			perSwitch := vlans[cluster.Name][name]
+			distinct := make(map[string]bool)
+			for _, vlan := range perSwitch {
+				distinct[vlan] = true
+			}
+			if len(distinct) > 1 {
+				report.Mismatches = append(report.Mismatches, VLANMismatchInfo{Cluster: cluster.Name, Portgroup: name, VLANs: perSwitch})
+			}
+		}
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, info := range report.Switches {
+		fmt.Printf("\nDistributed Switch: %s\n", info.Name)
+		fmt.Println(strings.Repeat("-", len(info.Name)+20))
+		fmt.Printf("  MTU: %d\n", info.MTU)
+		fmt.Printf("  Uplinks: %s\n", valueOrNone(strings.Join(info.Uplinks, ", ")))
+		fmt.Printf("  Hosts: %d\n", info.HostCount)
+		fmt.Printf("  Clusters: %s\n", valueOrNone(strings.Join(info.Clusters, ", ")))
+		fmt.Println("  Portgroups:")
+		if len(info.Portgroups) == 0 {
+			fmt.Println("    (none)")
+		}
+		for _, pg := range info.Portgroups {
+			fmt.Printf("    %s: %s\n", pg.Name, pg.VLAN)
+		}
+	}
+
+	fmt.Printf("\nPortgroups with different VLANs on switches serving the same cluster: %d\n", len(report.Mismatches))
+	for _, m := range report.Mismatches {
+		var perSwitch []string
+		for dvs, vlan := range m.VLANs {
+			perSwitch = append(perSwitch, fmt.Sprintf("%s on %s", vlan, dvs))
+		}
+		sort.Strings(perSwitch)
+		fmt.Printf("  WARNING: %s in cluster %s: %s\n", m.Portgroup, m.Cluster, strings.Join(perSwitch, ", "))
+	}
+
+	return nil
+}
+
+// portgroupVLAN formats the VLAN setting of a distributed portgroup
+func portgroupVLAN(setting types.BaseDVPortSetting) string {
+	vmware, ok := setting.(*types.VMwareDVSPortSetting)
+	if !ok || vmware.Vlan == nil {
+		return "none"
+	}
+
+	switch vlan := vmware.Vlan.(type) {
+	case *types.VmwareDistributedVirtualSwitchVlanIdSpec:
+		if vlan.VlanId == 0 {
+			return "none"
+		}
+		return fmt.Sprintf("VLAN %d", vlan.VlanId)
+	case *types.VmwareDistributedVirtualSwitchTrunkVlanSpec:
+		ranges := make([]string, 0, len(vlan.VlanId))
+		for _, r := range vlan.VlanId {
+			if r.Start == r.End {
+				ranges = append(ranges, fmt.Sprint(r.Start))
+			} else {
+				ranges = append(ranges, fmt.Sprintf("%d-%d", r.Start, r.End))
+			}
+		}
+		return "trunk " + strings.Join(ranges, ",")
+	case *types.VmwareDistributedVirtualSwitchPvlanSpec:
+		return fmt.Sprintf("private VLAN %d", vlan.PvlanId)
+	}
+	return "none"
+}
+
+// standardPortgroupVLAN formats the VLAN ID of a standard switch portgroup like portgroupVLAN,
+// 4095 passes all VLANs
+func standardPortgroupVLAN(id int32) string {
+	switch id {
+	case 0:
+		return "none"
+	case 4095:
+		return "trunk 0-4094"
+	}
+	return fmt.Sprintf("VLAN %d", id)
+}
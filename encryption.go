@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type KeyProviderInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default bool   `json:"default"`
+}
+
+type ClusterEncryptionInfo struct {
+	Name             string         `json:"name"`
+	KeyProvider      string         `json:"key_provider"`
+	HostCryptoStates map[string]int `json:"host_crypto_states"`
+}
+
+type DatastoreEncryptionInfo struct {
+	Name         string `json:"name"`
+	EncryptedVMs int    `json:"encrypted_vms"`
+}
+
+type EncryptedVMInfo struct {
+	Name           string   `json:"name"`
+	EncryptedHome  bool     `json:"encrypted_home"`
+	EncryptedDisks []string `json:"encrypted_disks"`
+	VTPM           bool     `json:"vtpm"`
+	Datastores     []string `json:"datastores"`
+}
+
+type EncryptionReport struct {
+	Datacenter       string                    `json:"datacenter"`
+	KeyProviders     []KeyProviderInfo         `json:"key_providers"`
+	KeyProviderError string                    `json:"key_provider_error,omitempty"`
+	Clusters         []ClusterEncryptionInfo   `json:"clusters"`
+	Datastores       []DatastoreEncryptionInfo `json:"datastores"`
+	VMs              []EncryptedVMInfo         `json:"vms"`
+}
+
+// reportEncryption shows the configured key providers, which VMs have encrypted
+// disks or a vTPM and which datastores host encrypted VMs
+func reportEncryption(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	report := EncryptionReport{
+		Datacenter:   dc.Name(),
+		KeyProviders: make([]KeyProviderInfo, 0),
+		Clusters:     make([]ClusterEncryptionInfo, 0),
+		Datastores:   make([]DatastoreEncryptionInfo, 0),
+		VMs:          make([]EncryptedVMInfo, 0),
+	}
+
+	// Key providers are configured vCenter wide, they can be the default for specific clusters
+	var providers []types.KmipClusterInfo
+	if ref := client.ServiceContent.CryptoManager; ref != nil {
+		res, err := methods.ListKmipServers(ctx, client.Client, &types.ListKmipServers{This: *ref})
+		if err != nil {
+			// keep reporting VM encryption even if the key providers can't be listed
+			report.KeyProviderError = err.Error()
+		} else {
+			providers = res.Returnval
+		}
+	}
+
+	defaultProvider := ""
+	entityProvider := make(map[string]string)
+	for _, p := range providers {
+		report.KeyProviders = append(report.KeyProviders, KeyProviderInfo{
+			Name:    p.ClusterId.Id,
+			Type:    p.ManagementType,
+			Default: p.UseAsDefault,
+		})
+		if p.UseAsDefault {
+			defaultProvider = p.ClusterId.Id
+		}
+		for _, ref := range p.UseAsEntityDefault {
+			entityProvider[ref.Value] = p.ClusterId.Id
+		}
+	}
+
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+	for _, cluster := range clusters {
+		info := ClusterEncryptionInfo{
+			Name:             cluster.Name(),
+			KeyProvider:      defaultProvider,
+			HostCryptoStates: make(map[string]int),
+		}
+		if provider, ok := entityProvider[cluster.Reference().Value]; ok {
+			info.KeyProvider = provider
+		}
+
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+		if len(clusterMo.Host) > 0 {
+			var hosts []mo.HostSystem
+			err = pc.Retrieve(ctx, clusterMo.Host, []string{"runtime.cryptoState"}, &hosts)
+			if err != nil {
+				return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+			}
+			for _, host := range hosts {
+				state := host.Runtime.CryptoState
+				if state == "" {
+					state = "unknown"
+				}
+				info.HostCryptoStates[state]++
+			}
+		}
+
+		report.Clusters = append(report.Clusters, info)
+	}
+
+	dsNames, err := datastoreNames(ctx, client, dc)
+	if err != nil {
+		return fmt.Errorf("retrieving datastores: %s", err)
+	}
+
+	var vms []mo.VirtualMachine
+	err = retrieveAll(ctx, client, dc, "VirtualMachine", []string{"name", "config.keyId", "config.hardware.device", "datastore"}, &vms)
+	if err != nil {
+		return fmt.Errorf("retrieving VMs: %s", err)
+	}
+
+	encryptedPerDatastore := make(map[string]int)
+	for _, vm := range vms {
+		if vm.Config == nil {
+			continue
+		}
+
+		info := EncryptedVMInfo{
+			Name:           vm.Name,
+			EncryptedHome:  vm.Config.KeyId != nil,
+			EncryptedDisks: make([]string, 0),
+			Datastores:     make([]string, 0, len(vm.Datastore)),
+		}
+
+		for _, device := range vm.Config.Hardware.Device {
+			switch d := device.(type) {
+			case *types.VirtualTPM:
+				info.VTPM = true
+			case *types.VirtualDisk:
+				if diskKeyID(d.Backing) != nil {
+					label := fmt.Sprintf("disk-%d", d.Key)
+					if di := d.GetVirtualDevice().DeviceInfo; di != nil {
+						label = di.GetDescription().Label
+					}
+					info.EncryptedDisks = append(info.EncryptedDisks, label)
+				}
+			}
+		}
+
+		if !info.EncryptedHome && len(info.EncryptedDisks) == 0 && !info.VTPM {
+			continue
+		}
+
+		for _, ref := range vm.Datastore {
+			name := lookupName(dsNames, ref)
+			info.Datastores = append(info.Datastores, name)
+			if info.EncryptedHome || len(info.EncryptedDisks) > 0 {
+				encryptedPerDatastore[name]++
+			}
+		}
+
+		report.VMs = append(report.VMs, info)
+	}
+
+	for name, count := range encryptedPerDatastore {
+		report.Datastores = append(report.Datastores, DatastoreEncryptionInfo{Name: name, EncryptedVMs: count})
+	}
+	sort.Slice(report.Datastores, func(i, j int) bool {
+		return report.Datastores[i].Name < report.Datastores[j].Name
+	})
+	sort.Slice(report.VMs, func(i, j int) bool {
+		return report.VMs[i].Name < report.VMs[j].Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Println("\nKey providers:")
+	if report.KeyProviderError != "" {
+		fmt.Printf("  Error listing key providers: %s\n", report.KeyProviderError)
+	} else if len(report.KeyProviders) == 0 {
+		fmt.Println("  No key provider configured")
+	}
+	for _, p := range report.KeyProviders {
+		def := ""
+		if p.Default {
+			def = ", default"
+		}
+		fmt.Printf("  - %s (%s%s)\n", p.Name, valueOrNone(p.Type), def)
+	}
+
+	fmt.Println("\nClusters:")
+	for _, c := range report.Clusters {
+		states := make([]string, 0, len(c.HostCryptoStates))
+		for state, count := range c.HostCryptoStates {
+			states = append(states, fmt.Sprintf("%d %s", count, state))
+		}
+		sort.Strings(states)
+		fmt.Printf("  - %s (key provider: %s, hosts: %s)\n", c.Name, valueOrNone(c.KeyProvider), strings.Join(states, ", "))
+	}
+
+	fmt.Println("\nDatastores hosting encrypted VMs:")
+	if len(report.Datastores) == 0 {
+		fmt.Println("  None")
+	}
+	for _, ds := range report.Datastores {
+		fmt.Printf("  - %s (%d encrypted VMs)\n", ds.Name, ds.EncryptedVMs)
+	}
+
+	fmt.Println("\nEncrypted VMs and VMs with vTPM:")
+	if len(report.VMs) == 0 {
+		fmt.Println("  None")
+	}
+	for _, vm := range report.VMs {
+		var features []string
+		if vm.EncryptedHome {
+			features = append(features, "encrypted home")
+		}
+		if len(vm.EncryptedDisks) > 0 {
+			features = append(features, "encrypted disks: "+strings.Join(vm.EncryptedDisks, ", "))
+		}
+		if vm.VTPM {
+			features = append(features, "vTPM")
+		}
+		fmt.Printf("  - %s (%s)\n", vm.Name, strings.Join(features, "; "))
+	}
+
+	return nil
+}
+
+// diskKeyID returns the encryption key of a virtual disk backing, nil for unencrypted disks
+func diskKeyID(backing types.BaseVirtualDeviceBackingInfo) *types.CryptoKeyId {
+	switch b := backing.(type) {
+	case *types.VirtualDiskFlatVer2BackingInfo:
+		return b.KeyId
+	case *types.VirtualDiskSeSparseBackingInfo:
+		return b.KeyId
+	case *types.VirtualDiskSparseVer2BackingInfo:
+		return b.KeyId
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Codes of the JSON error object
+const (
+	errorUsage      = "usage"
+	errorConfig     = "config"
+	errorConnect    = "connect"
+	errorDatacenter = "datacenter"
+	errorUpload     = "upload"
+	errorCommand    = "command"
+)
+
+type ErrorInfo struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+type ErrorOutput struct {
+	Error ErrorInfo `json:"error"`
+}
+
+// reportPrinted is set once a JSON report has been written to stdout
+var reportPrinted bool
+
+// exitCleanups run when godcinfo exits with exit, as os.Exit skips the deferred calls
+var exitCleanups []func()
+
+func exit(code int) {
+	for i := len(exitCleanups) - 1; i >= 0; i-- {
+		exitCleanups[i]()
+	}
+	os.Exit(code)
+}
+
+// fatalf prints an error and exits. With -o json the error is printed as a JSON error object
+// on stdout, unless a report was already printed there, then it goes to stderr.
+func fatalf(cfg *Config, code, hint, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	switch {
+	case cfg.OutputJSON && !reportPrinted:
+		printJSON(ErrorOutput{Error: ErrorInfo{Code: code, Message: message, Hint: hint}})
+	case reportPrinted:
+		fmt.Fprintln(os.Stderr, message)
+	default:
+		fmt.Println(message)
+	}
+	exit(1)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+)
+
+// extensionDialTimeout limits the connection test to each extension server
+const extensionDialTimeout = 5 * time.Second
+
+// extension health
+const (
+	extensionReachable   = "reachable"
+	extensionUnreachable = "unreachable"
+	// extensionNoServers is the health of extensions registering no HTTP(S) server, like
+	// the built-in vCenter services
+	extensionNoServers = "no_servers"
+)
+
+type ExtensionServerInfo struct {
+	URL   string `json:"url"`
+	Type  string `json:"type"`
+	Error string `json:"error,omitempty"`
+}
+
+type ExtensionInfo struct {
+	Key     string `json:"key"`
+	Label   string `json:"label"`
+	Company string `json:"company,omitempty"`
+	Version string `json:"version"`
+	Type    string `json:"type,omitempty"`
+	// LastHeartbeat is only set by extensions reporting their liveness to vCenter
+	LastHeartbeat *time.Time            `json:"last_heartbeat,omitempty"`
+	Servers       []ExtensionServerInfo `json:"servers"`
+	// Health tells whether the HTTP(S) servers of the extension accept connections from
+	// this host
+	Health string `json:"health"`
+}
+
+type ExtensionsReport struct {
+	Extensions []ExtensionInfo `json:"extensions"`
+}
+
+// reportExtensions lists the extensions registered with vCenter, like backup appliances,
+// storage plugins and NSX, with their versions and whether their servers are reachable, so
+// they can be checked for compatibility before an upgrade
+func reportExtensions(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	m, err := object.GetExtensionManager(client.Client)
+	if err != nil {
+		return fmt.Errorf("getting the extension manager: %s", err)
+	}
+
+	extensions, err := m.List(ctx)
+	if err != nil {
+		return fmt.Errorf("listing extensions: %s", err)
+	}
+
+	report := ExtensionsReport{
+		Extensions: make([]ExtensionInfo, 0, len(extensions)),
+	}
+
+	for _, ext := range extensions {
+		info := ExtensionInfo{
+			Key:     ext.Key,
+			Label:   ext.Key,
+			Company: ext.Company,
+			Version: ext.Version,
+			Type:    ext.Type,
+			Servers: make([]ExtensionServerInfo, 0, len(ext.Server)),
+			Health:  extensionNoServers,
+		}
+		if ext.Description != nil {
+			if label := ext.Description.GetDescription().Label; label != "" {
+				info.Label = label
+			}
+		}
+		if !ext.LastHeartbeatTime.IsZero() {
+			heartbeat := ext.LastHeartbeatTime
+			info.LastHeartbeat = &heartbeat
+		}
+
+		for _, server := range ext.Server {
+			serverInfo := ExtensionServerInfo{URL: server.Url, Type: server.Type}
+			u, err := url.Parse(server.Url)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				info.Servers = append(info.Servers, serverInfo)
+				continue
+			}
+
+			if err := dialExtensionServer(ctx, u); err != nil {
+				serverInfo.Error = err.Error()
+				info.Health = extensionUnreachable
+			} else if info.Health == extensionNoServers {
+				info.Health = extensionReachable
+			}
+			info.Servers = append(info.Servers, serverInfo)
+		}
+
+		report.Extensions = append(report.Extensions, info)
+	}
+	sort.Slice(report.Extensions, func(i, j int) bool {
+		return strings.ToLower(report.Extensions[i].Label) < strings.ToLower(report.Extensions[j].Label)
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	var unreachable int
+	fmt.Printf("\nExtensions: %d\n", len(report.Extensions))
+	for _, ext := range report.Extensions {
+		fmt.Printf("\n  %s (%s)\n", ext.Label, ext.Key)
+		fmt.Printf("    Company: %s, Version: %s\n", valueOrNone(ext.Company), valueOrNone(ext.Version))
+		if ext.LastHeartbeat != nil {
+			fmt.Printf("    Last heartbeat: %s\n", ext.LastHeartbeat.Local().Format("2006-01-02 15:04"))
+		}
+		for _, server := range ext.Servers {
+			fmt.Printf("    Server: %s (%s)\n", server.URL, valueOrNone(server.Type))
+			if server.Error != "" {
+				fmt.Printf("    WARNING: server unreachable: %s\n", server.Error)
+			}
+		}
+		if ext.Health == extensionUnreachable {
+			unreachable++
+		}
+	}
+
+	fmt.Printf("\nExtensions with unreachable servers: %d\n", unreachable)
+
+	return nil
+}
+
+// dialExtensionServer tests whether an extension server accepts TCP connections
+func dialExtensionServer(ctx context.Context, u *url.URL) error {
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, extensionDialTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// includeDatastore reports whether a datastore passes the datastore filters given on
+// the command line. Filters only affect which datastores are listed, rollups and
+// totals always cover all datastores.
+func includeDatastore(cfg *Config, info DatastoreInfo) bool {
+	if info.UsedPct < cfg.MinUsedPct {
+		return false
+	}
+
+	if 100-info.UsedPct > cfg.MaxFreePct {
+		return false
+	}
+
+	if info.Capacity < cfg.MinCapacityGB {
+		return false
+	}
+
+	return true
+}
+
+// matchesAny reports whether a name matches one of the names or glob patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded reports whether a cluster, datastore cluster or datastore name matches -exclude
+func (cfg *Config) excluded(name string) bool {
+	return matchesAny(cfg.Exclude, name)
+}
+
+// clusterSelected reports whether a compute cluster is collected, all but the excluded
+// ones are without -cluster
+func (cfg *Config) clusterSelected(name string) bool {
+	return (len(cfg.Clusters) == 0 || matchesAny(cfg.Clusters, name)) && !cfg.excluded(name)
+}
+
+// listClusters returns the compute clusters of the datacenter selected with -cluster
+func listClusters(ctx context.Context, finder *find.Finder, cfg *Config) ([]*object.ClusterComputeResource, error) {
+	clusters, err := finder.ClusterComputeResourceList(ctx, "*")
+	if err != nil || (len(cfg.Clusters) == 0 && len(cfg.Exclude) == 0) {
+		return clusters, err
+	}
+
+	selected := make([]*object.ClusterComputeResource, 0, len(clusters))
+	for _, cluster := range clusters {
+		if cfg.clusterSelected(cluster.Name()) {
+			selected = append(selected, cluster)
+		}
+	}
+	if len(cfg.Clusters) > 0 && len(selected) == 0 {
+		return nil, fmt.Errorf("no cluster matches -cluster %s", strings.Join(cfg.Clusters, ", "))
+	}
+	return selected, nil
+}
+
+// datastoreClusterSelected reports whether a datastore cluster is reported, all but the
+// excluded ones are without -datastore-cluster
+func (cfg *Config) datastoreClusterSelected(name string) bool {
+	return (len(cfg.DatastoreClusters) == 0 || matchesAny(cfg.DatastoreClusters, name)) && !cfg.excluded(name)
+}
+
+// scopeDatastoreClusters applies -datastore-cluster and -exclude to the datastore clusters and
+// datastores. With -datastore-cluster only the members of the selected datastore clusters are
+// kept, the members of excluded datastore clusters are always dropped.
+func scopeDatastoreClusters(cfg *Config, pods []mo.StoragePod, datastores []mo.Datastore) ([]mo.StoragePod, []mo.Datastore, error) {
+	if len(cfg.DatastoreClusters) == 0 && len(cfg.Exclude) == 0 {
+		return pods, datastores, nil
+	}
+
+	selected := make([]mo.StoragePod, 0, len(pods))
+	members := make(map[string]bool)
+	dropped := make(map[string]bool)
+	for _, pod := range pods {
+		keep := cfg.datastoreClusterSelected(pod.Name)
+		if keep {
+			selected = append(selected, pod)
+		}
+		for _, ref := range pod.ChildEntity {
+			if keep {
+				members[ref.Value] = true
+			} else {
+				dropped[ref.Value] = true
+			}
+		}
+	}
+	if len(cfg.DatastoreClusters) > 0 && len(selected) == 0 {
+		return nil, nil, fmt.Errorf("no datastore cluster matches -datastore-cluster %s", strings.Join(cfg.DatastoreClusters, ", "))
+	}
+
+	kept := make([]mo.Datastore, 0, len(datastores))
+	for _, ds := range datastores {
+		if dropped[ds.Self.Value] || (len(cfg.DatastoreClusters) > 0 && !members[ds.Self.Value]) {
+			continue
+		}
+		kept = append(kept, ds)
+	}
+	return selected, kept, nil
+}
+
+// withoutExcludedDatastores drops the datastores matching -exclude
+func withoutExcludedDatastores(cfg *Config, datastores []mo.Datastore) []mo.Datastore {
+	if len(cfg.Exclude) == 0 {
+		return datastores
+	}
+
+	kept := make([]mo.Datastore, 0, len(datastores))
+	for _, ds := range datastores {
+		if !cfg.excluded(ds.Name) {
+			kept = append(kept, ds)
+		}
+	}
+	return kept
+}
+
+// withDatastoreTags keeps the datastores with one of the -datastore-tag category=value tags
+func withDatastoreTags(ctx context.Context, client *govmomi.Client, cfg *Config, datastores []mo.Datastore) ([]mo.Datastore, error) {
+	if len(cfg.DatastoreTags) == 0 || len(datastores) == 0 {
+		return datastores, nil
+	}
+
+	rc, err := connectToREST(ctx, client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the vSphere REST API: %s", err)
+	}
+	defer rc.Logout(ctx)
+
+	m := tags.NewManager(rc)
+
+	// the wanted tag names per category ID
+	wanted := make(map[string]map[string]bool)
+	for _, tag := range cfg.DatastoreTags {
+		category, value, _ := strings.Cut(tag, "=")
+		cat, err := m.GetCategory(ctx, category)
+		if err != nil {
+			return nil, fmt.Errorf("getting tag category %s: %s", category, err)
+		}
+		if wanted[cat.ID] == nil {
+			wanted[cat.ID] = make(map[string]bool)
+		}
+		wanted[cat.ID][value] = true
+	}
+
+	attached, err := attachedTags(ctx, m, datastores)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving datastore tags: %s", err)
+	}
+
+	kept := make([]mo.Datastore, 0, len(datastores))
+	for _, ds := range datastores {
+		for _, tag := range attached[ds.Self.Value] {
+			if wanted[tag.CategoryID][tag.Name] {
+				kept = append(kept, ds)
+				break
+			}
+		}
+	}
+	return kept, nil
+}
+
+// clusterDatastores returns the datastores used by the clusters selected with -cluster,
+// nil without -cluster
+func clusterDatastores(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, cfg *Config) (map[string]bool, error) {
+	if len(cfg.Clusters) == 0 {
+		return nil, nil
+	}
+
+	var clusters []mo.ClusterComputeResource
+	err := retrieveAll(ctx, client, dc, "ClusterComputeResource", []string{"name", "datastore"}, &clusters)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving clusters: %s", err)
+	}
+
+	refs := make(map[string]bool)
+	found := false
+	for _, cluster := range clusters {
+		if !cfg.clusterSelected(cluster.Name) {
+			continue
+		}
+		found = true
+		for _, ref := range cluster.Datastore {
+			refs[ref.Value] = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no cluster matches -cluster %s", strings.Join(cfg.Clusters, ", "))
+	}
+	return refs, nil
+}
+
+// withoutLocalDatastores drops the VMFS datastores that can only be accessed by a single host
+func withoutLocalDatastores(datastores []mo.Datastore) []mo.Datastore {
+	shared := make([]mo.Datastore, 0, len(datastores))
+	for _, ds := range datastores {
+		if isLocalDatastore(ds) {
+			continue
+		}
+		shared = append(shared, ds)
+	}
+	return shared
+}
+
+// isLocalDatastore reports whether a datastore is host-local, like the VMFS volume
+// on a host's boot disk. NFS, vSAN and vVol datastores mounted by a single host are
+// shared storage that just isn't mounted elsewhere yet.
+func isLocalDatastore(ds mo.Datastore) bool {
+	return ds.Summary.Type == string(types.HostFileSystemVolumeFileSystemTypeVMFS) && !datastoreShared(ds)
+}
+
+// datastoreShared reports whether a datastore can be accessed by multiple hosts. When the
+// datastore summary doesn't tell, the datastore counts as shared if more than one host mounts it.
+func datastoreShared(ds mo.Datastore) bool {
+	if access := ds.Summary.MultipleHostAccess; access != nil {
+		return *access
+	}
+	return mountedHostCount(ds) > 1
+}
+
+// mountedHostCount returns the number of hosts that have the datastore mounted. It needs
+// the "host" property of the datastore.
+func mountedHostCount(ds mo.Datastore) int {
+	count := 0
+	for _, mount := range ds.Host {
+		if mount.MountInfo.Mounted != nil && !*mount.MountInfo.Mounted {
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// sizeUnits maps size suffixes to their factor in GB, a size without unit is in GB
+var sizeUnits = []struct {
+	suffix string
+	factor float64
+}{
+	{"TB", 1024},
+	{"GB", 1},
+	{"MB", 1.0 / 1024},
+	{"T", 1024},
+	{"G", 1},
+	{"M", 1.0 / 1024},
+}
+
+// parseSizeGB parses a size like "500GB", "1.5TB" or "500" into GB
+func parseSizeGB(s string) (float64, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	factor := 1.0
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			factor = unit.factor
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	return n * factor, nil
+}
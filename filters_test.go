@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestParseSizeGB(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{"500", 500, false},
+		{"500GB", 500, false},
+		{"500G", 500, false},
+		{"1.5TB", 1536, false},
+		{"2t", 2048, false},
+		{"512MB", 0.5, false},
+		{" 10 gb ", 10, false},
+		{"0", 0, false},
+		{"", 0, true},
+		{"GB", 0, true},
+		{"-1GB", 0, true},
+		{"10PB", 0, true},
+		{"ten", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSizeGB(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSizeGB(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseSizeGB(%q) = %g, want %g", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsLocalDatastore(t *testing.T) {
+	single, multiple := false, true
+	tests := []struct {
+		name   string
+		fsType string
+		access *bool
+		want   bool
+	}{
+		{"local vmfs", "VMFS", &single, true},
+		{"shared vmfs", "VMFS", &multiple, false},
+		{"nfs mounted by one host", "NFS", &single, false},
+		{"vsan mounted by one host", "vsan", &single, false},
+		{"vvol mounted by one host", "VVOL", &single, false},
+		{"vmfs without access and mounts", "VMFS", nil, true},
+	}
+
+	for _, tt := range tests {
+		ds := mo.Datastore{Summary: types.DatastoreSummary{Type: tt.fsType, MultipleHostAccess: tt.access}}
+		if got := isLocalDatastore(ds); got != tt.want {
+			t.Errorf("%s: isLocalDatastore = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
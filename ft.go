@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ftLoggingNicType is the vmkernel service carrying Fault Tolerance logging traffic
+const ftLoggingNicType = "faultToleranceLogging"
+
+type FTSecondaryInfo struct {
+	Name       string   `json:"name"`
+	Host       string   `json:"host"`
+	Datastores []string `json:"datastores"`
+}
+
+type FTVMInfo struct {
+	Name string `json:"name"`
+	// State is the Fault Tolerance state, like running or needSecondary
+	State       string            `json:"state"`
+	Host        string            `json:"host"`
+	Datastores  []string          `json:"datastores"`
+	Secondaries []FTSecondaryInfo `json:"secondaries"`
+}
+
+type FTLoggingAdapterInfo struct {
+	Host      string `json:"host"`
+	Device    string `json:"device"`
+	IPAddress string `json:"ip_address"`
+	Portgroup string `json:"portgroup"`
+}
+
+type ClusterFTInfo struct {
+	Name string     `json:"name"`
+	VMs  []FTVMInfo `json:"vms"`
+	// LoggingAdapters are the vmkernel adapters of the hosts enabled for FT logging
+	LoggingAdapters []FTLoggingAdapterInfo `json:"logging_adapters"`
+	// Warnings flag secondaries sharing the host or a datastore with their primary, primaries
+	// without secondary and hosts without FT logging adapter
+	Warnings []string `json:"warnings"`
+}
+
+type FTReport struct {
+	Datacenter string          `json:"datacenter"`
+	Clusters   []ClusterFTInfo `json:"clusters"`
+}
+
+// reportFT lists the Fault Tolerance protected VMs of every cluster with the hosts and
+// datastores of their primary and secondary VMs and the FT logging network of the hosts. A
+// secondary on a datastore of its primary doesn't survive the loss of that datastore.
+func reportFT(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	var datastores []mo.Datastore
+	err = retrieveAll(ctx, client, dc, "Datastore", []string{"name"}, &datastores)
+	if err != nil {
+		return fmt.Errorf("retrieving datastores: %s", err)
+	}
+	datastoreNames := make(map[string]string, len(datastores))
+	for _, ds := range datastores {
+		datastoreNames[ds.Self.Value] = ds.Name
+	}
+	names := func(refs []types.ManagedObjectReference) []string {
+		list := make([]string, 0, len(refs))
+		for _, ref := range refs {
+			list = append(list, datastoreNames[ref.Value])
+		}
+		sort.Strings(list)
+		return list
+	}
+
+	_, portgroups, err := distributedNetworkNames(ctx, client, dc)
+	if err != nil {
+		return fmt.Errorf("retrieving distributed switches: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := FTReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterFTInfo, 0, len(clusters)),
+	}
+
+	for _, cluster := range clusters {
+		info := ClusterFTInfo{
+			Name:            cluster.Name(),
+			VMs:             make([]FTVMInfo, 0),
+			LoggingAdapters: make([]FTLoggingAdapterInfo, 0),
+			Warnings:        make([]string, 0),
+		}
+
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+		var hosts []mo.HostSystem
+		if len(clusterMo.Host) > 0 {
+			err = pc.Retrieve(ctx, clusterMo.Host, []string{"name", "config.network.vnic", "config.virtualNicManagerInfo"}, &hosts)
+			if err != nil {
+				return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+			}
+		}
+		sort.Slice(hosts, func(i, j int) bool {
+			return hosts[i].Name < hosts[j].Name
+		})
+		hostNames := make(map[string]string, len(hosts))
+		for _, host := range hosts {
+			hostNames[host.Self.Value] = host.Name
+		}
+		hostName := func(ref *types.ManagedObjectReference) string {
+			if ref == nil {
+				return ""
+			}
+			return hostNames[ref.Value]
+		}
+
+		var vms []mo.VirtualMachine
+		err = retrieveClusterVMs(ctx, client, cluster, []string{"name", "config.ftInfo", "runtime.faultToleranceState", "runtime.host", "datastore"}, &vms)
+		if err != nil {
+			return fmt.Errorf("getting VMs of cluster %s: %s", cluster.Name(), err)
+		}
+
+		for _, vm := range vms {
+			if vm.Config == nil || vm.Config.FtInfo == nil {
+				continue
+			}
+			primary, ok := vm.Config.FtInfo.(*types.FaultTolerancePrimaryConfigInfo)
+			if !ok {
+				continue
+			}
+
+			ftVM := FTVMInfo{
+				Name:        vm.Name,
+				State:       string(vm.Runtime.FaultToleranceState),
+				Host:        hostName(vm.Runtime.Host),
+				Datastores:  names(vm.Datastore),
+				Secondaries: make([]FTSecondaryInfo, 0, len(primary.Secondaries)),
+			}
+
+			var secondaries []mo.VirtualMachine
+			if len(primary.Secondaries) > 0 {
+				err = pc.Retrieve(ctx, primary.Secondaries, []string{"name", "runtime.host", "datastore"}, &secondaries)
+				if err != nil {
+					return fmt.Errorf("getting secondaries of VM %s: %s", vm.Name, err)
+				}
+			}
+			for _, secondary := range secondaries {
+				s := FTSecondaryInfo{
+					Name:       secondary.Name,
+					Host:       hostName(secondary.Runtime.Host),
+					Datastores: names(secondary.Datastore),
+				}
+				if s.Host != "" && s.Host == ftVM.Host {
+					info.Warnings = append(info.Warnings, fmt.Sprintf("secondary %s runs on the host of its primary %s (%s)", s.Name, vm.Name, s.Host))
+				}
+				if shared := sharedNames(ftVM.Datastores, s.Datastores); len(shared) > 0 {
+					info.Warnings = append(info.Warnings, fmt.Sprintf("secondary %s shares datastores with its primary %s: %s", s.Name, vm.Name, strings.Join(shared, ", ")))
+				}
+				ftVM.Secondaries = append(ftVM.Secondaries, s)
+			}
+			if len(ftVM.Secondaries) == 0 {
+				info.Warnings = append(info.Warnings, fmt.Sprintf("%s has no secondary (%s)", vm.Name, ftVM.State))
+			}
+
+			info.VMs = append(info.VMs, ftVM)
+		}
+		sort.Slice(info.VMs, func(i, j int) bool {
+			return info.VMs[i].Name < info.VMs[j].Name
+		})
+
+		for _, host := range hosts {
+			adapters := ftLoggingAdapters(host, portgroups)
+			if len(adapters) == 0 && len(info.VMs) > 0 {
+				info.Warnings = append(info.Warnings, fmt.Sprintf("host %s has no FT logging adapter", host.Name))
+			}
+			info.LoggingAdapters = append(info.LoggingAdapters, adapters...)
+		}
+
+		report.Clusters = append(report.Clusters, info)
+	}
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		return report.Clusters[i].Name < report.Clusters[j].Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", cluster.Name)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		if len(cluster.VMs) == 0 {
+			fmt.Println("  No FT protected VMs")
+		}
+		for _, vm := range cluster.VMs {
+			fmt.Printf("  %s (%s): primary on %s, datastores %s\n", vm.Name, vm.State, valueOrNone(vm.Host), valueOrNone(strings.Join(vm.Datastores, ", ")))
+			for _, s := range vm.Secondaries {
+				fmt.Printf("    Secondary %s on %s, datastores %s\n", s.Name, valueOrNone(s.Host), valueOrNone(strings.Join(s.Datastores, ", ")))
+			}
+		}
+		for _, a := range cluster.LoggingAdapters {
+			fmt.Printf("  FT logging: %s %s, %s, portgroup %s\n", a.Host, a.Device, valueOrNone(a.IPAddress), valueOrNone(a.Portgroup))
+		}
+		for _, warning := range cluster.Warnings {
+			fmt.Printf("  WARNING: %s\n", warning)
+		}
+	}
+
+	return nil
+}
+
+// ftLoggingAdapters returns the vmkernel adapters of a host enabled for FT logging, portgroups
+// names the distributed portgroups by key
+func ftLoggingAdapters(host mo.HostSystem, portgroups map[string]string) []FTLoggingAdapterInfo {
+	var adapters []FTLoggingAdapterInfo
+	if host.Config == nil || host.Config.VirtualNicManagerInfo == nil || host.Config.Network == nil {
+		return adapters
+	}
+
+	selected := make(map[string]bool)
+	for _, nc := range host.Config.VirtualNicManagerInfo.NetConfig {
+		if nc.NicType != ftLoggingNicType {
+			continue
+		}
+		devices := make(map[string]string)
+		for _, vnic := range nc.CandidateVnic {
+			devices[vnic.Key] = vnic.Device
+		}
+		for _, key := range nc.SelectedVnic {
+			if device, ok := devices[key]; ok {
+				selected[device] = true
+			}
+		}
+	}
+
+	for _, vnic := range host.Config.Network.Vnic {
+		if !selected[vnic.Device] {
+			continue
+		}
+		adapter := FTLoggingAdapterInfo{
+			Host:      host.Name,
+			Device:    vnic.Device,
+			Portgroup: vnic.Portgroup,
+		}
+		if vnic.Spec.Ip != nil {
+			adapter.IPAddress = vnic.Spec.Ip.IpAddress
+		}
+		if port := vnic.Spec.DistributedVirtualPort; port != nil {
+			adapter.Portgroup = portgroups[port.PortgroupKey]
+		}
+		adapters = append(adapters, adapter)
+	}
+	return adapters
+}
+
+// sharedNames returns the names in both lists
+func sharedNames(a, b []string) []string {
+	in := make(map[string]bool, len(a))
+	for _, name := range a {
+		in[name] = true
+	}
+	var shared []string
+	for _, name := range b {
+		if in[name] {
+			shared = append(shared, name)
+		}
+	}
+	return shared
+}
@@ -1,5 +1,10 @@
 module github.com/kelaro/godcinfo
 
-go 1.20
+go 1.25
 
-require github.com/vmware/govmomi v0.30.4 
\ No newline at end of file
+require (
+	github.com/klauspost/compress v1.20.1
+	github.com/vmware/govmomi v0.30.4
+)
+
+require github.com/google/uuid v1.3.0 // indirect
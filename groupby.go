@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// noGroup collects the datastores without a tag in the category or a value for the attribute
+const noGroup = "(none)"
+
+type DatastoreGroupInfo struct {
+	Name       string          `json:"name"`
+	Datastores []DatastoreInfo `json:"datastores"`
+	Totals     CapacityTotals  `json:"totals"`
+}
+
+type GroupedDatastoresInfo struct {
+	Datacenter string               `json:"datacenter"`
+	GroupBy    string               `json:"group_by"`
+	Groups     []DatastoreGroupInfo `json:"groups"`
+}
+
+// reportDatastoreGroups lists the datastores of the datacenter grouped by the tags of a
+// tag category or the values of a custom attribute instead of by cluster. A datastore with
+// several tags of the category is listed in each of their groups.
+func reportDatastoreGroups(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, cfg *Config) error {
+	kind, key, ok := strings.Cut(cfg.GroupBy, ":")
+	if !ok || key == "" || (kind != "tag" && kind != "attribute") {
+		return fmt.Errorf("invalid -group-by %q, use tag:<category> or attribute:<name>", cfg.GroupBy)
+	}
+
+	var datastores []mo.Datastore
+	err := retrieveAll(ctx, client, dc, "Datastore", []string{"name", "summary", "info", "host", "customValue"}, &datastores)
+	if err != nil {
+		return fmt.Errorf("retrieving datastores: %s", err)
+	}
+	if cfg.ExcludeLocal {
+		datastores = withoutLocalDatastores(datastores)
+	}
+
+	scoped, err := clusterDatastores(ctx, client, dc, cfg)
+	if err != nil {
+		return err
+	}
+	if scoped != nil {
+		inScope := make([]mo.Datastore, 0, len(datastores))
+		for _, ds := range datastores {
+			if scoped[ds.Self.Value] {
+				inScope = append(inScope, ds)
+			}
+		}
+		datastores = inScope
+	}
+
+	datastores = withoutExcludedDatastores(cfg, datastores)
+	datastores, err = withDatastoreTags(ctx, client, cfg, datastores)
+	if err != nil {
+		return err
+	}
+	if len(cfg.DatastoreClusters) > 0 || len(cfg.Exclude) > 0 {
+		var pods []mo.StoragePod
+		err = retrieveAll(ctx, client, dc, "StoragePod", []string{"name", "childEntity"}, &pods)
+		if err != nil {
+			return fmt.Errorf("retrieving datastore clusters: %s", err)
+		}
+		_, datastores, err = scopeDatastoreClusters(cfg, pods, datastores)
+		if err != nil {
+			return err
+		}
+	}
+
+	var groups map[string][]string
+	if kind == "tag" {
+		groups, err = datastoreTagGroups(ctx, client, cfg, datastores, key)
+	} else {
+		groups, err = datastoreAttributeGroups(ctx, client, datastores, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	pc := property.DefaultCollector(client.Client)
+	hostNames, err := vvolHostNames(ctx, pc, datastores)
+	if err != nil {
+		return fmt.Errorf("retrieving vVol protocol endpoint hosts: %s", err)
+	}
+
+	var dsTags map[string][]string
+	if cfg.Thresholds.usesTags() {
+		dsTags, err = datastoreTags(ctx, client, cfg, datastores)
+		if err != nil {
+			return fmt.Errorf("retrieving datastore tags: %s", err)
+		}
+	}
+
+	members := make(map[string][]mo.Datastore)
+	var infos []DatastoreInfo
+	for _, ds := range datastores {
+		info := newDatastoreInfo(ds, hostNames)
+		info.Status = cfg.Thresholds.status(info.Name, dsTags[ds.Self.Value], info.UsedPct)
+		infos = append(infos, info)
+
+		names := groups[ds.Self.Value]
+		if len(names) == 0 {
+			names = []string{noGroup}
+		}
+		for _, name := range names {
+			members[name] = append(members[name], ds)
+		}
+	}
+
+	report := GroupedDatastoresInfo{
+		Datacenter: cfg.pseudonym(kindDatacenter, dc.Name()),
+		GroupBy:    cfg.anonymizeGroupBy(cfg.GroupBy),
+		Groups:     make([]DatastoreGroupInfo, 0, len(members)),
+	}
+	for name, dsList := range members {
+		sort.Slice(dsList, func(i, j int) bool {
+			return dsList[i].Name < dsList[j].Name
+		})
+
+		if name != noGroup {
+			name = cfg.pseudonym("group", name)
+		}
+		group := DatastoreGroupInfo{
+			Name:       name,
+			Datastores: make([]DatastoreInfo, 0, len(dsList)),
+			Totals:     capacityTotals(dsList),
+		}
+		for _, ds := range dsList {
+			info := newDatastoreInfo(ds, hostNames)
+			info.Status = cfg.Thresholds.status(info.Name, dsTags[ds.Self.Value], info.UsedPct)
+			if includeDatastore(cfg, info) {
+				group.Datastores = append(group.Datastores, cfg.anonymizeDatastore(info))
+			}
+		}
+		report.Groups = append(report.Groups, group)
+	}
+
+	// Sort the groups by name, datastores without a group go last
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i].Name, report.Groups[j].Name
+		if a == noGroup || b == noGroup {
+			return b == noGroup && a != noGroup
+		}
+		return a < b
+	})
+
+	sendAlerts(cfg, datastoreAlerts(dc.Name(), infos))
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, group := range report.Groups {
+		fmt.Printf("\nGroup: %s\n", group.Name)
+		fmt.Println(strings.Repeat("-", len(group.Name)+7))
+		if len(group.Datastores) == 0 {
+			fmt.Println("    No datastores matching the filters")
+		}
+		for _, info := range group.Datastores {
+			printDatastore(info)
+		}
+		printTotals("  Group total", group.Totals)
+	}
+
+	return nil
+}
+
+// datastoreTagGroups returns the names of the tags of a category attached to each datastore
+func datastoreTagGroups(ctx context.Context, client *govmomi.Client, cfg *Config, datastores []mo.Datastore, category string) (map[string][]string, error) {
+	rc, err := connectToREST(ctx, client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the vSphere REST API: %s", err)
+	}
+	defer rc.Logout(ctx)
+
+	m := tags.NewManager(rc)
+	cat, err := m.GetCategory(ctx, category)
+	if err != nil {
+		return nil, fmt.Errorf("getting tag category %s: %s", category, err)
+	}
+
+	groups := make(map[string][]string)
+	if len(datastores) == 0 {
+		return groups, nil
+	}
+
+	attached, err := attachedTags(ctx, m, datastores)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving datastore tags: %s", err)
+	}
+
+	for ref, dsTags := range attached {
+		for _, tag := range dsTags {
+			if tag.CategoryID == cat.ID {
+				groups[ref] = append(groups[ref], tag.Name)
+			}
+		}
+	}
+
+	return groups, nil
+}
+
+// datastoreAttributeGroups returns the value of a custom attribute for each datastore. It
+// needs the "customValue" property of the datastores.
+func datastoreAttributeGroups(ctx context.Context, client *govmomi.Client, datastores []mo.Datastore, attribute string) (map[string][]string, error) {
+	m, err := object.GetCustomFieldsManager(client.Client)
+	if err != nil {
+		return nil, fmt.Errorf("getting custom attributes: %s", err)
+	}
+
+	key, err := m.FindKey(ctx, attribute)
+	if err != nil {
+		return nil, fmt.Errorf("getting custom attribute %s: %s", attribute, err)
+	}
+
+	groups := make(map[string][]string)
+	for _, ds := range datastores {
+		for _, v := range ds.CustomValue {
+			value, ok := v.(*types.CustomFieldStringValue)
+			if ok && value.Key == key && value.Value != "" {
+				groups[ds.Self.Value] = []string{value.Value}
+			}
+		}
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+// guestOSFamilies names the guest OS families reported by VMware Tools
+var guestOSFamilies = map[string]string{
+	"windowsGuest":      "Windows",
+	"linuxGuest":        "Linux",
+	"netwareGuest":      "NetWare",
+	"solarisGuest":      "Solaris",
+	"darwinGuestFamily": "macOS",
+	"otherGuestFamily":  "Other",
+}
+
+// guestOSPropertyNames are the VM properties the guest OS is taken from
+var guestOSPropertyNames = []string{"name", "config.template", "config.guestId", "config.guestFullName", "guest.guestFamily", "guest.guestFullName"}
+
+type GuestOSCount struct {
+	Family  string `json:"family"`
+	Version string `json:"version"`
+	Count   int    `json:"count"`
+}
+
+// guestOSKey is a guest OS family and version VMs are counted by
+type guestOSKey struct {
+	Family  string
+	Version string
+}
+
+type ClusterGuestOSInfo struct {
+	Name    string         `json:"name"`
+	VMCount int            `json:"vm_count"`
+	GuestOS []GuestOSCount `json:"guest_os"`
+}
+
+type GuestOSReport struct {
+	Datacenter string `json:"datacenter"`
+	// VMCount and GuestOS cover the whole datacenter, or only the selected clusters with
+	// -cluster or -exclude
+	VMCount  int                  `json:"vm_count"`
+	GuestOS  []GuestOSCount       `json:"guest_os"`
+	Clusters []ClusterGuestOSInfo `json:"clusters"`
+}
+
+// reportGuestOS counts the VMs by guest OS family and version per cluster and for the
+// datacenter, templates are not counted. The guest OS detected by VMware Tools is preferred
+// over the one configured for the VM.
+func reportGuestOS(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	report := GuestOSReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterGuestOSInfo, 0, len(clusters)),
+	}
+
+	total := make(map[guestOSKey]int)
+	for _, cluster := range clusters {
+		var vms []mo.VirtualMachine
+		err = retrieveClusterVMs(ctx, client, cluster, guestOSPropertyNames, &vms)
+		if err != nil {
+			return fmt.Errorf("getting VMs of cluster %s: %s", cluster.Name(), err)
+		}
+
+		counts := countGuestOS(vms)
+		info := ClusterGuestOSInfo{
+			Name:    cluster.Name(),
+			GuestOS: sortedGuestOSCounts(counts),
+		}
+		for key, count := range counts {
+			info.VMCount += count
+			total[key] += count
+		}
+		report.Clusters = append(report.Clusters, info)
+	}
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		return report.Clusters[i].Name < report.Clusters[j].Name
+	})
+
+	// without a cluster selection the VMs on standalone hosts count for the datacenter too
+	if len(cfg.Clusters) == 0 && len(cfg.Exclude) == 0 {
+		var vms []mo.VirtualMachine
+		err = retrieveAll(ctx, client, dc, "VirtualMachine", guestOSPropertyNames, &vms)
+		if err != nil {
+			return fmt.Errorf("retrieving VMs: %s", err)
+		}
+		total = countGuestOS(vms)
+	}
+	report.GuestOS = sortedGuestOSCounts(total)
+	for _, count := range total {
+		report.VMCount += count
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s (%d VMs)\n", cluster.Name, cluster.VMCount)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		printGuestOSCounts(cluster.GuestOS)
+	}
+
+	fmt.Printf("\nDatacenter: %s (%d VMs)\n", report.Datacenter, report.VMCount)
+	fmt.Println(strings.Repeat("-", len(report.Datacenter)+12))
+	printGuestOSCounts(report.GuestOS)
+
+	return nil
+}
+
+// countGuestOS counts the VMs by guest OS family and version
+func countGuestOS(vms []mo.VirtualMachine) map[guestOSKey]int {
+	counts := make(map[guestOSKey]int)
+	for _, vm := range vms {
+		if vm.Config != nil && vm.Config.Template {
+			continue
+		}
+
+		var family, version, guestID string
+		if vm.Guest != nil {
+			family = vm.Guest.GuestFamily
+			version = vm.Guest.GuestFullName
+		}
+		if vm.Config != nil {
+			guestID = vm.Config.GuestId
+			if version == "" {
+				version = vm.Config.GuestFullName
+			}
+		}
+		if family == "" {
+			family = guestFamilyFromID(guestID)
+		}
+		if name, ok := guestOSFamilies[family]; ok {
+			family = name
+		}
+		if version == "" {
+			version = "unknown"
+		}
+
+		counts[guestOSKey{Family: family, Version: version}]++
+	}
+	return counts
+}
+
+// guestFamilyFromID guesses the guest OS family from the configured guest ID for VMs
+// without running VMware Tools
+func guestFamilyFromID(id string) string {
+	switch {
+	case id == "":
+		return "unknown"
+	case strings.HasPrefix(id, "win"):
+		return "windowsGuest"
+	case strings.HasPrefix(id, "darwin"):
+		return "darwinGuestFamily"
+	case strings.HasPrefix(id, "solaris"):
+		return "solarisGuest"
+	case strings.HasPrefix(id, "netware"):
+		return "netwareGuest"
+	case strings.HasPrefix(id, "other") && !strings.Contains(id, "Linux"), strings.HasPrefix(id, "freebsd"),
+		strings.HasPrefix(id, "dos"), strings.HasPrefix(id, "os2"), strings.HasPrefix(id, "eComStation"),
+		strings.HasPrefix(id, "openServer"), strings.HasPrefix(id, "unixWare"):
+		return "otherGuestFamily"
+	}
+	return "linuxGuest"
+}
+
+func sortedGuestOSCounts(counts map[guestOSKey]int) []GuestOSCount {
+	sorted := make([]GuestOSCount, 0, len(counts))
+	for key, count := range counts {
+		sorted = append(sorted, GuestOSCount{Family: key.Family, Version: key.Version, Count: count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Family != sorted[j].Family {
+			return sorted[i].Family < sorted[j].Family
+		}
+		return sorted[i].Version < sorted[j].Version
+	})
+	return sorted
+}
+
+func printGuestOSCounts(counts []GuestOSCount) {
+	if len(counts) == 0 {
+		fmt.Println("  No VMs")
+		return
+	}
+	family := ""
+	for _, c := range counts {
+		if c.Family != family {
+			family = c.Family
+			var n int
+			for _, other := range counts {
+				if other.Family == family {
+					n += other.Count
+				}
+			}
+			fmt.Printf("  %s: %d\n", family, n)
+		}
+		fmt.Printf("    %s: %d\n", c.Version, c.Count)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// minHeartbeatDatastores is the number of heartbeat datastores vSphere HA wants per host
+const minHeartbeatDatastores = 2
+
+type HeartbeatDatastoreInfo struct {
+	Name      string `json:"name"`
+	HostCount int    `json:"host_count"`
+	Pinned    bool   `json:"pinned"`
+}
+
+type ClusterHeartbeatInfo struct {
+	Name      string                   `json:"name"`
+	HAEnabled bool                     `json:"ha_enabled"`
+	Policy    string                   `json:"policy"`
+	Pinned    []string                 `json:"pinned_datastores"`
+	Selected  []HeartbeatDatastoreInfo `json:"selected_datastores"`
+	Warnings  []string                 `json:"warnings"`
+}
+
+type HeartbeatReport struct {
+	Datacenter string                 `json:"datacenter"`
+	Clusters   []ClusterHeartbeatInfo `json:"clusters"`
+}
+
+// reportHeartbeatDatastores shows the datastores vSphere HA uses for datastore
+// heartbeating per cluster and warns about hosts with too few of them
+func reportHeartbeatDatastores(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	dsNames, err := datastoreNames(ctx, client, dc)
+	if err != nil {
+		return fmt.Errorf("retrieving datastores: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := HeartbeatReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterHeartbeatInfo, 0, len(clusters)),
+	}
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"name", "configurationEx", "host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting details of cluster %s: %s", cluster.Name(), err)
+		}
+
+		info := ClusterHeartbeatInfo{
+			Name:     clusterMo.Name,
+			Pinned:   make([]string, 0),
+			Selected: make([]HeartbeatDatastoreInfo, 0),
+			Warnings: make([]string, 0),
+		}
+
+		pinned := make(map[string]bool)
+		if ex, ok := clusterMo.ConfigurationEx.(*types.ClusterConfigInfoEx); ok {
+			das := ex.DasConfig
+			info.HAEnabled = das.Enabled != nil && *das.Enabled
+			info.Policy = das.HBDatastoreCandidatePolicy
+			for _, ref := range das.HeartbeatDatastore {
+				pinned[ref.Value] = true
+				info.Pinned = append(info.Pinned, lookupName(dsNames, ref))
+			}
+		}
+
+		if !info.HAEnabled {
+			report.Clusters = append(report.Clusters, info)
+			continue
+		}
+
+		req := types.RetrieveDasAdvancedRuntimeInfo{This: cluster.Reference()}
+		res, err := methods.RetrieveDasAdvancedRuntimeInfo(ctx, client.Client, &req)
+		if err != nil {
+			return fmt.Errorf("getting HA runtime info of cluster %s: %s", clusterMo.Name, err)
+		}
+
+		// Count the heartbeat datastores of every host in the cluster
+		perHost := make(map[string]int)
+		for _, host := range clusterMo.Host {
+			perHost[host.Value] = 0
+		}
+
+		if res.Returnval != nil {
+			runtime := res.Returnval.GetClusterDasAdvancedRuntimeInfo()
+			for _, hb := range runtime.HeartbeatDatastoreInfo {
+				info.Selected = append(info.Selected, HeartbeatDatastoreInfo{
+					Name:      lookupName(dsNames, hb.Datastore),
+					HostCount: len(hb.Hosts),
+					Pinned:    pinned[hb.Datastore.Value],
+				})
+				for _, host := range hb.Hosts {
+					perHost[host.Value]++
+				}
+			}
+		}
+
+		if len(clusterMo.Host) > 0 {
+			var hosts []mo.HostSystem
+			err = pc.Retrieve(ctx, clusterMo.Host, []string{"name"}, &hosts)
+			if err != nil {
+				return fmt.Errorf("getting hosts of cluster %s: %s", clusterMo.Name, err)
+			}
+			sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+
+			for _, host := range hosts {
+				if count := perHost[host.Self.Value]; count < minHeartbeatDatastores {
+					info.Warnings = append(info.Warnings,
+						fmt.Sprintf("host %s has %d heartbeat datastore(s), at least %d recommended", host.Name, count, minHeartbeatDatastores))
+				}
+			}
+		}
+
+		report.Clusters = append(report.Clusters, info)
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, info := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", info.Name)
+		fmt.Println(strings.Repeat("-", len(info.Name)+9))
+
+		if !info.HAEnabled {
+			fmt.Println("  vSphere HA is not enabled")
+			continue
+		}
+
+		fmt.Printf("  Heartbeat datastore policy: %s\n", info.Policy)
+		if len(info.Pinned) > 0 {
+			fmt.Printf("  Pinned datastores: %s\n", strings.Join(info.Pinned, ", "))
+		}
+
+		fmt.Println("  Selected heartbeat datastores:")
+		if len(info.Selected) == 0 {
+			fmt.Println("    None")
+		}
+		for _, hb := range info.Selected {
+			pinned := ""
+			if hb.Pinned {
+				pinned = ", pinned"
+			}
+			fmt.Printf("    - %s (%d hosts%s)\n", hb.Name, hb.HostCount, pinned)
+		}
+
+		for _, warning := range info.Warnings {
+			fmt.Printf("  WARNING: %s\n", warning)
+		}
+	}
+
+	return nil
+}
+
+// lookupName returns the name for a reference, falling back to its managed object ID
+func lookupName(names map[string]string, ref types.ManagedObjectReference) string {
+	if name, ok := names[ref.Value]; ok {
+		return name
+	}
+	return ref.Value
+}
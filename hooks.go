@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const defaultHookTimeout = 30 * time.Second
+
+// HookConfig is a program or URL the datastores report is passed through after the scan and
+// before it is printed, uploaded or published. The hook receives the report as JSON and
+// answers with the report to continue with, an empty answer keeps the report unchanged.
+type HookConfig struct {
+	Name string `json:"name,omitempty"`
+	// Exec is the program and its arguments, it reads the report on stdin and writes it to stdout
+	Exec []string `json:"exec,omitempty"`
+	// URL receives the report as POST request and responds with it
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// TimeoutSeconds defaults to 30
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// Optional hooks only print a warning when they fail, the report stays unchanged
+	Optional bool `json:"optional,omitempty"`
+}
+
+// validate checks that the hook has either a program or a URL
+func (h HookConfig) validate() error {
+	switch {
+	case len(h.Exec) == 0 && h.URL == "":
+		return fmt.Errorf("needs exec or url")
+	case len(h.Exec) > 0 && h.URL != "":
+		return fmt.Errorf("has both exec and url, use one")
+	case h.TimeoutSeconds < 0:
+		return fmt.Errorf("invalid timeout_seconds %d", h.TimeoutSeconds)
+	}
+	return nil
+}
+
+// label names the hook in errors
+func (h HookConfig) label() string {
+	switch {
+	case h.Name != "":
+		return h.Name
+	case h.URL != "":
+		return h.URL
+	}
+	return h.Exec[0]
+}
+
+// runHooks passes the report through the hooks in the order of the config file. It returns the
+// JSON of the final report, which may hold fields the hooks added beyond InfrastructureInfo.
+func runHooks(ctx context.Context, hooks []HookConfig, infra InfrastructureInfo) ([]byte, error) {
+	report, err := json.Marshal(infra)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hook := range hooks {
+		out, err := hook.run(ctx, infra.Datacenter, report)
+		if err == nil && len(bytes.TrimSpace(out)) > 0 {
+			var check InfrastructureInfo
+			if jsonErr := json.Unmarshal(out, &check); jsonErr != nil {
+				err = fmt.Errorf("returned no datastores report: %s", jsonErr)
+			} else {
+				report = out
+			}
+		}
+		if err != nil {
+			if !hook.Optional {
+				return nil, fmt.Errorf("hook %s: %s", hook.label(), err)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: hook %s: %s\n", hook.label(), err)
+		}
+	}
+
+	return report, nil
+}
+
+// run sends the report to the program or URL and returns its answer
+func (h HookConfig) run(ctx context.Context, datacenter string, report []byte) ([]byte, error) {
+	timeout := defaultHookTimeout
+	if h.TimeoutSeconds > 0 {
+		timeout = time.Duration(h.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if h.URL != "" {
+		return h.post(ctx, report)
+	}
+
+	cmd := exec.CommandContext(ctx, h.Exec[0], h.Exec[1:]...)
+	cmd.Stdin = bytes.NewReader(report)
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GODCINFO_DATACENTER="+datacenter)
+	out, err := cmd.Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("timed out after %s", timeout)
+	}
+	return out, err
+}
+
+func (h HookConfig) post(ctx context.Context, report []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(report))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	for name, value := range h.Headers {
+		req.Header.Set(name, os.ExpandEnv(value))
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	if res.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	return body, nil
+}
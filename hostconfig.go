@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+// serviceNTP is the service key of the host NTP daemon
+const serviceNTP = "ntpd"
+
+type HostConfigInfo struct {
+	Name       string   `json:"name"`
+	Cluster    string   `json:"cluster"`
+	NTPServers []string `json:"ntp_servers"`
+	// NTPRunning tells whether the NTP daemon runs, hosts without it don't sync their clock
+	NTPRunning    bool     `json:"ntp_running"`
+	DNSServers    []string `json:"dns_servers"`
+	SearchDomains []string `json:"search_domains"`
+	SyslogHosts   []string `json:"syslog_hosts"`
+	Warnings      []string `json:"warnings"`
+}
+
+type HostConfigReport struct {
+	Datacenter string           `json:"datacenter"`
+	Reference  HostConfigPolicy `json:"reference"`
+	Hosts      []HostConfigInfo `json:"hosts"`
+	// hosts whose configuration differs from the reference
+	Deviating []string `json:"deviating"`
+}
+
+// reportHostConfig shows the NTP, DNS and remote syslog configuration of every host and
+// flags hosts that differ from the host_config reference of the config file
+func reportHostConfig(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+	reference := cfg.HostConfig
+
+	report := HostConfigReport{
+		Datacenter: dc.Name(),
+		Reference:  reference,
+		Hosts:      make([]HostConfigInfo, 0),
+		Deviating:  make([]string, 0),
+	}
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+		if len(clusterMo.Host) == 0 {
+			continue
+		}
+
+		var hosts []mo.HostSystem
+		err = pc.Retrieve(ctx, clusterMo.Host, []string{"name", "configManager", "config.dateTimeInfo", "config.network.dnsConfig", "config.service"}, &hosts)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+
+		for _, host := range hosts {
+			info := HostConfigInfo{
+				Name:          host.Name,
+				Cluster:       cluster.Name(),
+				NTPServers:    make([]string, 0),
+				DNSServers:    make([]string, 0),
+				SearchDomains: make([]string, 0),
+				SyslogHosts:   make([]string, 0),
+				Warnings:      make([]string, 0),
+			}
+
+			if host.Config != nil {
+				if dt := host.Config.DateTimeInfo; dt != nil && dt.NtpConfig != nil {
+					info.NTPServers = append(info.NTPServers, dt.NtpConfig.Server...)
+				}
+				if host.Config.Network != nil && host.Config.Network.DnsConfig != nil {
+					dns := host.Config.Network.DnsConfig.GetHostDnsConfig()
+					info.DNSServers = append(info.DNSServers, dns.Address...)
+					info.SearchDomains = append(info.SearchDomains, dns.SearchDomain...)
+				}
+				if host.Config.Service != nil {
+					for _, service := range host.Config.Service.Service {
+						if service.Key == serviceNTP {
+							info.NTPRunning = service.Running
+						}
+					}
+				}
+			}
+
+			if ref := host.ConfigManager.AdvancedOption; ref != nil {
+				om := object.NewOptionManager(client.Client, *ref)
+				info.SyslogHosts = append(info.SyslogHosts, splitList(queryOptionString(ctx, om, "Syslog.global.logHost"))...)
+			}
+
+			if len(info.NTPServers) == 0 {
+				info.Warnings = append(info.Warnings, "no NTP servers configured")
+			} else if !info.NTPRunning {
+				info.Warnings = append(info.Warnings, "NTP service is not running")
+			}
+			if len(info.SyslogHosts) == 0 {
+				info.Warnings = append(info.Warnings, "no remote syslog target")
+			}
+
+			deviations := []string{
+				configDeviation("NTP servers", reference.NTPServers, info.NTPServers),
+				configDeviation("DNS servers", reference.DNSServers, info.DNSServers),
+				configDeviation("search domains", reference.SearchDomains, info.SearchDomains),
+				configDeviation("syslog targets", reference.SyslogHosts, info.SyslogHosts),
+			}
+			deviating := false
+			for _, deviation := range deviations {
+				if deviation != "" {
+					info.Warnings = append(info.Warnings, deviation)
+					deviating = true
+				}
+			}
+			if deviating {
+				report.Deviating = append(report.Deviating, host.Name)
+			}
+
+			report.Hosts = append(report.Hosts, info)
+		}
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	cluster := ""
+	for _, info := range report.Hosts {
+		if info.Cluster != cluster {
+			cluster = info.Cluster
+			fmt.Printf("\nCluster: %s\n", cluster)
+			fmt.Println(strings.Repeat("-", len(cluster)+9))
+		}
+
+		ntpState := "stopped"
+		if info.NTPRunning {
+			ntpState = "running"
+		}
+
+		fmt.Printf("  Host: %s\n", info.Name)
+		fmt.Printf("    NTP servers: %s (service %s)\n", valueOrNone(strings.Join(info.NTPServers, ", ")), ntpState)
+		fmt.Printf("    DNS servers: %s\n", valueOrNone(strings.Join(info.DNSServers, ", ")))
+		fmt.Printf("    Search domains: %s\n", valueOrNone(strings.Join(info.SearchDomains, ", ")))
+		fmt.Printf("    Syslog targets: %s\n", valueOrNone(strings.Join(info.SyslogHosts, ", ")))
+		for _, warning := range info.Warnings {
+			fmt.Printf("    WARNING: %s\n", warning)
+		}
+	}
+
+	fmt.Printf("\nHosts deviating from the reference configuration: %d\n", len(report.Deviating))
+	for _, name := range report.Deviating {
+		fmt.Printf("  - %s\n", name)
+	}
+
+	return nil
+}
+
+// configDeviation compares a host setting with the reference, ignoring order and case. It
+// returns an empty string when they match or when the reference doesn't set the setting.
+func configDeviation(setting string, reference, actual []string) string {
+	if len(reference) == 0 {
+		return ""
+	}
+
+	normalize := func(values []string) []string {
+		normalized := make([]string, 0, len(values))
+		for _, v := range values {
+			normalized = append(normalized, strings.ToLower(strings.TrimSpace(v)))
+		}
+		sort.Strings(normalized)
+		return normalized
+	}
+
+	if strings.Join(normalize(reference), ",") == strings.Join(normalize(actual), ",") {
+		return ""
+	}
+	return fmt.Sprintf("%s %s differ from the reference %s", setting, valueOrNone(strings.Join(actual, ", ")), strings.Join(reference, ", "))
+}
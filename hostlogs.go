@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+type HostLogLocationInfo struct {
+	Name             string   `json:"name"`
+	Cluster          string   `json:"cluster"`
+	ScratchLocation  string   `json:"scratch_location"`
+	ScratchDatastore string   `json:"scratch_datastore"`
+	Coredump         string   `json:"coredump"`
+	SyslogDir        string   `json:"syslog_dir"`
+	SyslogDatastore  string   `json:"syslog_datastore"`
+	Warnings         []string `json:"warnings"`
+}
+
+type HostLogLocationReport struct {
+	Datacenter string                `json:"datacenter"`
+	Hosts      []HostLogLocationInfo `json:"hosts"`
+}
+
+// reportHostLogLocations audits the scratch partition, coredump target and syslog
+// directory of every host, flagging hosts that log to ramdisk or to datastores
+// that are slated for decommission
+func reportHostLogLocations(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	resolver, err := newDatastorePathResolver(ctx, client, dc)
+	if err != nil {
+		return fmt.Errorf("retrieving datastores: %s", err)
+	}
+
+	decommission := make(map[string]bool)
+	for _, name := range splitList(cfg.Decommission) {
+		decommission[name] = true
+	}
+
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := HostLogLocationReport{
+		Datacenter: dc.Name(),
+		Hosts:      make([]HostLogLocationInfo, 0),
+	}
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+		if len(clusterMo.Host) == 0 {
+			continue
+		}
+
+		var hosts []mo.HostSystem
+		err = pc.Retrieve(ctx, clusterMo.Host, []string{"name", "configManager"}, &hosts)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+
+		for _, host := range hosts {
+			info := HostLogLocationInfo{
+				Name:     host.Name,
+				Cluster:  cluster.Name(),
+				Warnings: make([]string, 0),
+			}
+
+			if ref := host.ConfigManager.AdvancedOption; ref != nil {
+				om := object.NewOptionManager(client.Client, *ref)
+				info.ScratchLocation = queryOptionString(ctx, om, "ScratchConfig.CurrentScratchLocation")
+				info.SyslogDir = queryOptionString(ctx, om, "Syslog.global.logDir")
+			}
+
+			if ref := host.ConfigManager.DiagnosticSystem; ref != nil {
+				var diag mo.HostDiagnosticSystem
+				err = pc.RetrieveOne(ctx, *ref, []string{"activePartition"}, &diag)
+				if err == nil && diag.ActivePartition != nil {
+					info.Coredump = fmt.Sprintf("%s:%d", diag.ActivePartition.Id.DiskName, diag.ActivePartition.Id.Partition)
+				}
+			}
+
+			info.ScratchDatastore = resolver.resolve(info.ScratchLocation)
+			info.SyslogDatastore = resolver.resolve(info.SyslogDir)
+
+			if info.ScratchDatastore == "" {
+				info.Warnings = append(info.Warnings, "scratch is on ramdisk")
+			}
+			if info.SyslogDatastore == "" && (info.ScratchDatastore == "" || !strings.Contains(info.SyslogDir, "/scratch")) {
+				info.Warnings = append(info.Warnings, "logs are written to ramdisk")
+			}
+			if info.Coredump == "" {
+				info.Warnings = append(info.Warnings, "no active coredump partition")
+			}
+			if decommission[info.ScratchDatastore] {
+				info.Warnings = append(info.Warnings, fmt.Sprintf("scratch is on datastore %s slated for decommission", info.ScratchDatastore))
+			}
+			if decommission[info.SyslogDatastore] {
+				info.Warnings = append(info.Warnings, fmt.Sprintf("logs are written to datastore %s slated for decommission", info.SyslogDatastore))
+			}
+
+			report.Hosts = append(report.Hosts, info)
+		}
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	cluster := ""
+	for _, info := range report.Hosts {
+		if info.Cluster != cluster {
+			cluster = info.Cluster
+			fmt.Printf("\nCluster: %s\n", cluster)
+			fmt.Println(strings.Repeat("-", len(cluster)+9))
+		}
+
+		fmt.Printf("  Host: %s\n", info.Name)
+		fmt.Printf("    Scratch: %s\n", valueOrNone(info.ScratchLocation))
+		fmt.Printf("    Coredump: %s\n", valueOrNone(info.Coredump))
+		fmt.Printf("    Syslog directory: %s\n", valueOrNone(info.SyslogDir))
+		for _, warning := range info.Warnings {
+			fmt.Printf("    WARNING: %s\n", warning)
+		}
+	}
+
+	return nil
+}
+
+// queryOptionString returns the value of a host advanced option, or an empty string if it is not set
+func queryOptionString(ctx context.Context, om *object.OptionManager, name string) string {
+	options, err := om.Query(ctx, name)
+	if err != nil || len(options) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%v", options[0].GetOptionValue().Value)
+}
+
+// datastorePathResolver maps "[datastore] path" and "/vmfs/volumes/..." paths to datastore names
+type datastorePathResolver struct {
+	names map[string]string
+}
+
+func newDatastorePathResolver(ctx context.Context, client *govmomi.Client, dc *object.Datacenter) (*datastorePathResolver, error) {
+	var datastores []mo.Datastore
+	err := retrieveAll(ctx, client, dc, "Datastore", []string{"name", "summary.url"}, &datastores)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &datastorePathResolver{names: make(map[string]string)}
+	for _, ds := range datastores {
+		r.names[ds.Name] = ds.Name
+		// summary.url looks like ds:///vmfs/volumes/<uuid>/
+		if uuid := strings.Trim(strings.TrimPrefix(ds.Summary.Url, "ds:///vmfs/volumes/"), "/"); uuid != "" {
+			r.names[uuid] = ds.Name
+		}
+	}
+
+	return r, nil
+}
+
+// resolve returns the name of the datastore a path lives on, or an empty string for local paths
+func (r *datastorePathResolver) resolve(path string) string {
+	var p object.DatastorePath
+	if p.FromString(path) {
+		return r.names[p.Datastore]
+	}
+
+	if rest := strings.TrimPrefix(path, "/vmfs/volumes/"); rest != path {
+		volume := strings.SplitN(rest, "/", 2)[0]
+		return r.names[volume]
+	}
+
+	return ""
+}
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// valueOrNone returns s, or "(none)" when s is empty
+func valueOrNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type HostInfo struct {
+	Name              string     `json:"name"`
+	ConnectionState   string     `json:"connection_state"`
+	PowerState        string     `json:"power_state"`
+	InMaintenanceMode bool       `json:"in_maintenance_mode"`
+	BootTime          *time.Time `json:"boot_time,omitempty"`
+	UptimeSeconds     int32      `json:"uptime_seconds"`
+	RebootRequired    bool       `json:"reboot_required"`
+}
+
+type ClusterHostsInfo struct {
+	Name  string     `json:"name"`
+	Hosts []HostInfo `json:"hosts"`
+	// Degraded is set when a host is not connected, not powered on or in maintenance mode
+	Degraded bool     `json:"degraded"`
+	Warnings []string `json:"warnings"`
+}
+
+type HostsReport struct {
+	Datacenter string             `json:"datacenter"`
+	Clusters   []ClusterHostsInfo `json:"clusters"`
+	// hosts waiting for a reboot, e.g. after patching
+	RebootRequired []string `json:"reboot_required"`
+}
+
+// reportHosts shows the connection and power state, maintenance mode, uptime, last boot time
+// and pending reboots of the hosts in every cluster, so hosts left behind in a patch cycle and
+// clusters running with degraded membership stand out
+func reportHosts(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := HostsReport{
+		Datacenter:     dc.Name(),
+		Clusters:       make([]ClusterHostsInfo, 0, len(clusters)),
+		RebootRequired: make([]string, 0),
+	}
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"name", "host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting details of cluster %s: %s", cluster.Name(), err)
+		}
+
+		info := ClusterHostsInfo{
+			Name:     clusterMo.Name,
+			Hosts:    make([]HostInfo, 0, len(clusterMo.Host)),
+			Warnings: make([]string, 0),
+		}
+
+		if len(clusterMo.Host) == 0 {
+			report.Clusters = append(report.Clusters, info)
+			continue
+		}
+
+		var hosts []mo.HostSystem
+		err = pc.Retrieve(ctx, clusterMo.Host, []string{"name", "summary.runtime", "summary.quickStats.uptime", "summary.rebootRequired"}, &hosts)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", clusterMo.Name, err)
+		}
+
+		for _, host := range hosts {
+			hostInfo := HostInfo{
+				Name:           host.Name,
+				UptimeSeconds:  host.Summary.QuickStats.Uptime,
+				RebootRequired: host.Summary.RebootRequired,
+			}
+			if runtime := host.Summary.Runtime; runtime != nil {
+				hostInfo.ConnectionState = string(runtime.ConnectionState)
+				hostInfo.PowerState = string(runtime.PowerState)
+				hostInfo.InMaintenanceMode = runtime.InMaintenanceMode
+				hostInfo.BootTime = runtime.BootTime
+			}
+			if warning := hostMembershipWarning(hostInfo); warning != "" {
+				info.Degraded = true
+				info.Warnings = append(info.Warnings, fmt.Sprintf("host %s %s", host.Name, warning))
+			}
+			if hostInfo.RebootRequired {
+				report.RebootRequired = append(report.RebootRequired, host.Name)
+			}
+			info.Hosts = append(info.Hosts, hostInfo)
+		}
+
+		report.Clusters = append(report.Clusters, info)
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, info := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", info.Name)
+		fmt.Println(strings.Repeat("-", len(info.Name)+9))
+		connected := 0
+		for _, host := range info.Hosts {
+			if hostMembershipWarning(host) == "" {
+				connected++
+			}
+		}
+		fmt.Printf("  Hosts available: %d of %d\n", connected, len(info.Hosts))
+
+		for _, host := range info.Hosts {
+			booted := "unknown"
+			if host.BootTime != nil {
+				booted = host.BootTime.Format("2006-01-02 15:04")
+			}
+			state := host.ConnectionState + ", " + host.PowerState
+			if host.InMaintenanceMode {
+				state += ", maintenance mode"
+			}
+			fmt.Printf("    - %s (%s): up %s, booted %s\n", host.Name, state, formatUptime(host.UptimeSeconds), booted)
+			if host.RebootRequired {
+				fmt.Println("      WARNING: reboot required")
+			}
+		}
+		for _, w := range info.Warnings {
+			fmt.Printf("  WARNING: %s\n", w)
+		}
+	}
+
+	fmt.Printf("\nHosts waiting for a reboot: %d\n", len(report.RebootRequired))
+	for _, name := range report.RebootRequired {
+		fmt.Printf("  - %s\n", name)
+	}
+
+	return nil
+}
+
+// hostMembershipWarning tells why a host doesn't contribute to its cluster, empty if it does
+func hostMembershipWarning(host HostInfo) string {
+	switch {
+	case host.ConnectionState != string(types.HostSystemConnectionStateConnected):
+		return "is " + host.ConnectionState
+	case host.PowerState != string(types.HostSystemPowerStatePoweredOn):
+		return "is " + host.PowerState
+	case host.InMaintenanceMode:
+		return "is in maintenance mode"
+	}
+	return ""
+}
+
+// formatUptime formats an uptime in seconds as days and hours
+func formatUptime(seconds int32) string {
+	d := time.Duration(seconds) * time.Second
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh", days, hours)
+	}
+	return fmt.Sprintf("%dh %dm", hours, int(d.Minutes())%60)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// idleCounters are the performance counters VM activity is measured by, CPU usage in
+// hundredths of a percent, disk and network throughput in KBps
+var idleCounters = []string{"cpu.usage.average", "disk.usage.average", "net.usage.average"}
+
+// idlePercentile is the percentile of the samples compared to the thresholds, so a nightly
+// backup or virus scan doesn't make an otherwise idle VM look busy
+const idlePercentile = 95
+
+type IdleVMInfo struct {
+	Name    string `json:"name"`
+	Cluster string `json:"cluster"`
+	// CPUPct, DiskKBps and NetworkKBps are the 95th percentiles over the window
+	CPUPct      float64 `json:"cpu_pct"`
+	DiskKBps    int64   `json:"disk_kbps"`
+	NetworkKBps int64   `json:"network_kbps"`
+	Committed   float64 `json:"committed_gb"`
+}
+
+type IdleReport struct {
+	Datacenter string       `json:"datacenter"`
+	WindowDays int          `json:"window_days"`
+	IdleVMs    []IdleVMInfo `json:"idle_vms"`
+	// Committed sums up the space of the idle VMs
+	Committed float64 `json:"committed_gb"`
+	// NoData counts the powered on VMs without performance samples in the window
+	NoData int `json:"vms_without_data"`
+}
+
+// reportIdle lists the powered on VMs whose CPU, disk and network activity stayed below
+// -idle-cpu-pct and -idle-io-kbps over the last -idle-days according to the historical
+// performance samples of vCenter, with their datastore footprint, largest first
+func reportIdle(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	if cfg.IdleDays <= 0 {
+		return fmt.Errorf("-idle-days must be at least 1")
+	}
+
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	report := IdleReport{
+		Datacenter: dc.Name(),
+		WindowDays: cfg.IdleDays,
+		IdleVMs:    make([]IdleVMInfo, 0),
+	}
+	window := time.Duration(cfg.IdleDays) * 24 * time.Hour
+
+	for _, cluster := range clusters {
+		var vms []mo.VirtualMachine
+		err = retrieveClusterVMs(ctx, client, cluster, []string{"name", "config.template", "runtime.powerState", "summary.storage"}, &vms)
+		if err != nil {
+			return fmt.Errorf("getting VMs of cluster %s: %s", cluster.Name(), err)
+		}
+
+		var refs []types.ManagedObjectReference
+		running := make(map[string]mo.VirtualMachine)
+		for _, vm := range vms {
+			if vm.Config == nil || vm.Config.Template || vm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+				continue
+			}
+			refs = append(refs, vm.Self)
+			running[vm.Self.Value] = vm
+		}
+		if len(refs) == 0 {
+			continue
+		}
+
+		samples, err := entityMetrics(ctx, client, refs, idleCounters, window)
+		if err != nil {
+			return fmt.Errorf("querying performance of cluster %s: %s", cluster.Name(), err)
+		}
+
+		for _, ref := range refs {
+			vm := running[ref.Value]
+			metrics, ok := samples[ref.Value]
+			if !ok || len(metrics["cpu.usage.average"]) == 0 {
+				report.NoData++
+				continue
+			}
+
+			info := IdleVMInfo{
+				Name:        vm.Name,
+				Cluster:     cluster.Name(),
+				CPUPct:      float64(percentile(metrics["cpu.usage.average"], idlePercentile)) / 100,
+				DiskKBps:    percentile(metrics["disk.usage.average"], idlePercentile),
+				NetworkKBps: percentile(metrics["net.usage.average"], idlePercentile),
+			}
+			if info.CPUPct >= cfg.IdleCPUPct || info.DiskKBps >= cfg.IdleIOKBps || info.NetworkKBps >= cfg.IdleIOKBps {
+				continue
+			}
+			if vm.Summary.Storage != nil {
+				info.Committed = bytesToGB(vm.Summary.Storage.Committed)
+			}
+
+			report.IdleVMs = append(report.IdleVMs, info)
+			report.Committed += info.Committed
+		}
+	}
+	sort.Slice(report.IdleVMs, func(i, j int) bool {
+		a, b := report.IdleVMs[i], report.IdleVMs[j]
+		if a.Committed != b.Committed {
+			return a.Committed > b.Committed
+		}
+		return a.Name < b.Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nIdle VMs over the last %d days: %d (%s committed)\n", report.WindowDays, len(report.IdleVMs), formatGB(report.Committed))
+	for _, vm := range report.IdleVMs {
+		fmt.Printf("  %s (cluster %s): CPU %s, disk %d KBps, network %d KBps, %s committed\n",
+			vm.Name, vm.Cluster, formatPct(vm.CPUPct), vm.DiskKBps, vm.NetworkKBps, formatGB(vm.Committed))
+	}
+	if report.NoData > 0 {
+		fmt.Printf("\nVMs without performance data in the window: %d\n", report.NoData)
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type ISOFileInfo struct {
+	Datastore    string     `json:"datastore"`
+	Path         string     `json:"path"`
+	Size         float64    `json:"size_gb"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+	MountedBy    []string   `json:"mounted_by"`
+}
+
+type ISOReport struct {
+	Datacenter string        `json:"datacenter"`
+	TotalSize  float64       `json:"total_size_gb"`
+	Files      []ISOFileInfo `json:"files"`
+	Errors     []string      `json:"errors"`
+}
+
+// datastoreFile is a file found by searching a datastore
+type datastoreFile struct {
+	Datastore string
+	Path      string
+	Size      int64
+	Modified  *time.Time
+}
+
+// reportISOFiles scans all datastores for ISO images and shows which VMs have them mounted
+func reportISOFiles(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	var vms []mo.VirtualMachine
+	err := retrieveAll(ctx, client, dc, "VirtualMachine", []string{"name", "config.hardware.device"}, &vms)
+	if err != nil {
+		return fmt.Errorf("retrieving VMs: %s", err)
+	}
+
+	// Map mounted ISO image paths to the VMs using them
+	mounts := make(map[string][]string)
+	for _, vm := range vms {
+		if vm.Config == nil {
+			continue
+		}
+		for _, device := range vm.Config.Hardware.Device {
+			cdrom, ok := device.(*types.VirtualCdrom)
+			if !ok {
+				continue
+			}
+			if backing, ok := cdrom.Backing.(*types.VirtualCdromIsoBackingInfo); ok {
+				file := normalizeDatastorePath(backing.FileName)
+				mounts[file] = append(mounts[file], vm.Name)
+			}
+		}
+	}
+
+	var datastores []mo.Datastore
+	err = retrieveAll(ctx, client, dc, "Datastore", []string{"name", "summary.accessible", "browser"}, &datastores)
+	if err != nil {
+		return fmt.Errorf("retrieving datastores: %s", err)
+	}
+	datastores = withoutExcludedDatastores(cfg, datastores)
+
+	report := ISOReport{
+		Datacenter: dc.Name(),
+		Files:      make([]ISOFileInfo, 0),
+		Errors:     make([]string, 0),
+	}
+
+	// search the datastores concurrently, the results are merged in datastore order
+	found := make([][]datastoreFile, len(datastores))
+	searchErrs := make([]error, len(datastores))
+	forEachConcurrently(cfg, len(datastores), func(i int) {
+		if datastores[i].Summary.Accessible {
+			found[i], searchErrs[i] = searchDatastore(ctx, client, datastores[i], []string{"*.iso"})
+		}
+	})
+
+	var total int64
+	for i, ds := range datastores {
+		if !ds.Summary.Accessible {
+			report.Errors = append(report.Errors, fmt.Sprintf("datastore %s is not accessible", ds.Name))
+			continue
+		}
+
+		files, err := found[i], searchErrs[i]
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("searching datastore %s: %s", ds.Name, err))
+			continue
+		}
+
+		for _, file := range files {
+			total += file.Size
+			mountedBy := mounts[file.Path]
+			if mountedBy == nil {
+				mountedBy = make([]string, 0)
+			}
+			report.Files = append(report.Files, ISOFileInfo{
+				Datastore:    file.Datastore,
+				Path:         file.Path,
+				Size:         bytesToGB(file.Size),
+				LastModified: file.Modified,
+				MountedBy:    mountedBy,
+			})
+		}
+	}
+	report.TotalSize = bytesToGB(total)
+
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].Size > report.Files[j].Size
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nISO images: %d (%s)\n", len(report.Files), formatGB(report.TotalSize))
+	for _, file := range report.Files {
+		modified := "unknown"
+		if file.LastModified != nil {
+			modified = file.LastModified.Format("2006-01-02")
+		}
+		fmt.Printf("  - %s (%s, modified %s)\n", file.Path, formatGB(file.Size), modified)
+		if len(file.MountedBy) > 0 {
+			fmt.Printf("      Mounted by: %s\n", strings.Join(file.MountedBy, ", "))
+		}
+	}
+
+	for _, e := range report.Errors {
+		fmt.Printf("  Error: %s\n", e)
+	}
+
+	return nil
+}
+
+// searchDatastore recursively searches a datastore for files matching any of the patterns
+func searchDatastore(ctx context.Context, client *govmomi.Client, ds mo.Datastore, patterns []string) ([]datastoreFile, error) {
+	if ds.Browser.Value == "" {
+		return nil, fmt.Errorf("no datastore browser available")
+	}
+	browser := object.NewHostDatastoreBrowser(client.Client, ds.Browser)
+
+	caseInsensitive := true
+	spec := types.HostDatastoreBrowserSearchSpec{
+		MatchPattern:          patterns,
+		SearchCaseInsensitive: &caseInsensitive,
+		Details: &types.FileQueryFlags{
+			FileType:     true,
+			FileSize:     true,
+			Modification: true,
+		},
+	}
+
+	task, err := browser.SearchDatastoreSubFolders(ctx, fmt.Sprintf("[%s]", ds.Name), &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []types.HostDatastoreBrowserSearchResults
+	switch r := info.Result.(type) {
+	case types.ArrayOfHostDatastoreBrowserSearchResults:
+		results = r.HostDatastoreBrowserSearchResults
+	case types.HostDatastoreBrowserSearchResults:
+		results = []types.HostDatastoreBrowserSearchResults{r}
+	}
+
+	var files []datastoreFile
+	for _, result := range results {
+		var folder object.DatastorePath
+		folder.FromString(result.FolderPath)
+
+		for _, f := range result.File {
+			fi := f.GetFileInfo()
+			p := object.DatastorePath{Datastore: ds.Name, Path: strings.TrimPrefix(path.Join(folder.Path, fi.Path), "/")}
+			files = append(files, datastoreFile{
+				Datastore: ds.Name,
+				Path:      p.String(),
+				Size:      fi.FileSize,
+				Modified:  fi.Modification,
+			})
+		}
+	}
+
+	return files, nil
+}
+
+// normalizeDatastorePath formats a datastore path as "[datastore] folder/file"
+func normalizeDatastorePath(s string) string {
+	var p object.DatastorePath
+	if !p.FromString(s) {
+		return s
+	}
+	p.Path = strings.TrimPrefix(path.Clean(p.Path), "/")
+	return p.String()
+}
@@ -0,0 +1,574 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// KafkaConfig sets the Kafka cluster the datastores command publishes its snapshots and change
+// events to. Messages are keyed by datacenter, so the scans of a datacenter stay in order.
+type KafkaConfig struct {
+	// Brokers are the bootstrap servers as host:port
+	Brokers []string `json:"brokers"`
+	// Topic defaults to godcinfo.snapshots
+	Topic string `json:"topic,omitempty"`
+	// EventsTopic defaults to godcinfo.changes
+	EventsTopic string `json:"events_topic,omitempty"`
+	// Format is json (default) or avro
+	Format   string `json:"format,omitempty"`
+	TLS      bool   `json:"tls,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+	// Username and Password authenticate with SASL PLAIN, the password defaults to KAFKA_PASSWORD
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+const (
+	kafkaDefaultTopic       = "godcinfo.snapshots"
+	kafkaDefaultEventsTopic = "godcinfo.changes"
+)
+
+// Kafka API keys and the versions used, the oldest Kafka 4 still supports
+const (
+	kafkaAPIProduce          = 0
+	kafkaAPIMetadata         = 3
+	kafkaAPISaslHandshake    = 17
+	kafkaAPISaslAuthenticate = 36
+
+	kafkaProduceVersion       = 3
+	kafkaMetadataVersion      = 4
+	kafkaSaslHandshakeVersion = 1
+)
+
+// kafkaErrors names the error codes a producer gets to see
+var kafkaErrors = map[int16]string{
+	1:  "OFFSET_OUT_OF_RANGE",
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	17: "INVALID_TOPIC_EXCEPTION",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	31: "CLUSTER_AUTHORIZATION_FAILED",
+	33: "UNSUPPORTED_SASL_MECHANISM",
+	34: "ILLEGAL_SASL_STATE",
+	35: "UNSUPPORTED_VERSION",
+	58: "SASL_AUTHENTICATION_FAILED",
+	87: "INVALID_RECORD",
+}
+
+func kafkaError(code int16) error {
+	if name, ok := kafkaErrors[code]; ok {
+		return fmt.Errorf("%s (%d)", name, code)
+	}
+	return fmt.Errorf("error code %d", code)
+}
+
+// kafkaMessage is a record to produce
+type kafkaMessage struct {
+	topic string
+	key   []byte
+	value []byte
+}
+
+func (c KafkaConfig) validate() error {
+	if len(c.Brokers) == 0 {
+		return fmt.Errorf("needs brokers")
+	}
+	return validateFormat(c.Format)
+}
+
+// publish produces the report and a record per change event and waits until all in-sync
+// replicas have them
+func (c KafkaConfig) publish(infra InfrastructureInfo, changes []ChangeEvent) error {
+	topic, eventsTopic := c.Topic, c.EventsTopic
+	if topic == "" {
+		topic = kafkaDefaultTopic
+	}
+	if eventsTopic == "" {
+		eventsTopic = kafkaDefaultEventsTopic
+	}
+
+	key := []byte(infra.Datacenter)
+	payload, err := encodeSnapshot(c.Format, infra)
+	if err != nil {
+		return err
+	}
+	msgs := []kafkaMessage{{topic, key, payload}}
+	for _, change := range changes {
+		event, err := encodeChange(c.Format, change)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, kafkaMessage{eventsTopic, key, event})
+	}
+
+	topics := []string{topic}
+	if len(changes) > 0 && eventsTopic != topic {
+		topics = append(topics, eventsTopic)
+	}
+
+	var conn *kafkaConn
+	for _, broker := range c.Brokers {
+		conn, err = c.dial(broker)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	metadata, err := conn.metadata(topics)
+	conn.Close()
+	if err != nil {
+		return err
+	}
+
+	// the records go to the leader of the partition of their key, like the Java client does
+	byLeader := make(map[int32]map[string]map[int32][]kafkaMessage)
+	for _, msg := range msgs {
+		partitions := metadata.topics[msg.topic]
+		partition := int32(kafkaPartition(msg.key, len(partitions)))
+		leader := partitions[partition]
+		if byLeader[leader] == nil {
+			byLeader[leader] = make(map[string]map[int32][]kafkaMessage)
+		}
+		if byLeader[leader][msg.topic] == nil {
+			byLeader[leader][msg.topic] = make(map[int32][]kafkaMessage)
+		}
+		byLeader[leader][msg.topic][partition] = append(byLeader[leader][msg.topic][partition], msg)
+	}
+
+	for leader, batches := range byLeader {
+		addr, ok := metadata.brokers[leader]
+		if !ok {
+			return fmt.Errorf("unknown leader broker %d", leader)
+		}
+		conn, err := c.dial(addr)
+		if err != nil {
+			return err
+		}
+		err = conn.produce(batches)
+		conn.Close()
+		if err != nil {
+			return fmt.Errorf("broker %s: %s", addr, err)
+		}
+	}
+
+	return nil
+}
+
+// kafkaConn is a connection to a broker
+type kafkaConn struct {
+	net.Conn
+	r           *bufio.Reader
+	correlation int32
+}
+
+// dial connects to a broker, with TLS and SASL PLAIN when configured
+func (c KafkaConfig) dial(addr string) (*kafkaConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(60 * time.Second))
+
+	if c.TLS {
+		host, _, _ := net.SplitHostPort(addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: c.Insecure})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	kc := &kafkaConn{Conn: conn, r: bufio.NewReader(conn)}
+	if c.Username != "" {
+		if err := kc.authenticate(c.Username, c.Password); err != nil {
+			kc.Close()
+			return nil, fmt.Errorf("authenticating to %s: %s", addr, err)
+		}
+	}
+	return kc, nil
+}
+
+// request sends a request with a v1 header and returns the response after its header
+func (kc *kafkaConn) request(apiKey, version int16, body []byte) (*kafkaDecoder, error) {
+	kc.correlation++
+	e := &kafkaEncoder{}
+	e.int32(0) // the size, set below
+	e.int16(apiKey)
+	e.int16(version)
+	e.int32(kc.correlation)
+	e.string("godcinfo")
+	e.buf = append(e.buf, body...)
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+	if _, err := kc.Write(e.buf); err != nil {
+		return nil, err
+	}
+
+	var size int32
+	if err := binary.Read(kc.r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 4 {
+		return nil, fmt.Errorf("invalid response size %d", size)
+	}
+	response := make([]byte, size)
+	if _, err := io.ReadFull(kc.r, response); err != nil {
+		return nil, err
+	}
+	d := &kafkaDecoder{buf: response}
+	if id := d.int32(); id != kc.correlation {
+		return nil, fmt.Errorf("response to request %d instead of %d", id, kc.correlation)
+	}
+	return d, nil
+}
+
+func (kc *kafkaConn) authenticate(username, password string) error {
+	e := &kafkaEncoder{}
+	e.string("PLAIN")
+	d, err := kc.request(kafkaAPISaslHandshake, kafkaSaslHandshakeVersion, e.buf)
+	if err != nil {
+		return err
+	}
+	if code := d.int16(); code != 0 {
+		return kafkaError(code)
+	}
+
+	e = &kafkaEncoder{}
+	e.bytes([]byte("\x00" + username + "\x00" + password))
+	d, err = kc.request(kafkaAPISaslAuthenticate, 0, e.buf)
+	if err != nil {
+		return err
+	}
+	code := d.int16()
+	msg := d.nullableString()
+	if d.err != nil {
+		return d.err
+	}
+	if code != 0 {
+		if msg != "" {
+			return fmt.Errorf("%s: %s", kafkaError(code), msg)
+		}
+		return kafkaError(code)
+	}
+	return nil
+}
+
+// kafkaMetadata holds the broker addresses and the partition leaders of the topics
+type kafkaMetadata struct {
+	brokers map[int32]string
+	// topics maps the topic names to the leader of each partition
+	topics map[string][]int32
+}
+
+// metadata looks up the partitions of the topics. Topics the cluster creates on first use
+// have no leader for a moment, which is waited for.
+func (kc *kafkaConn) metadata(topics []string) (kafkaMetadata, error) {
+	for attempt := 1; ; attempt++ {
+		metadata, retry, err := kc.requestMetadata(topics)
+		if err == nil || !retry || attempt == 5 {
+			return metadata, err
+		}
+		time.Sleep(time.Duration(attempt) * 500 * time.Millisecond)
+	}
+}
+
+func (kc *kafkaConn) requestMetadata(topics []string) (metadata kafkaMetadata, retry bool, err error) {
+	e := &kafkaEncoder{}
+	e.int32(int32(len(topics)))
+	for _, topic := range topics {
+		e.string(topic)
+	}
+	e.boolean(true) // allow_auto_topic_creation
+	d, err := kc.request(kafkaAPIMetadata, kafkaMetadataVersion, e.buf)
+	if err != nil {
+		return metadata, false, err
+	}
+
+	metadata.brokers = make(map[int32]string)
+	metadata.topics = make(map[string][]int32)
+	d.int32() // throttle_time_ms
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.nullableString() // rack
+		metadata.brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.nullableString() // cluster_id
+	d.int32()          // controller_id
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		code := d.int16()
+		name := d.string()
+		d.boolean() // is_internal
+		var leaders []int32
+		for p := d.int32(); p > 0 && d.err == nil; p-- {
+			partitionCode := d.int16()
+			index := d.int32()
+			leader := d.int32()
+			d.int32Array() // replica_nodes
+			d.int32Array() // isr_nodes
+			if partitionCode == 5 || leader < 0 {
+				retry = true
+			}
+			for int(index) >= len(leaders) {
+				leaders = append(leaders, -1)
+			}
+			leaders[index] = leader
+		}
+		if d.err != nil {
+			break
+		}
+		if code != 0 {
+			return metadata, code == 5, fmt.Errorf("topic %s: %s", name, kafkaError(code))
+		}
+		if len(leaders) == 0 {
+			return metadata, true, fmt.Errorf("topic %s has no partitions", name)
+		}
+		if retry {
+			return metadata, true, fmt.Errorf("topic %s: partition without leader", name)
+		}
+		metadata.topics[name] = leaders
+	}
+	if d.err != nil {
+		return metadata, false, d.err
+	}
+	for _, topic := range topics {
+		if _, ok := metadata.topics[topic]; !ok {
+			return metadata, false, fmt.Errorf("topic %s: missing in the metadata", topic)
+		}
+	}
+	return metadata, false, nil
+}
+
+// produce sends the records of the partitions this broker leads with acks from all in-sync
+// replicas
+func (kc *kafkaConn) produce(batches map[string]map[int32][]kafkaMessage) error {
+	e := &kafkaEncoder{}
+	e.nullableString("") // transactional_id
+	e.int16(-1)          // acks
+	e.int32(30000)       // timeout_ms
+	e.int32(int32(len(batches)))
+	for topic, partitions := range batches {
+		e.string(topic)
+		e.int32(int32(len(partitions)))
+		for partition, msgs := range partitions {
+			e.int32(partition)
+			e.bytes(kafkaRecordBatch(msgs, time.Now()))
+		}
+	}
+	d, err := kc.request(kafkaAPIProduce, kafkaProduceVersion, e.buf)
+	if err != nil {
+		return err
+	}
+
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		topic := d.string()
+		for p := d.int32(); p > 0 && d.err == nil; p-- {
+			partition := d.int32()
+			code := d.int16()
+			d.int64() // base_offset
+			d.int64() // log_append_time_ms
+			if code != 0 && d.err == nil {
+				return fmt.Errorf("topic %s partition %d: %s", topic, partition, kafkaError(code))
+			}
+		}
+	}
+	return d.err
+}
+
+// kafkaRecordBatch encodes the records in the v2 batch format with a CRC-32C checksum
+func kafkaRecordBatch(msgs []kafkaMessage, now time.Time) []byte {
+	timestamp := now.UnixMilli()
+
+	// the part covered by the checksum
+	e := &kafkaEncoder{}
+	e.int16(0) // attributes: no compression, create time
+	e.int32(int32(len(msgs) - 1))
+	e.int64(timestamp) // base timestamp
+	e.int64(timestamp) // max timestamp
+	e.int64(-1)        // producer_id
+	e.int16(-1)        // producer_epoch
+	e.int32(-1)        // base_sequence
+	e.int32(int32(len(msgs)))
+	for i, msg := range msgs {
+		var record []byte
+		record = append(record, 0)                     // attributes
+		record = binary.AppendVarint(record, 0)        // timestamp delta
+		record = binary.AppendVarint(record, int64(i)) // offset delta
+		record = binary.AppendVarint(record, int64(len(msg.key)))
+		record = append(record, msg.key...)
+		record = binary.AppendVarint(record, int64(len(msg.value)))
+		record = append(record, msg.value...)
+		record = binary.AppendVarint(record, 0) // headers
+		e.buf = binary.AppendVarint(e.buf, int64(len(record)))
+		e.buf = append(e.buf, record...)
+	}
+
+	batch := &kafkaEncoder{}
+	batch.int64(0)                             // base_offset, set by the broker
+	batch.int32(int32(4 + 1 + 4 + len(e.buf))) // the length after this field
+	batch.int32(-1)                            // partition_leader_epoch
+	batch.buf = append(batch.buf, 2)           // magic
+	batch.int32(int32(crc32.Checksum(e.buf, crc32.MakeTable(crc32.Castagnoli))))
+	batch.buf = append(batch.buf, e.buf...)
+	return batch.buf
+}
+
+// kafkaPartition picks the partition of a key with the murmur2 hash of the default partitioner
+func kafkaPartition(key []byte, partitions int) int {
+	return int(murmur2(key)&0x7fffffff) % partitions
+}
+
+// murmur2 is the 32-bit MurmurHash2 with the seed of the Kafka clients
+func murmur2(data []byte) int32 {
+	const (
+		seed uint32 = 0x9747b28c
+		m    uint32 = 0x5bd1e995
+		r           = 24
+	)
+	length := len(data)
+	h := seed ^ uint32(length)
+
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// kafkaEncoder writes the big-endian primitives of the Kafka protocol
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *kafkaEncoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *kafkaEncoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+
+func (e *kafkaEncoder) boolean(v bool) {
+	if v {
+		e.buf = append(e.buf, 1)
+	} else {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// nullableString writes empty strings as null
+func (e *kafkaEncoder) nullableString(s string) {
+	if s == "" {
+		e.int16(-1)
+		return
+	}
+	e.string(s)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// kafkaDecoder reads a response, the first error stops reading and is kept in err
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = fmt.Errorf("truncated response")
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) boolean() bool {
+	if b := d.next(1); b != nil {
+		return b[0] != 0
+	}
+	return false
+}
+
+func (d *kafkaDecoder) string() string {
+	return string(d.next(int(d.int16())))
+}
+
+func (d *kafkaDecoder) nullableString() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *kafkaDecoder) int32Array() {
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		d.int32()
+	}
+}
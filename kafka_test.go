@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+	"time"
+)
+
+func TestMurmur2(t *testing.T) {
+	// the test vectors of the Kafka clients
+	tests := []struct {
+		in   string
+		want int32
+	}{
+		{"21", -973932308},
+		{"foobar", -790332482},
+		{"a-little-bit-long-string", -985981536},
+		{"a-little-bit-longer-string", -1486304829},
+		{"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", -58897971},
+		{"abc", 479470107},
+	}
+
+	for _, tt := range tests {
+		if got := murmur2([]byte(tt.in)); got != tt.want {
+			t.Errorf("murmur2(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestKafkaRecordBatch(t *testing.T) {
+	msgs := []kafkaMessage{
+		{"snapshots", []byte("DC0"), []byte(`{"datacenter":"DC0"}`)},
+		{"snapshots", []byte("DC0"), []byte(`{}`)},
+	}
+	batch := kafkaRecordBatch(msgs, time.UnixMilli(1700000000000))
+
+	if length := int(binary.BigEndian.Uint32(batch[8:])); length != len(batch)-12 {
+		t.Errorf("batch length = %d, want %d", length, len(batch)-12)
+	}
+	if magic := batch[16]; magic != 2 {
+		t.Errorf("magic = %d, want 2", magic)
+	}
+	crc := binary.BigEndian.Uint32(batch[17:])
+	if want := crc32.Checksum(batch[21:], crc32.MakeTable(crc32.Castagnoli)); crc != want {
+		t.Errorf("crc = %x, want %x", crc, want)
+	}
+	if lastOffsetDelta := binary.BigEndian.Uint32(batch[23:]); lastOffsetDelta != 1 {
+		t.Errorf("last offset delta = %d, want 1", lastOffsetDelta)
+	}
+	if count := binary.BigEndian.Uint32(batch[57:]); count != 2 {
+		t.Errorf("record count = %d, want 2", count)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// keychainService is the service the passwords are stored under in the OS keychain
+const keychainService = "godcinfo"
+
+// errKeychainNotFound is returned when the keychain holds no password for the account
+var errKeychainNotFound = errors.New("no password in the keychain")
+
+// keychainAccount returns the keychain account of the vSphere user, user@vcenter
+func keychainAccount(cfg *Config) (string, error) {
+	u, err := soap.ParseURL(cfg.URL)
+	if err != nil {
+		return "", err
+	}
+	if u == nil || cfg.Username == "" {
+		return "", fmt.Errorf("set -url and -username")
+	}
+	return cfg.Username + "@" + u.Host, nil
+}
+
+// keychainPassword looks up the password of the vSphere user in the OS keychain when no
+// password was given, the keychain is left alone when the URL or username is missing
+func keychainPassword(cfg *Config) {
+	if cfg.Password != "" || cfg.URL == "" || cfg.Username == "" {
+		return
+	}
+	account, err := keychainAccount(cfg)
+	if err != nil {
+		return
+	}
+
+	password, err := keychainGet(account)
+	if err != nil {
+		if !errors.Is(err, errKeychainNotFound) && !cfg.OutputJSON {
+			fmt.Fprintf(os.Stderr, "Warning: reading the password from the keychain: %s\n", err)
+		}
+		return
+	}
+	cfg.Password = password
+}
+
+// loginKeychain stores the password of the vSphere user in the OS keychain, the password is
+// read from the terminal without echo or from stdin. With login delete the password is
+// removed again.
+func loginKeychain(cfg *Config) error {
+	account, err := keychainAccount(cfg)
+	if err != nil {
+		return fmt.Errorf("login needs the vCenter and user: %s", err)
+	}
+
+	if len(cfg.Args) > 0 {
+		if cfg.Args[0] != "delete" || len(cfg.Args) > 1 {
+			return fmt.Errorf("unknown login argument %s: login [delete]", strings.Join(cfg.Args, " "))
+		}
+		if err := keychainDelete(account); err != nil {
+			return fmt.Errorf("deleting the password of %s from the keychain: %s", account, err)
+		}
+		fmt.Printf("Deleted the password of %s from the keychain\n", account)
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Password for %s: ", account)
+	password, err := readPassword()
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("reading the password: %s", err)
+	}
+	if password == "" {
+		return fmt.Errorf("empty password")
+	}
+
+	if err := keychainSet(account, password); err != nil {
+		return fmt.Errorf("storing the password of %s in the keychain: %s", account, err)
+	}
+	fmt.Printf("Stored the password of %s in the keychain\n", account)
+	return nil
+}
+
+// readLine reads the password line from stdin
+func readLine() (string, error) {
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
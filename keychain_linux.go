@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// the Secret Service (GNOME Keyring, KWallet) is reached through secret-tool of libsecret,
+// the password is passed on stdin, so it doesn't show up in the process list
+
+func keychainGet(account string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", keychainService, "account", account)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", secretToolError(err, stderr.String())
+	}
+	// secret-tool lookup exits with 0 without output when nothing matches on some versions
+	if len(out) == 0 {
+		return "", errKeychainNotFound
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func keychainSet(account, password string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "store", "--label", fmt.Sprintf("%s %s", keychainService, account), "service", keychainService, "account", account)
+	cmd.Stdin = strings.NewReader(password)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return secretToolError(err, stderr.String())
+	}
+	return nil
+}
+
+func keychainDelete(account string) error {
+	if _, err := keychainGet(account); err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "clear", "service", keychainService, "account", account)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return secretToolError(err, stderr.String())
+	}
+	return nil
+}
+
+// secretToolError turns a failed secret-tool run into an error, secret-tool exits with 1
+// without a message when the password doesn't exist
+func secretToolError(err error, stderr string) error {
+	if _, ok := err.(*exec.Error); ok {
+		return fmt.Errorf("secret-tool of libsecret is needed for the Secret Service: %s", err)
+	}
+	msg := strings.TrimSpace(stderr)
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 && msg == "" {
+		return errKeychainNotFound
+	}
+	if msg != "" {
+		return fmt.Errorf("%s: %s", err, msg)
+	}
+	return err
+}
@@ -0,0 +1,22 @@
+//go:build !darwin && !linux && !windows
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+var errKeychainUnsupported = fmt.Errorf("no keychain support on %s", runtime.GOOS)
+
+func keychainGet(account string) (string, error) {
+	return "", errKeychainNotFound
+}
+
+func keychainSet(account, password string) error {
+	return errKeychainUnsupported
+}
+
+func keychainDelete(account string) error {
+	return errKeychainUnsupported
+}
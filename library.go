@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/library"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+type ContentLibraryInfo struct {
+	Name       string         `json:"name"`
+	Type       string         `json:"type"`
+	Datastores []string       `json:"datastores"`
+	ItemCount  int            `json:"item_count"`
+	ItemTypes  map[string]int `json:"item_types"`
+	Size       float64        `json:"size_gb"`
+}
+
+type LibraryDatastoreUsage struct {
+	Datastore string  `json:"datastore"`
+	Libraries int     `json:"libraries"`
+	Size      float64 `json:"size_gb"`
+}
+
+type ContentLibraryReport struct {
+	Datacenter     string                  `json:"datacenter"`
+	Libraries      []ContentLibraryInfo    `json:"libraries"`
+	DatastoreUsage []LibraryDatastoreUsage `json:"datastore_usage"`
+}
+
+// reportContentLibraries lists content libraries with their backing datastores,
+// item counts and consumed size, and sums up library usage per datastore
+func reportContentLibraries(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	rc, err := connectToREST(ctx, client, cfg)
+	if err != nil {
+		return fmt.Errorf("connecting to the vSphere REST API: %s", err)
+	}
+	defer rc.Logout(ctx)
+
+	dsNames, err := datastoreNames(ctx, client, dc)
+	if err != nil {
+		return fmt.Errorf("retrieving datastores: %s", err)
+	}
+
+	m := library.NewManager(rc)
+	libraries, err := m.GetLibraries(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieving content libraries: %s", err)
+	}
+
+	report := ContentLibraryReport{
+		Datacenter:     dc.Name(),
+		Libraries:      make([]ContentLibraryInfo, 0, len(libraries)),
+		DatastoreUsage: make([]LibraryDatastoreUsage, 0),
+	}
+	usage := make(map[string]*LibraryDatastoreUsage)
+
+	for _, lib := range libraries {
+		items, err := m.GetLibraryItems(ctx, lib.ID)
+		if err != nil {
+			return fmt.Errorf("retrieving items of library %s: %s", lib.Name, err)
+		}
+
+		info := ContentLibraryInfo{
+			Name:       lib.Name,
+			Type:       lib.Type,
+			Datastores: make([]string, 0, len(lib.Storage)),
+			ItemCount:  len(items),
+			ItemTypes:  make(map[string]int),
+		}
+
+		var size int64
+		for _, item := range items {
+			size += item.Size
+			info.ItemTypes[item.Type]++
+		}
+		info.Size = bytesToGB(size)
+
+		// the items are spread over the backings, which one holds an item isn't known
+		// without a storage query per item, so every backing gets an equal share
+		var share float64
+		if len(lib.Storage) > 0 {
+			share = bytesToGB(size / int64(len(lib.Storage)))
+		}
+		for _, backing := range lib.Storage {
+			if backing.DatastoreID == "" {
+				// library stored on another file system
+				continue
+			}
+			name, ok := dsNames[backing.DatastoreID]
+			if !ok {
+				name = backing.DatastoreID
+			}
+			info.Datastores = append(info.Datastores, name)
+
+			u, exists := usage[name]
+			if !exists {
+				u = &LibraryDatastoreUsage{Datastore: name}
+				usage[name] = u
+			}
+			u.Libraries++
+			u.Size += share
+		}
+
+		report.Libraries = append(report.Libraries, info)
+	}
+
+	for _, u := range usage {
+		report.DatastoreUsage = append(report.DatastoreUsage, *u)
+	}
+	sort.Slice(report.DatastoreUsage, func(i, j int) bool {
+		return report.DatastoreUsage[i].Size > report.DatastoreUsage[j].Size
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	if len(report.Libraries) == 0 {
+		fmt.Println("\nNo content libraries found")
+		return nil
+	}
+
+	fmt.Println("\nContent Libraries:")
+	for _, info := range report.Libraries {
+		fmt.Printf("  - %s (%s, %d items, %s)\n", info.Name, info.Type, info.ItemCount, formatGB(info.Size))
+		for _, ds := range info.Datastores {
+			fmt.Printf("      Datastore: %s\n", ds)
+		}
+		itemTypes := make([]string, 0, len(info.ItemTypes))
+		for itemType := range info.ItemTypes {
+			itemTypes = append(itemTypes, itemType)
+		}
+		sort.Strings(itemTypes)
+		for _, itemType := range itemTypes {
+			fmt.Printf("      %s: %d\n", itemType, info.ItemTypes[itemType])
+		}
+	}
+
+	fmt.Println("\nLibrary usage per datastore:")
+	for _, u := range report.DatastoreUsage {
+		fmt.Printf("  - %s (%d libraries, %s)\n", u.Datastore, u.Libraries, formatGB(u.Size))
+	}
+
+	return nil
+}
+
+// datastoreNames maps the managed object IDs of all datastores in the datacenter to their names
+func datastoreNames(ctx context.Context, client *govmomi.Client, dc *object.Datacenter) (map[string]string, error) {
+	var datastores []mo.Datastore
+	err := retrieveAll(ctx, client, dc, "Datastore", []string{"name"}, &datastores)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(datastores))
+	for _, ds := range datastores {
+		names[ds.Self.Value] = ds.Name
+	}
+
+	return names, nil
+}
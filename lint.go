@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+type NamingViolation struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+type NamingReport struct {
+	Datacenter string            `json:"datacenter"`
+	Checked    int               `json:"checked"`
+	Violations []NamingViolation `json:"violations"`
+}
+
+// namingCheck is the naming policy of one kind of inventory object
+type namingCheck struct {
+	kind string
+	typ  string
+	re   *regexp.Regexp
+}
+
+// namingChecks returns the configured naming policies
+func namingChecks(n NamingConfig) ([]namingCheck, error) {
+	if n.cluster == nil && n.datastoreCluster == nil && n.datastore == nil {
+		return nil, fmt.Errorf("no naming policies configured, add a \"naming\" section to the -config file")
+	}
+
+	var checks []namingCheck
+	for _, check := range []namingCheck{
+		{"cluster", "ClusterComputeResource", n.cluster},
+		{"datastore_cluster", "StoragePod", n.datastoreCluster},
+		{"datastore", "Datastore", n.datastore},
+	} {
+		if check.re != nil {
+			checks = append(checks, check)
+		}
+	}
+	return checks, nil
+}
+
+// reportNamingViolations checks cluster, datastore cluster and datastore names against
+// the naming policies of the config file. Violations make the command exit nonzero.
+func reportNamingViolations(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	checks, err := namingChecks(cfg.Naming)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string][]string)
+	for _, check := range checks {
+		var entities []mo.ManagedEntity
+		err := retrieveAll(ctx, client, dc, check.typ, []string{"name"}, &entities)
+		if err != nil {
+			return fmt.Errorf("retrieving %s names: %s", check.kind, err)
+		}
+
+		for _, e := range entities {
+			names[check.kind] = append(names[check.kind], e.Name)
+		}
+	}
+
+	return lintNames(dc.Name(), checks, names, cfg)
+}
+
+// lintNames reports the names per kind that violate their naming policy
+func lintNames(datacenter string, checks []namingCheck, names map[string][]string, cfg *Config) error {
+	report := NamingReport{
+		Datacenter: datacenter,
+		Violations: make([]NamingViolation, 0),
+	}
+
+	for _, check := range checks {
+		for _, name := range names[check.kind] {
+			report.Checked++
+			if !check.re.MatchString(name) {
+				report.Violations = append(report.Violations, NamingViolation{
+					Kind:    check.kind,
+					Name:    name,
+					Pattern: check.re.String(),
+				})
+			}
+		}
+	}
+
+	sort.Slice(report.Violations, func(i, j int) bool {
+		a, b := report.Violations[i], report.Violations[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+
+	if cfg.OutputJSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("\nChecked %d names, %d violations\n", report.Checked, len(report.Violations))
+		for _, v := range report.Violations {
+			fmt.Printf("  - %s %s does not match %s\n", v.Kind, v.Name, v.Pattern)
+		}
+	}
+
+	if len(report.Violations) > 0 {
+		return fmt.Errorf("%d naming violations", len(report.Violations))
+	}
+
+	return nil
+}
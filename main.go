@@ -1,19 +1,26 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/url"
 	"os"
+	"path"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/property"
 	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/view"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/soap"
@@ -22,34 +29,240 @@ import (
 
 // connection params
 type Config struct {
-	URL        string
-	Username   string
-	Password   string
-	Insecure   bool
-	Datacenter string
+	Command  string
+	Args     []string
+	URL      string
+	Username string
+	Password string
+	// Certificate and PrivateKey are the -cert and -key files of a solution user or vCenter
+	// extension for certificate login
+	Certificate string
+	PrivateKey  string
+	Insecure    bool
+	// Datacenters are the -datacenter names and patterns, empty selects the default datacenter
+	Datacenters []string
+	Output      string
+	// OutputJSON is set for -o json
 	OutputJSON bool
+	ConfigFile string
+	// Profile is the config file profile with the connection settings and default filters
+	Profile string
+	// Upload is the object storage URL the rendered report is stored below
+	Upload string
+	// Anonymize replaces inventory names in the datastores report with pseudonyms
+	Anonymize    bool
+	AnonymizeKey string
+	// FromFile is a JSON snapshot of the datastores report to analyse instead of connecting
+	FromFile string
+	// Record saves the vCenter responses to this directory, Replay answers from them offline
+	Record string
+	Replay string
+	// DebugSOAP is the directory every request and response is dumped to, redacted
+	DebugSOAP string
+	// Demo runs the command against an in-process vCenter simulator
+	Demo bool
+	// Locale selects the number separators of text output
+	Locale string
+	// MaxConcurrentRequests and RequestDelay limit the load on vCenter
+	MaxConcurrentRequests int
+	RequestDelay          time.Duration
+	// Compress is the compression of uploaded reports, gzip, zstd or empty for none
+	Compress string
+
+	// settings from the config file
+	Thresholds ThresholdConfig
+	Naming     NamingConfig
+	ServiceNow ServiceNowConfig
+	Device42   Device42Config
+	Syslog     SyslogConfig
+	NATS       NATSConfig
+	Kafka      KafkaConfig
+	Hooks      []HookConfig
+	Signing    SigningConfig
+	HostConfig HostConfigPolicy
+
+	// datastore filters
+	MinUsedPct  float64
+	MaxFreePct  float64
+	MinCapacity string
+	// MinCapacity parsed into GB
+	MinCapacityGB float64
+	ExcludeLocal  bool
+	GroupBy       string
+	Tanzu         bool
+	Compute       bool
+	// Clusters are the -cluster names and globs collection is restricted to
+	Clusters []string
+	// DatastoreClusters are the -datastore-cluster names and globs, only their members are reported
+	DatastoreClusters []string
+	// Exclude are the -exclude globs hiding clusters, datastore clusters and datastores by name
+	Exclude []string
+	// DatastoreTags are the -datastore-tag category=value tags, only datastores with one of them are reported
+	DatastoreTags []string
+
+	// exit nonzero when datastores are inaccessible
+	FailOnInaccessible bool
+	// ChangedOnly skips the upload, published snapshot and alerts when the report didn't change
+	ChangedOnly bool
+	// ContinueOnError lists the objects that can't be read in the report, FailOnError ends
+	// the scan with the first of them instead of skipping it
+	ContinueOnError bool
+	FailOnError     bool
+
+	// swap command
+	SwapMinFreePct float64
+
+	// certs command
+	CertWarningDays int
+
+	// powerstate command
+	StaleDays int
+
+	// snapshots command
+	SnapshotAgeDays int
+
+	// density command
+	MaxVMsPerHost  int
+	MaxVCPUPerCore float64
+
+	// idle command
+	IdleDays   int
+	IdleCPUPct float64
+	IdleIOKBps int64
+
+	// rightsize command
+	RightsizeDays int
+	// bench command
+	BenchRuns      int
+	BenchBatchSize int
+
+	// hostlogs command
+	Decommission string
+
+	// sync command
+	DryRun bool
+
+	// check command
+	CheckWarningPct   float64
+	CheckCriticalPct  float64
+	CheckAggregate    bool
+	CheckCertificates bool
 }
 
 type DatastoreInfo struct {
-	Name      string  `json:"name"`
-	Capacity  float64 `json:"capacity_gb"`
-	FreeSpace float64 `json:"free_space_gb"`
+	Name               string    `json:"name"`
+	Type               string    `json:"type"`
+	Capacity           float64   `json:"capacity_gb"`
+	FreeSpace          float64   `json:"free_space_gb"`
+	UsedPct            float64   `json:"used_pct"`
+	Status             string    `json:"status"`
+	Shared             bool      `json:"shared"`
+	MountedHostCount   int       `json:"mounted_host_count"`
+	Accessible         bool      `json:"accessible"`
+	InaccessibleReason string    `json:"inaccessible_reason,omitempty"`
+	VVol               *VVolInfo `json:"vvol,omitempty"`
 }
 
 type DatastoreClusterInfo struct {
-	Name       string          `json:"name"`
-	Datastores []DatastoreInfo `json:"datastores"`
+	Name           string          `json:"name"`
+	TotalCapacity  float64         `json:"total_capacity_gb"`
+	TotalFreeSpace float64         `json:"total_free_space_gb"`
+	UsedPct        float64         `json:"used_pct"`
+	Datastores     []DatastoreInfo `json:"datastores"`
 }
 
 type ClusterInfo struct {
 	Name                 string                 `json:"name"`
 	DatastoreClusters    []DatastoreClusterInfo `json:"datastore_clusters"`
 	StandaloneDatastores []DatastoreInfo        `json:"standalone_datastores"`
+	Totals               CapacityTotals         `json:"totals"`
+	// Supervisor is set with -tanzu for clusters with vSphere with Tanzu enabled
+	Supervisor *SupervisorInfo `json:"supervisor,omitempty"`
+	// Compute is set with -compute
+	Compute *ComputeCapacityInfo `json:"compute,omitempty"`
+}
+
+// CapacityTotals sums up the datastores of a cluster or datacenter, datastores
+// shared between clusters are only counted once per datacenter
+type CapacityTotals struct {
+	DatastoreCount   int     `json:"datastore_count"`
+	SharedDatastores int     `json:"shared_datastore_count,omitempty"`
+	Capacity         float64 `json:"capacity_gb"`
+	FreeSpace        float64 `json:"free_space_gb"`
+	UsedPct          float64 `json:"used_pct"`
 }
 
 type InfrastructureInfo struct {
-	Datacenter string        `json:"datacenter"`
-	Clusters   []ClusterInfo `json:"clusters"`
+	Meta                   *ScanMeta      `json:"meta,omitempty"`
+	Datacenter             string         `json:"datacenter"`
+	Clusters               []ClusterInfo  `json:"clusters"`
+	Totals                 CapacityTotals `json:"totals"`
+	InaccessibleDatastores []string       `json:"inaccessible_datastores"`
+	Errors                 []ScanError    `json:"errors,omitempty"`
+}
+
+// commandFunc runs a single godcinfo command against the selected datacenter
+type commandFunc func(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error
+
+type command struct {
+	Name        string
+	Description string
+	Run         commandFunc
+}
+
+// commands lists the available subcommands, the first one is the default
+var commands = []command{
+	{"datastores", "List datastore clusters and datastores per cluster (default)", reportDatastores},
+	{"compliance", "Check storage policy (SPBM) compliance of VMs and disks", reportCompliance},
+	{"libraries", "Show content library storage usage per datastore", reportContentLibraries},
+	{"heartbeat", "Show HA heartbeat datastores per cluster", reportHeartbeatDatastores},
+	{"clusters", "Show HA admission control, failover capacity, Proactive HA and DPM per cluster", reportClusters},
+	{"swap", "Audit VM swapfile placement and host swap datastores", reportSwapPlacement},
+	{"hostlogs", "Audit host scratch, coredump and syslog locations", reportHostLogLocations},
+	{"hosts", "Show host state, maintenance mode, uptime and pending reboots", reportHosts},
+	{"security", "Audit host lockdown mode, SSH and ESXi Shell and account lockout", reportHostSecurity},
+	{"networks", "List the standard, distributed and NSX networks visible to each cluster", reportNetworks},
+	{"dvswitches", "Show distributed switch uplinks, MTU and portgroup VLANs, flagging VLAN mismatches", reportDistributedSwitches},
+	{"vmkernel", "List host vmkernel adapters with IP, MTU, services and portgroup", reportVMkernelAdapters},
+	{"hostconfig", "Audit host NTP, DNS and remote syslog configuration against the config file", reportHostConfig},
+	{"certs", "Show vCenter and host TLS certificate expiry", reportCertificates},
+	{"tanzu", "Show supervisor clusters, namespaces and their storage quotas and usage", reportTanzu},
+	{"cns", "List Kubernetes CSI (CNS) volumes per datastore with their PVC and size", reportCNSVolumes},
+	{"vsan", "Report on vSAN clusters: vsan health|resync|capacity", reportVSAN},
+	{"sioc", "Show Storage I/O Control congestion thresholds per datastore, flagging non-defaults", reportSIOC},
+	{"scheduledtasks", "List vCenter scheduled tasks on objects in scope with their next run", reportScheduledTasks},
+	{"extensions", "List registered vCenter extensions and plugins with version and server reachability", reportExtensions},
+	{"alarms", "Export alarm definitions for datastores and clusters with expressions and actions", reportAlarms},
+	{"guestos", "Count VMs by guest OS family and version per cluster and datacenter", reportGuestOS},
+	{"powerstate", "Count VMs by power state per cluster and list VMs powered off longer than -stale-days", reportPowerStates},
+	{"tools", "Report the VMware Tools running state and version status of the VMs per cluster", reportTools},
+	{"templates", "List the VM templates with their datastores, size, guest OS and last modification", reportTemplates},
+	{"vapps", "List the vApps per cluster with their members, start order and resource allocations", reportVApps},
+	{"snapshots", "List VM snapshots older than -snapshot-age-days with size and creator, largest first", reportSnapshots},
+	{"ft", "List Fault Tolerance protected VMs per cluster with primary and secondary placement and FT logging network", reportFT},
+	{"drsrules", "List DRS affinity rules and VM/host groups per cluster, flagging references to removed VMs and hosts", reportDRSRules},
+	{"density", "Show VMs per host and vCPUs per core per cluster, warning above -max-vms-per-host or -max-vcpu-per-core", reportDensity},
+	{"idle", "List powered on VMs with near-zero CPU, disk and network activity over -idle-days", reportIdle},
+	{"rightsize", "Suggest downsizing VMs whose CPU and memory usage over -rightsize-days stayed well below their configuration", reportRightsize},
+	{"drsrecommendations", "List pending DRS recommendations per cluster with VM, source and target host, reason and priority", reportDRSRecommendations},
+	{"usage", "Show current CPU and memory utilization per cluster next to storage utilization", reportUsage},
+	{"bench", "Time login, folder traversal and property retrieval over -bench-runs scans with percentiles", runBench},
+	{"isos", "Find ISO images on all datastores and the VMs mounting them", reportISOFiles},
+	{"unregistered", "Find VM directories on datastores that are not in the inventory", reportUnregisteredVMs},
+	{"deltadisks", "Find VMs running on snapshot deltas or linked clones", reportDeltaDisks},
+	{"cbt", "Report Changed Block Tracking status per VM and disk", reportCBT},
+	{"encryption", "Report key providers, encrypted VMs and vTPM usage", reportEncryption},
+	{"lint", "Check cluster and datastore names against the naming policies in the config file", reportNamingViolations},
+	{"check", "Nagios/Icinga plugin checking datastore usage against the thresholds", runCheck},
+	{"sync", "Push clusters, hosts and datastores to an external inventory: sync servicenow|device42", syncInventory},
+	// merge only reads saved reports, it is run from localCommands without vCenter
+	{"merge", "Merge saved JSON datastores reports into one: merge [label=]file...", nil},
+	// login only talks to the OS keychain
+	{"login", "Store the password of -username for -url in the OS keychain: login [delete]", nil},
+	// doctor connects itself to report each step
+	{"doctor", "Check connectivity, TLS trust, login and the privileges each command needs", nil},
+	// avro-schema only prints the schemas
+	{"avro-schema", "Print the Avro schemas of the snapshots and change events published to NATS or Kafka", nil},
 }
 
 func main() {
@@ -57,34 +270,83 @@ func main() {
 
 	cfg := parseFlags()
 
+	cmd, ok := lookupCommand(cfg.Command)
+	if !ok {
+		if !cfg.OutputJSON {
+			flag.Usage()
+		}
+		fatalf(cfg, errorUsage, "run godcinfo -h for the list of commands", "Unknown command: %s", cfg.Command)
+	}
+
+	// only the datastores command renders the topology formats
+	if cfg.Output != outputText && cfg.Output != outputJSON && cmd.Name != "datastores" {
+		fatalf(cfg, errorUsage, "use -o text or -o json", "Output format %s is not supported by the %s command", cfg.Output, cmd.Name)
+	}
+
+	if cfg.Anonymize && cmd.Name != "datastores" {
+		fatalf(cfg, errorUsage, "-anonymize only works with the datastores command", "-anonymize is not supported by the %s command", cmd.Name)
+	}
+
+	if cfg.ChangedOnly && cmd.Name != "datastores" {
+		fatalf(cfg, errorUsage, "-changed-only only works with the datastores command", "-changed-only is not supported by the %s command", cmd.Name)
+	}
+
+	if reason, ok := demoUnsupportedCommands[cmd.Name]; ok && cfg.Demo {
+		fatalf(cfg, errorUsage, reason, "-demo is not supported by the %s command", cmd.Name)
+	}
+
+	if cfg.Demo {
+		stop, err := startDemo(cfg)
+		if err != nil {
+			fatalf(cfg, errorConnect, "", "Error starting the demo: %s", err)
+		}
+		defer stop()
+		exitCleanups = append(exitCleanups, stop)
+	}
+
+	if run, ok := localCommands[cmd.Name]; ok {
+		exitOnError(cfg, cmd.Name, run(cfg))
+		return
+	}
+	if cfg.FromFile != "" {
+		exitOnError(cfg, cmd.Name, runFromFile(cmd.Name, cfg))
+		return
+	}
+
 	client, err := connectToVSphere(ctx, cfg)
 	if err != nil {
-		fmt.Printf("Error connecting to vSphere: %s\n", err)
-		os.Exit(1)
+		if cmd.Name == "check" {
+			fmt.Printf("%s UNKNOWN - connecting to vSphere: %s\n", checkLabel, err)
+			exit(checkUnknown)
+		}
+		fatalf(cfg, errorConnect, "check -url, the credentials and -insecure", "Error connecting to vSphere: %s", err)
 	}
 	defer client.Logout(ctx)
 
 	finder := find.NewFinder(client.Client, true)
 
-	var dc *object.Datacenter
-	if cfg.Datacenter != "" {
-		dc, err = finder.Datacenter(ctx, cfg.Datacenter)
-	} else {
-		// Find the default datacenter
-		dc, err = finder.DefaultDatacenter(ctx)
+	dcs, multi, err := selectDatacenters(ctx, finder, cfg.Datacenters)
+	if err != nil && cmd.Name == "check" {
+		fmt.Printf("%s UNKNOWN - finding datacenter: %s\n", checkLabel, err)
+		exit(checkUnknown)
 	}
-
 	if err != nil {
 		// if we can't find a specific datacenter, list all datacenters and exit
-		dcs, err := finder.DatacenterList(ctx, "*")
-		if err != nil {
-			fmt.Printf("Error: %s\n", err)
-			os.Exit(1)
+		dcs, listErr := finder.DatacenterList(ctx, "*")
+		if listErr != nil {
+			fatalf(cfg, errorDatacenter, "", "Error: %s", listErr)
 		}
 
 		if len(dcs) == 0 {
-			fmt.Println("No datacenters found. Please check your vSphere environment.")
-			os.Exit(1)
+			fatalf(cfg, errorDatacenter, "check the permissions of the vSphere user", "No datacenters found. Please check your vSphere environment.")
+		}
+
+		if cfg.OutputJSON {
+			names := make([]string, 0, len(dcs))
+			for _, dc := range dcs {
+				names = append(names, dc.Name())
+			}
+			fatalf(cfg, errorDatacenter, "use -datacenter with one of: "+strings.Join(names, ", "), "Error: %s", err)
 		}
 
 		fmt.Println("Available datacenters:")
@@ -92,43 +354,227 @@ func main() {
 			fmt.Printf("- %s\n", dc.Name())
 		}
 		fmt.Println("\nPlease specify a datacenter using the -datacenter flag.")
-		os.Exit(1)
+		exit(1)
+	}
+
+	if !multi {
+		exitOnError(cfg, cmd.Name, runDatacenter(ctx, client, finder, dcs[0], cmd, cfg, true, nil))
+		return
+	}
+
+	if cmd.Name == "check" {
+		fmt.Printf("%s UNKNOWN - the check supports a single datacenter, %d match\n", checkLabel, len(dcs))
+		exit(checkUnknown)
+	}
+
+	// The reports of several datacenters are grouped per datacenter. JSON reports are
+	// collected into one document, the other formats are printed one after the other.
+	report := MultiDatacenterReport{Datacenters: make([]DatacenterReport, 0, len(dcs))}
+	var failed []string
+	for i, dc := range dcs {
+		if i > 0 && cfg.Output == outputText {
+			fmt.Println()
+		}
+
+		var output []byte
+		err := runDatacenter(ctx, client, finder, dc, cmd, cfg, !cfg.OutputJSON, &output)
+
+		dcReport := DatacenterReport{Datacenter: cfg.pseudonym(kindDatacenter, dc.Name())}
+		if err != nil {
+			dcReport.Error = err.Error()
+			failed = append(failed, fmt.Sprintf("%s: %s", dc.Name(), err))
+		}
+		if output = bytes.TrimSpace(output); cfg.OutputJSON && len(output) > 0 && json.Valid(output) {
+			dcReport.Report = json.RawMessage(output)
+		}
+		report.Datacenters = append(report.Datacenters, dcReport)
 	}
 
+	if cfg.OutputJSON {
+		if err := printJSON(report); err != nil {
+			exitOnError(cfg, cmd.Name, err)
+		}
+	}
+	if len(failed) > 0 {
+		exitOnError(cfg, cmd.Name, errors.New(strings.Join(failed, "; ")))
+	}
+}
+
+// runDatacenter runs a command in one datacenter and uploads its report with -upload. With
+// output set the report is also captured there, echo prints it on stdout as well.
+func runDatacenter(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cmd command, cfg *Config, echo bool, output *[]byte) error {
 	finder.SetDatacenter(dc)
+	changeDetection.key, changeDetection.unchanged = "", false
+
+	if cfg.Output == outputText && cmd.Name != "check" {
+		fmt.Printf("Using datacenter: %s\n", cfg.pseudonym(kindDatacenter, dc.Name()))
+	}
 
-	if !cfg.OutputJSON {
-		fmt.Printf("Using datacenter: %s\n", dc.Name())
+	var capture *stdoutCapture
+	if cfg.Upload != "" || output != nil {
+		var err error
+		capture, err = captureStdout(echo)
+		if err != nil {
+			fatalf(cfg, errorUpload, "", "Error capturing the report: %s", err)
+		}
 	}
 
+	err := cmd.Run(ctx, client, finder, dc, cfg)
+
+	if capture != nil {
+		report := capture.stop()
+		if output != nil {
+			*output = report
+		}
+		if cfg.Upload != "" && !changeDetection.unchanged {
+			location, uploadErr := uploadReport(cfg, cfg.pseudonym(kindDatacenter, dc.Name()), cmd.Name, report)
+			if uploadErr != nil {
+				if err == nil {
+					fatalf(cfg, errorUpload, "check the upload URL and the storage credentials", "Error uploading report: %s", uploadErr)
+				}
+				fmt.Fprintf(os.Stderr, "Error uploading report: %s\n", uploadErr)
+			} else {
+				fmt.Fprintf(os.Stderr, "Uploaded report to %s\n", location)
+			}
+		}
+	}
+
+	// the hash is kept once the report was delivered, so a failed run is retried the next time
+	if err == nil {
+		if saveErr := saveFingerprint(); saveErr != nil {
+			fmt.Fprintf(os.Stderr, "Error saving the content hash: %s\n", saveErr)
+		}
+	}
+
+	return err
+}
+
+// exitOnError ends godcinfo with the exit status of a failed command
+func exitOnError(cfg *Config, command string, err error) {
+	if err == nil {
+		return
+	}
+	if status := exitStatus(err); status != 0 {
+		exit(status)
+	}
+	sendAlerts(cfg, []alert{scanErrorAlert(command, err)})
+	fatalf(cfg, errorCommand, "", "Error: %s", err)
+}
+
+// lookupCommand returns the command with the given name, an empty name selects the default
+func lookupCommand(name string) (command, bool) {
+	if name == "" {
+		return commands[0], true
+	}
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// reportDatastores lists datastore clusters and standalone datastores for every cluster
+func reportDatastores(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	if cfg.GroupBy != "" {
+		if cfg.Output != outputText && cfg.Output != outputJSON {
+			return fmt.Errorf("-group-by only supports text and JSON output")
+		}
+		return reportDatastoreGroups(ctx, client, dc, cfg)
+	}
+
+	if cfg.Output == outputDot || cfg.Output == outputMermaid {
+		return reportTopology(ctx, client, dc, cfg)
+	}
+
+	started := time.Now()
+
+	// the hooks get the report before anything of it is printed
+	hooked := len(cfg.Hooks) > 0
+	// collect the report instead of printing it as text while walking the clusters
+	structured := cfg.Output != outputText || hooked
+	// ndjson prints every datastore as it is found, the snapshot published to NATS or Kafka
+	// needs the collected report in any output format
+	stream := cfg.Output == outputNDJSON && !hooked
+	collect := (structured && !stream) || cfg.publishes() || cfg.ChangedOnly
+
 	// get all clusters
-	clusters, err := finder.ClusterComputeResourceList(ctx, "*")
+	clusters, err := listClusters(ctx, finder, cfg)
 	if err != nil {
-		fmt.Printf("Error getting clusters: %s\n", err)
-		os.Exit(1)
+		return fmt.Errorf("getting clusters: %s", err)
 	}
 
 	if len(clusters) == 0 {
 		fmt.Println("No clusters found in the selected datacenter.")
-		os.Exit(0)
+		return nil
 	}
 
 	// Initialize the infrastructure info object if using JSON output
+	dcName := cfg.pseudonym(kindDatacenter, dc.Name())
 	var infraInfo InfrastructureInfo
-	if cfg.OutputJSON {
-		infraInfo.Datacenter = dc.Name()
+	if collect {
+		infraInfo.Datacenter = dcName
 		infraInfo.Clusters = make([]ClusterInfo, 0, len(clusters))
 	}
 
+	// Tags are only needed when a threshold override matches on them
+	var dsTags map[string][]string
+	if cfg.Thresholds.usesTags() {
+		var all []mo.Datastore
+		err = retrieveAll(ctx, client, dc, "Datastore", []string{"name"}, &all)
+		if err != nil {
+			return fmt.Errorf("retrieving datastores: %s", err)
+		}
+		dsTags, err = datastoreTags(ctx, client, cfg, all)
+		if err != nil {
+			return fmt.Errorf("retrieving datastore tags: %s", err)
+		}
+	}
+
+	// With -datastore-tag only the tagged datastores are reported
+	var tagged map[string]bool
+	if len(cfg.DatastoreTags) > 0 {
+		var all []mo.Datastore
+		err = retrieveAll(ctx, client, dc, "Datastore", []string{"name"}, &all)
+		if err != nil {
+			return fmt.Errorf("retrieving datastores: %s", err)
+		}
+		all, err = withDatastoreTags(ctx, client, cfg, all)
+		if err != nil {
+			return err
+		}
+		tagged = make(map[string]bool, len(all))
+		for _, ds := range all {
+			tagged[ds.Self.Value] = true
+		}
+	}
+
+	// With -tanzu the supervisor clusters are reported with their namespace storage quotas
+	var supervisors map[string]*SupervisorInfo
+	if cfg.Tanzu {
+		supervisors, err = collectSupervisors(ctx, client, cfg)
+		if err != nil {
+			return err
+		}
+	}
+
+	errs := &scanErrors{continueOnError: cfg.ContinueOnError, failOnError: cfg.FailOnError, message: cfg.anonymizeError}
+
+	// datastores of all clusters and the number of clusters using them
+	dcDatastores := make(map[string]mo.Datastore)
+	dsClusterCount := make(map[string]int)
+
 	for _, cluster := range clusters {
+		clusterName := cfg.pseudonym(kindCluster, cluster.Name())
 		var clusterInfo ClusterInfo
-		if cfg.OutputJSON {
-			clusterInfo.Name = cluster.Name()
+		if collect {
+			clusterInfo.Name = clusterName
 			clusterInfo.DatastoreClusters = make([]DatastoreClusterInfo, 0)
 			clusterInfo.StandaloneDatastores = make([]DatastoreInfo, 0)
-		} else {
-			fmt.Printf("\nCluster: %s\n", cluster.Name())
-			fmt.Println(strings.Repeat("-", len(cluster.Name())+9))
+		}
+		if !structured {
+			fmt.Printf("\nCluster: %s\n", clusterName)
+			fmt.Println(strings.Repeat("-", len(clusterName)+9))
 		}
 
 		// Get datastore clusters (StoragePods)
@@ -147,8 +593,11 @@ func main() {
 				// try direct path
 				datastoreFolders, err = finder.FolderList(ctx, fmt.Sprintf("%s/datastore", dc.InventoryPath))
 				if err != nil {
-					if !cfg.OutputJSON {
-						fmt.Printf("  Error finding datastore folders: %s\n", err)
+					if err := errs.add(clusterName, "finding datastore folders for", err); err != nil {
+						return err
+					}
+					if !structured {
+						fmt.Printf("  Error finding datastore folders: %s\n", errs.message(err))
 					}
 					continue
 				}
@@ -159,14 +608,20 @@ func main() {
 		for _, dsFolder := range datastoreFolders {
 			children, err := dsFolder.Children(ctx)
 			if err != nil {
+				if err := errs.add(cfg.pseudonym("path", dsFolder.InventoryPath), "listing datastore folder", err); err != nil {
+					return err
+				}
 				continue
 			}
 
 			for _, child := range children {
 				if pod, ok := child.(*object.StoragePod); ok {
 					var podInfo mo.StoragePod
-					err = pc.RetrieveOne(ctx, pod.Reference(), []string{"name", "childEntity"}, &podInfo)
+					err = pc.RetrieveOne(ctx, pod.Reference(), []string{"name", "childEntity", "summary"}, &podInfo)
 					if err != nil {
+						if err := errs.add(cfg.pseudonym("path", pod.InventoryPath), "getting datastore cluster", err); err != nil {
+							return err
+						}
 						continue
 					}
 					storagePods = append(storagePods, podInfo)
@@ -179,8 +634,11 @@ func main() {
 		var clusterMo mo.ClusterComputeResource
 		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"datastore"}, &clusterMo)
 		if err != nil {
-			if !cfg.OutputJSON {
-				fmt.Printf("  Error getting cluster details: %s\n", err)
+			if err := errs.add(clusterName, "getting datastores of cluster", err); err != nil {
+				return err
+			}
+			if !structured {
+				fmt.Printf("  Error getting cluster details: %s\n", errs.message(err))
 			}
 			continue
 		}
@@ -193,74 +651,130 @@ func main() {
 		}
 
 		var datastores []mo.Datastore
-		err = pc.Retrieve(ctx, dsList, []string{"name", "summary"}, &datastores)
+		err = pc.Retrieve(ctx, dsList, []string{"name", "summary", "info", "host"}, &datastores)
 		if err != nil {
-			if !cfg.OutputJSON {
-				fmt.Printf("  Error retrieving datastore details: %s\n", err)
+			if err := errs.add(clusterName, "retrieving datastores of cluster", err); err != nil {
+				return err
+			}
+			if !structured {
+				fmt.Printf("  Error retrieving datastore details: %s\n", errs.message(err))
 			}
 			continue
 		}
 
+		// Resolve the hosts behind vVol protocol endpoints
+		hostNames, err := vvolHostNames(ctx, pc, datastores)
+		if err != nil {
+			// the protocol endpoints are listed with the host IDs instead, the cluster is still reported
+			fmt.Fprintf(os.Stderr, "Warning: retrieving the vVol protocol endpoint hosts of cluster %s: %s\n", clusterName, err)
+			hostNames = nil
+		}
+
+		if cfg.ExcludeLocal {
+			datastores = withoutLocalDatastores(datastores)
+		}
+		datastores = withoutExcludedDatastores(cfg, datastores)
+		if tagged != nil {
+			kept := make([]mo.Datastore, 0, len(datastores))
+			for _, ds := range datastores {
+				if tagged[ds.Self.Value] {
+					kept = append(kept, ds)
+				}
+			}
+			datastores = kept
+		}
+
+		// With -datastore-cluster only the selected datastore clusters and their members are reported
+		storagePods, datastores, err = scopeDatastoreClusters(cfg, storagePods, datastores)
+		if err != nil {
+			return err
+		}
+
 		for _, ds := range datastores {
 			datastoreMap[ds.Reference().Value] = ds
+			// streaming without a collected report only keeps what the alerts and
+			// -fail-on-inaccessible need
+			if !stream || collect || cfg.Syslog.Address != "" || !ds.Summary.Accessible {
+				dcDatastores[ds.Reference().Value] = ds
+			}
+			if !stream || collect {
+				dsClusterCount[ds.Reference().Value]++
+			}
 		}
+		clusterInfo.Totals = capacityTotals(datastores)
 
 		// Display datastore clusters and their datastores
 		if len(storagePods) == 0 {
-			if !cfg.OutputJSON {
+			if !structured {
 				fmt.Println("  No datastore clusters found for this cluster")
 			}
 		} else {
 			for _, pod := range storagePods {
+				podName := cfg.pseudonym(kindDatastoreCluster, pod.Name)
 				var dsClusterInfo DatastoreClusterInfo
-				if cfg.OutputJSON {
-					dsClusterInfo.Name = pod.Name
+				dsClusterInfo.TotalCapacity, dsClusterInfo.TotalFreeSpace = podCapacity(pod, datastoreMap)
+				dsClusterInfo.UsedPct = usedPct(dsClusterInfo.TotalCapacity, dsClusterInfo.TotalFreeSpace)
+				if collect {
+					dsClusterInfo.Name = podName
 					dsClusterInfo.Datastores = make([]DatastoreInfo, 0)
-				} else {
-					fmt.Printf("  Datastore Cluster: %s\n", pod.Name)
+				}
+				if !structured {
+					fmt.Printf("  Datastore Cluster: %s (Capacity: %s, Free: %s, Used: %s)\n",
+						podName, formatGB(dsClusterInfo.TotalCapacity), formatGB(dsClusterInfo.TotalFreeSpace), formatPct(dsClusterInfo.UsedPct))
 				}
 
 				// Check if this datastore cluster has datastores in this cluster
 				podHasDatastoresInCluster := false
+				podDatastoresShown := 0
 
 				for _, childRef := range pod.ChildEntity {
 					if ds, exists := datastoreMap[childRef.Value]; exists {
 						if !podHasDatastoresInCluster {
 							podHasDatastoresInCluster = true
 						}
-						capacity := float64(ds.Summary.Capacity) / (1024 * 1024 * 1024)
-						freeSpace := float64(ds.Summary.FreeSpace) / (1024 * 1024 * 1024)
-
-						if cfg.OutputJSON {
-							dsClusterInfo.Datastores = append(dsClusterInfo.Datastores, DatastoreInfo{
-								Name:      ds.Name,
-								Capacity:  capacity,
-								FreeSpace: freeSpace,
-							})
-						} else {
-							fmt.Printf("    - %s (Capacity: %.2f GB, Free: %.2f GB)\n",
-								ds.Name, capacity, freeSpace)
+						dsInfo := newDatastoreInfo(ds, hostNames)
+						dsInfo.Status = cfg.Thresholds.status(dsInfo.Name, dsTags[ds.Self.Value], dsInfo.UsedPct)
+						if !includeDatastore(cfg, dsInfo) {
+							continue
+						}
+						podDatastoresShown++
+						dsInfo = cfg.anonymizeDatastore(dsInfo)
+
+						if collect {
+							dsClusterInfo.Datastores = append(dsClusterInfo.Datastores, dsInfo)
+						}
+						if stream {
+							err := printNDJSON(DatastoreRecord{Datacenter: dcName, Cluster: clusterName, DatastoreCluster: podName, DatastoreInfo: dsInfo})
+							if err != nil {
+								return err
+							}
+						}
+						if !structured {
+							printDatastore(dsInfo)
 						}
 					}
 				}
 
 				if !podHasDatastoresInCluster {
-					if !cfg.OutputJSON {
+					if !structured {
 						fmt.Println("    No datastores from this cluster in this datastore cluster")
 					}
+				} else if podDatastoresShown == 0 && !structured {
+					fmt.Println("    No datastores matching the filters")
 				}
 
-				if cfg.OutputJSON && len(dsClusterInfo.Datastores) > 0 {
+				if collect && len(dsClusterInfo.Datastores) > 0 {
 					clusterInfo.DatastoreClusters = append(clusterInfo.DatastoreClusters, dsClusterInfo)
 				}
 			}
 		}
 
 		// Display standalone datastores (not in any datastore cluster)
-		if !cfg.OutputJSON {
+		if !structured && len(cfg.DatastoreClusters) == 0 {
 			fmt.Println("  Standalone Datastores:")
 		}
 		standaloneDsFound := false
+		standaloneDsShown := 0
 
 		for _, ds := range datastores {
 			// Check if this datastore belongs to any storage pod
@@ -279,65 +793,522 @@ func main() {
 
 			if !belongsToStoragePod {
 				standaloneDsFound = true
-				capacity := float64(ds.Summary.Capacity) / (1024 * 1024 * 1024)
-				freeSpace := float64(ds.Summary.FreeSpace) / (1024 * 1024 * 1024)
-
-				if cfg.OutputJSON {
-					clusterInfo.StandaloneDatastores = append(clusterInfo.StandaloneDatastores, DatastoreInfo{
-						Name:      ds.Name,
-						Capacity:  capacity,
-						FreeSpace: freeSpace,
-					})
-				} else {
-					fmt.Printf("    - %s (Capacity: %.2f GB, Free: %.2f GB)\n",
-						ds.Name, capacity, freeSpace)
+				dsInfo := newDatastoreInfo(ds, hostNames)
+				dsInfo.Status = cfg.Thresholds.status(dsInfo.Name, dsTags[ds.Self.Value], dsInfo.UsedPct)
+				if !includeDatastore(cfg, dsInfo) {
+					continue
+				}
+				standaloneDsShown++
+				dsInfo = cfg.anonymizeDatastore(dsInfo)
+
+				if collect {
+					clusterInfo.StandaloneDatastores = append(clusterInfo.StandaloneDatastores, dsInfo)
+				}
+				if stream {
+					if err := printNDJSON(DatastoreRecord{Datacenter: dcName, Cluster: clusterName, DatastoreInfo: dsInfo}); err != nil {
+						return err
+					}
+				}
+				if !structured {
+					printDatastore(dsInfo)
 				}
 			}
 		}
 
-		if !standaloneDsFound && !cfg.OutputJSON {
-			fmt.Println("    No standalone datastores found")
+		// with -datastore-cluster only datastore cluster members are left
+		if len(cfg.DatastoreClusters) == 0 {
+			if !standaloneDsFound && !structured {
+				fmt.Println("    No standalone datastores found")
+			} else if standaloneDsShown == 0 && !structured {
+				fmt.Println("    No datastores matching the filters")
+			}
 		}
 
-		if cfg.OutputJSON {
+		if sv, ok := supervisors[cluster.Reference().Value]; ok {
+			if collect {
+				clusterInfo.Supervisor = sv
+			}
+			if !structured {
+				printSupervisor(sv)
+			}
+		}
+
+		if cfg.Compute {
+			compute, err := clusterCompute(ctx, client, cluster)
+			if err != nil {
+				if err := errs.add(clusterName, "getting compute capacity of cluster", err); err != nil {
+					return err
+				}
+			} else {
+				if collect {
+					clusterInfo.Compute = compute
+				}
+				if !structured {
+					printComputeCapacity(compute)
+				}
+			}
+		}
+
+		if !structured {
+			printTotals("  Cluster total", clusterInfo.Totals)
+		}
+
+		if collect {
 			infraInfo.Clusters = append(infraInfo.Clusters, clusterInfo)
 		}
 	}
 
-	// Output JSON if requested
-	if cfg.OutputJSON {
-		jsonOutput, err := json.MarshalIndent(infraInfo, "", "  ")
+	all := make([]mo.Datastore, 0, len(dcDatastores))
+	for _, ds := range dcDatastores {
+		all = append(all, ds)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	infraInfo.Totals = capacityTotals(all)
+	infraInfo.InaccessibleDatastores = make([]string, 0)
+	for _, ds := range all {
+		if !ds.Summary.Accessible {
+			infraInfo.InaccessibleDatastores = append(infraInfo.InaccessibleDatastores, cfg.pseudonym(kindDatastore, ds.Name))
+		}
+	}
+	sort.Strings(infraInfo.InaccessibleDatastores)
+	infraInfo.Errors = errs.errors
+	if collect {
+		infraInfo.Meta = newScanMeta(client, cfg, infraInfo, started)
+	}
+	if cfg.ChangedOnly {
+		if err := checkChanged(client.URL().Host, dc.Name(), infraInfo.Meta.ContentHash); err != nil {
+			return err
+		}
+		if changeDetection.unchanged {
+			fmt.Fprintln(os.Stderr, "No changes since the last scan, skipping the upload, published snapshot and alerts")
+		}
+	}
+
+	// alerts cover all datastores, the filters only affect what is listed
+	if cfg.Syslog.Address != "" && !changeDetection.unchanged {
+		infos := make([]DatastoreInfo, 0, len(all))
+		for _, ds := range all {
+			info := newDatastoreInfo(ds, nil)
+			info.Status = cfg.Thresholds.status(info.Name, dsTags[ds.Self.Value], info.UsedPct)
+			infos = append(infos, info)
+		}
+		sendAlerts(cfg, datastoreAlerts(dc.Name(), infos))
+	}
+	for _, count := range dsClusterCount {
+		if count > 1 {
+			infraInfo.Totals.SharedDatastores++
+		}
+	}
+
+	switch {
+	case hooked:
+		report, err := runHooks(ctx, cfg.Hooks, infraInfo)
+		if err != nil {
+			return err
+		}
+		infraInfo = InfrastructureInfo{}
+		if err := json.Unmarshal(report, &infraInfo); err != nil {
+			return err
+		}
+		// the JSON report keeps the fields the hooks added
+		if cfg.Output == outputJSON {
+			err = printJSON(json.RawMessage(report))
+		} else {
+			err = printDatastoresReport(infraInfo, cfg)
+		}
 		if err != nil {
-			fmt.Printf("Error generating JSON output: %s\n", err)
-			os.Exit(1)
+			return err
+		}
+	case cfg.Output == outputJSON:
+		if err := printJSON(infraInfo); err != nil {
+			return err
+		}
+	case cfg.Output == outputTree:
+		printTree(infraInfo)
+	case cfg.Output == outputNDJSON:
+	default:
+		printDatacenterTotals(infraInfo)
+	}
+
+	errs.printSummary()
+
+	if cfg.publishes() && !changeDetection.unchanged {
+		if err := publishSnapshot(cfg, client.URL().Host, dc.Name(), infraInfo); err != nil {
+			return err
+		}
+	}
+
+	if cfg.FailOnInaccessible && len(infraInfo.InaccessibleDatastores) > 0 {
+		return fmt.Errorf("%d inaccessible datastores", len(infraInfo.InaccessibleDatastores))
+	}
+
+	return nil
+}
+
+// capacityTotals sums up the capacity and free space of the given datastores
+func capacityTotals(datastores []mo.Datastore) CapacityTotals {
+	var capacity, free int64
+	for _, ds := range datastores {
+		capacity += ds.Summary.Capacity
+		free += ds.Summary.FreeSpace
+	}
+
+	totals := CapacityTotals{
+		DatastoreCount: len(datastores),
+		Capacity:       bytesToGB(capacity),
+		FreeSpace:      bytesToGB(free),
+	}
+	totals.UsedPct = usedPct(totals.Capacity, totals.FreeSpace)
+
+	return totals
+}
+
+// printDatacenterTotals prints the datacenter totals and inaccessible datastores at the end of the text report
+func printDatacenterTotals(infra InfrastructureInfo) {
+	fmt.Println()
+	printTotals("Datacenter total", infra.Totals)
+	if infra.Totals.SharedDatastores > 0 {
+		fmt.Printf("  (%d datastores shared between clusters counted once)\n", infra.Totals.SharedDatastores)
+	}
+	if len(infra.InaccessibleDatastores) > 0 {
+		fmt.Printf("\nWARNING: %d inaccessible datastores: %s\n",
+			len(infra.InaccessibleDatastores), strings.Join(infra.InaccessibleDatastores, ", "))
+	}
+}
+
+// printTotals prints a totals line in text output
+func printTotals(label string, totals CapacityTotals) {
+	fmt.Printf("%s: %d datastores (Capacity: %s, Free: %s, Used: %s)\n",
+		label, totals.DatastoreCount, formatGB(totals.Capacity), formatGB(totals.FreeSpace), formatPct(totals.UsedPct))
+}
+
+// newDatastoreInfo converts a datastore into its report representation
+func newDatastoreInfo(ds mo.Datastore, hostNames map[string]string) DatastoreInfo {
+	info := DatastoreInfo{
+		Name:      ds.Name,
+		Type:      ds.Summary.Type,
+		Capacity:  bytesToGB(ds.Summary.Capacity),
+		FreeSpace: bytesToGB(ds.Summary.FreeSpace),
+	}
+	info.UsedPct = usedPct(info.Capacity, info.FreeSpace)
+	info.MountedHostCount = mountedHostCount(ds)
+	info.Shared = datastoreShared(ds)
+	info.Accessible = ds.Summary.Accessible
+	if !info.Accessible {
+		info.InaccessibleReason = inaccessibleReason(ds)
+	}
+
+	if ds.Summary.Type == string(types.HostFileSystemVolumeFileSystemTypeVVOL) {
+		info.VVol = newVVolInfo(ds, hostNames)
+	}
+
+	return info
+}
+
+// podCapacity returns the capacity and free space of a datastore cluster in GB. The pod
+// summary covers all member datastores, when it is missing or empty the members visible to the
+// compute cluster are summed up.
+func podCapacity(pod mo.StoragePod, datastoreMap map[string]mo.Datastore) (float64, float64) {
+	if pod.Summary != nil && pod.Summary.Capacity > 0 {
+		return bytesToGB(pod.Summary.Capacity), bytesToGB(pod.Summary.FreeSpace)
+	}
+
+	var capacity, free int64
+	for _, childRef := range pod.ChildEntity {
+		if ds, exists := datastoreMap[childRef.Value]; exists {
+			capacity += ds.Summary.Capacity
+			free += ds.Summary.FreeSpace
 		}
-		fmt.Println(string(jsonOutput))
 	}
+	return bytesToGB(capacity), bytesToGB(free)
+}
+
+// usedPct returns the used share of a capacity in percent
+func usedPct(capacity, free float64) float64 {
+	if capacity <= 0 {
+		return 0
+	}
+	return (capacity - free) / capacity * 100
+}
+
+// bytesToGB converts a size in bytes to GB
+func bytesToGB(n int64) float64 {
+	return float64(n) / (1024 * 1024 * 1024)
+}
+
+// printDatastore prints a single datastore line in text output
+func printDatastore(info DatastoreInfo) {
+	fmt.Printf("    - %s (Capacity: %s, Free: %s, Used: %s)\n",
+		info.Name, formatGB(info.Capacity), formatGB(info.FreeSpace), formatPct(info.UsedPct))
+	if !info.Accessible {
+		fmt.Printf("      INACCESSIBLE: %s\n", valueOrNone(info.InaccessibleReason))
+	}
+	if info.Status != "" && info.Status != statusOK {
+		fmt.Printf("      Status: %s\n", strings.ToUpper(info.Status))
+	}
+
+	if info.VVol != nil {
+		printVVol(info.VVol)
+	}
+}
+
+// retrieveAll retrieves the given properties of all objects of one kind in the datacenter
+func retrieveAll(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, kind string, props []string, dst interface{}) error {
+	m := view.NewManager(client.Client)
+	v, err := m.CreateContainerView(ctx, dc.Reference(), []string{kind}, true)
+	if err != nil {
+		return err
+	}
+	defer v.Destroy(ctx)
+
+	return v.Retrieve(ctx, []string{kind}, props, dst)
+}
+
+// retrieveClusterVMs retrieves the given properties of the VMs in a cluster, including the
+// VMs in its resource pools and vApps
+func retrieveClusterVMs(ctx context.Context, client *govmomi.Client, cluster *object.ClusterComputeResource, props []string, dst interface{}) error {
+	m := view.NewManager(client.Client)
+	v, err := m.CreateContainerView(ctx, cluster.Reference(), []string{"VirtualMachine"}, true)
+	if err != nil {
+		return err
+	}
+	defer v.Destroy(ctx)
+
+	return v.Retrieve(ctx, []string{"VirtualMachine"}, props, dst)
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	jsonOutput, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("generating JSON output: %s", err)
+	}
+	fmt.Println(string(jsonOutput))
+	reportPrinted = true
+	return nil
 }
 
 // parseFlags parses command line flags
 func parseFlags() *Config {
 	cfg := &Config{}
 
-	flag.StringVar(&cfg.URL, "url", os.Getenv("VSPHERE_URL"), "vSphere URL (can also set VSPHERE_URL env var)")
-	flag.StringVar(&cfg.Username, "username", os.Getenv("VSPHERE_USERNAME"), "vSphere username (can also set VSPHERE_USERNAME env var)")
-	flag.StringVar(&cfg.Password, "password", os.Getenv("VSPHERE_PASSWORD"), "vSphere password (can also set VSPHERE_PASSWORD env var)")
-	flag.BoolVar(&cfg.Insecure, "insecure", true, "Skip verification of server certificate")
-	flag.StringVar(&cfg.Datacenter, "datacenter", os.Getenv("VSPHERE_DATACENTER"), "vSphere datacenter name (can also set VSPHERE_DATACENTER env var)")
-	flag.BoolVar(&cfg.OutputJSON, "o", false, "Output format (use 'json' for JSON output)")
+	flag.StringVar(&cfg.URL, "url", getenv("VSPHERE_URL", "GOVC_URL"), "vSphere URL, may include user:password@ (can also set VSPHERE_URL or GOVC_URL env var)")
+	flag.StringVar(&cfg.Username, "username", getenv("VSPHERE_USERNAME", "GOVC_USERNAME"), "vSphere username (can also set VSPHERE_USERNAME or GOVC_USERNAME env var)")
+	flag.StringVar(&cfg.Password, "password", getenv("VSPHERE_PASSWORD", "GOVC_PASSWORD"), "vSphere password (can also set VSPHERE_PASSWORD or GOVC_PASSWORD env var)")
+	flag.StringVar(&cfg.Certificate, "cert", getenv("VSPHERE_CERTIFICATE", "GOVC_CERTIFICATE"), "Client certificate file for certificate login instead of a password (can also set VSPHERE_CERTIFICATE or GOVC_CERTIFICATE env var)")
+	flag.StringVar(&cfg.PrivateKey, "key", getenv("VSPHERE_PRIVATE_KEY", "GOVC_PRIVATE_KEY"), "Private key file of -cert, defaults to the -cert file (can also set VSPHERE_PRIVATE_KEY or GOVC_PRIVATE_KEY env var)")
+	flag.BoolVar(&cfg.Insecure, "insecure", insecureDefault(), "Skip verification of server certificate (can also set GOVC_INSECURE env var)")
+	flag.Var((*stringList)(&cfg.Datacenters), "datacenter", "vSphere datacenter name or glob pattern like 'EU-*', re:<regexp> for a regular expression; can be repeated (can also set VSPHERE_DATACENTER or GOVC_DATACENTER env var)")
+	cfg.Output = outputText
+	flag.Var((*outputFlag)(&cfg.Output), "o", "Output format: text, json, ndjson, tree, dot or mermaid (-o alone selects json)")
+	flag.StringVar(&cfg.Upload, "upload", "", "Upload the report to s3://bucket/prefix, gs://bucket/prefix or azblob://account/container/prefix")
+	flag.StringVar(&cfg.FromFile, "from-file", "", "Run the datastores, lint or check command against a saved JSON report instead of vCenter (- for stdin)")
+	flag.StringVar(&cfg.Record, "record", "", "Record the vCenter responses to this directory")
+	flag.StringVar(&cfg.Replay, "replay", "", "Replay vCenter responses recorded with -record from this directory instead of connecting")
+	flag.StringVar(&cfg.DebugSOAP, "debug-soap", "", "Dump every vCenter request and response to this directory with credentials and session cookies redacted")
+	flag.BoolVar(&cfg.Demo, "demo", false, "Run the command against a built-in vCenter simulator with a sample inventory instead of a vCenter")
+	flag.BoolVar(&cfg.Anonymize, "anonymize", false, "Replace inventory names with stable pseudonyms and strip addresses for sharing the report")
+	flag.StringVar(&cfg.Compress, "compress", "", "Compress uploaded reports with gzip or zstd")
+	flag.IntVar(&cfg.MaxConcurrentRequests, "max-concurrent-requests", 8, "Maximum number of vCenter requests in flight at once")
+	flag.DurationVar(&cfg.RequestDelay, "request-delay", 0, "Minimum time between the start of two vCenter requests, e.g. 200ms")
+	flag.StringVar(&cfg.Locale, "locale", "", "Thousands and decimal separators of numbers in text output, e.g. en, de_DE or fr")
+	flag.IntVar(&numberFormat.precision, "precision", 2, "Decimals of sizes in GB in text output")
+	flag.StringVar(&cfg.ConfigFile, "config", os.Getenv("GODCINFO_CONFIG"), "JSON config file with threshold overrides (can also set GODCINFO_CONFIG env var)")
+	flag.StringVar(&cfg.Profile, "profile", os.Getenv("GODCINFO_PROFILE"), "Config file profile with the vCenter URL, credentials, datacenter and default filters (can also set GODCINFO_PROFILE env var)")
+	flag.Float64Var(&cfg.MinUsedPct, "min-used-pct", 0, "Only list datastores with at least this used percentage")
+	flag.Float64Var(&cfg.MaxFreePct, "max-free-pct", 100, "Only list datastores with at most this free percentage")
+	flag.StringVar(&cfg.MinCapacity, "min-capacity", "", "Only list datastores with at least this capacity, e.g. 500GB or 2TB")
+	flag.Var((*stringList)(&cfg.Clusters), "cluster", "Only collect this compute cluster, a name or glob pattern like 'prod-*'; can be repeated")
+	flag.Var((*stringList)(&cfg.DatastoreClusters), "datastore-cluster", "Only report this datastore cluster and its datastores, a name or glob pattern; can be repeated")
+	flag.Var((*stringList)(&cfg.Exclude), "exclude", "Hide clusters, datastore clusters and datastores matching this name or glob pattern like '*-swap'; can be repeated")
+	flag.Var((*stringList)(&cfg.DatastoreTags), "datastore-tag", "Only report datastores with this vSphere tag, given as category=value; can be repeated")
+	flag.BoolVar(&cfg.ExcludeLocal, "exclude-local", false, "Leave out host-local datastores, also from capacity totals")
+	flag.StringVar(&cfg.GroupBy, "group-by", "", "Group datastores by tag:<category> or attribute:<name> instead of by cluster")
+	flag.BoolVar(&cfg.Tanzu, "tanzu", false, "Add the supervisor clusters with their namespace storage quotas and usage to the report")
+	flag.BoolVar(&cfg.Compute, "compute", false, "Add the CPU and memory capacity, allocation and overcommit ratios of every cluster to the report")
+	flag.BoolVar(&cfg.FailOnInaccessible, "fail-on-inaccessible", false, "Exit with a nonzero status when a datastore is inaccessible")
+	flag.BoolVar(&cfg.ChangedOnly, "changed-only", false, "Skip the upload, published snapshot and syslog alerts when the datastores report didn't change since the last scan")
+	flag.BoolVar(&cfg.ContinueOnError, "continue-on-error", false, "List the clusters and datastore clusters that can't be read in the report's errors and on stderr")
+	flag.BoolVar(&cfg.FailOnError, "fail-on-error", false, "Fail the scan when a cluster or datastore cluster can't be read instead of skipping it")
+	flag.Float64Var(&cfg.SwapMinFreePct, "swap-min-free-pct", 10, "Flag swap datastores with less free space than this percentage (swap command)")
+	flag.IntVar(&cfg.CertWarningDays, "cert-warning-days", 30, "Flag certificates expiring within this many days (certs command)")
+	flag.IntVar(&cfg.StaleDays, "stale-days", 30, "List VMs powered off longer than this many days (powerstate command)")
+	flag.IntVar(&cfg.SnapshotAgeDays, "snapshot-age-days", 3, "List snapshots older than this many days (snapshots command)")
+	flag.IntVar(&cfg.MaxVMsPerHost, "max-vms-per-host", 50, "Warn about clusters and hosts with more powered on VMs per host, 0 to disable (density command)")
+	flag.Float64Var(&cfg.MaxVCPUPerCore, "max-vcpu-per-core", 4, "Warn about clusters and hosts with more vCPUs per physical core, 0 to disable (density command)")
+	flag.IntVar(&cfg.IdleDays, "idle-days", 14, "Window of performance history idle VMs are detected in (idle command)")
+	flag.Float64Var(&cfg.IdleCPUPct, "idle-cpu-pct", 2, "VMs using less CPU percent than this are idle (idle command)")
+	flag.Int64Var(&cfg.IdleIOKBps, "idle-io-kbps", 10, "VMs with less disk and network throughput in KBps than this are idle (idle command)")
+	flag.IntVar(&cfg.RightsizeDays, "rightsize-days", 30, "Window of performance history the VM usage is taken from (rightsize command)")
+	flag.IntVar(&cfg.BenchRuns, "bench-runs", 5, "Number of timed scans (bench command)")
+	flag.IntVar(&cfg.BenchBatchSize, "bench-batch-size", 100, "Objects per property retrieval request (bench command)")
+	flag.StringVar(&cfg.Decommission, "decommission", "", "Comma separated datastores slated for decommission (hostlogs command)")
+	flag.Float64Var(&cfg.CheckWarningPct, "w", 0, "Warning used percentage, overrides the config file default (check command)")
+	flag.Float64Var(&cfg.CheckCriticalPct, "c", 0, "Critical used percentage, overrides the config file default (check command)")
+	flag.BoolVar(&cfg.CheckAggregate, "aggregate", false, "Check the datacenter total instead of every datastore (check command)")
+	flag.BoolVar(&cfg.CheckCertificates, "check-certs", false, "Check the expiry of the vCenter certificates instead of datastore usage (check command)")
+	flag.BoolVar(&cfg.DryRun, "dry-run", false, "Show the changes without writing them (sync command)")
+
+	flag.Usage = usage
 
-	flag.Parse()
+	// an optional leading non-flag argument selects the command
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cfg.Command = args[0]
+		args = args[1:]
+	}
+	// arguments after the command, like the sync target, may be mixed with flags
+	args = normalizeOutputArgs(args)
+	for {
+		flag.CommandLine.Parse(args)
+		args = flag.Args()
+		if len(args) == 0 {
+			break
+		}
+		cfg.Args = append(cfg.Args, args[0])
+		args = args[1:]
+	}
+	cfg.OutputJSON = cfg.Output == outputJSON
 
-	if cfg.URL == "" || cfg.Username == "" || cfg.Password == "" {
-		fmt.Println("Must specify vSphere URL, username, and password")
-		fmt.Println("Usage:")
-		flag.PrintDefaults()
-		os.Exit(1)
+	fc := defaultFileConfig()
+	if cfg.ConfigFile != "" {
+		var err error
+		fc, err = loadConfigFile(cfg.ConfigFile)
+		if err != nil {
+			fatalf(cfg, errorConfig, "", "Error loading config file: %s", err)
+		}
 	}
+	if cfg.Profile != "" {
+		if cfg.ConfigFile == "" {
+			fatalf(cfg, errorUsage, "set -config or GODCINFO_CONFIG to the file defining the profile", "-profile needs a config file")
+		}
+		if err := applyProfile(cfg, fc.Profiles); err != nil {
+			fatalf(cfg, errorConfig, "", "Error loading profile: %s", err)
+		}
+	}
+	if dc := getenv("VSPHERE_DATACENTER", "GOVC_DATACENTER"); len(cfg.Datacenters) == 0 && dc != "" {
+		cfg.Datacenters = []string{dc}
+	}
+	urlCredentials(cfg)
+
+	if cfg.Record != "" && cfg.Replay != "" {
+		fatalf(cfg, errorUsage, "", "Use either -record or -replay")
+	}
+	if cfg.Record != "" {
+		if err := os.MkdirAll(cfg.Record, 0700); err != nil {
+			fatalf(cfg, errorConfig, "", "Error creating the recording directory: %s", err)
+		}
+	}
+	if cfg.Demo && (cfg.Replay != "" || cfg.FromFile != "") {
+		fatalf(cfg, errorUsage, "", "Use either -demo, -replay or -from-file")
+	}
+	if cfg.DebugSOAP != "" {
+		if err := enableSOAPDump(cfg.DebugSOAP); err != nil {
+			fatalf(cfg, errorConfig, "", "Error creating the -debug-soap directory: %s", err)
+		}
+	}
+
+	// a replay needs no vCenter, the credentials are not part of the recording
+	if cfg.Replay != "" && cfg.URL == "" {
+		cfg.URL = "https://replay/sdk"
+	}
+	_, local := localCommands[cfg.Command]
+	if !local && cfg.FromFile == "" && cfg.Replay == "" && cfg.Certificate == "" && !cfg.Demo {
+		keychainPassword(cfg)
+	}
+	// the demo simulator sets the URL and credentials
+	if !local && cfg.FromFile == "" && !cfg.Demo && (cfg.URL == "" || (cfg.Replay == "" && cfg.Certificate == "" && (cfg.Username == "" || cfg.Password == ""))) {
+		if !cfg.OutputJSON {
+			flag.Usage()
+		}
+		fatalf(cfg, errorUsage, "set -url, -username and -password (or store it with godcinfo login, or use -cert and -key), VSPHERE_URL, VSPHERE_USERNAME and VSPHERE_PASSWORD or GOVC_URL, GOVC_USERNAME and GOVC_PASSWORD", "Must specify vSphere URL, username, and password")
+	}
+
+	cfg.AnonymizeKey = os.Getenv("GODCINFO_ANONYMIZE_KEY")
+	// without a secret anyone could map the pseudonyms back by hashing guessed names
+	if cfg.Anonymize && cfg.AnonymizeKey == "" {
+		fatalf(cfg, errorUsage, "set GODCINFO_ANONYMIZE_KEY to a secret kept out of the shared report", "-anonymize needs GODCINFO_ANONYMIZE_KEY")
+	}
+
+	if cfg.Locale != "" {
+		locale, err := lookupLocale(cfg.Locale)
+		if err != nil {
+			fatalf(cfg, errorUsage, "use a locale like en, de_DE or fr", "Invalid -locale: %s", err)
+		}
+		numberFormat.numberLocale = locale
+	}
+	if cfg.ContinueOnError && cfg.FailOnError {
+		fatalf(cfg, errorUsage, "", "Use either -continue-on-error or -fail-on-error")
+	}
+	if cfg.MaxConcurrentRequests < 1 {
+		fatalf(cfg, errorUsage, "", "Invalid -max-concurrent-requests: %d", cfg.MaxConcurrentRequests)
+	}
+	if numberFormat.precision < 0 {
+		fatalf(cfg, errorUsage, "", "Invalid -precision: %d", numberFormat.precision)
+	}
+
+	switch cfg.Compress {
+	case "", compressGzip, compressZstd:
+	default:
+		fatalf(cfg, errorUsage, "use -compress gzip or -compress zstd", "Unknown compression %s, use gzip or zstd", cfg.Compress)
+	}
+
+	for _, pattern := range cfg.Clusters {
+		if _, err := path.Match(pattern, ""); err != nil {
+			fatalf(cfg, errorUsage, "use a cluster name or a glob pattern like 'prod-*'", "Invalid -cluster %s: %s", pattern, err)
+		}
+	}
+	for _, pattern := range cfg.DatastoreClusters {
+		if _, err := path.Match(pattern, ""); err != nil {
+			fatalf(cfg, errorUsage, "use a datastore cluster name or a glob pattern like 'pod-*'", "Invalid -datastore-cluster %s: %s", pattern, err)
+		}
+	}
+	for _, pattern := range cfg.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			fatalf(cfg, errorUsage, "use a name or a glob pattern like '*-swap'", "Invalid -exclude %s: %s", pattern, err)
+		}
+	}
+	for _, tag := range cfg.DatastoreTags {
+		if category, value, ok := strings.Cut(tag, "="); !ok || category == "" || value == "" {
+			fatalf(cfg, errorUsage, "use -datastore-tag category=value, e.g. tier=gold", "Invalid -datastore-tag %s", tag)
+		}
+	}
+
+	if cfg.MinCapacity != "" {
+		var err error
+		cfg.MinCapacityGB, err = parseSizeGB(cfg.MinCapacity)
+		if err != nil {
+			fatalf(cfg, errorUsage, "use a size like 500GB or 2TB", "Invalid -min-capacity: %s", err)
+		}
+	}
+
+	cfg.Thresholds = fc.Thresholds
+	cfg.Naming = fc.Naming
+	cfg.ServiceNow = fc.ServiceNow
+	cfg.Device42 = fc.Device42
+	cfg.Syslog = fc.Syslog
+	cfg.NATS = fc.NATS
+	cfg.Kafka = fc.Kafka
+	cfg.Hooks = fc.Hooks
+	cfg.Signing = fc.Signing
+	cfg.HostConfig = fc.HostConfig
 
 	return cfg
 }
 
+// usage prints the available commands and flags
+func usage() {
+	fmt.Println("Usage: godcinfo [command] [flags]")
+	fmt.Println("\nCommands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-12s %s\n", cmd.Name, cmd.Description)
+	}
+	fmt.Println("\nFlags:")
+	flag.PrintDefaults()
+}
+
 func connectToVSphere(ctx context.Context, cfg *Config) (*govmomi.Client, error) {
 	u, err := soap.ParseURL(cfg.URL)
 	if err != nil {
@@ -347,6 +1318,14 @@ func connectToVSphere(ctx context.Context, cfg *Config) (*govmomi.Client, error)
 	u.User = url.UserPassword(cfg.Username, cfg.Password)
 
 	soapClient := soap.NewClient(u, cfg.Insecure)
+	if cfg.Certificate != "" {
+		cert, err := loadClientCertificate(cfg)
+		if err != nil {
+			return nil, err
+		}
+		soapClient.SetCertificate(cert)
+	}
+	soapClient.Transport = vcenterTransport(cfg, soapClient.Transport)
 	vimClient, err := vim25.NewClient(ctx, soapClient)
 	if err != nil {
 		return nil, err
@@ -359,10 +1338,44 @@ func connectToVSphere(ctx context.Context, cfg *Config) (*govmomi.Client, error)
 		SessionManager: sm,
 	}
 
-	err = sm.Login(ctx, u.User)
+	if cfg.Certificate != "" && cfg.Password == "" {
+		err = certificateLogin(ctx, client, cfg)
+	} else {
+		err = sm.Login(ctx, u.User)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	return client, nil
 }
+
+// connectToREST logs in to the vSphere Automation (vAPI) REST endpoint with the configured credentials or certificate
+func connectToREST(ctx context.Context, client *govmomi.Client, cfg *Config) (*rest.Client, error) {
+	rc := rest.NewClient(client.Client)
+	rc.Transport = vcenterTransport(cfg, rc.Transport)
+
+	err := restLogin(ctx, rc, client, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return rc, nil
+}
+
+// inaccessibleReason collects the reasons the hosts give for not being able to access a
+// datastore, e.g. AllPathsDown_Start or PermanentDeviceLoss
+func inaccessibleReason(ds mo.Datastore) string {
+	seen := make(map[string]bool)
+	var reasons []string
+	for _, mount := range ds.Host {
+		reason := mount.MountInfo.InaccessibleReason
+		if reason == "" || seen[reason] {
+			continue
+		}
+		seen[reason] = true
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, ", ")
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// localFunc runs a command that works on saved reports or the local setup instead of a vCenter
+type localFunc func(cfg *Config) error
+
+// localCommands run without connecting to vCenter
+var localCommands = map[string]localFunc{
+	"merge":       mergeSnapshots,
+	"login":       loginKeychain,
+	"doctor":      runDoctor,
+	"avro-schema": printAvroSchemas,
+}
+
+// MergedSource is one saved datastores report of a merged report
+type MergedSource struct {
+	Source string `json:"source"`
+	File   string `json:"file"`
+	InfrastructureInfo
+}
+
+type MergedReport struct {
+	Sources                []MergedSource `json:"sources"`
+	Totals                 CapacityTotals `json:"totals"`
+	InaccessibleDatastores []string       `json:"inaccessible_datastores"`
+}
+
+// mergeSnapshots combines the datastores reports of several vCenters or datacenters, saved
+// with -o json, into one report with global totals. Every report keeps its source label,
+// given as label=file or the file name otherwise.
+func mergeSnapshots(cfg *Config) error {
+	if len(cfg.Args) == 0 {
+		return fmt.Errorf("merge needs the JSON reports to merge: merge [label=]file...")
+	}
+
+	report := MergedReport{
+		Sources:                make([]MergedSource, 0, len(cfg.Args)),
+		InaccessibleDatastores: make([]string, 0),
+	}
+
+	var capacity, free float64
+	for _, arg := range cfg.Args {
+		label, file := arg, arg
+		if i := strings.Index(arg, "="); i > 0 {
+			label, file = arg[:i], arg[i+1:]
+		}
+
+		// a report of several datacenters adds a source per datacenter
+		infras, err := loadSnapshots(file)
+		if err != nil {
+			return fmt.Errorf("loading %s: %s", file, err)
+		}
+		for _, infra := range infras {
+			report.Sources = append(report.Sources, MergedSource{Source: label, File: file, InfrastructureInfo: infra})
+
+			report.Totals.DatastoreCount += infra.Totals.DatastoreCount
+			report.Totals.SharedDatastores += infra.Totals.SharedDatastores
+			capacity += infra.Totals.Capacity
+			free += infra.Totals.FreeSpace
+			for _, name := range infra.InaccessibleDatastores {
+				report.InaccessibleDatastores = append(report.InaccessibleDatastores, fmt.Sprintf("%s/%s/%s", label, infra.Datacenter, name))
+			}
+		}
+	}
+	report.Totals.Capacity = capacity
+	report.Totals.FreeSpace = free
+	report.Totals.UsedPct = usedPct(capacity, free)
+	sort.Strings(report.InaccessibleDatastores)
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, src := range report.Sources {
+		fmt.Printf("\nSource: %s (datacenter %s, %d clusters)\n", src.Source, src.Datacenter, len(src.Clusters))
+		for _, cluster := range src.Clusters {
+			printTotals("  "+cluster.Name, cluster.Totals)
+		}
+		printTotals("  Datacenter total", src.Totals)
+	}
+
+	fmt.Println()
+	printTotals("Merged total", report.Totals)
+	if len(report.InaccessibleDatastores) > 0 {
+		fmt.Printf("\nWARNING: %d inaccessible datastores: %s\n",
+			len(report.InaccessibleDatastores), strings.Join(report.InaccessibleDatastores, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/vmware/govmomi"
+)
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// ScanMeta describes when and where a report was collected, so saved reports are self-describing
+type ScanMeta struct {
+	StartedAt       time.Time   `json:"started_at"`
+	FinishedAt      time.Time   `json:"finished_at"`
+	DurationSeconds float64     `json:"duration_seconds"`
+	Endpoint        string      `json:"endpoint"`
+	VCenterVersion  string      `json:"vcenter_version"`
+	ToolVersion     string      `json:"tool_version"`
+	Datacenter      string      `json:"datacenter"`
+	Counts          ObjectCount `json:"counts"`
+	// ContentHash changes only with the inventory, its status or a change in usage of more
+	// than a few percent
+	ContentHash string `json:"content_hash"`
+}
+
+type ObjectCount struct {
+	Clusters          int `json:"clusters"`
+	DatastoreClusters int `json:"datastore_clusters"`
+	Datastores        int `json:"datastores"`
+}
+
+// toolVersion returns the version of godcinfo, the module version for go install builds
+func toolVersion() string {
+	if version == "dev" {
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			return info.Main.Version
+		}
+	}
+	return version
+}
+
+// newScanMeta returns the metadata of a datastores report collected since started
+func newScanMeta(client *govmomi.Client, cfg *Config, infra InfrastructureInfo, started time.Time) *ScanMeta {
+	finished := time.Now()
+	meta := &ScanMeta{
+		StartedAt:       started.UTC(),
+		FinishedAt:      finished.UTC(),
+		DurationSeconds: finished.Sub(started).Seconds(),
+		Endpoint:        cfg.pseudonym("endpoint", client.URL().Host),
+		VCenterVersion:  client.ServiceContent.About.Version,
+		ToolVersion:     toolVersion(),
+		Datacenter:      infra.Datacenter,
+	}
+
+	pods := make(map[string]bool)
+	for _, cluster := range infra.Clusters {
+		for _, pod := range cluster.DatastoreClusters {
+			pods[pod.Name] = true
+		}
+	}
+	meta.Counts = ObjectCount{
+		Clusters:          len(infra.Clusters),
+		DatastoreClusters: len(pods),
+		Datastores:        infra.Totals.DatastoreCount,
+	}
+	meta.ContentHash = contentHash(infra)
+
+	return meta
+}
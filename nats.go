@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// NATSConfig sets the NATS server the datastores command publishes its snapshots and change
+// events to
+type NATSConfig struct {
+	// URL of the server, nats://[user:password@]host:port or tls:// for TLS
+	URL string `json:"url"`
+	// Subject defaults to godcinfo.snapshot.<datacenter>
+	Subject string `json:"subject,omitempty"`
+	// EventsSubject defaults to godcinfo.changes.<datacenter>
+	EventsSubject string `json:"events_subject,omitempty"`
+	// Format is json (default) or avro
+	Format   string `json:"format,omitempty"`
+	Token    string `json:"token,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+const natsDefaultPort = "4222"
+
+// publish sends the report and a message per change event and waits for the server to
+// confirm them
+func (c NATSConfig) publish(infra InfrastructureInfo, changes []ChangeEvent) error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return fmt.Errorf("unsupported URL scheme %s, use nats:// or tls://", u.Scheme)
+	}
+
+	subject := c.Subject
+	if subject == "" {
+		subject = "godcinfo.snapshot." + subjectToken(infra.Datacenter)
+	}
+	eventsSubject := c.EventsSubject
+	if eventsSubject == "" {
+		eventsSubject = "godcinfo.changes." + subjectToken(infra.Datacenter)
+	}
+
+	payload, err := encodeSnapshot(c.Format, infra)
+	if err != nil {
+		return err
+	}
+	var msgs bytes.Buffer
+	fmt.Fprintf(&msgs, "PUB %s %d\r\n%s\r\n", subject, len(payload), payload)
+	for _, change := range changes {
+		event, err := encodeChange(c.Format, change)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&msgs, "PUB %s %d\r\n%s\r\n", eventsSubject, len(event), event)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), natsDefaultPort)
+	}
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	// The server greets with INFO, TLS is negotiated after it
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(line))
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+
+	if u.Scheme == "tls" || info.TLSRequired {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname(), InsecureSkipVerify: c.Insecure})
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		conn = tlsConn
+		r = bufio.NewReader(conn)
+	}
+
+	connect := map[string]interface{}{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "godcinfo",
+		"lang":     "go",
+		"protocol": 0,
+	}
+	if u.User != nil {
+		connect["user"] = u.User.Username()
+		connect["pass"], _ = u.User.Password()
+	}
+	if c.Token != "" {
+		connect["auth_token"] = c.Token
+	}
+	options, err := json.Marshal(connect)
+	if err != nil {
+		return err
+	}
+
+	// PING makes the server answer with PONG once the messages were processed, or -ERR
+	msg := fmt.Sprintf("CONNECT %s\r\n%sPING\r\n", options, msgs.Bytes())
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return err
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// subjectToken turns a datacenter name into a single subject token, the dot separates tokens,
+// * and > are wildcards and whitespace ends the subject
+func subjectToken(name string) string {
+	token := strings.Map(func(r rune) rune {
+		if r == '.' || r == '*' || r == '>' || unicode.IsSpace(r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if token == "" {
+		return "_"
+	}
+	return token
+}
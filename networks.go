@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// network kinds
+const (
+	networkStandard    = "standard"
+	networkDistributed = "distributed"
+	// networkNSXSegment is an NSX segment on a distributed switch, NSX-T 3 on vSphere 7 and later
+	networkNSXSegment = "nsx_segment"
+	// networkOpaque is an NSX segment on an N-VDS, shown as opaque network by vCenter
+	networkOpaque = "opaque"
+)
+
+type NetworkInfo struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Switch is the distributed switch of distributed portgroups and NSX segments
+	Switch string `json:"switch,omitempty"`
+	// NSXID is the opaque network or segment ID, NSXType the opaque network type like
+	// nsx.LogicalSwitch
+	NSXID         string `json:"nsx_id,omitempty"`
+	NSXType       string `json:"nsx_type,omitempty"`
+	TransportZone string `json:"transport_zone,omitempty"`
+}
+
+type ClusterNetworksInfo struct {
+	Name     string        `json:"name"`
+	Networks []NetworkInfo `json:"networks"`
+}
+
+type NetworksReport struct {
+	Datacenter string                `json:"datacenter"`
+	Clusters   []ClusterNetworksInfo `json:"clusters"`
+}
+
+// reportNetworks lists the networks visible to each cluster, standard and distributed
+// portgroups as well as NSX backed opaque networks and segments, so that the network
+// inventory is complete in NSX environments
+func reportNetworks(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	var switches []mo.DistributedVirtualSwitch
+	err = retrieveAll(ctx, client, dc, "DistributedVirtualSwitch", []string{"name"}, &switches)
+	if err != nil {
+		return fmt.Errorf("retrieving distributed switches: %s", err)
+	}
+	switchNames := make(map[string]string, len(switches))
+	for _, dvs := range switches {
+		switchNames[dvs.Self.Value] = dvs.Name
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := NetworksReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterNetworksInfo, 0, len(clusters)),
+	}
+
+	for _, cluster := range clusters {
+		clusterInfo := ClusterNetworksInfo{
+			Name:     cluster.Name(),
+			Networks: make([]NetworkInfo, 0),
+		}
+
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"network"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting networks of cluster %s: %s", cluster.Name(), err)
+		}
+
+		var networks []mo.Network
+		var portgroupRefs []types.ManagedObjectReference
+		for _, ref := range clusterMo.Network {
+			if ref.Type == "DistributedVirtualPortgroup" {
+				portgroupRefs = append(portgroupRefs, ref)
+			}
+		}
+		if len(clusterMo.Network) > 0 {
+			err = pc.Retrieve(ctx, clusterMo.Network, []string{"name", "summary"}, &networks)
+			if err != nil {
+				return fmt.Errorf("getting networks of cluster %s: %s", cluster.Name(), err)
+			}
+		}
+
+		portgroups := make(map[string]mo.DistributedVirtualPortgroup)
+		if len(portgroupRefs) > 0 {
+			var pgs []mo.DistributedVirtualPortgroup
+			err = pc.Retrieve(ctx, portgroupRefs, []string{"config"}, &pgs)
+			if err != nil {
+				return fmt.Errorf("getting portgroups of cluster %s: %s", cluster.Name(), err)
+			}
+			for _, pg := range pgs {
+				portgroups[pg.Self.Value] = pg
+			}
+		}
+
+		for _, network := range networks {
+			info := NetworkInfo{Name: network.Name, Kind: networkStandard}
+
+			switch network.Self.Type {
+			case "DistributedVirtualPortgroup":
+				pg := portgroups[network.Self.Value]
+				if pg.Config.Uplink != nil && *pg.Config.Uplink {
+					continue
+				}
+				info.Kind = networkDistributed
+				if ref := pg.Config.DistributedVirtualSwitch; ref != nil {
+					info.Switch = switchNames[ref.Value]
+				}
+				if pg.Config.BackingType == "nsx" {
+					info.Kind = networkNSXSegment
+					info.NSXID = pg.Config.SegmentId
+					info.TransportZone = pg.Config.TransportZoneName
+				}
+			case "OpaqueNetwork":
+				info.Kind = networkOpaque
+				if summary, ok := network.Summary.(*types.OpaqueNetworkSummary); ok {
+					info.NSXType = summary.OpaqueNetworkType
+					info.NSXID = summary.OpaqueNetworkId
+				}
+			}
+
+			clusterInfo.Networks = append(clusterInfo.Networks, info)
+		}
+		sort.Slice(clusterInfo.Networks, func(i, j int) bool {
+			return clusterInfo.Networks[i].Name < clusterInfo.Networks[j].Name
+		})
+
+		report.Clusters = append(report.Clusters, clusterInfo)
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", cluster.Name)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		if len(cluster.Networks) == 0 {
+			fmt.Println("  No networks")
+		}
+		for _, n := range cluster.Networks {
+			fmt.Printf("  %s (%s", n.Name, strings.ReplaceAll(n.Kind, "_", " "))
+			if n.Switch != "" {
+				fmt.Printf(" on %s", n.Switch)
+			}
+			if n.NSXType != "" {
+				fmt.Printf(", %s", n.NSXType)
+			}
+			if n.NSXID != "" {
+				fmt.Printf(", ID %s", n.NSXID)
+			}
+			if n.TransportZone != "" {
+				fmt.Printf(", transport zone %s", n.TransportZone)
+			}
+			fmt.Println(")")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// numberLocale separates thousands and decimals of the sizes and percentages in text output
+type numberLocale struct {
+	group   string
+	decimal string
+}
+
+// numberLocales maps -locale values to their separators, by language or language_REGION
+var numberLocales = map[string]numberLocale{
+	"en":    {",", "."},
+	"ja":    {",", "."},
+	"zh":    {",", "."},
+	"de":    {".", ","},
+	"es":    {".", ","},
+	"it":    {".", ","},
+	"nl":    {".", ","},
+	"pt":    {".", ","},
+	"da":    {".", ","},
+	"fr":    {" ", ","},
+	"sv":    {" ", ","},
+	"fi":    {" ", ","},
+	"nb":    {" ", ","},
+	"pl":    {" ", ","},
+	"cs":    {" ", ","},
+	"de_CH": {"'", "."},
+	"fr_CH": {"'", "."},
+	"it_CH": {"'", "."},
+}
+
+// numberFormat is the separators and size precision set with -locale and -precision.
+// Without -locale numbers are printed without thousands separators.
+var numberFormat = struct {
+	numberLocale
+	precision int
+}{numberLocale{"", "."}, 2}
+
+// lookupLocale returns the separators of a locale like de, de_DE or de-DE.UTF-8
+func lookupLocale(name string) (numberLocale, error) {
+	name = strings.ReplaceAll(name, "-", "_")
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	if l, ok := numberLocales[name]; ok {
+		return l, nil
+	}
+	lang := strings.ToLower(strings.SplitN(name, "_", 2)[0])
+	if l, ok := numberLocales[lang]; ok {
+		return l, nil
+	}
+	return numberLocale{}, fmt.Errorf("unknown locale %s", name)
+}
+
+// formatNumber formats a number with the given number of decimals and the separators of numberFormat
+func formatNumber(v float64, precision int) string {
+	s := strconv.FormatFloat(v, 'f', precision, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	intPart, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, frac = s[:i], s[i+1:]
+	}
+
+	if group := numberFormat.group; group != "" && len(intPart) > 3 {
+		var b strings.Builder
+		for i, digit := range intPart {
+			if i > 0 && (len(intPart)-i)%3 == 0 {
+				b.WriteString(group)
+			}
+			b.WriteRune(digit)
+		}
+		intPart = b.String()
+	}
+
+	if frac != "" {
+		return sign + intPart + numberFormat.decimal + frac
+	}
+	return sign + intPart
+}
+
+// formatGB formats a size in GB with the -precision decimals
+func formatGB(v float64) string {
+	return formatNumber(v, numberFormat.precision) + " GB"
+}
+
+// formatPct formats a percentage with one decimal
+func formatPct(v float64) string {
+	return formatNumber(v, 1) + "%"
+}
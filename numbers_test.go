@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func TestFormatNumber(t *testing.T) {
+	defer func(saved numberLocale) { numberFormat.numberLocale = saved }(numberFormat.numberLocale)
+
+	tests := []struct {
+		locale    string
+		v         float64
+		precision int
+		want      string
+	}{
+		{"", 1234567.891, 2, "1234567.89"},
+		{"", 0.5, 0, "0"},
+		{"en", 1234567.891, 2, "1,234,567.89"},
+		{"en", 999.5, 1, "999.5"},
+		{"en", 1000, 0, "1,000"},
+		{"en", -1234.5, 1, "-1,234.5"},
+		{"de", 1234567.891, 2, "1.234.567,89"},
+		{"fr", 1234.5, 1, "1 234,5"},
+		{"de_CH", 1234567.25, 2, "1'234'567.25"},
+		{"en", 123456, 3, "123,456.000"},
+	}
+
+	for _, tt := range tests {
+		numberFormat.numberLocale = numberLocale{"", "."}
+		if tt.locale != "" {
+			l, err := lookupLocale(tt.locale)
+			if err != nil {
+				t.Fatal(err)
+			}
+			numberFormat.numberLocale = l
+		}
+		if got := formatNumber(tt.v, tt.precision); got != tt.want {
+			t.Errorf("formatNumber(%g, %d) with locale %q = %q, want %q", tt.v, tt.precision, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestLookupLocale(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    numberLocale
+		wantErr bool
+	}{
+		{"de", numberLocale{".", ","}, false},
+		{"de_DE", numberLocale{".", ","}, false},
+		{"de-DE.UTF-8", numberLocale{".", ","}, false},
+		{"de_CH", numberLocale{"'", "."}, false},
+		{"fr-CH", numberLocale{"'", "."}, false},
+		{"FR_fr", numberLocale{" ", ","}, false},
+		{"en_US.UTF-8", numberLocale{",", "."}, false},
+		{"xx", numberLocale{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := lookupLocale(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("lookupLocale(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("lookupLocale(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
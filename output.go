@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// output formats selected with -o
+const (
+	outputText    = "text"
+	outputJSON    = "json"
+	outputTree    = "tree"
+	outputDot     = "dot"
+	outputMermaid = "mermaid"
+	outputNDJSON  = "ndjson"
+)
+
+var outputFormats = []string{outputText, outputJSON, outputTree, outputDot, outputMermaid, outputNDJSON}
+
+// outputFlag is the -o flag. It used to be a boolean selecting JSON, so a bare -o
+// still selects JSON output.
+type outputFlag string
+
+func (f *outputFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return string(*f)
+}
+
+func (f *outputFlag) Set(value string) error {
+	switch value {
+	case "true":
+		value = outputJSON
+	case "false":
+		value = outputText
+	}
+	for _, format := range outputFormats {
+		if value == format {
+			*f = outputFlag(value)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %q, use one of %s", value, strings.Join(outputFormats, ", "))
+}
+
+func (f *outputFlag) IsBoolFlag() bool { return true }
+
+// normalizeOutputArgs joins "-o <format>" into "-o=<format>", the flag package
+// wouldn't pass the format to a flag that can be given without a value
+func normalizeOutputArgs(args []string) []string {
+	result := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if (arg == "-o" || arg == "--o") && i+1 < len(args) && isOutputFormat(args[i+1]) {
+			arg = "-o=" + args[i+1]
+			i++
+		}
+		result = append(result, arg)
+	}
+	return result
+}
+
+func isOutputFormat(s string) bool {
+	for _, format := range outputFormats {
+		if s == format {
+			return true
+		}
+	}
+	return false
+}
+
+// capacityBarWidth is the number of characters of the used capacity bar in tree output
+const capacityBarWidth = 20
+
+// capacityBar renders the used percentage as a bar like [#####...............]
+func capacityBar(used float64) string {
+	filled := int(math.Round(used / 100 * capacityBarWidth))
+	if filled < 0 {
+		filled = 0
+	}
+	if filled > capacityBarWidth {
+		filled = capacityBarWidth
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", capacityBarWidth-filled) + "]"
+}
+
+// treeNode is a line of the tree output with its children
+type treeNode struct {
+	label    string
+	children []treeNode
+}
+
+func capacityLabel(name string, capacity, free, used float64) string {
+	return fmt.Sprintf("%s %s %6s (Capacity: %s, Free: %s)", name, capacityBar(used), formatPct(used), formatGB(capacity), formatGB(free))
+}
+
+func datastoreNode(info DatastoreInfo) treeNode {
+	label := capacityLabel(info.Name, info.Capacity, info.FreeSpace, info.UsedPct)
+	if !info.Accessible {
+		label += " INACCESSIBLE"
+	}
+	if info.Status != "" && info.Status != statusOK {
+		label += " " + strings.ToUpper(info.Status)
+	}
+	return treeNode{label: label}
+}
+
+// printTree prints the datacenter, its clusters, datastore clusters and datastores as an indented tree
+func printTree(infra InfrastructureInfo) {
+	t := infra.Totals
+	root := treeNode{label: capacityLabel(infra.Datacenter, t.Capacity, t.FreeSpace, t.UsedPct)}
+
+	for _, cluster := range infra.Clusters {
+		ct := cluster.Totals
+		clusterNode := treeNode{label: capacityLabel(cluster.Name, ct.Capacity, ct.FreeSpace, ct.UsedPct)}
+
+		for _, pod := range cluster.DatastoreClusters {
+			podNode := treeNode{label: capacityLabel(pod.Name, pod.TotalCapacity, pod.TotalFreeSpace, pod.UsedPct)}
+			for _, ds := range pod.Datastores {
+				podNode.children = append(podNode.children, datastoreNode(ds))
+			}
+			clusterNode.children = append(clusterNode.children, podNode)
+		}
+
+		for _, ds := range cluster.StandaloneDatastores {
+			clusterNode.children = append(clusterNode.children, datastoreNode(ds))
+		}
+
+		root.children = append(root.children, clusterNode)
+	}
+
+	fmt.Println(root.label)
+	printTreeChildren(root.children, "")
+}
+
+func printTreeChildren(nodes []treeNode, prefix string) {
+	for i, node := range nodes {
+		branch, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, indent = "└── ", "    "
+		}
+		fmt.Println(prefix + branch + node.label)
+		printTreeChildren(node.children, prefix+indent)
+	}
+}
+
+// DatastoreRecord is one line of -o ndjson output
+type DatastoreRecord struct {
+	Datacenter       string `json:"datacenter"`
+	Cluster          string `json:"cluster"`
+	DatastoreCluster string `json:"datastore_cluster,omitempty"`
+	DatastoreInfo
+}
+
+// printNDJSON prints a datastore as a single line of JSON
+func printNDJSON(record DatastoreRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("generating JSON output: %s", err)
+	}
+	fmt.Println(string(line))
+	reportPrinted = true
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/performance"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// perfBatchSize limits the number of entities per performance query, vCenter rejects queries
+// exceeding its config.vpxd.stats.maxQueryMetrics limit
+const perfBatchSize = 64
+
+// perfIntervals are the historical intervals of vCenter in seconds with how long their samples
+// are kept by default, the finest one covering a window is queried
+var perfIntervals = []struct {
+	seconds int32
+	keep    time.Duration
+}{
+	{300, 24 * time.Hour},
+	{1800, 7 * 24 * time.Hour},
+	{7200, 30 * 24 * time.Hour},
+	{86400, 365 * 24 * time.Hour},
+}
+
+// perfInterval returns the historical interval whose samples cover the window
+func perfInterval(window time.Duration) int32 {
+	for _, interval := range perfIntervals {
+		if window <= interval.keep {
+			return interval.seconds
+		}
+	}
+	return perfIntervals[len(perfIntervals)-1].seconds
+}
+
+// entityMetrics queries the historical samples of the given counters, like cpu.usage.average,
+// for the entities over the window. The samples of the aggregate instance are returned by
+// entity reference and counter name, entities without samples are left out.
+func entityMetrics(ctx context.Context, client *govmomi.Client, entities []types.ManagedObjectReference, counters []string, window time.Duration) (map[string]map[string][]int64, error) {
+	m := performance.NewManager(client.Client)
+
+	interval := perfInterval(window)
+	end := time.Now()
+	start := end.Add(-window)
+	spec := types.PerfQuerySpec{
+		StartTime:  &start,
+		EndTime:    &end,
+		IntervalId: interval,
+		MaxSample:  int32(window.Seconds()) / interval,
+		MetricId:   []types.PerfMetricId{{Instance: ""}},
+	}
+
+	samples := make(map[string]map[string][]int64, len(entities))
+	for i := 0; i < len(entities); i += perfBatchSize {
+		batch := entities[i:]
+		if len(batch) > perfBatchSize {
+			batch = batch[:perfBatchSize]
+		}
+
+		series, err := m.SampleByName(ctx, spec, counters, batch)
+		if err != nil {
+			return nil, err
+		}
+		metrics, err := m.ToMetricSeries(ctx, series)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, metric := range metrics {
+			for _, v := range metric.Value {
+				if v.Instance != "" || len(v.Value) == 0 {
+					continue
+				}
+				if samples[metric.Entity.Value] == nil {
+					samples[metric.Entity.Value] = make(map[string][]int64)
+				}
+				samples[metric.Entity.Value][v.Name] = v.Value
+			}
+		}
+	}
+
+	return samples, nil
+}
+
+// percentile returns the p-th percentile (0-100) of the samples, ignoring negative values
+// vCenter reports for missing samples
+func percentile(samples []int64, p float64) int64 {
+	values := make([]int64, 0, len(samples))
+	for _, v := range samples {
+		if v >= 0 {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	i := int(math.Ceil(p/100*float64(len(values)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return values[i]
+}
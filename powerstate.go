@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type ClusterPowerStateInfo struct {
+	Name      string `json:"name"`
+	PoweredOn int    `json:"powered_on"`
+	// PoweredOff includes the stale VMs
+	PoweredOff int `json:"powered_off"`
+	Suspended  int `json:"suspended"`
+}
+
+// StaleVMInfo is a VM powered off for longer than -stale-days
+type StaleVMInfo struct {
+	Name    string `json:"name"`
+	Cluster string `json:"cluster"`
+	// PoweredOffSince is the time of the last power off event, nil when the event is older
+	// than the event retention of vCenter
+	PoweredOffSince *time.Time `json:"powered_off_since,omitempty"`
+	DaysOff         *int       `json:"days_off,omitempty"`
+	// Created is set for VMs without power off event, they are stale by their age
+	Created     *time.Time `json:"created,omitempty"`
+	DaysCreated *int       `json:"days_created,omitempty"`
+	Datastores  []string   `json:"datastores"`
+	// Committed is the datastore space the VM uses
+	Committed float64 `json:"committed_gb"`
+}
+
+type PowerStateReport struct {
+	Datacenter string                  `json:"datacenter"`
+	StaleDays  int                     `json:"stale_days"`
+	Clusters   []ClusterPowerStateInfo `json:"clusters"`
+	StaleVMs   []StaleVMInfo           `json:"stale_vms"`
+	// StaleCommitted sums up the space of the stale VMs that could be reclaimed
+	StaleCommitted float64 `json:"stale_committed_gb"`
+	// UndatedVMs are powered off without power off event and creation date, so it is unknown
+	// whether they are stale
+	UndatedVMs []StaleVMInfo `json:"undated_vms"`
+}
+
+// reportPowerStates counts the powered on, powered off and suspended VMs of every cluster and
+// lists the VMs that have been powered off for longer than -stale-days according to their
+// last power off event, or their creation date without one, with the space they take up
+// on the datastores
+func reportPowerStates(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	report := PowerStateReport{
+		Datacenter: dc.Name(),
+		StaleDays:  cfg.StaleDays,
+		Clusters:   make([]ClusterPowerStateInfo, 0, len(clusters)),
+		StaleVMs:   make([]StaleVMInfo, 0),
+		UndatedVMs: make([]StaleVMInfo, 0),
+	}
+
+	events := event.NewManager(client.Client)
+	now := time.Now()
+	datastoreNames := make(map[string]string)
+
+	for _, cluster := range clusters {
+		var vms []mo.VirtualMachine
+		err = retrieveClusterVMs(ctx, client, cluster, []string{"name", "config.template", "config.createDate", "runtime.powerState", "summary.storage", "datastore"}, &vms)
+		if err != nil {
+			return fmt.Errorf("getting VMs of cluster %s: %s", cluster.Name(), err)
+		}
+
+		info := ClusterPowerStateInfo{Name: cluster.Name()}
+		for _, vm := range vms {
+			if vm.Config != nil && vm.Config.Template {
+				continue
+			}
+			switch vm.Runtime.PowerState {
+			case types.VirtualMachinePowerStatePoweredOn:
+				info.PoweredOn++
+				continue
+			case types.VirtualMachinePowerStateSuspended:
+				info.Suspended++
+				continue
+			}
+			info.PoweredOff++
+
+			since, err := lastPowerOff(ctx, events, vm.Self)
+			if err != nil {
+				return fmt.Errorf("querying events of VM %s: %s", vm.Name, err)
+			}
+			stale := StaleVMInfo{
+				Name:       vm.Name,
+				Cluster:    cluster.Name(),
+				Datastores: make([]string, 0, len(vm.Datastore)),
+			}
+			// without power off event the VM was off before the event retention or never
+			// powered on, a VM created within -stale-days is not stale either way
+			var created *time.Time
+			if vm.Config != nil {
+				created = vm.Config.CreateDate
+			}
+			switch {
+			case since != nil:
+				days := int(now.Sub(*since).Hours() / 24)
+				if days < cfg.StaleDays {
+					continue
+				}
+				stale.PoweredOffSince = since
+				stale.DaysOff = &days
+			case created != nil:
+				days := int(now.Sub(*created).Hours() / 24)
+				if days < cfg.StaleDays {
+					continue
+				}
+				stale.Created = created
+				stale.DaysCreated = &days
+			}
+			if vm.Summary.Storage != nil {
+				stale.Committed = bytesToGB(vm.Summary.Storage.Committed)
+			}
+			for _, ref := range vm.Datastore {
+				stale.Datastores = append(stale.Datastores, ref.Value)
+				datastoreNames[ref.Value] = ""
+			}
+			if since == nil && created == nil {
+				report.UndatedVMs = append(report.UndatedVMs, stale)
+				continue
+			}
+			report.StaleVMs = append(report.StaleVMs, stale)
+			report.StaleCommitted += stale.Committed
+		}
+
+		report.Clusters = append(report.Clusters, info)
+	}
+
+	if len(datastoreNames) > 0 {
+		refs := make([]types.ManagedObjectReference, 0, len(datastoreNames))
+		for value := range datastoreNames {
+			refs = append(refs, types.ManagedObjectReference{Type: "Datastore", Value: value})
+		}
+		var datastores []mo.Datastore
+		err = property.DefaultCollector(client.Client).Retrieve(ctx, refs, []string{"name"}, &datastores)
+		if err != nil {
+			return fmt.Errorf("getting datastores: %s", err)
+		}
+		for _, ds := range datastores {
+			datastoreNames[ds.Self.Value] = ds.Name
+		}
+		for _, vm := range append(report.StaleVMs, report.UndatedVMs...) {
+			for i, ref := range vm.Datastores {
+				if name := datastoreNames[ref]; name != "" {
+					vm.Datastores[i] = name
+				}
+			}
+			sort.Strings(vm.Datastores)
+		}
+	}
+
+	// the longest off first, VMs without power off event by their age, then the largest first
+	sort.SliceStable(report.StaleVMs, func(i, j int) bool {
+		a, b := report.StaleVMs[i].staleDays(), report.StaleVMs[j].staleDays()
+		if a != b {
+			return a > b
+		}
+		return report.StaleVMs[i].Committed > report.StaleVMs[j].Committed
+	})
+	sort.SliceStable(report.UndatedVMs, func(i, j int) bool {
+		return report.UndatedVMs[i].Committed > report.UndatedVMs[j].Committed
+	})
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		return report.Clusters[i].Name < report.Clusters[j].Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", cluster.Name)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		fmt.Printf("  Powered on: %d, Powered off: %d, Suspended: %d\n", cluster.PoweredOn, cluster.PoweredOff, cluster.Suspended)
+	}
+
+	fmt.Printf("\nVMs powered off for more than %d days: %d (%s committed)\n", report.StaleDays, len(report.StaleVMs), formatGB(report.StaleCommitted))
+	for _, vm := range report.StaleVMs {
+		var since string
+		if vm.PoweredOffSince != nil {
+			since = fmt.Sprintf("since %s (%d days)", vm.PoweredOffSince.Local().Format("2006-01-02"), *vm.DaysOff)
+		} else {
+			since = fmt.Sprintf("before the event retention, created %s (%d days ago)", vm.Created.Local().Format("2006-01-02"), *vm.DaysCreated)
+		}
+		fmt.Printf("  %s (cluster %s): off %s, %s on %s\n", vm.Name, vm.Cluster, since, formatGB(vm.Committed), valueOrNone(strings.Join(vm.Datastores, ", ")))
+	}
+
+	if len(report.UndatedVMs) > 0 {
+		fmt.Printf("\nPowered off VMs without power off event or creation date: %d\n", len(report.UndatedVMs))
+		for _, vm := range report.UndatedVMs {
+			fmt.Printf("  %s (cluster %s): %s on %s\n", vm.Name, vm.Cluster, formatGB(vm.Committed), valueOrNone(strings.Join(vm.Datastores, ", ")))
+		}
+	}
+
+	return nil
+}
+
+// staleDays returns how long a stale VM has been off, by its age without power off event
+func (vm StaleVMInfo) staleDays() int {
+	if vm.DaysOff != nil {
+		return *vm.DaysOff
+	}
+	return *vm.DaysCreated
+}
+
+// lastPowerOff returns the time of the last power off event of a VM, nil without one
+func lastPowerOff(ctx context.Context, events *event.Manager, vm types.ManagedObjectReference) (*time.Time, error) {
+	filter := types.EventFilterSpec{
+		Entity: &types.EventFilterSpecByEntity{
+			Entity:    vm,
+			Recursion: types.EventFilterSpecRecursionOptionSelf,
+		},
+		EventTypeId: []string{"VmPoweredOffEvent"},
+	}
+	found, err := events.QueryEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var last *time.Time
+	for _, e := range found {
+		created := e.GetEvent().CreatedTime
+		if last == nil || created.After(*last) {
+			last = &created
+		}
+	}
+	return last, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Profile bundles the connection settings and default filters of one vCenter, selected with
+// -profile. Flags given on the command line take precedence over the profile, the profile
+// over the VSPHERE_* and GOVC_* environment variables.
+type Profile struct {
+	URL      string `json:"url,omitempty"`
+	Username string `json:"username,omitempty"`
+	// the password is read from the environment variable named by PasswordEnv or the first
+	// line of PasswordFile, Password in the config file itself is the last resort
+	Password     string   `json:"password,omitempty"`
+	PasswordEnv  string   `json:"password_env,omitempty"`
+	PasswordFile string   `json:"password_file,omitempty"`
+	Insecure     *bool    `json:"insecure,omitempty"`
+	Datacenters  []string `json:"datacenters,omitempty"`
+	// default filters
+	Clusters          []string `json:"clusters,omitempty"`
+	DatastoreClusters []string `json:"datastore_clusters,omitempty"`
+	Exclude           []string `json:"exclude,omitempty"`
+	DatastoreTags     []string `json:"datastore_tags,omitempty"`
+	ExcludeLocal      bool     `json:"exclude_local,omitempty"`
+}
+
+// password returns the password of the profile from its source
+func (p Profile) password() (string, error) {
+	switch {
+	case p.PasswordEnv != "":
+		password := os.Getenv(p.PasswordEnv)
+		if password == "" {
+			return "", fmt.Errorf("%s is not set", p.PasswordEnv)
+		}
+		return password, nil
+	case p.PasswordFile != "":
+		data, err := os.ReadFile(p.PasswordFile)
+		if err != nil {
+			return "", err
+		}
+		password, _, _ := strings.Cut(string(data), "\n")
+		return strings.TrimSuffix(password, "\r"), nil
+	}
+	return p.Password, nil
+}
+
+// applyProfile sets the settings of the -profile that were not given as flags
+func applyProfile(cfg *Config, profiles map[string]Profile) error {
+	p, ok := profiles[cfg.Profile]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("unknown profile %s, the config file defines no profiles", cfg.Profile)
+		}
+		return fmt.Errorf("unknown profile %s, the config file defines %s", cfg.Profile, strings.Join(names, ", "))
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	if p.URL != "" && !set["url"] {
+		cfg.URL = p.URL
+	}
+	if p.Username != "" && !set["username"] {
+		cfg.Username = p.Username
+	}
+	if !set["password"] {
+		password, err := p.password()
+		if err != nil {
+			return fmt.Errorf("reading the password of profile %s: %s", cfg.Profile, err)
+		}
+		if password != "" {
+			cfg.Password = password
+		}
+	}
+	if p.Insecure != nil && !set["insecure"] {
+		cfg.Insecure = *p.Insecure
+	}
+	if len(p.Datacenters) > 0 && !set["datacenter"] {
+		cfg.Datacenters = p.Datacenters
+	}
+	if len(p.Clusters) > 0 && !set["cluster"] {
+		cfg.Clusters = p.Clusters
+	}
+	if len(p.DatastoreClusters) > 0 && !set["datastore-cluster"] {
+		cfg.DatastoreClusters = p.DatastoreClusters
+	}
+	if len(p.Exclude) > 0 && !set["exclude"] {
+		cfg.Exclude = p.Exclude
+	}
+	if len(p.DatastoreTags) > 0 && !set["datastore-tag"] {
+		cfg.DatastoreTags = p.DatastoreTags
+	}
+	if p.ExcludeLocal && !set["exclude-local"] {
+		cfg.ExcludeLocal = true
+	}
+
+	return nil
+}
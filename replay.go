@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+var (
+	// credentials and the SOAP header holding the session cookie are left out of the
+	// request key so a recording can be replayed without them
+	credentialElements = regexp.MustCompile(`<(userName|password)>[^<]*</(?:userName|password)>`)
+	soapHeader         = regexp.MustCompile(`(?s)<(?:\w+:)?Header[^>]*>.*?</(?:\w+:)?Header>`)
+	soapOperation      = regexp.MustCompile(`<(?:\w+:)?Body[^>]*>\s*<(\w+)`)
+)
+
+// recordingTransport returns the transport for vCenter requests: the given one, or one
+// that records every response to -record or answers from the responses in -replay
+func recordingTransport(cfg *Config, next http.RoundTripper) http.RoundTripper {
+	switch {
+	case cfg.Record != "":
+		return &soapRecorder{dir: cfg.Record, next: next, seen: make(map[string]int)}
+	case cfg.Replay != "":
+		return &soapReplayer{dir: cfg.Replay, seen: make(map[string]int)}
+	}
+	return next
+}
+
+// recordingName names the file holding the response to a request. Identical requests
+// are numbered in the order they are sent, so a repeated request replays each response.
+func recordingName(req *http.Request, seen map[string]int) (string, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return "", err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	body = credentialElements.ReplaceAll(body, []byte("<${1}/>"))
+	body = soapHeader.ReplaceAll(body, nil)
+
+	op := req.Method
+	if m := soapOperation.FindSubmatch(body); m != nil {
+		op = string(m[1])
+	}
+
+	sum := sha256.Sum256(append([]byte(req.Method+" "+req.URL.RequestURI()+"\n"), body...))
+	key := fmt.Sprintf("%s-%x", op, sum[:6])
+	seen[key]++
+
+	return fmt.Sprintf("%s-%d.http", key, seen[key]), nil
+}
+
+// soapRecorder passes requests on to vCenter and saves every response below dir
+type soapRecorder struct {
+	dir  string
+	next http.RoundTripper
+
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+func (r *soapRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	name, err := recordingName(req, r.seen)
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	// the session cookie and the session ids in the bodies are only needed by the live
+	// client, keep them out of the recording with the redaction of -debug-soap
+	recorded := *res
+	recorded.Header = res.Header.Clone()
+	recorded.Header.Del("Set-Cookie")
+	redacted := redactSOAP(body)
+	recorded.Body = io.NopCloser(bytes.NewReader(redacted))
+	recorded.ContentLength = int64(len(redacted))
+	if recorded.Header.Get("Content-Length") != "" {
+		recorded.Header.Set("Content-Length", strconv.Itoa(len(redacted)))
+	}
+	dump, err := httputil.DumpResponse(&recorded, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(filepath.Join(r.dir, name), dump, 0600); err != nil {
+		return nil, fmt.Errorf("recording %s: %s", name, err)
+	}
+
+	return res, nil
+}
+
+// soapReplayer answers requests with the responses recorded below dir, without a vCenter
+type soapReplayer struct {
+	dir string
+
+	mu   sync.Mutex
+	seen map[string]int
+}
+
+func (r *soapReplayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.mu.Lock()
+	name, err := recordingName(req, r.seen)
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	dump, err := os.ReadFile(filepath.Join(r.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response for %s %s: %s", req.Method, req.URL.Path, err)
+	}
+
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+}
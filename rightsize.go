@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// rightsizeCounters are the CPU usage in hundredths of a percent of the configured vCPUs and
+// the active guest memory in KB
+var rightsizeCounters = []string{"cpu.usage.average", "mem.active.average"}
+
+// rightsizeTargetUtilization is the share of the suggested vCPUs and memory the 95th
+// percentile of the usage may take, leaving headroom for peaks
+const rightsizeTargetUtilization = 0.8
+
+type RightsizeVMInfo struct {
+	Name     string  `json:"name"`
+	Cluster  string  `json:"cluster"`
+	VCPUs    int     `json:"vcpus"`
+	MemoryGB float64 `json:"memory_gb"`
+	// the peak and 95th percentile usage over the window
+	CPUPeakPct   float64 `json:"cpu_peak_pct"`
+	CPUP95Pct    float64 `json:"cpu_p95_pct"`
+	MemoryPeakGB float64 `json:"memory_active_peak_gb"`
+	MemoryP95GB  float64 `json:"memory_active_p95_gb"`
+	// SuggestedVCPUs and SuggestedMemoryGB equal the configured values when the VM doesn't
+	// need downsizing
+	SuggestedVCPUs    int     `json:"suggested_vcpus"`
+	SuggestedMemoryGB float64 `json:"suggested_memory_gb"`
+}
+
+type RightsizeReport struct {
+	Datacenter string            `json:"datacenter"`
+	WindowDays int               `json:"window_days"`
+	Candidates []RightsizeVMInfo `json:"candidates"`
+	// the vCPUs and memory reclaimed by following all suggestions
+	ReclaimableVCPUs    int     `json:"reclaimable_vcpus"`
+	ReclaimableMemoryGB float64 `json:"reclaimable_memory_gb"`
+	// NoData counts the powered on VMs without performance samples in the window
+	NoData int `json:"vms_without_data"`
+}
+
+// reportRightsize compares the configured vCPUs and memory of the powered on VMs with the peak
+// and 95th percentile of their CPU usage and active memory over the last -rightsize-days and
+// lists the VMs that could be downsized, the suggestions keep the 95th percentile below 80%
+// of the new size
+func reportRightsize(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	if cfg.RightsizeDays <= 0 {
+		return fmt.Errorf("-rightsize-days must be at least 1")
+	}
+
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	report := RightsizeReport{
+		Datacenter: dc.Name(),
+		WindowDays: cfg.RightsizeDays,
+		Candidates: make([]RightsizeVMInfo, 0),
+	}
+	window := time.Duration(cfg.RightsizeDays) * 24 * time.Hour
+
+	for _, cluster := range clusters {
+		var vms []mo.VirtualMachine
+		err = retrieveClusterVMs(ctx, client, cluster, []string{"name", "config.template", "config.hardware", "runtime.powerState"}, &vms)
+		if err != nil {
+			return fmt.Errorf("getting VMs of cluster %s: %s", cluster.Name(), err)
+		}
+
+		var refs []types.ManagedObjectReference
+		running := make(map[string]mo.VirtualMachine)
+		for _, vm := range vms {
+			if vm.Config == nil || vm.Config.Template || vm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn {
+				continue
+			}
+			refs = append(refs, vm.Self)
+			running[vm.Self.Value] = vm
+		}
+		if len(refs) == 0 {
+			continue
+		}
+
+		samples, err := entityMetrics(ctx, client, refs, rightsizeCounters, window)
+		if err != nil {
+			return fmt.Errorf("querying performance of cluster %s: %s", cluster.Name(), err)
+		}
+
+		for _, ref := range refs {
+			vm := running[ref.Value]
+			metrics, ok := samples[ref.Value]
+			if !ok || len(metrics["cpu.usage.average"]) == 0 || len(metrics["mem.active.average"]) == 0 {
+				report.NoData++
+				continue
+			}
+
+			info := RightsizeVMInfo{
+				Name:         vm.Name,
+				Cluster:      cluster.Name(),
+				VCPUs:        int(vm.Config.Hardware.NumCPU),
+				MemoryGB:     float64(vm.Config.Hardware.MemoryMB) / 1024,
+				CPUPeakPct:   float64(percentile(metrics["cpu.usage.average"], 100)) / 100,
+				CPUP95Pct:    float64(percentile(metrics["cpu.usage.average"], 95)) / 100,
+				MemoryPeakGB: float64(percentile(metrics["mem.active.average"], 100)) / 1024 / 1024,
+				MemoryP95GB:  float64(percentile(metrics["mem.active.average"], 95)) / 1024 / 1024,
+			}
+
+			// vCPUs needed for the 95th percentile, whole GB of memory
+			info.SuggestedVCPUs = int(math.Ceil(float64(info.VCPUs) * info.CPUP95Pct / 100 / rightsizeTargetUtilization))
+			if info.SuggestedVCPUs < 1 {
+				info.SuggestedVCPUs = 1
+			}
+			if info.SuggestedVCPUs > info.VCPUs {
+				info.SuggestedVCPUs = info.VCPUs
+			}
+			info.SuggestedMemoryGB = math.Ceil(info.MemoryP95GB / rightsizeTargetUtilization)
+			if info.SuggestedMemoryGB < 1 {
+				info.SuggestedMemoryGB = 1
+			}
+			if info.SuggestedMemoryGB > info.MemoryGB {
+				info.SuggestedMemoryGB = info.MemoryGB
+			}
+			if info.SuggestedVCPUs == info.VCPUs && info.SuggestedMemoryGB == info.MemoryGB {
+				continue
+			}
+
+			report.Candidates = append(report.Candidates, info)
+			report.ReclaimableVCPUs += info.VCPUs - info.SuggestedVCPUs
+			report.ReclaimableMemoryGB += info.MemoryGB - info.SuggestedMemoryGB
+		}
+	}
+	// the largest savings first
+	sort.Slice(report.Candidates, func(i, j int) bool {
+		a, b := report.Candidates[i], report.Candidates[j]
+		savedA, savedB := a.MemoryGB-a.SuggestedMemoryGB, b.MemoryGB-b.SuggestedMemoryGB
+		if savedA != savedB {
+			return savedA > savedB
+		}
+		if a.VCPUs-a.SuggestedVCPUs != b.VCPUs-b.SuggestedVCPUs {
+			return a.VCPUs-a.SuggestedVCPUs > b.VCPUs-b.SuggestedVCPUs
+		}
+		return a.Name < b.Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nDownsizing candidates over the last %d days: %d (%d vCPUs, %s memory reclaimable)\n",
+		report.WindowDays, len(report.Candidates), report.ReclaimableVCPUs, formatGB(report.ReclaimableMemoryGB))
+	for _, vm := range report.Candidates {
+		fmt.Printf("\n  %s (cluster %s)\n", vm.Name, vm.Cluster)
+		fmt.Printf("    CPU: %d vCPUs, usage peak %s, 95th percentile %s, suggested %d vCPUs\n",
+			vm.VCPUs, formatPct(vm.CPUPeakPct), formatPct(vm.CPUP95Pct), vm.SuggestedVCPUs)
+		fmt.Printf("    Memory: %s, active peak %s, 95th percentile %s, suggested %s\n",
+			formatGB(vm.MemoryGB), formatGB(vm.MemoryPeakGB), formatGB(vm.MemoryP95GB), formatGB(vm.SuggestedMemoryGB))
+	}
+	if report.NoData > 0 {
+		fmt.Printf("\nVMs without performance data in the window: %d\n", report.NoData)
+	}
+
+	return nil
+}
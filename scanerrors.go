@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// ScanError is a failed lookup of one inventory object that -continue-on-error skipped
+type ScanError struct {
+	Object    string `json:"object"`
+	Operation string `json:"operation"`
+	Message   string `json:"message"`
+}
+
+// scanErrors collects the errors of a scan. Objects that can't be read are skipped, with
+// -continue-on-error they are listed in the report as well, with -fail-on-error the first
+// error ends the scan.
+type scanErrors struct {
+	continueOnError bool
+	failOnError     bool
+	errors          []ScanError
+	// message turns an error into the message printed and reported, see anonymizeError
+	message func(error) string
+}
+
+// add records an error, it returns the error to end the scan with when errors are fatal
+func (s *scanErrors) add(object, operation string, err error) error {
+	if s.failOnError {
+		return fmt.Errorf("%s %s: %s", operation, object, s.message(err))
+	}
+	if s.continueOnError {
+		s.errors = append(s.errors, ScanError{Object: object, Operation: operation, Message: s.message(err)})
+	}
+	return nil
+}
+
+// printSummary lists the skipped objects on stderr
+func (s *scanErrors) printSummary() {
+	if len(s.errors) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%d errors during the scan, the report is partial:\n", len(s.errors))
+	for _, e := range s.errors {
+		fmt.Fprintf(os.Stderr, "  - %s %s: %s\n", e.Operation, e.Object, e.Message)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type ScheduledTaskInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Enabled     bool   `json:"enabled"`
+	Entity      string `json:"entity"`
+	EntityType  string `json:"entity_type"`
+	// Action is the vSphere API method the task runs, like CreateSnapshot_Task
+	Action      string     `json:"action"`
+	Schedule    string     `json:"schedule"`
+	NextRun     *time.Time `json:"next_run,omitempty"`
+	PrevRun     *time.Time `json:"prev_run,omitempty"`
+	State       string     `json:"state"`
+	Error       string     `json:"error,omitempty"`
+	LastUser    string     `json:"last_modified_user,omitempty"`
+	LastChanged time.Time  `json:"last_modified"`
+}
+
+type ScheduledTasksReport struct {
+	Datacenter string              `json:"datacenter"`
+	Tasks      []ScheduledTaskInfo `json:"tasks"`
+}
+
+// reportScheduledTasks lists the vCenter scheduled tasks, like snapshot schedules and power
+// operations, that run on objects of the datacenter or the selected clusters, ordered by
+// their next run, so automation competing with maintenance windows is visible
+func reportScheduledTasks(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	manager := client.ServiceContent.ScheduledTaskManager
+	if manager == nil {
+		return fmt.Errorf("the scheduled task manager is not available, scheduled tasks need vCenter")
+	}
+
+	entities, err := scheduledTaskScope(ctx, client, finder, dc, cfg)
+	if err != nil {
+		return err
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	var stm mo.ScheduledTaskManager
+	err = pc.RetrieveOne(ctx, *manager, []string{"scheduledTask"}, &stm)
+	if err != nil {
+		return fmt.Errorf("retrieving scheduled tasks: %s", err)
+	}
+
+	var tasks []mo.ScheduledTask
+	if len(stm.ScheduledTask) > 0 {
+		err = pc.Retrieve(ctx, stm.ScheduledTask, []string{"info"}, &tasks)
+		if err != nil {
+			return fmt.Errorf("retrieving scheduled tasks: %s", err)
+		}
+	}
+
+	report := ScheduledTasksReport{
+		Datacenter: dc.Name(),
+		Tasks:      make([]ScheduledTaskInfo, 0),
+	}
+
+	for _, task := range tasks {
+		entity, ok := entities[task.Info.Entity.Value]
+		if !ok {
+			continue
+		}
+
+		info := ScheduledTaskInfo{
+			Name:        task.Info.Name,
+			Description: task.Info.Description,
+			Enabled:     task.Info.Enabled,
+			Entity:      entity,
+			EntityType:  task.Info.Entity.Type,
+			Schedule:    scheduleDescription(task.Info.Scheduler),
+			NextRun:     task.Info.NextRunTime,
+			PrevRun:     task.Info.PrevRunTime,
+			State:       string(task.Info.State),
+			LastUser:    task.Info.LastModifiedUser,
+			LastChanged: task.Info.LastModifiedTime,
+		}
+		if action, ok := task.Info.Action.(*types.MethodAction); ok {
+			info.Action = action.Name
+		}
+		if task.Info.Error != nil {
+			info.Error = task.Info.Error.LocalizedMessage
+		}
+
+		report.Tasks = append(report.Tasks, info)
+	}
+
+	// tasks without a next run, disabled or expired ones, come last
+	sort.Slice(report.Tasks, func(i, j int) bool {
+		a, b := report.Tasks[i], report.Tasks[j]
+		if (a.NextRun == nil) != (b.NextRun == nil) {
+			return a.NextRun != nil
+		}
+		if a.NextRun != nil && !a.NextRun.Equal(*b.NextRun) {
+			return a.NextRun.Before(*b.NextRun)
+		}
+		return a.Name < b.Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nScheduled tasks: %d\n", len(report.Tasks))
+	for _, t := range report.Tasks {
+		state := "enabled"
+		if !t.Enabled {
+			state = "disabled"
+		}
+		fmt.Printf("\n  %s (%s)\n", t.Name, state)
+		fmt.Printf("    Entity: %s (%s)\n", t.Entity, t.EntityType)
+		fmt.Printf("    Action: %s\n", valueOrNone(strings.TrimSuffix(t.Action, "_Task")))
+		fmt.Printf("    Schedule: %s\n", t.Schedule)
+		next := "none"
+		if t.NextRun != nil {
+			next = t.NextRun.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("    Next run: %s\n", next)
+		if t.PrevRun != nil {
+			fmt.Printf("    Last run: %s (%s)\n", t.PrevRun.Local().Format("2006-01-02 15:04"), t.State)
+		}
+		if t.Error != "" {
+			fmt.Printf("    WARNING: last run failed: %s\n", t.Error)
+		}
+	}
+
+	return nil
+}
+
+// scheduledTaskScope returns the names of the inventory objects in scope by reference, the
+// datacenter with everything in it, or the selected clusters with their hosts, resource
+// pools and VMs with -cluster or -exclude
+func scheduledTaskScope(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) (map[string]string, error) {
+	names := make(map[string]string)
+	var roots []types.ManagedObjectReference
+	if len(cfg.Clusters) == 0 && len(cfg.Exclude) == 0 {
+		names[dc.Reference().Value] = dc.Name()
+		roots = append(roots, dc.Reference())
+	} else {
+		clusters, err := listClusters(ctx, finder, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("getting clusters: %s", err)
+		}
+		for _, cluster := range clusters {
+			names[cluster.Reference().Value] = cluster.Name()
+			roots = append(roots, cluster.Reference())
+		}
+	}
+
+	m := view.NewManager(client.Client)
+	for _, root := range roots {
+		v, err := m.CreateContainerView(ctx, root, []string{"ManagedEntity"}, true)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving inventory: %s", err)
+		}
+		var entities []mo.ManagedEntity
+		err = v.Retrieve(ctx, []string{"ManagedEntity"}, []string{"name"}, &entities)
+		v.Destroy(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving inventory: %s", err)
+		}
+		for _, e := range entities {
+			names[e.Self.Value] = e.Name
+		}
+	}
+
+	return names, nil
+}
+
+// scheduleDescription describes when a scheduled task runs
+func scheduleDescription(scheduler types.BaseTaskScheduler) string {
+	at := func(hour, minute int32) string {
+		return fmt.Sprintf("%02d:%02d", hour, minute)
+	}
+	every := func(interval int32, unit string) string {
+		if interval > 1 {
+			return fmt.Sprintf("every %d %ss", interval, unit)
+		}
+		return "every " + unit
+	}
+
+	switch s := scheduler.(type) {
+	case *types.OnceTaskScheduler:
+		if s.RunAt == nil {
+			return "once, now"
+		}
+		return "once at " + s.RunAt.Local().Format("2006-01-02 15:04")
+	case *types.AfterStartupTaskScheduler:
+		return fmt.Sprintf("%d minutes after vCenter startup", s.Minute)
+	case *types.HourlyTaskScheduler:
+		return fmt.Sprintf("%s at minute %d", every(s.Interval, "hour"), s.Minute)
+	case *types.DailyTaskScheduler:
+		return fmt.Sprintf("%s at %s", every(s.Interval, "day"), at(s.Hour, s.Minute))
+	case *types.WeeklyTaskScheduler:
+		var days []string
+		for _, d := range []struct {
+			set  bool
+			name string
+		}{
+			{s.Monday, "Monday"}, {s.Tuesday, "Tuesday"}, {s.Wednesday, "Wednesday"}, {s.Thursday, "Thursday"},
+			{s.Friday, "Friday"}, {s.Saturday, "Saturday"}, {s.Sunday, "Sunday"},
+		} {
+			if d.set {
+				days = append(days, d.name)
+			}
+		}
+		return fmt.Sprintf("%s on %s at %s", every(s.Interval, "week"), strings.Join(days, ", "), at(s.Hour, s.Minute))
+	case *types.MonthlyByDayTaskScheduler:
+		return fmt.Sprintf("%s on day %d at %s", every(s.Interval, "month"), s.Day, at(s.Hour, s.Minute))
+	case *types.MonthlyByWeekdayTaskScheduler:
+		return fmt.Sprintf("%s on the %s %s at %s", every(s.Interval, "month"), s.Offset, s.Weekday, at(s.Hour, s.Minute))
+	}
+	return "unknown"
+}
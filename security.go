@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// service keys of the host remote access services
+const (
+	serviceSSH       = "TSM-SSH"
+	serviceESXiShell = "TSM"
+)
+
+type HostServiceState struct {
+	Running bool `json:"running"`
+	// Policy is the startup policy: on, off or automatic
+	Policy string `json:"policy"`
+}
+
+type HostSecurityInfo struct {
+	Name         string           `json:"name"`
+	Cluster      string           `json:"cluster"`
+	LockdownMode string           `json:"lockdown_mode"`
+	SSH          HostServiceState `json:"ssh"`
+	ESXiShell    HostServiceState `json:"esxi_shell"`
+	// account lockout after failed logins, empty when the host doesn't report it
+	AccountLockFailures string   `json:"account_lock_failures"`
+	AccountUnlockTime   string   `json:"account_unlock_time"`
+	Warnings            []string `json:"warnings"`
+}
+
+type HostSecurityReport struct {
+	Datacenter string             `json:"datacenter"`
+	Hosts      []HostSecurityInfo `json:"hosts"`
+}
+
+// reportHostSecurity audits the lockdown mode, the SSH and ESXi Shell services and the
+// account lockout settings of every host for hardening reviews
+func reportHostSecurity(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := HostSecurityReport{
+		Datacenter: dc.Name(),
+		Hosts:      make([]HostSecurityInfo, 0),
+	}
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+		if len(clusterMo.Host) == 0 {
+			continue
+		}
+
+		var hosts []mo.HostSystem
+		err = pc.Retrieve(ctx, clusterMo.Host, []string{"name", "configManager", "config.lockdownMode", "config.service"}, &hosts)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+
+		for _, host := range hosts {
+			info := HostSecurityInfo{
+				Name:     host.Name,
+				Cluster:  cluster.Name(),
+				Warnings: make([]string, 0),
+			}
+
+			if host.Config != nil {
+				info.LockdownMode = string(host.Config.LockdownMode)
+				if host.Config.Service != nil {
+					for _, service := range host.Config.Service.Service {
+						switch service.Key {
+						case serviceSSH:
+							info.SSH = HostServiceState{Running: service.Running, Policy: service.Policy}
+						case serviceESXiShell:
+							info.ESXiShell = HostServiceState{Running: service.Running, Policy: service.Policy}
+						}
+					}
+				}
+			}
+
+			if ref := host.ConfigManager.AdvancedOption; ref != nil {
+				om := object.NewOptionManager(client.Client, *ref)
+				info.AccountLockFailures = queryOptionString(ctx, om, "Security.AccountLockFailures")
+				info.AccountUnlockTime = queryOptionString(ctx, om, "Security.AccountUnlockTime")
+			}
+
+			if info.LockdownMode == "" || info.LockdownMode == string(types.HostLockdownModeLockdownDisabled) {
+				info.Warnings = append(info.Warnings, "lockdown mode is disabled")
+			}
+			if info.SSH.Running {
+				info.Warnings = append(info.Warnings, "SSH is running")
+			}
+			if info.ESXiShell.Running {
+				info.Warnings = append(info.Warnings, "ESXi Shell is running")
+			}
+			if info.SSH.Policy == "on" || info.ESXiShell.Policy == "on" {
+				info.Warnings = append(info.Warnings, "remote access starts with the host")
+			}
+			if info.AccountLockFailures == "0" {
+				info.Warnings = append(info.Warnings, "accounts are never locked after failed logins")
+			}
+
+			report.Hosts = append(report.Hosts, info)
+		}
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	cluster := ""
+	for _, info := range report.Hosts {
+		if info.Cluster != cluster {
+			cluster = info.Cluster
+			fmt.Printf("\nCluster: %s\n", cluster)
+			fmt.Println(strings.Repeat("-", len(cluster)+9))
+		}
+
+		fmt.Printf("  Host: %s\n", info.Name)
+		fmt.Printf("    Lockdown mode: %s\n", valueOrNone(info.LockdownMode))
+		fmt.Printf("    SSH: %s\n", serviceState(info.SSH))
+		fmt.Printf("    ESXi Shell: %s\n", serviceState(info.ESXiShell))
+		if info.AccountLockFailures != "" {
+			fmt.Printf("    Account lockout: after %s failures, unlock after %s seconds\n", info.AccountLockFailures, valueOrNone(info.AccountUnlockTime))
+		} else {
+			fmt.Println("    Account lockout: not reported")
+		}
+		for _, warning := range info.Warnings {
+			fmt.Printf("    WARNING: %s\n", warning)
+		}
+	}
+
+	return nil
+}
+
+// serviceState formats the state and startup policy of a host service
+func serviceState(s HostServiceState) string {
+	state := "stopped"
+	if s.Running {
+		state = "running"
+	}
+	return fmt.Sprintf("%s (policy: %s)", state, valueOrNone(s.Policy))
+}
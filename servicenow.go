@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ServiceNowConfig holds the ServiceNow instance and the CMDB tables the sync command writes to
+type ServiceNowConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	// Password can also be set with the SERVICENOW_PASSWORD env var
+	Password string `json:"password,omitempty"`
+	// Tables per inventory kind (cluster, host, datastore), missing kinds use the defaults
+	Tables map[string]ServiceNowTable `json:"tables,omitempty"`
+}
+
+// ServiceNowTable maps the attributes of an inventory kind to the fields of a CMDB table
+type ServiceNowTable struct {
+	Table string `json:"table"`
+	// Fields maps inventory attributes to table fields
+	Fields map[string]string `json:"fields"`
+	// Key is the attribute that identifies existing records, default: name. Records are also
+	// matched on the field of the datacenter attribute when it is mapped.
+	Key string `json:"key,omitempty"`
+}
+
+var defaultServiceNowTables = map[string]ServiceNowTable{
+	kindCluster: {
+		Table:  "cmdb_ci_vcenter_cluster",
+		Fields: map[string]string{"qualified_name": "name"},
+		Key:    "qualified_name",
+	},
+	kindHost: {
+		Table:  "cmdb_ci_esx_server",
+		Fields: map[string]string{"name": "name"},
+	},
+	kindDatastore: {
+		Table: "cmdb_ci_vcenter_datastore",
+		Fields: map[string]string{
+			"qualified_name": "name",
+			"type":           "type",
+			"capacity_gb":    "capacity",
+			"free_space_gb":  "free_space",
+			"accessible":     "accessible",
+		},
+		Key: "qualified_name",
+	},
+}
+
+// table returns the configured table of an inventory kind or its default
+func (c ServiceNowConfig) table(kind string) ServiceNowTable {
+	t, ok := c.Tables[kind]
+	if !ok {
+		t = defaultServiceNowTables[kind]
+	}
+	if t.Key == "" {
+		t.Key = "name"
+	}
+	return t
+}
+
+// syncServiceNow inserts or updates a CMDB record for every inventory record. Existing
+// records are found by the field the key attribute is mapped to, within the datacenter when
+// the datacenter attribute is mapped too.
+func syncServiceNow(ctx context.Context, cfg *Config, records map[string][]inventoryRecord) error {
+	sn := cfg.ServiceNow
+	if sn.URL == "" || sn.Username == "" || sn.Password == "" {
+		return fmt.Errorf("servicenow url, username and password must be set in the -config file")
+	}
+
+	c := &serviceNowClient{config: sn, http: http.DefaultClient}
+
+	var inserted, updated int
+	for _, kind := range inventoryKinds {
+		table := sn.table(kind)
+		keyField, ok := table.Fields[table.Key]
+		if !ok {
+			return fmt.Errorf("servicenow table %s: key attribute %s is not mapped to a field", table.Table, table.Key)
+		}
+
+		for _, record := range records[kind] {
+			fields := make(map[string]interface{}, len(table.Fields))
+			for attr, field := range table.Fields {
+				if value, ok := record[attr]; ok {
+					fields[field] = value
+				}
+			}
+			key := fmt.Sprint(record[table.Key])
+			query := [][2]string{{keyField, key}}
+			if field, ok := table.Fields["datacenter"]; ok && table.Key != "datacenter" {
+				query = append(query, [2]string{field, fmt.Sprint(record["datacenter"])})
+			}
+
+			sysID, err := c.find(ctx, table.Table, query)
+			if err != nil {
+				return fmt.Errorf("looking up %s %s: %s", kind, key, err)
+			}
+
+			action := "insert"
+			if sysID != "" {
+				action = "update"
+			}
+			if !cfg.OutputJSON {
+				fmt.Printf("  %s %s %s in %s\n", action, kind, key, table.Table)
+			}
+			if cfg.DryRun {
+				continue
+			}
+
+			if sysID == "" {
+				err = c.insert(ctx, table.Table, fields)
+				inserted++
+			} else {
+				err = c.update(ctx, table.Table, sysID, fields)
+				updated++
+			}
+			if err != nil {
+				return fmt.Errorf("%s of %s %s: %s", action, kind, key, err)
+			}
+		}
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(map[string]int{"inserted": inserted, "updated": updated})
+	}
+
+	if cfg.DryRun {
+		fmt.Println("\nDry run, nothing was written to ServiceNow")
+	} else {
+		fmt.Printf("\nServiceNow: %d records inserted, %d updated\n", inserted, updated)
+	}
+
+	return nil
+}
+
+// serviceNowClient talks to the ServiceNow Table API
+type serviceNowClient struct {
+	config ServiceNowConfig
+	http   *http.Client
+}
+
+// find returns the sys_id of the first record whose fields are set to the values of the
+// field, value pairs, empty if there is none
+func (c *serviceNowClient) find(ctx context.Context, table string, query [][2]string) (string, error) {
+	conditions := make([]string, 0, len(query))
+	for _, cond := range query {
+		// ^ separates the conditions of an encoded query and can't be escaped in a value
+		if strings.Contains(cond[1], "^") {
+			return "", fmt.Errorf("%s %q contains ^, which can't be matched in a ServiceNow query", cond[0], cond[1])
+		}
+		conditions = append(conditions, cond[0]+"="+cond[1])
+	}
+
+	q := url.Values{}
+	q.Set("sysparm_query", strings.Join(conditions, "^"))
+	q.Set("sysparm_fields", "sys_id")
+	q.Set("sysparm_limit", "1")
+
+	var res struct {
+		Result []struct {
+			SysID string `json:"sys_id"`
+		} `json:"result"`
+	}
+	if err := c.do(ctx, http.MethodGet, c.tableURL(table)+"?"+q.Encode(), nil, &res); err != nil {
+		return "", err
+	}
+
+	if len(res.Result) == 0 {
+		return "", nil
+	}
+	return res.Result[0].SysID, nil
+}
+
+func (c *serviceNowClient) insert(ctx context.Context, table string, fields map[string]interface{}) error {
+	return c.do(ctx, http.MethodPost, c.tableURL(table), fields, nil)
+}
+
+func (c *serviceNowClient) update(ctx context.Context, table, sysID string, fields map[string]interface{}) error {
+	return c.do(ctx, http.MethodPatch, c.tableURL(table)+"/"+url.PathEscape(sysID), fields, nil)
+}
+
+func (c *serviceNowClient) tableURL(table string) string {
+	return strings.TrimSuffix(c.config.URL, "/") + "/api/now/table/" + url.PathEscape(table)
+}
+
+func (c *serviceNowClient) do(ctx context.Context, method, u string, body, result interface{}) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, r)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.config.Username, c.config.Password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s %s: %s %s", method, req.URL.Path, res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	if result != nil {
+		return json.NewDecoder(res.Body).Decode(result)
+	}
+	return nil
+}
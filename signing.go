@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// signing tools of the signing section
+const (
+	signGPG      = "gpg"
+	signMinisign = "minisign"
+)
+
+// SigningConfig makes uploaded reports tamper-evident with a SHA-256 checksum and a detached
+// signature stored next to every report
+type SigningConfig struct {
+	// Checksum adds <report>.sha256 in sha256sum format
+	Checksum bool `json:"checksum,omitempty"`
+	// Tool is gpg or minisign, empty for no signature
+	Tool string `json:"tool,omitempty"`
+	// Key is the gpg key ID or the minisign secret key file, gpg defaults to its default key
+	Key string `json:"key,omitempty"`
+	// PasswordEnv names the environment variable holding the key passphrase
+	PasswordEnv string `json:"password_env,omitempty"`
+}
+
+// sidecar is a file stored next to a report
+type sidecar struct {
+	extension string
+	data      []byte
+}
+
+func (c SigningConfig) validate() error {
+	switch c.Tool {
+	case "", signGPG:
+	case signMinisign:
+		if c.Key == "" {
+			return fmt.Errorf("minisign needs the secret key file as key")
+		}
+	default:
+		return fmt.Errorf("unsupported tool %s, use gpg or minisign", c.Tool)
+	}
+	return nil
+}
+
+// sidecars returns the checksum and signature files of the report called name
+func (c SigningConfig) sidecars(name string, data []byte) ([]sidecar, error) {
+	var files []sidecar
+	if c.Checksum {
+		files = append(files, sidecar{".sha256", []byte(sha256Hex(data) + "  " + name + "\n")})
+	}
+	if c.Tool != "" {
+		sig, err := c.sign(name, data)
+		if err != nil {
+			return nil, fmt.Errorf("signing the report with %s: %s", c.Tool, err)
+		}
+		files = append(files, sig)
+	}
+	return files, nil
+}
+
+// sign creates a detached signature with the signing tool, which works on files
+func (c SigningConfig) sign(name string, data []byte) (sidecar, error) {
+	dir, err := os.MkdirTemp("", "godcinfo-sign")
+	if err != nil {
+		return sidecar{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	report := filepath.Join(dir, name)
+	if err := os.WriteFile(report, data, 0600); err != nil {
+		return sidecar{}, err
+	}
+
+	var cmd *exec.Cmd
+	var extension string
+	switch c.Tool {
+	case signGPG:
+		extension = ".asc"
+		args := []string{"--batch", "--yes", "--armor", "--detach-sign", "--output", report + extension}
+		if c.Key != "" {
+			args = append(args, "--local-user", c.Key)
+		}
+		if c.PasswordEnv != "" {
+			args = append(args, "--pinentry-mode", "loopback", "--passphrase-fd", "0")
+		}
+		cmd = exec.Command("gpg", append(args, report)...)
+	case signMinisign:
+		extension = ".minisig"
+		cmd = exec.Command("minisign", "-S", "-s", c.Key, "-m", report, "-x", report+extension, "-t", name)
+	default:
+		return sidecar{}, fmt.Errorf("unsupported tool %s", c.Tool)
+	}
+	if c.PasswordEnv != "" {
+		cmd.Stdin = strings.NewReader(os.Getenv(c.PasswordEnv) + "\n")
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return sidecar{}, fmt.Errorf("%s: %s", err, msg)
+		}
+		return sidecar{}, err
+	}
+
+	sig, err := os.ReadFile(report + extension)
+	if err != nil {
+		return sidecar{}, err
+	}
+	return sidecar{extension, sig}, nil
+}
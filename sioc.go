@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Storage I/O Control defaults of vSphere
+const (
+	siocDefaultMode = string(types.StorageIORMThresholdModeAutomatic)
+	// siocDefaultThreshold is the manual congestion threshold in ms
+	siocDefaultThreshold = 30
+	// siocDefaultPeakPct is the automatic congestion threshold in percent of the peak throughput
+	siocDefaultPeakPct = 90
+)
+
+type DatastoreSIOCInfo struct {
+	Name string `json:"name"`
+	// Supported is false for datastores without Storage I/O Control, like vSAN and local ones
+	Supported bool `json:"supported"`
+	Enabled   bool `json:"enabled"`
+	// Mode is automatic (percent of peak throughput) or manual (latency in ms)
+	Mode            string `json:"mode,omitempty"`
+	ThresholdMs     int32  `json:"threshold_ms,omitempty"`
+	PeakThroughput  int32  `json:"percent_of_peak_throughput,omitempty"`
+	StatsCollection bool   `json:"stats_collection"`
+	// NonDefaults lists the congestion threshold settings changed from the vSphere defaults
+	NonDefaults []string `json:"non_defaults"`
+}
+
+type SIOCReport struct {
+	Datacenter string              `json:"datacenter"`
+	Datastores []DatastoreSIOCInfo `json:"datastores"`
+}
+
+// reportSIOC shows the Storage I/O Control congestion threshold of every datastore, either
+// manual in ms or automatic in percent of the peak throughput, and flags the datastores
+// where the threshold was changed from the defaults
+func reportSIOC(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	var datastores []mo.Datastore
+	err := retrieveAll(ctx, client, dc, "Datastore", []string{"name", "iormConfiguration"}, &datastores)
+	if err != nil {
+		return fmt.Errorf("retrieving datastores: %s", err)
+	}
+	datastores = withoutExcludedDatastores(cfg, datastores)
+	sort.Slice(datastores, func(i, j int) bool {
+		return datastores[i].Name < datastores[j].Name
+	})
+
+	report := SIOCReport{
+		Datacenter: dc.Name(),
+		Datastores: make([]DatastoreSIOCInfo, 0, len(datastores)),
+	}
+
+	for _, ds := range datastores {
+		info := DatastoreSIOCInfo{
+			Name:        ds.Name,
+			NonDefaults: make([]string, 0),
+		}
+		if iorm := ds.IormConfiguration; iorm != nil {
+			info.Supported = true
+			info.Enabled = iorm.Enabled
+			info.Mode = iorm.CongestionThresholdMode
+			info.ThresholdMs = iorm.CongestionThreshold
+			info.PeakThroughput = iorm.PercentOfPeakThroughput
+			info.StatsCollection = iorm.StatsCollectionEnabled != nil && *iorm.StatsCollectionEnabled
+			info.NonDefaults = siocNonDefaults(iorm)
+		}
+		report.Datastores = append(report.Datastores, info)
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	var changed int
+	for _, ds := range report.Datastores {
+		fmt.Printf("\nDatastore: %s\n", ds.Name)
+		fmt.Println(strings.Repeat("-", len(ds.Name)+11))
+		if !ds.Supported {
+			fmt.Println("  Storage I/O Control: not supported")
+			continue
+		}
+		state := "disabled"
+		if ds.Enabled {
+			state = "enabled"
+		}
+		fmt.Printf("  Storage I/O Control: %s\n", state)
+		switch ds.Mode {
+		case string(types.StorageIORMThresholdModeManual):
+			fmt.Printf("  Congestion threshold: manual, %d ms\n", ds.ThresholdMs)
+		default:
+			fmt.Printf("  Congestion threshold: %s, %d%% of peak throughput\n", valueOrNone(ds.Mode), ds.PeakThroughput)
+		}
+		fmt.Printf("  Statistics collection: %t\n", ds.StatsCollection)
+		for _, setting := range ds.NonDefaults {
+			fmt.Printf("  WARNING: %s\n", setting)
+		}
+		if len(ds.NonDefaults) > 0 {
+			changed++
+		}
+	}
+
+	fmt.Printf("\nDatastores with non-default congestion thresholds: %d\n", changed)
+
+	return nil
+}
+
+// siocNonDefaults describes the congestion threshold settings of a datastore that differ
+// from the defaults, the threshold of the mode not in use is ignored
+func siocNonDefaults(iorm *types.StorageIORMInfo) []string {
+	settings := make([]string, 0)
+	if iorm.CongestionThresholdMode != "" && iorm.CongestionThresholdMode != siocDefaultMode {
+		settings = append(settings, fmt.Sprintf("congestion threshold mode is %s instead of %s", iorm.CongestionThresholdMode, siocDefaultMode))
+	}
+
+	switch iorm.CongestionThresholdMode {
+	case string(types.StorageIORMThresholdModeManual):
+		if iorm.CongestionThreshold != siocDefaultThreshold {
+			settings = append(settings, fmt.Sprintf("congestion threshold is %d ms instead of %d ms", iorm.CongestionThreshold, siocDefaultThreshold))
+		}
+	default:
+		if iorm.PercentOfPeakThroughput != 0 && iorm.PercentOfPeakThroughput != siocDefaultPeakPct {
+			settings = append(settings, fmt.Sprintf("congestion threshold is %d%% of peak throughput instead of %d%%", iorm.PercentOfPeakThroughput, siocDefaultPeakPct))
+		}
+	}
+
+	return settings
+}
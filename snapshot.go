@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// offlineFunc runs a command against a saved datastores report instead of vCenter
+type offlineFunc func(infra InfrastructureInfo, cfg *Config) error
+
+// offlineCommands are the commands that can run -from-file
+var offlineCommands = map[string]offlineFunc{
+	"datastores": reportSnapshotDatastores,
+	"lint":       lintSnapshot,
+	"check":      checkSnapshot,
+}
+
+// runFromFile runs a command against the JSON datastores report in cfg.FromFile
+func runFromFile(command string, cfg *Config) error {
+	run, ok := offlineCommands[command]
+	if !ok {
+		return fmt.Errorf("the %s command needs vCenter and can't run -from-file", command)
+	}
+	if len(cfg.DatastoreTags) > 0 {
+		return fmt.Errorf("tags are not part of the snapshot, -datastore-tag needs vCenter")
+	}
+
+	infras, err := loadSnapshots(cfg.FromFile)
+	if err != nil {
+		return fmt.Errorf("loading %s: %s", cfg.FromFile, err)
+	}
+	// -datacenter selects from the reports of several datacenters
+	if len(cfg.Datacenters) > 0 && len(infras) > 1 {
+		if infras, err = snapshotDatacenters(infras, cfg.Datacenters); err != nil {
+			return err
+		}
+	}
+
+	if len(infras) == 1 {
+		if cfg.Output == outputText && command != "check" {
+			fmt.Printf("Using snapshot of datacenter: %s\n", infras[0].Datacenter)
+		}
+		return run(infras[0], cfg)
+	}
+	if command == "check" {
+		return fmt.Errorf("the check supports a single datacenter, %s holds %d, select one with -datacenter", cfg.FromFile, len(infras))
+	}
+
+	// like a scan of several datacenters, the JSON reports are collected into one document
+	report := MultiDatacenterReport{Datacenters: make([]DatacenterReport, 0, len(infras))}
+	var failed []string
+	for i, infra := range infras {
+		if cfg.Output == outputText {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("Using snapshot of datacenter: %s\n", infra.Datacenter)
+		}
+
+		var capture *stdoutCapture
+		if cfg.OutputJSON {
+			if capture, err = captureStdout(false); err != nil {
+				return err
+			}
+		}
+		err := run(infra, cfg)
+
+		dcReport := DatacenterReport{Datacenter: infra.Datacenter}
+		if err != nil {
+			dcReport.Error = err.Error()
+			failed = append(failed, fmt.Sprintf("%s: %s", infra.Datacenter, err))
+		}
+		if capture != nil {
+			if output := bytes.TrimSpace(capture.stop()); len(output) > 0 && json.Valid(output) {
+				dcReport.Report = json.RawMessage(output)
+			}
+		}
+		report.Datacenters = append(report.Datacenters, dcReport)
+	}
+
+	if cfg.OutputJSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// loadSnapshots reads a datastores report saved with -o json, - reads it from stdin. A report
+// of several datacenters ({"datacenters":[{"report":...}]}) returns the report of every
+// datacenter that was scanned without an error.
+func loadSnapshots(path string) ([]InfrastructureInfo, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var multi MultiDatacenterReport
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return nil, err
+	}
+	if multi.Datacenters == nil {
+		infra, err := parseSnapshot(data)
+		if err != nil {
+			return nil, err
+		}
+		return []InfrastructureInfo{infra}, nil
+	}
+
+	infras := make([]InfrastructureInfo, 0, len(multi.Datacenters))
+	for _, dc := range multi.Datacenters {
+		if len(dc.Report) == 0 {
+			continue
+		}
+		infra, err := parseSnapshot(dc.Report)
+		if err != nil {
+			return nil, fmt.Errorf("datacenter %s: %s", dc.Datacenter, err)
+		}
+		infras = append(infras, infra)
+	}
+	if len(infras) == 0 {
+		return nil, fmt.Errorf("no datacenter of the report was scanned without errors")
+	}
+	return infras, nil
+}
+
+// parseSnapshot parses the datastores report of one datacenter
+func parseSnapshot(data []byte) (InfrastructureInfo, error) {
+	var infra InfrastructureInfo
+	if err := json.Unmarshal(data, &infra); err != nil {
+		return infra, err
+	}
+	if infra.Datacenter == "" && len(infra.Clusters) == 0 {
+		return infra, fmt.Errorf("not a datastores report")
+	}
+	return infra, nil
+}
+
+// snapshotDatacenters returns the saved reports of the datacenters selected with -datacenter,
+// by name, glob or re: regular expression
+func snapshotDatacenters(infras []InfrastructureInfo, selectors []string) ([]InfrastructureInfo, error) {
+	selected := make([]InfrastructureInfo, 0, len(infras))
+	for _, infra := range infras {
+		for _, selector := range selectors {
+			ok, err := matchDatacenter(selector, infra.Datacenter)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				selected = append(selected, infra)
+				break
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no datacenter of the report matches -datacenter %s", strings.Join(selectors, ", "))
+	}
+	return selected, nil
+}
+
+// snapshotDatastore applies the current thresholds and filters to a saved datastore. Tags are
+// not part of the snapshot, with tag based threshold overrides the saved status is kept.
+func snapshotDatastore(info DatastoreInfo, cfg *Config) (DatastoreInfo, bool) {
+	if !cfg.Thresholds.usesTags() {
+		info.Status = cfg.Thresholds.status(info.Name, nil, info.UsedPct)
+	}
+	if (cfg.ExcludeLocal && !info.Shared) || cfg.excluded(info.Name) {
+		return info, false
+	}
+	return info, includeDatastore(cfg, info)
+}
+
+// snapshotDatastores returns every datastore of the clusters selected with -cluster once
+func snapshotDatastores(infra InfrastructureInfo, cfg *Config) []DatastoreInfo {
+	seen := make(map[string]bool)
+	var all []DatastoreInfo
+	add := func(infos []DatastoreInfo) {
+		for _, info := range infos {
+			if !seen[info.Name] {
+				seen[info.Name] = true
+				all = append(all, info)
+			}
+		}
+	}
+
+	for _, cluster := range infra.Clusters {
+		if !cfg.clusterSelected(cluster.Name) {
+			continue
+		}
+		for _, pod := range cluster.DatastoreClusters {
+			if cfg.datastoreClusterSelected(pod.Name) {
+				add(pod.Datastores)
+			}
+		}
+		if len(cfg.DatastoreClusters) == 0 {
+			add(cluster.StandaloneDatastores)
+		}
+	}
+	return all
+}
+
+// reportSnapshotDatastores renders a saved datastores report with the current
+// thresholds and filters. The totals are the ones of the scan.
+func reportSnapshotDatastores(infra InfrastructureInfo, cfg *Config) error {
+	if cfg.GroupBy != "" || cfg.Output == outputDot || cfg.Output == outputMermaid {
+		return fmt.Errorf("-group-by, -o dot and -o mermaid need vCenter and can't run -from-file")
+	}
+
+	filter := func(infos []DatastoreInfo) []DatastoreInfo {
+		shown := make([]DatastoreInfo, 0, len(infos))
+		for _, info := range infos {
+			if info, ok := snapshotDatastore(info, cfg); ok {
+				shown = append(shown, info)
+			}
+		}
+		return shown
+	}
+
+	clusters := make([]ClusterInfo, 0, len(infra.Clusters))
+	for _, cluster := range infra.Clusters {
+		if !cfg.clusterSelected(cluster.Name) {
+			continue
+		}
+		pods := make([]DatastoreClusterInfo, 0, len(cluster.DatastoreClusters))
+		for _, pod := range cluster.DatastoreClusters {
+			if !cfg.datastoreClusterSelected(pod.Name) {
+				continue
+			}
+			pod.Datastores = filter(pod.Datastores)
+			if len(pod.Datastores) > 0 {
+				pods = append(pods, pod)
+			}
+		}
+		cluster.DatastoreClusters = pods
+		cluster.StandaloneDatastores = filter(cluster.StandaloneDatastores)
+		if len(cfg.DatastoreClusters) > 0 {
+			cluster.StandaloneDatastores = make([]DatastoreInfo, 0)
+		}
+		clusters = append(clusters, cluster)
+	}
+	infra.Clusters = clusters
+
+	if err := printDatastoresReport(infra, cfg); err != nil {
+		return err
+	}
+
+	if cfg.FailOnInaccessible && len(infra.InaccessibleDatastores) > 0 {
+		return fmt.Errorf("%d inaccessible datastores", len(infra.InaccessibleDatastores))
+	}
+
+	return nil
+}
+
+// printDatastoresReport prints a collected datastores report in the -o format
+func printDatastoresReport(infra InfrastructureInfo, cfg *Config) error {
+	switch cfg.Output {
+	case outputJSON:
+		if err := printJSON(infra); err != nil {
+			return err
+		}
+	case outputTree:
+		printTree(infra)
+	case outputNDJSON:
+		for _, cluster := range infra.Clusters {
+			for _, pod := range cluster.DatastoreClusters {
+				for _, ds := range pod.Datastores {
+					if err := printNDJSON(DatastoreRecord{Datacenter: infra.Datacenter, Cluster: cluster.Name, DatastoreCluster: pod.Name, DatastoreInfo: ds}); err != nil {
+						return err
+					}
+				}
+			}
+			for _, ds := range cluster.StandaloneDatastores {
+				if err := printNDJSON(DatastoreRecord{Datacenter: infra.Datacenter, Cluster: cluster.Name, DatastoreInfo: ds}); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		for _, cluster := range infra.Clusters {
+			fmt.Printf("\nCluster: %s\n", cluster.Name)
+			fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+			for _, pod := range cluster.DatastoreClusters {
+				fmt.Printf("  Datastore Cluster: %s (Capacity: %s, Free: %s, Used: %s)\n",
+					pod.Name, formatGB(pod.TotalCapacity), formatGB(pod.TotalFreeSpace), formatPct(pod.UsedPct))
+				for _, ds := range pod.Datastores {
+					printDatastore(ds)
+				}
+			}
+			if len(cfg.DatastoreClusters) == 0 {
+				fmt.Println("  Standalone Datastores:")
+				if len(cluster.StandaloneDatastores) == 0 {
+					fmt.Println("    No datastores matching the filters")
+				}
+				for _, ds := range cluster.StandaloneDatastores {
+					printDatastore(ds)
+				}
+			}
+			if cluster.Supervisor != nil {
+				printSupervisor(cluster.Supervisor)
+			}
+			if cluster.Compute != nil {
+				printComputeCapacity(cluster.Compute)
+			}
+			printTotals("  Cluster total", cluster.Totals)
+		}
+		printDatacenterTotals(infra)
+	}
+
+	return nil
+}
+
+// lintSnapshot checks the cluster, datastore cluster and datastore names of a saved report
+func lintSnapshot(infra InfrastructureInfo, cfg *Config) error {
+	checks, err := namingChecks(cfg.Naming)
+	if err != nil {
+		return err
+	}
+
+	names := make(map[string][]string)
+	pods := make(map[string]bool)
+	for _, cluster := range infra.Clusters {
+		names["cluster"] = append(names["cluster"], cluster.Name)
+		for _, pod := range cluster.DatastoreClusters {
+			if !pods[pod.Name] {
+				pods[pod.Name] = true
+				names["datastore_cluster"] = append(names["datastore_cluster"], pod.Name)
+			}
+		}
+	}
+	for _, ds := range snapshotDatastores(infra, cfg) {
+		names["datastore"] = append(names["datastore"], ds.Name)
+	}
+
+	return lintNames(infra.Datacenter, checks, names, cfg)
+}
+
+// checkSnapshot runs the Nagios check against the datastores of a saved report
+func checkSnapshot(infra InfrastructureInfo, cfg *Config) error {
+	applyCheckThresholds(cfg)
+
+	var infos []DatastoreInfo
+	for _, info := range snapshotDatastores(infra, cfg) {
+		if info, ok := snapshotDatastore(info, cfg); ok {
+			infos = append(infos, info)
+		}
+	}
+
+	return checkDatastores(infra.Datacenter, infos, nil, cfg)
+}
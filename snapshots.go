@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/event"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// snapshotCreatorWindow is how far the create snapshot task may be from the creation time of
+// a snapshot to be taken as the task that created it
+const snapshotCreatorWindow = 10 * time.Minute
+
+type SnapshotInfo struct {
+	VM          string    `json:"vm"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Created     time.Time `json:"created"`
+	AgeDays     int       `json:"age_days"`
+	// Size is the space of the snapshot's delta disks and memory file, for the current
+	// snapshot it includes the delta disks the VM writes to
+	Size float64 `json:"size_gb"`
+	// Creator is the user of the create snapshot task, empty when its event is older than the
+	// event retention of vCenter
+	Creator string `json:"creator,omitempty"`
+	Current bool   `json:"current"`
+}
+
+type SnapshotsReport struct {
+	Datacenter string         `json:"datacenter"`
+	MinAgeDays int            `json:"min_age_days"`
+	Snapshots  []SnapshotInfo `json:"snapshots"`
+	// Size sums up the space of the listed snapshots
+	Size float64 `json:"size_gb"`
+}
+
+// reportSnapshots lists the VM snapshots older than -snapshot-age-days with their size,
+// description and the user who created them according to the create snapshot task events,
+// largest first
+func reportSnapshots(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	var vms []mo.VirtualMachine
+	err := retrieveAll(ctx, client, dc, "VirtualMachine", []string{"name", "config.template", "snapshot", "layoutEx"}, &vms)
+	if err != nil {
+		return fmt.Errorf("retrieving VMs: %s", err)
+	}
+
+	report := SnapshotsReport{
+		Datacenter: dc.Name(),
+		MinAgeDays: cfg.SnapshotAgeDays,
+		Snapshots:  make([]SnapshotInfo, 0),
+	}
+
+	events := event.NewManager(client.Client)
+	now := time.Now()
+	for _, vm := range vms {
+		if vm.Snapshot == nil || (vm.Config != nil && vm.Config.Template) {
+			continue
+		}
+
+		var snapshots []SnapshotInfo
+		var walk func(tree []types.VirtualMachineSnapshotTree, parent *types.ManagedObjectReference)
+		walk = func(tree []types.VirtualMachineSnapshotTree, parent *types.ManagedObjectReference) {
+			for _, s := range tree {
+				current := vm.Snapshot.CurrentSnapshot != nil && vm.Snapshot.CurrentSnapshot.Value == s.Snapshot.Value
+				info := SnapshotInfo{
+					VM:          vm.Name,
+					Name:        s.Name,
+					Description: s.Description,
+					Created:     s.CreateTime,
+					AgeDays:     int(now.Sub(s.CreateTime).Hours() / 24),
+					Current:     current,
+				}
+				if vm.LayoutEx != nil {
+					info.Size = bytesToGB(int64(object.SnapshotSize(s.Snapshot, parent, vm.LayoutEx, current)))
+				}
+				if info.AgeDays >= cfg.SnapshotAgeDays {
+					snapshots = append(snapshots, info)
+				}
+
+				ref := s.Snapshot
+				walk(s.ChildSnapshotList, &ref)
+			}
+		}
+		walk(vm.Snapshot.RootSnapshotList, nil)
+		if len(snapshots) == 0 {
+			continue
+		}
+
+		creators, err := snapshotCreators(ctx, events, vm.Self)
+		if err != nil {
+			return fmt.Errorf("querying events of VM %s: %s", vm.Name, err)
+		}
+		for _, s := range snapshots {
+			s.Creator = snapshotCreator(creators, s.Created)
+			report.Snapshots = append(report.Snapshots, s)
+			report.Size += s.Size
+		}
+	}
+	sort.Slice(report.Snapshots, func(i, j int) bool {
+		a, b := report.Snapshots[i], report.Snapshots[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Created.Before(b.Created)
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nSnapshots older than %d days: %d (%s)\n", report.MinAgeDays, len(report.Snapshots), formatGB(report.Size))
+	for _, s := range report.Snapshots {
+		name := s.Name
+		if s.Current {
+			name += " (current)"
+		}
+		fmt.Printf("\n  %s: %s\n", s.VM, name)
+		fmt.Printf("    Size: %s\n", formatGB(s.Size))
+		creator := s.Creator
+		if creator == "" {
+			creator = "unknown user"
+		}
+		fmt.Printf("    Created: %s (%d days ago) by %s\n", s.Created.Local().Format("2006-01-02 15:04"), s.AgeDays, creator)
+		if s.Description != "" {
+			fmt.Printf("    Description: %s\n", s.Description)
+		}
+	}
+
+	return nil
+}
+
+// snapshotCreators returns the create snapshot task events of a VM
+func snapshotCreators(ctx context.Context, events *event.Manager, vm types.ManagedObjectReference) ([]*types.TaskEvent, error) {
+	filter := types.EventFilterSpec{
+		Entity: &types.EventFilterSpecByEntity{
+			Entity:    vm,
+			Recursion: types.EventFilterSpecRecursionOptionSelf,
+		},
+		EventTypeId: []string{"TaskEvent"},
+	}
+	found, err := events.QueryEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var tasks []*types.TaskEvent
+	for _, e := range found {
+		if task, ok := e.(*types.TaskEvent); ok && task.Info.DescriptionId == "VirtualMachine.createSnapshot" {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+// snapshotCreator returns the user of the create snapshot task closest to the creation time
+// of a snapshot
+func snapshotCreator(tasks []*types.TaskEvent, created time.Time) string {
+	var creator string
+	closest := snapshotCreatorWindow
+	for _, task := range tasks {
+		diff := created.Sub(task.CreatedTime)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= closest {
+			closest = diff
+			creator = task.UserName
+			switch reason := task.Info.Reason.(type) {
+			case *types.TaskReasonUser:
+				creator = reason.UserName
+			case *types.TaskReasonSchedule:
+				creator = "scheduled task " + reason.Name
+			}
+		}
+	}
+	return creator
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// serializations of the snapshots and change events published to NATS and Kafka
+const (
+	formatJSON = "json"
+	formatAvro = "avro"
+)
+
+// validateFormat checks the format setting of a NATS or Kafka section
+func validateFormat(format string) error {
+	switch format {
+	case "", formatJSON, formatAvro:
+		return nil
+	}
+	return fmt.Errorf("unsupported format %s, use json or avro", format)
+}
+
+// kinds of change and changed objects of a change event
+const (
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeChanged = "changed"
+
+	objectCluster          = "cluster"
+	objectDatastoreCluster = "datastore_cluster"
+	objectDatastore        = "datastore"
+)
+
+// ChangeEvent is a difference between two scans of a datacenter, published next to the snapshot
+type ChangeEvent struct {
+	Datacenter string    `json:"datacenter"`
+	DetectedAt time.Time `json:"detected_at"`
+	// Change is added, removed or changed
+	Change string `json:"change"`
+	// ObjectType is cluster, datastore_cluster or datastore
+	ObjectType string `json:"object_type"`
+	Object     string `json:"object"`
+	// Cluster is set for datastore clusters, whose names are only unique within a cluster here
+	Cluster string `json:"cluster,omitempty"`
+	// Field, Old and New are set for changes of a datastore
+	Field string `json:"field,omitempty"`
+	Old   string `json:"old,omitempty"`
+	New   string `json:"new,omitempty"`
+}
+
+// inventoryChanges compares the normalized inventories of two scans. Datastores are compared
+// once per datacenter, the used percentage only through their threshold status.
+func inventoryChanges(prev, cur hashedInventory, at time.Time) []ChangeEvent {
+	var changes []ChangeEvent
+	event := func(change, objectType, object string) ChangeEvent {
+		return ChangeEvent{Datacenter: cur.Datacenter, DetectedAt: at, Change: change, ObjectType: objectType, Object: object}
+	}
+
+	prevClusters := make(map[string]hashedCluster, len(prev.Clusters))
+	for _, cluster := range prev.Clusters {
+		prevClusters[cluster.Name] = cluster
+	}
+	curClusters := make(map[string]bool, len(cur.Clusters))
+	for _, cluster := range cur.Clusters {
+		curClusters[cluster.Name] = true
+		old, ok := prevClusters[cluster.Name]
+		if !ok {
+			changes = append(changes, event(changeAdded, objectCluster, cluster.Name))
+		}
+
+		oldPods := make(map[string]bool, len(old.DatastoreClusters))
+		for _, pod := range old.DatastoreClusters {
+			oldPods[pod.Name] = true
+		}
+		curPods := make(map[string]bool, len(cluster.DatastoreClusters))
+		for _, pod := range cluster.DatastoreClusters {
+			curPods[pod.Name] = true
+			if ok && !oldPods[pod.Name] {
+				e := event(changeAdded, objectDatastoreCluster, pod.Name)
+				e.Cluster = cluster.Name
+				changes = append(changes, e)
+			}
+		}
+		for _, pod := range old.DatastoreClusters {
+			if !curPods[pod.Name] {
+				e := event(changeRemoved, objectDatastoreCluster, pod.Name)
+				e.Cluster = cluster.Name
+				changes = append(changes, e)
+			}
+		}
+	}
+	for _, cluster := range prev.Clusters {
+		if !curClusters[cluster.Name] {
+			changes = append(changes, event(changeRemoved, objectCluster, cluster.Name))
+		}
+	}
+
+	prevDatastores := inventoryDatastores(prev)
+	curDatastores := inventoryDatastores(cur)
+	for _, ds := range sortedDatastoreNames(curDatastores) {
+		now := curDatastores[ds]
+		old, ok := prevDatastores[ds]
+		if !ok {
+			changes = append(changes, event(changeAdded, objectDatastore, ds))
+			continue
+		}
+		for _, field := range []struct {
+			name     string
+			old, new string
+		}{
+			{"type", old.Type, now.Type},
+			{"capacity_gb", strconv.FormatInt(old.CapacityGB, 10), strconv.FormatInt(now.CapacityGB, 10)},
+			{"status", old.Status, now.Status},
+			{"accessible", strconv.FormatBool(old.Accessible), strconv.FormatBool(now.Accessible)},
+			{"shared", strconv.FormatBool(old.Shared), strconv.FormatBool(now.Shared)},
+			{"mounted_host_count", strconv.Itoa(old.MountedHostCount), strconv.Itoa(now.MountedHostCount)},
+		} {
+			if field.old != field.new {
+				e := event(changeChanged, objectDatastore, ds)
+				e.Field, e.Old, e.New = field.name, field.old, field.new
+				changes = append(changes, e)
+			}
+		}
+	}
+	for _, ds := range sortedDatastoreNames(prevDatastores) {
+		if _, ok := curDatastores[ds]; !ok {
+			changes = append(changes, event(changeRemoved, objectDatastore, ds))
+		}
+	}
+
+	return changes
+}
+
+// inventoryDatastores returns the datastores of all clusters by name, shared ones once
+func inventoryDatastores(inventory hashedInventory) map[string]hashedDatastore {
+	datastores := make(map[string]hashedDatastore)
+	for _, cluster := range inventory.Clusters {
+		for _, pod := range cluster.DatastoreClusters {
+			for _, ds := range pod.Datastores {
+				datastores[ds.Name] = ds
+			}
+		}
+		for _, ds := range cluster.StandaloneDatastores {
+			datastores[ds.Name] = ds
+		}
+	}
+	return datastores
+}
+
+func sortedDatastoreNames(datastores map[string]hashedDatastore) []string {
+	names := make([]string, 0, len(datastores))
+	for name := range datastores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inventoriesFile holds the normalized inventory of the last published scan per vCenter and
+// datacenter, the change events are the difference to it
+func inventoriesFile() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "godcinfo", "inventories.json"), nil
+}
+
+func loadInventories() (map[string]hashedInventory, error) {
+	path, err := inventoriesFile()
+	if err != nil {
+		return nil, err
+	}
+	inventories := make(map[string]hashedInventory)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return inventories, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &inventories); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	return inventories, nil
+}
+
+func saveInventories(inventories map[string]hashedInventory) error {
+	path, err := inventoriesFile()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(inventories, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// publishes reports whether the config file sets a NATS or Kafka sink for the datastores report
+func (cfg *Config) publishes() bool {
+	return cfg.NATS.URL != "" || len(cfg.Kafka.Brokers) > 0
+}
+
+// publishSnapshot publishes the report and its changes since the last published scan of the
+// same vCenter and datacenter to the NATS and Kafka sinks of the config file. The first scan
+// of a datacenter only publishes the snapshot.
+func publishSnapshot(cfg *Config, endpoint, datacenter string, infra InfrastructureInfo) error {
+	inventories, err := loadInventories()
+	if err != nil {
+		return fmt.Errorf("reading the last published inventory: %s", err)
+	}
+	key := endpoint + "/" + datacenter
+	current := normalizeInventory(infra)
+	var changes []ChangeEvent
+	if last, ok := inventories[key]; ok {
+		changes = inventoryChanges(last, current, time.Now().UTC())
+	}
+
+	if cfg.NATS.URL != "" {
+		if err := cfg.NATS.publish(infra, changes); err != nil {
+			return fmt.Errorf("publishing to NATS: %s", err)
+		}
+	}
+	if len(cfg.Kafka.Brokers) > 0 {
+		if err := cfg.Kafka.publish(infra, changes); err != nil {
+			return fmt.Errorf("publishing to Kafka: %s", err)
+		}
+	}
+
+	// kept once published, so the changes of a failed run are published with the next one
+	inventories[key] = current
+	if err := saveInventories(inventories); err != nil {
+		return fmt.Errorf("saving the published inventory: %s", err)
+	}
+	return nil
+}
+
+// encodeSnapshot serializes the report as JSON, the same document as -o json, or as Avro
+func encodeSnapshot(format string, infra InfrastructureInfo) ([]byte, error) {
+	if format == formatAvro {
+		return avroSnapshot(infra), nil
+	}
+	return json.Marshal(infra)
+}
+
+// encodeChange serializes a change event as JSON or Avro
+func encodeChange(format string, change ChangeEvent) ([]byte, error) {
+	if format == formatAvro {
+		return avroChange(change), nil
+	}
+	return json.Marshal(change)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInventoryChanges(t *testing.T) {
+	at := time.Unix(0, 0)
+	ds := func(name, status string) hashedDatastore {
+		return hashedDatastore{Name: name, Type: "VMFS", CapacityGB: 100, Status: status, Accessible: true}
+	}
+	prev := hashedInventory{
+		Datacenter: "DC0",
+		Clusters: []hashedCluster{
+			{
+				Name:                 "cluster-a",
+				DatastoreClusters:    []hashedDatastoreCluster{{Name: "pod-1", Datastores: []hashedDatastore{ds("ds-1", statusOK)}}},
+				StandaloneDatastores: []hashedDatastore{ds("ds-2", statusOK), ds("shared", statusOK)},
+			},
+			{Name: "cluster-b", StandaloneDatastores: []hashedDatastore{ds("shared", statusOK)}},
+		},
+	}
+	cur := hashedInventory{
+		Datacenter: "DC0",
+		Clusters: []hashedCluster{
+			{
+				Name:                 "cluster-a",
+				DatastoreClusters:    []hashedDatastoreCluster{{Name: "pod-2", Datastores: []hashedDatastore{ds("ds-1", statusWarning)}}},
+				StandaloneDatastores: []hashedDatastore{ds("shared", statusOK)},
+			},
+			{Name: "cluster-c", StandaloneDatastores: []hashedDatastore{ds("shared", statusOK), ds("ds-3", statusOK)}},
+		},
+	}
+
+	event := func(change, objectType, object string) ChangeEvent {
+		return ChangeEvent{Datacenter: "DC0", DetectedAt: at, Change: change, ObjectType: objectType, Object: object}
+	}
+	podAdded := event(changeAdded, objectDatastoreCluster, "pod-2")
+	podAdded.Cluster = "cluster-a"
+	podRemoved := event(changeRemoved, objectDatastoreCluster, "pod-1")
+	podRemoved.Cluster = "cluster-a"
+	statusChanged := event(changeChanged, objectDatastore, "ds-1")
+	statusChanged.Field, statusChanged.Old, statusChanged.New = "status", statusOK, statusWarning
+	want := []ChangeEvent{
+		podAdded,
+		podRemoved,
+		event(changeAdded, objectCluster, "cluster-c"),
+		event(changeRemoved, objectCluster, "cluster-b"),
+		statusChanged,
+		event(changeAdded, objectDatastore, "ds-3"),
+		event(changeRemoved, objectDatastore, "ds-2"),
+	}
+
+	if got := inventoryChanges(prev, cur, at); !reflect.DeepEqual(got, want) {
+		t.Errorf("inventoryChanges =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := inventoryChanges(cur, cur, at); len(got) != 0 {
+		t.Errorf("inventoryChanges of the same inventory = %+v, want none", got)
+	}
+}
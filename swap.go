@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+type HostSwapInfo struct {
+	Name          string   `json:"name"`
+	SwapDatastore string   `json:"swap_datastore"`
+	FreePct       *float64 `json:"free_pct,omitempty"`
+	Warning       string   `json:"warning,omitempty"`
+}
+
+type ClusterSwapInfo struct {
+	Name      string         `json:"name"`
+	Placement string         `json:"swap_placement"`
+	Hosts     []HostSwapInfo `json:"hosts"`
+}
+
+type SwapReport struct {
+	Datacenter string            `json:"datacenter"`
+	MinFreePct float64           `json:"min_free_pct"`
+	Clusters   []ClusterSwapInfo `json:"clusters"`
+}
+
+// reportSwapPlacement audits the VM swapfile placement policy of each cluster and the
+// swap datastore of each host, flagging hosts that swap onto nearly full datastores
+func reportSwapPlacement(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := SwapReport{
+		Datacenter: dc.Name(),
+		MinFreePct: cfg.SwapMinFreePct,
+		Clusters:   make([]ClusterSwapInfo, 0, len(clusters)),
+	}
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"name", "configurationEx", "host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting details of cluster %s: %s", cluster.Name(), err)
+		}
+
+		info := ClusterSwapInfo{
+			Name:  clusterMo.Name,
+			Hosts: make([]HostSwapInfo, 0, len(clusterMo.Host)),
+		}
+		if config := clusterMo.ConfigurationEx; config != nil {
+			info.Placement = config.GetComputeResourceConfigInfo().VmSwapPlacement
+		}
+
+		if len(clusterMo.Host) == 0 {
+			report.Clusters = append(report.Clusters, info)
+			continue
+		}
+
+		var hosts []mo.HostSystem
+		err = pc.Retrieve(ctx, clusterMo.Host, []string{"name", "config.localSwapDatastore"}, &hosts)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", clusterMo.Name, err)
+		}
+
+		// Look up the capacity of all designated swap datastores at once
+		var dsRefs []types.ManagedObjectReference
+		seen := make(map[string]bool)
+		for _, host := range hosts {
+			if ref := hostSwapDatastore(host); ref != nil && !seen[ref.Value] {
+				seen[ref.Value] = true
+				dsRefs = append(dsRefs, *ref)
+			}
+		}
+
+		swapDatastores := make(map[string]mo.Datastore)
+		if len(dsRefs) > 0 {
+			var datastores []mo.Datastore
+			err = pc.Retrieve(ctx, dsRefs, []string{"name", "summary"}, &datastores)
+			if err != nil {
+				return fmt.Errorf("getting swap datastores of cluster %s: %s", clusterMo.Name, err)
+			}
+			for _, ds := range datastores {
+				swapDatastores[ds.Self.Value] = ds
+			}
+		}
+
+		hostLocal := info.Placement == string(types.VirtualMachineConfigInfoSwapPlacementTypeHostLocal)
+
+		for _, host := range hosts {
+			hostInfo := HostSwapInfo{Name: host.Name}
+
+			ref := hostSwapDatastore(host)
+			if ref == nil {
+				if hostLocal {
+					hostInfo.Warning = "no swap datastore designated, VMs swap to their own directory"
+				}
+				info.Hosts = append(info.Hosts, hostInfo)
+				continue
+			}
+
+			ds, ok := swapDatastores[ref.Value]
+			if !ok {
+				hostInfo.SwapDatastore = ref.Value
+				info.Hosts = append(info.Hosts, hostInfo)
+				continue
+			}
+
+			hostInfo.SwapDatastore = ds.Name
+			if ds.Summary.Capacity > 0 {
+				freePct := float64(ds.Summary.FreeSpace) / float64(ds.Summary.Capacity) * 100
+				hostInfo.FreePct = &freePct
+				if hostLocal && freePct < cfg.SwapMinFreePct {
+					hostInfo.Warning = fmt.Sprintf("swap datastore has only %.1f%% free", freePct)
+				}
+			}
+
+			info.Hosts = append(info.Hosts, hostInfo)
+		}
+
+		report.Clusters = append(report.Clusters, info)
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, info := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", info.Name)
+		fmt.Println(strings.Repeat("-", len(info.Name)+9))
+		fmt.Printf("  Swapfile placement: %s\n", info.Placement)
+
+		for _, host := range info.Hosts {
+			swap := host.SwapDatastore
+			if swap == "" {
+				swap = "(none)"
+			}
+			if host.FreePct != nil {
+				fmt.Printf("    - %s: %s (%s free)\n", host.Name, swap, formatPct(*host.FreePct))
+			} else {
+				fmt.Printf("    - %s: %s\n", host.Name, swap)
+			}
+			if host.Warning != "" {
+				fmt.Printf("      WARNING: %s\n", host.Warning)
+			}
+		}
+	}
+
+	return nil
+}
+
+// hostSwapDatastore returns the swap datastore designated on a host, if any
+func hostSwapDatastore(host mo.HostSystem) *types.ManagedObjectReference {
+	if host.Config == nil {
+		return nil
+	}
+	return host.Config.LocalSwapDatastore
+}
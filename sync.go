@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+)
+
+// inventory kinds pushed by the sync command
+const (
+	kindCluster   = "cluster"
+	kindHost      = "host"
+	kindDatastore = "datastore"
+)
+
+var inventoryKinds = []string{kindCluster, kindHost, kindDatastore}
+
+// inventoryRecord holds the attributes of a cluster, host or datastore that can be
+// mapped to fields of an external inventory
+type inventoryRecord map[string]interface{}
+
+// syncTarget pushes the inventory records of every kind to an external system
+type syncTarget func(ctx context.Context, cfg *Config, records map[string][]inventoryRecord) error
+
+var syncTargets = map[string]syncTarget{
+	"servicenow": syncServiceNow,
+	"device42":   syncDevice42,
+}
+
+// syncInventory pushes the clusters, hosts and datastores of the datacenter to the
+// external inventory given as argument, e.g. "sync servicenow"
+func syncInventory(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	if len(cfg.Args) != 1 {
+		return fmt.Errorf("sync needs a target, one of %s", syncTargetNames())
+	}
+	target, ok := syncTargets[cfg.Args[0]]
+	if !ok {
+		return fmt.Errorf("unknown sync target %s, use one of %s", cfg.Args[0], syncTargetNames())
+	}
+
+	topo, err := collectTopology(ctx, client, dc, cfg)
+	if err != nil {
+		return err
+	}
+
+	return target(ctx, cfg, inventoryRecords(topo))
+}
+
+func syncTargetNames() string {
+	names := make([]string, 0, len(syncTargets))
+	for name := range syncTargets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// inventoryRecords turns the topology into records per inventory kind
+func inventoryRecords(t topology) map[string][]inventoryRecord {
+	records := make(map[string][]inventoryRecord)
+
+	hostCluster := make(map[string]string)
+	for _, c := range t.Clusters {
+		for _, host := range c.Hosts {
+			hostCluster[host] = c.Name
+		}
+		records[kindCluster] = append(records[kindCluster], inventoryRecord{
+			"name":           c.Name,
+			"qualified_name": qualifiedName(t.Datacenter, c.Name),
+			"datacenter":     t.Datacenter,
+			"host_count":     len(c.Hosts),
+		})
+	}
+
+	hosts := make([]string, 0, len(t.Hosts))
+	for ref := range t.Hosts {
+		hosts = append(hosts, ref)
+	}
+	sort.Strings(hosts)
+	for _, ref := range hosts {
+		records[kindHost] = append(records[kindHost], inventoryRecord{
+			"name":            t.Hosts[ref],
+			"qualified_name":  qualifiedName(t.Datacenter, t.Hosts[ref]),
+			"datacenter":      t.Datacenter,
+			"cluster":         hostCluster[ref],
+			"datastore_count": len(t.HostDatastores[ref]),
+		})
+	}
+
+	datastores := make([]string, 0, len(t.Datastores))
+	for ref := range t.Datastores {
+		datastores = append(datastores, ref)
+	}
+	sort.Strings(datastores)
+	for _, ref := range datastores {
+		ds := t.Datastores[ref]
+		records[kindDatastore] = append(records[kindDatastore], inventoryRecord{
+			"name":               ds.Name,
+			"qualified_name":     qualifiedName(t.Datacenter, ds.Name),
+			"datacenter":         t.Datacenter,
+			"type":               ds.Type,
+			"capacity_gb":        ds.Capacity,
+			"free_space_gb":      ds.FreeSpace,
+			"used_pct":           ds.UsedPct,
+			"shared":             ds.Shared,
+			"mounted_host_count": ds.MountedHostCount,
+			"accessible":         ds.Accessible,
+		})
+	}
+
+	return records
+}
+
+// qualifiedName prefixes a name with its datacenter, cluster and datastore names are only
+// unique within a datacenter
+func qualifiedName(datacenter, name string) string {
+	return datacenter + "/" + name
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SyslogConfig sets the syslog server that threshold breaches and scan errors are sent to
+type SyslogConfig struct {
+	// Address of the server as host:port, alerts are only sent when it is set
+	Address string `json:"address"`
+	// Network is udp (default), tcp or tls
+	Network  string `json:"network,omitempty"`
+	Facility string `json:"facility,omitempty"`
+	AppName  string `json:"app_name,omitempty"`
+	// CAFile verifies the server certificate for tls instead of the system roots
+	CAFile   string `json:"ca_file,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// syslog severities used for alerts
+const (
+	severityCritical = 2
+	severityError    = 3
+	severityWarning  = 4
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "daemon": 3, "auth": 4, "syslog": 5,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSDID is the structured data ID of alert details, 32473 is the example enterprise number
+const syslogSDID = "godcinfo@32473"
+
+// alert is a threshold breach or error reported to syslog
+type alert struct {
+	Severity int
+	// MsgID categorizes the alert, e.g. threshold, inaccessible or scan_error
+	MsgID   string
+	Message string
+	Data    map[string]string
+}
+
+// datastoreAlerts returns an alert for every inaccessible datastore and every datastore above its thresholds
+func datastoreAlerts(dc string, datastores []DatastoreInfo) []alert {
+	var alerts []alert
+	for _, ds := range datastores {
+		data := map[string]string{
+			"datacenter": dc,
+			"datastore":  ds.Name,
+			"used_pct":   fmt.Sprintf("%.1f", ds.UsedPct),
+		}
+
+		if !ds.Accessible {
+			data["reason"] = ds.InaccessibleReason
+			alerts = append(alerts, alert{
+				Severity: severityCritical,
+				MsgID:    "inaccessible",
+				Message:  fmt.Sprintf("datastore %s is inaccessible: %s", ds.Name, valueOrNone(ds.InaccessibleReason)),
+				Data:     data,
+			})
+			continue
+		}
+
+		severity := severityWarning
+		switch ds.Status {
+		case statusCritical:
+			severity = severityCritical
+		case statusWarning:
+		default:
+			continue
+		}
+		data["status"] = ds.Status
+		alerts = append(alerts, alert{
+			Severity: severity,
+			MsgID:    "threshold",
+			Message:  fmt.Sprintf("datastore %s is %.1f%% used (%s)", ds.Name, ds.UsedPct, ds.Status),
+			Data:     data,
+		})
+	}
+	return alerts
+}
+
+// scanErrorAlert turns an error of a command into an alert
+func scanErrorAlert(command string, err error) alert {
+	return alert{
+		Severity: severityError,
+		MsgID:    "scan_error",
+		Message:  fmt.Sprintf("%s failed: %s", command, err),
+		Data:     map[string]string{"command": command},
+	}
+}
+
+// sendAlerts sends the alerts to the configured syslog server, it does nothing when
+// no server is configured. Failing to send is reported but doesn't fail the command.
+func sendAlerts(cfg *Config, alerts []alert) {
+	if cfg.Syslog.Address == "" || len(alerts) == 0 {
+		return
+	}
+
+	if err := cfg.Syslog.send(alerts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error sending alerts to syslog: %s\n", err)
+	}
+}
+
+// send writes the alerts as RFC 5424 messages. TCP and TLS use octet counting framing (RFC 6587, RFC 5425).
+func (c SyslogConfig) send(alerts []alert) error {
+	facility := syslogFacilities["user"]
+	if c.Facility != "" {
+		f, ok := syslogFacilities[c.Facility]
+		if !ok {
+			return fmt.Errorf("unknown facility %s", c.Facility)
+		}
+		facility = f
+	}
+
+	network := c.Network
+	if network == "" {
+		network = "udp"
+	}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	switch network {
+	case "udp", "tcp":
+		conn, err = dialer.Dial(network, c.Address)
+	case "tls":
+		var tlsConfig *tls.Config
+		tlsConfig, err = c.tlsConfig()
+		if err == nil {
+			conn, err = tls.DialWithDialer(dialer, "tcp", c.Address, tlsConfig)
+		}
+	default:
+		return fmt.Errorf("unknown network %s, use udp, tcp or tls", network)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, a := range alerts {
+		msg := c.format(facility, a)
+		if network != "udp" {
+			msg = fmt.Sprintf("%d %s", len(msg), msg)
+		}
+		if _, err := conn.Write([]byte(msg)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c SyslogConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.Insecure}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// format returns an alert as RFC 5424 message with its data as structured data
+func (c SyslogConfig) format(facility int, a alert) string {
+	appName := c.AppName
+	if appName == "" {
+		appName = "godcinfo"
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	sd := "-"
+	if len(a.Data) > 0 {
+		keys := make([]string, 0, len(a.Data))
+		for k := range a.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+		var b strings.Builder
+		b.WriteString("[" + syslogSDID)
+		for _, k := range keys {
+			fmt.Fprintf(&b, ` %s="%s"`, k, escape.Replace(a.Data[k]))
+		}
+		b.WriteString("]")
+		sd = b.String()
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		facility*8+a.Severity, time.Now().UTC().Format(time.RFC3339Nano), hostname, appName, os.Getpid(), a.MsgID, sd, a.Message)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/namespace"
+	"github.com/vmware/govmomi/vapi/rest"
+)
+
+// namespacesPath is the vSphere Namespaces REST endpoint, govmomi only covers the
+// namespace management of the supervisor clusters
+const namespacesPath = "/api/vcenter/namespaces/instances"
+
+type NamespaceStorageQuotaInfo struct {
+	Policy string `json:"policy"`
+	// Limit is 0 when the namespace may use the policy without limit
+	Limit float64 `json:"limit_gb"`
+}
+
+type SupervisorNamespaceInfo struct {
+	Name         string                      `json:"name"`
+	ConfigStatus string                      `json:"config_status"`
+	StorageUsed  float64                     `json:"storage_used_gb"`
+	Quotas       []NamespaceStorageQuotaInfo `json:"storage_quotas"`
+	// UsedPct is the storage used of the summed up limits, 0 without limits
+	UsedPct float64 `json:"used_pct"`
+	Status  string  `json:"status"`
+}
+
+type SupervisorInfo struct {
+	KubernetesStatus string                    `json:"kubernetes_status"`
+	ConfigStatus     string                    `json:"config_status"`
+	Namespaces       []SupervisorNamespaceInfo `json:"namespaces"`
+}
+
+type SupervisorClusterInfo struct {
+	Cluster string `json:"cluster"`
+	SupervisorInfo
+}
+
+type TanzuReport struct {
+	Datacenter  string                  `json:"datacenter"`
+	Supervisors []SupervisorClusterInfo `json:"supervisors"`
+}
+
+// namespaceSummary and namespaceInfo are the parts of the vSphere Namespaces API responses
+// that are reported
+type namespaceSummary struct {
+	Namespace    string `json:"namespace"`
+	Cluster      string `json:"cluster"`
+	ConfigStatus string `json:"config_status"`
+	Stats        struct {
+		StorageUsed int64 `json:"storage_used"`
+	} `json:"stats"`
+}
+
+type namespaceInfo struct {
+	StorageSpecs []struct {
+		Policy string `json:"policy"`
+		Limit  int64  `json:"limit"`
+	} `json:"storage_specs"`
+}
+
+// reportTanzu shows the supervisor clusters of the datacenter with their namespaces, the
+// storage policy quotas of the namespaces and the storage they use
+func reportTanzu(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	supervisors, err := collectSupervisors(ctx, client, cfg)
+	if err != nil {
+		return err
+	}
+
+	report := TanzuReport{
+		Datacenter:  dc.Name(),
+		Supervisors: make([]SupervisorClusterInfo, 0),
+	}
+	for _, cluster := range clusters {
+		if sv, ok := supervisors[cluster.Reference().Value]; ok {
+			report.Supervisors = append(report.Supervisors, SupervisorClusterInfo{Cluster: cluster.Name(), SupervisorInfo: *sv})
+		}
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	if len(report.Supervisors) == 0 {
+		fmt.Println("No supervisor clusters found in the selected datacenter.")
+		return nil
+	}
+	for _, sv := range report.Supervisors {
+		fmt.Printf("\nCluster: %s\n", sv.Cluster)
+		fmt.Println(strings.Repeat("-", len(sv.Cluster)+9))
+		printSupervisor(&sv.SupervisorInfo)
+	}
+
+	return nil
+}
+
+// collectSupervisors returns the supervisor clusters of vCenter by cluster reference, with
+// the storage quotas and usage of their namespaces
+func collectSupervisors(ctx context.Context, client *govmomi.Client, cfg *Config) (map[string]*SupervisorInfo, error) {
+	rc, err := connectToREST(ctx, client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the vSphere REST API: %s", err)
+	}
+	defer rc.Logout(ctx)
+
+	enabled, err := namespace.NewManager(rc).ListClusters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing supervisor clusters: %s", err)
+	}
+
+	supervisors := make(map[string]*SupervisorInfo, len(enabled))
+	if len(enabled) == 0 {
+		return supervisors, nil
+	}
+	for _, cluster := range enabled {
+		sv := &SupervisorInfo{Namespaces: make([]SupervisorNamespaceInfo, 0)}
+		if cluster.KubernetesStatus != nil {
+			sv.KubernetesStatus = cluster.KubernetesStatus.String()
+		}
+		if cluster.ConfigStatus != nil {
+			sv.ConfigStatus = cluster.ConfigStatus.String()
+		}
+		supervisors[cluster.ID] = sv
+	}
+
+	var summaries []namespaceSummary
+	err = rc.Do(ctx, rc.Resource(namespacesPath).Request(http.MethodGet), &summaries)
+	if err != nil {
+		return nil, fmt.Errorf("listing namespaces: %s", err)
+	}
+
+	policyNames := make(map[string]string)
+	if pc, err := connectToPBM(ctx, client, cfg); err == nil {
+		if names, err := storagePolicyNames(ctx, pc); err == nil {
+			policyNames = names
+		}
+	}
+
+	for _, summary := range summaries {
+		sv, ok := supervisors[summary.Cluster]
+		if !ok {
+			continue
+		}
+
+		info, err := namespaceDetails(ctx, rc, summary.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("getting namespace %s: %s", summary.Namespace, err)
+		}
+
+		ns := SupervisorNamespaceInfo{
+			Name:         cfg.pseudonym("namespace", summary.Namespace),
+			ConfigStatus: summary.ConfigStatus,
+			StorageUsed:  float64(summary.Stats.StorageUsed) / 1024,
+			Quotas:       make([]NamespaceStorageQuotaInfo, 0, len(info.StorageSpecs)),
+		}
+		var limit float64
+		for _, spec := range info.StorageSpecs {
+			policy := policyNames[spec.Policy]
+			if policy == "" {
+				policy = spec.Policy
+			}
+			quota := NamespaceStorageQuotaInfo{Policy: cfg.pseudonym("storage-policy", policy), Limit: float64(spec.Limit) / 1024}
+			ns.Quotas = append(ns.Quotas, quota)
+			limit += quota.Limit
+		}
+		// the namespace is only bounded when every policy has a limit
+		for _, quota := range ns.Quotas {
+			if quota.Limit == 0 {
+				limit = 0
+				break
+			}
+		}
+		if limit > 0 {
+			ns.UsedPct = usedPct(limit, limit-ns.StorageUsed)
+		}
+		ns.Status = cfg.Thresholds.status("", nil, ns.UsedPct)
+
+		sv.Namespaces = append(sv.Namespaces, ns)
+	}
+
+	for _, sv := range supervisors {
+		sort.Slice(sv.Namespaces, func(i, j int) bool {
+			return sv.Namespaces[i].Name < sv.Namespaces[j].Name
+		})
+	}
+
+	return supervisors, nil
+}
+
+// namespaceDetails returns the storage specs of a namespace
+func namespaceDetails(ctx context.Context, rc *rest.Client, name string) (namespaceInfo, error) {
+	var info namespaceInfo
+	err := rc.Do(ctx, rc.Resource(namespacesPath+"/"+name).Request(http.MethodGet), &info)
+	return info, err
+}
+
+// printSupervisor prints the state and the namespaces of a supervisor cluster
+func printSupervisor(sv *SupervisorInfo) {
+	fmt.Printf("  Supervisor: kubernetes %s, config %s\n", valueOrNone(sv.KubernetesStatus), valueOrNone(sv.ConfigStatus))
+	if len(sv.Namespaces) == 0 {
+		fmt.Println("    No namespaces")
+	}
+	for _, ns := range sv.Namespaces {
+		fmt.Printf("    Namespace: %s (Storage used: %s", ns.Name, formatGB(ns.StorageUsed))
+		if ns.UsedPct > 0 {
+			fmt.Printf(", %s of quota", formatPct(ns.UsedPct))
+		}
+		fmt.Println(")")
+		for _, quota := range ns.Quotas {
+			limit := "no limit"
+			if quota.Limit > 0 {
+				limit = formatGB(quota.Limit)
+			}
+			fmt.Printf("      Storage policy %s: %s\n", quota.Policy, limit)
+		}
+		if ns.Status != statusOK {
+			fmt.Printf("      %s: namespace storage quota %s used\n", strings.ToUpper(ns.Status), formatPct(ns.UsedPct))
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+type TemplateInfo struct {
+	Name       string   `json:"name"`
+	Datastores []string `json:"datastores"`
+	// Committed is the datastore space the template uses, Provisioned includes the space thin
+	// disks can grow into
+	Committed   float64    `json:"committed_gb"`
+	Provisioned float64    `json:"provisioned_gb"`
+	GuestOS     string     `json:"guest_os"`
+	Modified    *time.Time `json:"last_modified,omitempty"`
+	DaysOld     *int       `json:"days_since_modified,omitempty"`
+}
+
+type TemplatesReport struct {
+	Datacenter string         `json:"datacenter"`
+	Templates  []TemplateInfo `json:"templates"`
+	// Committed sums up the space of all templates
+	Committed float64 `json:"committed_gb"`
+}
+
+// reportTemplates lists the VM templates of the datacenter with their datastores, size, guest
+// OS and last modification, largest first, so big templates nobody updated for a long time
+// can be cleaned up
+func reportTemplates(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	var vms []mo.VirtualMachine
+	err := retrieveAll(ctx, client, dc, "VirtualMachine", []string{"name", "config.template", "config.guestFullName", "config.modified", "summary.storage", "datastore"}, &vms)
+	if err != nil {
+		return fmt.Errorf("retrieving VMs: %s", err)
+	}
+
+	var datastores []mo.Datastore
+	err = retrieveAll(ctx, client, dc, "Datastore", []string{"name"}, &datastores)
+	if err != nil {
+		return fmt.Errorf("retrieving datastores: %s", err)
+	}
+	datastoreNames := make(map[string]string, len(datastores))
+	for _, ds := range datastores {
+		datastoreNames[ds.Self.Value] = ds.Name
+	}
+
+	report := TemplatesReport{
+		Datacenter: dc.Name(),
+		Templates:  make([]TemplateInfo, 0),
+	}
+
+	now := time.Now()
+	for _, vm := range vms {
+		if vm.Config == nil || !vm.Config.Template {
+			continue
+		}
+
+		info := TemplateInfo{
+			Name:       vm.Name,
+			Datastores: make([]string, 0, len(vm.Datastore)),
+			GuestOS:    vm.Config.GuestFullName,
+		}
+		if !vm.Config.Modified.IsZero() {
+			modified := vm.Config.Modified
+			days := int(now.Sub(modified).Hours() / 24)
+			info.Modified = &modified
+			info.DaysOld = &days
+		}
+		if vm.Summary.Storage != nil {
+			info.Committed = bytesToGB(vm.Summary.Storage.Committed)
+			info.Provisioned = bytesToGB(vm.Summary.Storage.Committed + vm.Summary.Storage.Uncommitted)
+		}
+		for _, ref := range vm.Datastore {
+			name := datastoreNames[ref.Value]
+			if name == "" {
+				name = ref.Value
+			}
+			info.Datastores = append(info.Datastores, name)
+		}
+		sort.Strings(info.Datastores)
+
+		report.Templates = append(report.Templates, info)
+		report.Committed += info.Committed
+	}
+	sort.Slice(report.Templates, func(i, j int) bool {
+		a, b := report.Templates[i], report.Templates[j]
+		if a.Committed != b.Committed {
+			return a.Committed > b.Committed
+		}
+		return a.Name < b.Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nTemplates: %d (%s committed)\n", len(report.Templates), formatGB(report.Committed))
+	for _, t := range report.Templates {
+		fmt.Printf("\n  %s\n", t.Name)
+		fmt.Printf("    Datastores: %s\n", valueOrNone(strings.Join(t.Datastores, ", ")))
+		fmt.Printf("    Size: %s committed, %s provisioned\n", formatGB(t.Committed), formatGB(t.Provisioned))
+		fmt.Printf("    Guest OS: %s\n", valueOrNone(t.GuestOS))
+		modified := "unknown"
+		if t.Modified != nil {
+			modified = fmt.Sprintf("%s (%d days ago)", t.Modified.Local().Format("2006-01-02"), *t.DaysOld)
+		}
+		fmt.Printf("    Last modified: %s\n", modified)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+const (
+	statusOK       = "ok"
+	statusWarning  = "warning"
+	statusCritical = "critical"
+)
+
+// status returns ok, warning or critical for a datastore with the given name, tags and used percentage
+func (t ThresholdConfig) status(name string, dsTags []string, used float64) string {
+	warning, critical := t.limits(name, dsTags)
+
+	switch {
+	case used >= critical:
+		return statusCritical
+	case used >= warning:
+		return statusWarning
+	}
+	return statusOK
+}
+
+// limits returns the warning and critical percentage for a datastore with the given name and tags
+func (t ThresholdConfig) limits(name string, dsTags []string) (float64, float64) {
+	warning, critical := t.WarningPct, t.CriticalPct
+
+	for _, o := range t.Overrides {
+		if !o.matches(name, dsTags) {
+			continue
+		}
+		if o.WarningPct > 0 {
+			warning = o.WarningPct
+		}
+		if o.CriticalPct > 0 {
+			critical = o.CriticalPct
+		}
+		break
+	}
+
+	return warning, critical
+}
+
+// matches reports whether all criteria set on the override match the datastore
+func (o ThresholdOverride) matches(name string, dsTags []string) bool {
+	if o.Name != "" && o.Name != name {
+		return false
+	}
+	if o.re != nil && !o.re.MatchString(name) {
+		return false
+	}
+	if o.Tag != "" {
+		for _, tag := range dsTags {
+			if tag == o.Tag {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// usesTags reports whether any override matches on vSphere tags
+func (t ThresholdConfig) usesTags() bool {
+	for _, o := range t.Overrides {
+		if o.Tag != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// datastoreTags returns the names of the tags attached to the given datastores, keyed by datastore reference
+func datastoreTags(ctx context.Context, client *govmomi.Client, cfg *Config, datastores []mo.Datastore) (map[string][]string, error) {
+	result := make(map[string][]string)
+	if len(datastores) == 0 {
+		return result, nil
+	}
+
+	rc, err := connectToREST(ctx, client, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the vSphere REST API: %s", err)
+	}
+	defer rc.Logout(ctx)
+
+	attached, err := attachedTags(ctx, tags.NewManager(rc), datastores)
+	if err != nil {
+		return nil, err
+	}
+
+	for ref, dsTags := range attached {
+		for _, tag := range dsTags {
+			result[ref] = append(result[ref], tag.Name)
+		}
+	}
+
+	return result, nil
+}
+
+// attachedTags returns the tags attached to the given datastores, keyed by datastore reference
+func attachedTags(ctx context.Context, m *tags.Manager, datastores []mo.Datastore) (map[string][]tags.Tag, error) {
+	refs := make([]mo.Reference, 0, len(datastores))
+	for _, ds := range datastores {
+		refs = append(refs, ds.Self)
+	}
+
+	attached, err := m.GetAttachedTagsOnObjects(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]tags.Tag, len(attached))
+	for _, a := range attached {
+		ref := a.ObjectID.Reference()
+		result[ref.Value] = append(result[ref.Value], a.Tags...)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestThresholdLimits(t *testing.T) {
+	thresholds := ThresholdConfig{
+		WarningPct:  80,
+		CriticalPct: 90,
+		Overrides: []ThresholdOverride{
+			{Name: "scratch", WarningPct: 95, CriticalPct: 98},
+			{Regex: "^backup-", re: regexp.MustCompile("^backup-"), CriticalPct: 95},
+			{Tag: "gold", WarningPct: 70},
+			{Regex: "^backup-", re: regexp.MustCompile("^backup-"), Tag: "gold", WarningPct: 60, CriticalPct: 70},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		dsName       string
+		tags         []string
+		wantWarning  float64
+		wantCritical float64
+	}{
+		{"defaults", "ds-1", nil, 80, 90},
+		{"name", "scratch", nil, 95, 98},
+		{"regex keeps the default warning", "backup-01", nil, 80, 95},
+		{"tag keeps the default critical", "ds-1", []string{"silver", "gold"}, 70, 90},
+		{"first match wins", "backup-01", []string{"gold"}, 80, 95},
+		{"other tags", "ds-1", []string{"silver"}, 80, 90},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning, critical := thresholds.limits(tt.dsName, tt.tags)
+			if warning != tt.wantWarning || critical != tt.wantCritical {
+				t.Errorf("limits(%q, %v) = %g, %g, want %g, %g", tt.dsName, tt.tags, warning, critical, tt.wantWarning, tt.wantCritical)
+			}
+		})
+	}
+}
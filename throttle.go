@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// requestLimiter limits the requests in flight to vCenter and spaces out their start
+type requestLimiter struct {
+	slots chan struct{}
+	delay time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// wait blocks until a request may start, done must be called when it has finished
+func (l *requestLimiter) wait() (done func()) {
+	l.slots <- struct{}{}
+
+	if l.delay > 0 {
+		l.mu.Lock()
+		if wait := l.delay - time.Since(l.last); wait > 0 {
+			time.Sleep(wait)
+		}
+		l.last = time.Now()
+		l.mu.Unlock()
+	}
+
+	return func() { <-l.slots }
+}
+
+var (
+	limiterOnce sync.Once
+	limiter     *requestLimiter
+)
+
+// throttledTransport passes requests on once the shared requestLimiter lets them start
+type throttledTransport struct {
+	next    http.RoundTripper
+	limiter *requestLimiter
+}
+
+func (t *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done := t.limiter.wait()
+	defer done()
+	return t.next.RoundTrip(req)
+}
+
+// vcenterTransport returns the transport for vCenter requests. The SOAP, PBM and REST clients
+// share the -max-concurrent-requests and -request-delay limits and go through -record or -replay.
+func vcenterTransport(cfg *Config, next http.RoundTripper) http.RoundTripper {
+	limiterOnce.Do(func() {
+		limiter = &requestLimiter{slots: make(chan struct{}, cfg.MaxConcurrentRequests), delay: cfg.RequestDelay}
+	})
+	return recordingTransport(cfg, &throttledTransport{next: next, limiter: limiter})
+}
+
+// forEachConcurrently calls fn for 0..n-1 with at most -max-concurrent-requests calls running at once
+func forEachConcurrently(cfg *Config, n int, fn func(i int)) {
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, cfg.MaxConcurrentRequests)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-workers }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// toolsVersionStatuses maps the VMware Tools version status of a VM to a status, the versions
+// the hosts no longer support are critical since they block host upgrades
+var toolsVersionStatuses = map[string]string{
+	string(types.VirtualMachineToolsVersionStatusGuestToolsCurrent):      statusOK,
+	string(types.VirtualMachineToolsVersionStatusGuestToolsUnmanaged):    statusOK,
+	string(types.VirtualMachineToolsVersionStatusGuestToolsSupportedNew): statusOK,
+	string(types.VirtualMachineToolsVersionStatusGuestToolsNeedUpgrade):  statusWarning,
+	string(types.VirtualMachineToolsVersionStatusGuestToolsSupportedOld): statusWarning,
+	string(types.VirtualMachineToolsVersionStatusGuestToolsNotInstalled): statusWarning,
+	string(types.VirtualMachineToolsVersionStatusGuestToolsTooNew):       statusWarning,
+	string(types.VirtualMachineToolsVersionStatusGuestToolsTooOld):       statusCritical,
+	string(types.VirtualMachineToolsVersionStatusGuestToolsBlacklisted):  statusCritical,
+}
+
+type ToolsVMInfo struct {
+	Name       string `json:"name"`
+	PowerState string `json:"power_state"`
+	// Running is the Tools running state, like guestToolsRunning
+	Running string `json:"running"`
+	Version string `json:"version"`
+	// VersionStatus tells how the Tools version compares to the one of the host, like
+	// guestToolsNeedUpgrade
+	VersionStatus string `json:"version_status"`
+	Status        string `json:"status"`
+}
+
+type ClusterToolsInfo struct {
+	Name         string `json:"name"`
+	VMCount      int    `json:"vm_count"`
+	Current      int    `json:"current"`
+	Outdated     int    `json:"outdated"`
+	Unsupported  int    `json:"unsupported"`
+	NotInstalled int    `json:"not_installed"`
+	// NotRunning counts the powered on VMs with Tools installed but not running
+	NotRunning int           `json:"not_running"`
+	VMs        []ToolsVMInfo `json:"vms"`
+}
+
+type ToolsReport struct {
+	Datacenter string             `json:"datacenter"`
+	Clusters   []ClusterToolsInfo `json:"clusters"`
+}
+
+// reportTools reports the VMware Tools running state and version status of every VM per
+// cluster, templates excluded. VMs running Tools versions the hosts no longer support are
+// critical since they block host upgrades, outdated and missing Tools are warnings.
+func reportTools(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	report := ToolsReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterToolsInfo, 0, len(clusters)),
+	}
+
+	props := []string{"name", "config.template", "runtime.powerState", "guest.toolsStatus", "guest.toolsRunningStatus", "guest.toolsVersion", "guest.toolsVersionStatus2"}
+	for _, cluster := range clusters {
+		var vms []mo.VirtualMachine
+		err = retrieveClusterVMs(ctx, client, cluster, props, &vms)
+		if err != nil {
+			return fmt.Errorf("getting VMs of cluster %s: %s", cluster.Name(), err)
+		}
+
+		info := ClusterToolsInfo{
+			Name: cluster.Name(),
+			VMs:  make([]ToolsVMInfo, 0, len(vms)),
+		}
+		for _, vm := range vms {
+			if vm.Config != nil && vm.Config.Template {
+				continue
+			}
+
+			tools := ToolsVMInfo{
+				Name:       vm.Name,
+				PowerState: string(vm.Runtime.PowerState),
+			}
+			if vm.Guest != nil {
+				tools.Running = vm.Guest.ToolsRunningStatus
+				tools.Version = vm.Guest.ToolsVersion
+				tools.VersionStatus = toolsVersionStatus(vm.Guest)
+			}
+			tools.Status = toolsVersionStatuses[tools.VersionStatus]
+			if tools.Status == "" {
+				tools.Status = statusWarning
+			}
+
+			switch tools.VersionStatus {
+			case string(types.VirtualMachineToolsVersionStatusGuestToolsNotInstalled):
+				info.NotInstalled++
+			case string(types.VirtualMachineToolsVersionStatusGuestToolsTooOld), string(types.VirtualMachineToolsVersionStatusGuestToolsBlacklisted):
+				info.Unsupported++
+			case string(types.VirtualMachineToolsVersionStatusGuestToolsNeedUpgrade), string(types.VirtualMachineToolsVersionStatusGuestToolsSupportedOld):
+				info.Outdated++
+			default:
+				if tools.Status == statusOK {
+					info.Current++
+				}
+			}
+			if tools.VersionStatus != string(types.VirtualMachineToolsVersionStatusGuestToolsNotInstalled) &&
+				vm.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn &&
+				tools.Running != string(types.VirtualMachineToolsRunningStatusGuestToolsRunning) {
+				info.NotRunning++
+				if tools.Status == statusOK {
+					tools.Status = statusWarning
+				}
+			}
+
+			info.VMCount++
+			info.VMs = append(info.VMs, tools)
+		}
+		sort.Slice(info.VMs, func(i, j int) bool {
+			return info.VMs[i].Name < info.VMs[j].Name
+		})
+
+		report.Clusters = append(report.Clusters, info)
+	}
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		return report.Clusters[i].Name < report.Clusters[j].Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s (%d VMs)\n", cluster.Name, cluster.VMCount)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		fmt.Printf("  Current: %d, Outdated: %d, Unsupported: %d, Not installed: %d, Not running: %d\n",
+			cluster.Current, cluster.Outdated, cluster.Unsupported, cluster.NotInstalled, cluster.NotRunning)
+		for _, vm := range cluster.VMs {
+			switch vm.Status {
+			case statusCritical:
+				fmt.Printf("  WARNING: %s runs unsupported Tools %s (%s), this blocks host upgrades\n", vm.Name, valueOrNone(vm.Version), vm.VersionStatus)
+			case statusWarning:
+				fmt.Printf("  %s: Tools %s (%s), %s, %s\n", vm.Name, valueOrNone(vm.Version), valueOrNone(vm.VersionStatus), valueOrNone(vm.Running), vm.PowerState)
+			}
+		}
+	}
+
+	return nil
+}
+
+// toolsVersionStatus returns the Tools version status of a VM, falling back on the older
+// tools status for hosts that don't report the version status
+func toolsVersionStatus(guest *types.GuestInfo) string {
+	if guest.ToolsVersionStatus2 != "" {
+		return guest.ToolsVersionStatus2
+	}
+	switch guest.ToolsStatus {
+	case types.VirtualMachineToolsStatusToolsNotInstalled:
+		return string(types.VirtualMachineToolsVersionStatusGuestToolsNotInstalled)
+	case types.VirtualMachineToolsStatusToolsOld:
+		return string(types.VirtualMachineToolsVersionStatusGuestToolsNeedUpgrade)
+	case types.VirtualMachineToolsStatusToolsOk:
+		return string(types.VirtualMachineToolsVersionStatusGuestToolsCurrent)
+	}
+	return ""
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// topology is the storage layout of a datacenter used by the diagram output formats.
+// Objects are keyed by their managed object reference.
+type topology struct {
+	Datacenter string
+	Clusters   []topologyCluster
+	Hosts      map[string]string
+	// datastores mounted per host, a shared datastore shows up for several hosts
+	HostDatastores map[string][]string
+	Pods           []topologyPod
+	Datastores     map[string]DatastoreInfo
+	// tags per datastore, only looked up when threshold overrides use them
+	Tags map[string][]string
+}
+
+type topologyCluster struct {
+	Name  string
+	Hosts []string
+}
+
+type topologyPod struct {
+	Ref        string
+	Name       string
+	Datastores []string
+}
+
+// reportTopology renders the clusters, hosts, datastore clusters and datastores of the
+// datacenter as a diagram
+func reportTopology(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, cfg *Config) error {
+	topo, err := collectTopology(ctx, client, dc, cfg)
+	if err != nil {
+		return err
+	}
+
+	switch cfg.Output {
+	case outputDot:
+		printDot(topo)
+	case outputMermaid:
+		printMermaid(topo)
+	}
+
+	return nil
+}
+
+// collectTopology retrieves the hosts of every cluster and the datastores they mount.
+// Datastores hidden by the filters are left out.
+func collectTopology(ctx context.Context, client *govmomi.Client, dc *object.Datacenter, cfg *Config) (topology, error) {
+	topo := topology{
+		Datacenter:     cfg.pseudonym(kindDatacenter, dc.Name()),
+		Hosts:          make(map[string]string),
+		HostDatastores: make(map[string][]string),
+		Datastores:     make(map[string]DatastoreInfo),
+	}
+
+	var clusters []mo.ClusterComputeResource
+	err := retrieveAll(ctx, client, dc, "ClusterComputeResource", []string{"name", "host"}, &clusters)
+	if err != nil {
+		return topo, fmt.Errorf("retrieving clusters: %s", err)
+	}
+
+	var hosts []mo.HostSystem
+	err = retrieveAll(ctx, client, dc, "HostSystem", []string{"name", "datastore"}, &hosts)
+	if err != nil {
+		return topo, fmt.Errorf("retrieving hosts: %s", err)
+	}
+
+	// With -cluster only the selected clusters, their hosts and the datastores they mount are
+	// collected. The hosts of excluded clusters are left out.
+	if len(cfg.Clusters) > 0 || len(cfg.Exclude) > 0 {
+		selected := make([]mo.ClusterComputeResource, 0, len(clusters))
+		clustered := make(map[string]bool)
+		dropped := make(map[string]bool)
+		for _, cluster := range clusters {
+			keep := cfg.clusterSelected(cluster.Name)
+			if keep {
+				selected = append(selected, cluster)
+			}
+			for _, ref := range cluster.Host {
+				if keep {
+					clustered[ref.Value] = true
+				} else {
+					dropped[ref.Value] = true
+				}
+			}
+		}
+		if len(cfg.Clusters) > 0 && len(selected) == 0 {
+			return topo, fmt.Errorf("no cluster matches -cluster %s", strings.Join(cfg.Clusters, ", "))
+		}
+		clusters = selected
+
+		scoped := make([]mo.HostSystem, 0, len(hosts))
+		for _, host := range hosts {
+			if dropped[host.Self.Value] || (len(cfg.Clusters) > 0 && !clustered[host.Self.Value]) {
+				continue
+			}
+			scoped = append(scoped, host)
+		}
+		hosts = scoped
+	}
+
+	var pods []mo.StoragePod
+	err = retrieveAll(ctx, client, dc, "StoragePod", []string{"name", "childEntity"}, &pods)
+	if err != nil {
+		return topo, fmt.Errorf("retrieving datastore clusters: %s", err)
+	}
+
+	// Datastores are looked up through the hosts, which also finds the members of datastore clusters
+	seen := make(map[string]bool)
+	var dsRefs []types.ManagedObjectReference
+	for _, host := range hosts {
+		for _, ref := range host.Datastore {
+			if !seen[ref.Value] {
+				seen[ref.Value] = true
+				dsRefs = append(dsRefs, ref)
+			}
+		}
+	}
+
+	var datastores []mo.Datastore
+	if len(dsRefs) > 0 {
+		pc := property.DefaultCollector(client.Client)
+		err = pc.Retrieve(ctx, dsRefs, []string{"name", "summary", "host"}, &datastores)
+		if err != nil {
+			return topo, fmt.Errorf("retrieving datastores: %s", err)
+		}
+	}
+	if cfg.ExcludeLocal {
+		datastores = withoutLocalDatastores(datastores)
+	}
+	datastores = withoutExcludedDatastores(cfg, datastores)
+	datastores, err = withDatastoreTags(ctx, client, cfg, datastores)
+	if err != nil {
+		return topo, err
+	}
+	pods, datastores, err = scopeDatastoreClusters(cfg, pods, datastores)
+	if err != nil {
+		return topo, err
+	}
+
+	if cfg.Thresholds.usesTags() {
+		topo.Tags, err = datastoreTags(ctx, client, cfg, datastores)
+		if err != nil {
+			return topo, fmt.Errorf("retrieving datastore tags: %s", err)
+		}
+	}
+
+	for _, ds := range datastores {
+		info := newDatastoreInfo(ds, nil)
+		info.Status = cfg.Thresholds.status(info.Name, topo.Tags[ds.Self.Value], info.UsedPct)
+		if includeDatastore(cfg, info) {
+			topo.Datastores[ds.Self.Value] = cfg.anonymizeDatastore(info)
+		}
+	}
+
+	for _, host := range hosts {
+		topo.Hosts[host.Self.Value] = cfg.pseudonym(kindHost, host.Name)
+		for _, ref := range host.Datastore {
+			if _, ok := topo.Datastores[ref.Value]; ok {
+				topo.HostDatastores[host.Self.Value] = append(topo.HostDatastores[host.Self.Value], ref.Value)
+			}
+		}
+	}
+
+	for _, cluster := range clusters {
+		c := topologyCluster{Name: cfg.pseudonym(kindCluster, cluster.Name)}
+		for _, ref := range cluster.Host {
+			c.Hosts = append(c.Hosts, ref.Value)
+		}
+		topo.Clusters = append(topo.Clusters, c)
+	}
+	sort.Slice(topo.Clusters, func(i, j int) bool {
+		return topo.Clusters[i].Name < topo.Clusters[j].Name
+	})
+
+	for _, pod := range pods {
+		p := topologyPod{Ref: pod.Self.Value, Name: cfg.pseudonym(kindDatastoreCluster, pod.Name)}
+		for _, ref := range pod.ChildEntity {
+			if _, ok := topo.Datastores[ref.Value]; ok {
+				p.Datastores = append(p.Datastores, ref.Value)
+			}
+		}
+		if len(p.Datastores) > 0 {
+			topo.Pods = append(topo.Pods, p)
+		}
+	}
+	sort.Slice(topo.Pods, func(i, j int) bool {
+		return topo.Pods[i].Name < topo.Pods[j].Name
+	})
+
+	return topo, nil
+}
+
+// standaloneHosts returns the hosts that are not part of a cluster
+func (t topology) standaloneHosts() []string {
+	clustered := make(map[string]bool)
+	for _, c := range t.Clusters {
+		for _, ref := range c.Hosts {
+			clustered[ref] = true
+		}
+	}
+
+	var hosts []string
+	for ref := range t.Hosts {
+		if !clustered[ref] {
+			hosts = append(hosts, ref)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+// standaloneDatastores returns the datastores that are not part of a datastore cluster
+func (t topology) standaloneDatastores() []string {
+	inPod := make(map[string]bool)
+	for _, p := range t.Pods {
+		for _, ref := range p.Datastores {
+			inPod[ref] = true
+		}
+	}
+
+	var datastores []string
+	for ref := range t.Datastores {
+		if !inPod[ref] {
+			datastores = append(datastores, ref)
+		}
+	}
+	sort.Strings(datastores)
+	return datastores
+}
+
+// datastoreLabel returns the name, capacity and used percentage of a datastore on two lines
+func (t topology) datastoreLabel(ref string) (string, string) {
+	info := t.Datastores[ref]
+	return info.Name, fmt.Sprintf("%.0f GB, %.1f%% used", info.Capacity, info.UsedPct)
+}
+
+// printDot prints the topology as a GraphViz graph. Clusters and datastore clusters are
+// drawn as subgraphs, every host mounting a datastore gets its own edge to it.
+func printDot(t topology) {
+	fmt.Printf("graph %s {\n", dotQuote(t.Datacenter))
+	fmt.Println("  rankdir=LR;")
+	fmt.Println("  node [shape=box];")
+
+	for i, c := range t.Clusters {
+		fmt.Printf("  subgraph cluster_%d {\n", i)
+		fmt.Printf("    label=%s;\n", dotQuote(c.Name))
+		for _, ref := range c.Hosts {
+			fmt.Printf("    %s [label=%s];\n", dotQuote(ref), dotQuote(t.Hosts[ref]))
+		}
+		fmt.Println("  }")
+	}
+	for _, ref := range t.standaloneHosts() {
+		fmt.Printf("  %s [label=%s];\n", dotQuote(ref), dotQuote(t.Hosts[ref]))
+	}
+
+	printDotDatastore := func(indent, ref string) {
+		name, details := t.datastoreLabel(ref)
+		attrs := ""
+		if t.Datastores[ref].Status == statusCritical || !t.Datastores[ref].Accessible {
+			attrs = ", color=red"
+		} else if t.Datastores[ref].Status == statusWarning {
+			attrs = ", color=orange"
+		}
+		fmt.Printf("%s%s [label=%s, shape=cylinder%s];\n", indent, dotQuote(ref), dotQuote(name+"\n"+details), attrs)
+	}
+
+	for i, p := range t.Pods {
+		fmt.Printf("  subgraph cluster_pod_%d {\n", i)
+		fmt.Printf("    label=%s;\n", dotQuote(p.Name))
+		fmt.Println("    style=dashed;")
+		for _, ref := range p.Datastores {
+			printDotDatastore("    ", ref)
+		}
+		fmt.Println("  }")
+	}
+	for _, ref := range t.standaloneDatastores() {
+		printDotDatastore("  ", ref)
+	}
+
+	hosts := make([]string, 0, len(t.HostDatastores))
+	for ref := range t.HostDatastores {
+		hosts = append(hosts, ref)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		for _, ds := range t.HostDatastores[host] {
+			fmt.Printf("  %s -- %s;\n", dotQuote(host), dotQuote(ds))
+		}
+	}
+
+	fmt.Println("}")
+}
+
+// dotQuote returns s as a quoted GraphViz ID
+func dotQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// printMermaid prints the topology as a Mermaid flowchart for embedding in Markdown
+func printMermaid(t topology) {
+	fmt.Println("flowchart LR")
+
+	for i, c := range t.Clusters {
+		fmt.Printf("  subgraph cluster_%d[%s]\n", i, mermaidQuote(c.Name))
+		for _, ref := range c.Hosts {
+			fmt.Printf("    %s[%s]\n", mermaidID(ref), mermaidQuote(t.Hosts[ref]))
+		}
+		fmt.Println("  end")
+	}
+	for _, ref := range t.standaloneHosts() {
+		fmt.Printf("  %s[%s]\n", mermaidID(ref), mermaidQuote(t.Hosts[ref]))
+	}
+
+	var critical, warning []string
+	printMermaidDatastore := func(indent, ref string) {
+		name, details := t.datastoreLabel(ref)
+		fmt.Printf("%s%s[(%s)]\n", indent, mermaidID(ref), mermaidQuote(name+"<br/>"+details))
+		if t.Datastores[ref].Status == statusCritical || !t.Datastores[ref].Accessible {
+			critical = append(critical, mermaidID(ref))
+		} else if t.Datastores[ref].Status == statusWarning {
+			warning = append(warning, mermaidID(ref))
+		}
+	}
+
+	for i, p := range t.Pods {
+		fmt.Printf("  subgraph pod_%d[%s]\n", i, mermaidQuote(p.Name))
+		for _, ref := range p.Datastores {
+			printMermaidDatastore("    ", ref)
+		}
+		fmt.Println("  end")
+	}
+	for _, ref := range t.standaloneDatastores() {
+		printMermaidDatastore("  ", ref)
+	}
+
+	hosts := make([]string, 0, len(t.HostDatastores))
+	for ref := range t.HostDatastores {
+		hosts = append(hosts, ref)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		for _, ds := range t.HostDatastores[host] {
+			fmt.Printf("  %s --- %s\n", mermaidID(host), mermaidID(ds))
+		}
+	}
+
+	if len(warning) > 0 {
+		fmt.Println("  classDef warning stroke:orange,stroke-width:2px")
+		fmt.Printf("  class %s warning\n", strings.Join(warning, ","))
+	}
+	if len(critical) > 0 {
+		fmt.Println("  classDef critical stroke:red,stroke-width:2px")
+		fmt.Printf("  class %s critical\n", strings.Join(critical, ","))
+	}
+}
+
+// mermaidID turns a managed object reference into a Mermaid node ID
+func mermaidID(ref string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, ref)
+}
+
+// mermaidQuote returns s as a quoted Mermaid label
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
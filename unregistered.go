@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+type UnregisteredVMInfo struct {
+	Datastore    string     `json:"datastore"`
+	Directory    string     `json:"directory"`
+	VMX          string     `json:"vmx"`
+	Size         float64    `json:"size_gb"`
+	LastModified *time.Time `json:"last_modified,omitempty"`
+}
+
+type UnregisteredVMReport struct {
+	Datacenter string               `json:"datacenter"`
+	TotalSize  float64              `json:"total_size_gb"`
+	VMs        []UnregisteredVMInfo `json:"vms"`
+	Errors     []string             `json:"errors"`
+}
+
+// reportUnregisteredVMs finds .vmx files on all datastores that do not belong to a registered VM
+func reportUnregisteredVMs(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	var vms []mo.VirtualMachine
+	err := retrieveAll(ctx, client, dc, "VirtualMachine", []string{"config.files.vmPathName"}, &vms)
+	if err != nil {
+		return fmt.Errorf("retrieving VMs: %s", err)
+	}
+
+	registered := make(map[string]bool, len(vms))
+	for _, vm := range vms {
+		if vm.Config != nil {
+			registered[normalizeDatastorePath(vm.Config.Files.VmPathName)] = true
+		}
+	}
+
+	var datastores []mo.Datastore
+	err = retrieveAll(ctx, client, dc, "Datastore", []string{"name", "summary.accessible", "browser"}, &datastores)
+	if err != nil {
+		return fmt.Errorf("retrieving datastores: %s", err)
+	}
+	datastores = withoutExcludedDatastores(cfg, datastores)
+
+	report := UnregisteredVMReport{
+		Datacenter: dc.Name(),
+		VMs:        make([]UnregisteredVMInfo, 0),
+		Errors:     make([]string, 0),
+	}
+
+	// search the datastores concurrently, the results are merged in datastore order
+	found := make([][]datastoreFile, len(datastores))
+	searchErrs := make([]error, len(datastores))
+	forEachConcurrently(cfg, len(datastores), func(i int) {
+		if datastores[i].Summary.Accessible {
+			found[i], searchErrs[i] = searchDatastore(ctx, client, datastores[i], []string{"*.vmx"})
+		}
+	})
+
+	var total int64
+	for i, ds := range datastores {
+		if !ds.Summary.Accessible {
+			report.Errors = append(report.Errors, fmt.Sprintf("datastore %s is not accessible", ds.Name))
+			continue
+		}
+
+		vmxFiles, err := found[i], searchErrs[i]
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("searching datastore %s: %s", ds.Name, err))
+			continue
+		}
+
+		// Sum up the files of every directory once, a datastore search returns all of them
+		var allFiles []datastoreFile
+		for _, vmx := range vmxFiles {
+			if registered[vmx.Path] {
+				continue
+			}
+			if allFiles == nil {
+				allFiles, err = searchDatastore(ctx, client, ds, []string{"*"})
+				if err != nil {
+					report.Errors = append(report.Errors, fmt.Sprintf("searching datastore %s: %s", ds.Name, err))
+					break
+				}
+			}
+
+			var p object.DatastorePath
+			p.FromString(vmx.Path)
+			dir := object.DatastorePath{Datastore: ds.Name, Path: path.Dir(p.Path)}
+
+			var size int64
+			for _, file := range allFiles {
+				var fp object.DatastorePath
+				fp.FromString(file.Path)
+				if path.Dir(fp.Path) == dir.Path {
+					size += file.Size
+				}
+			}
+			total += size
+
+			report.VMs = append(report.VMs, UnregisteredVMInfo{
+				Datastore:    ds.Name,
+				Directory:    dir.String(),
+				VMX:          vmx.Path,
+				Size:         bytesToGB(size),
+				LastModified: vmx.Modified,
+			})
+		}
+	}
+	report.TotalSize = bytesToGB(total)
+
+	sort.Slice(report.VMs, func(i, j int) bool {
+		return report.VMs[i].Size > report.VMs[j].Size
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nUnregistered VM directories: %d (%s)\n", len(report.VMs), formatGB(report.TotalSize))
+	for _, vm := range report.VMs {
+		modified := "unknown"
+		if vm.LastModified != nil {
+			modified = vm.LastModified.Format("2006-01-02")
+		}
+		fmt.Printf("  - %s (%s, vmx modified %s)\n", vm.Directory, formatGB(vm.Size), modified)
+	}
+
+	for _, e := range report.Errors {
+		fmt.Printf("  Error: %s\n", e)
+	}
+
+	return nil
+}
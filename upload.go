@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// stdoutCapture copies everything written to stdout into a buffer, printing it as well with echo
+type stdoutCapture struct {
+	orig *os.File
+	w    *os.File
+	buf  bytes.Buffer
+	done chan struct{}
+}
+
+func captureStdout(echo bool) (*stdoutCapture, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &stdoutCapture{orig: os.Stdout, w: w, done: make(chan struct{})}
+	os.Stdout = w
+	var dst io.Writer = &c.buf
+	if echo {
+		dst = io.MultiWriter(c.orig, &c.buf)
+	}
+	go func() {
+		io.Copy(dst, r)
+		r.Close()
+		close(c.done)
+	}()
+
+	return c, nil
+}
+
+// stop restores stdout and returns the captured output
+func (c *stdoutCapture) stop() []byte {
+	c.w.Close()
+	<-c.done
+	os.Stdout = c.orig
+	return c.buf.Bytes()
+}
+
+// outputExtensions are the file extensions of uploaded reports per output format
+var outputExtensions = map[string]string{
+	outputText:    ".txt",
+	outputJSON:    ".json",
+	outputTree:    ".txt",
+	outputDot:     ".dot",
+	outputMermaid: ".mmd",
+	outputNDJSON:  ".ndjson",
+}
+
+// uploadReport stores a rendered report under a timestamped key below the -upload URL.
+// Supported are s3://bucket/prefix, gs://bucket/prefix with HMAC keys and
+// azblob://account/container/prefix with a SAS token.
+func uploadReport(cfg *Config, dc, command string, data []byte) (string, error) {
+	u, err := url.Parse(cfg.Upload)
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%s-%s%s", command, time.Now().UTC().Format("20060102T150405Z"), outputExtensions[cfg.Output])
+	contentType := "text/plain"
+	if cfg.Output == outputJSON {
+		contentType = "application/json"
+	}
+	if cfg.Compress != "" {
+		data, err = compressData(cfg.Compress, data)
+		if err != nil {
+			return "", err
+		}
+		name += compressions[cfg.Compress].extension
+		contentType = compressions[cfg.Compress].contentType
+	}
+	key := path.Join(strings.Trim(u.Path, "/"), dc, name)
+	location := u.Scheme + "://" + u.Host + "/" + key
+
+	// the checksum and signature are stored next to the report, made before anything is
+	// uploaded so a failing signature leaves no unsigned report behind
+	sidecars, err := cfg.Signing.sidecars(name, data)
+	if err != nil {
+		return "", err
+	}
+
+	if err := putObject(u, key, contentType, data); err != nil {
+		return "", err
+	}
+	for _, sidecar := range sidecars {
+		if err := putObject(u, key+sidecar.extension, "text/plain", sidecar.data); err != nil {
+			return "", fmt.Errorf("uploading %s: %s", name+sidecar.extension, err)
+		}
+	}
+
+	return location, nil
+}
+
+// putObject stores data under key in the bucket or container of the -upload URL
+func putObject(u *url.URL, key, contentType string, data []byte) error {
+	switch u.Scheme {
+	case "s3":
+		region := os.Getenv("AWS_REGION")
+		if region == "" {
+			region = "us-east-1"
+		}
+		endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", u.Host, region, sigV4EscapePath(key))
+		if e := os.Getenv("AWS_ENDPOINT_URL"); e != "" {
+			// S3 compatible stores like MinIO use path style URLs
+			endpoint = strings.TrimSuffix(e, "/") + "/" + u.Host + "/" + sigV4EscapePath(key)
+		}
+		creds := s3Credentials{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+			region:       region,
+		}
+		return putSigV4(endpoint, creds, contentType, data)
+	case "gs":
+		creds := s3Credentials{
+			accessKey: os.Getenv("GCS_HMAC_ACCESS_KEY"),
+			secretKey: os.Getenv("GCS_HMAC_SECRET"),
+			region:    "auto",
+		}
+		endpoint := fmt.Sprintf("https://storage.googleapis.com/%s/%s", u.Host, sigV4EscapePath(key))
+		return putSigV4(endpoint, creds, contentType, data)
+	case "azblob":
+		sas := strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+		if sas == "" {
+			return fmt.Errorf("AZURE_STORAGE_SAS_TOKEN must be set for azblob uploads")
+		}
+		// the first path element is the container
+		segments := strings.Split(key, "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		endpoint := fmt.Sprintf("https://%s.blob.core.windows.net/%s?%s", u.Host, strings.Join(segments, "/"), sas)
+		req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("x-ms-blob-type", "BlockBlob")
+		return doUpload(req)
+	}
+
+	return fmt.Errorf("unsupported upload URL %s, use s3://, gs:// or azblob://", u)
+}
+
+// -compress values
+const (
+	compressGzip = "gzip"
+	compressZstd = "zstd"
+)
+
+// compressions maps -compress values to the key extension and content type of the upload
+var compressions = map[string]struct {
+	extension   string
+	contentType string
+}{
+	compressGzip: {".gz", "application/gzip"},
+	compressZstd: {".zst", "application/zstd"},
+}
+
+// compressData compresses an uploaded report with gzip or zstd
+func compressData(compression string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var zw io.WriteCloser
+	switch compression {
+	case compressGzip:
+		zw = gzip.NewWriter(&buf)
+	case compressZstd:
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		zw = w
+	default:
+		return nil, fmt.Errorf("unknown compression %s", compression)
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sigV4EscapePath URI-encodes each segment of a path as Signature Version 4 defines it: all
+// bytes but letters, digits and -._~ as %XY
+func sigV4EscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+type s3Credentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+}
+
+// putSigV4 uploads data with an AWS Signature Version 4 signed PUT request
+func putSigV4(endpoint string, creds s3Credentials, contentType string, data []byte) error {
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return fmt.Errorf("no credentials for the upload, see the README for the environment variables")
+	}
+
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(data)
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	signed := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	if creds.sessionToken != "" {
+		signed = append(signed, "x-amz-security-token")
+	}
+	var canonicalHeaders strings.Builder
+	for _, h := range signed {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signedHeaders := strings.Join(signed, ";")
+
+	// the path is sent as signed, Go would leave characters unescaped that SigV4 escapes
+	canonicalURI := sigV4EscapePath(req.URL.Path)
+	req.URL.RawPath = canonicalURI
+
+	canonicalRequest := strings.Join([]string{
+		http.MethodPut,
+		canonicalURI,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + creds.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), day)
+	key = hmacSHA256(key, creds.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKey, scope, signedHeaders, signature))
+
+	return doUpload(req)
+}
+
+func doUpload(req *http.Request) error {
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSigV4EscapePath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"reports/DC0/datastores-20240101T000000Z.json", "reports/DC0/datastores-20240101T000000Z.json"},
+		{"reports/DC 1/x.json", "reports/DC%201/x.json"},
+		{"a/b!$&'()*+,;=:@/c", "a/b%21%24%26%27%28%29%2A%2B%2C%3B%3D%3A%40/c"},
+		{"a/#?/c", "a/%23%3F/c"},
+		{"a/~-._/c", "a/~-._/c"},
+		{"a/ü", "a/%C3%BC"},
+	}
+
+	for _, tt := range tests {
+		if got := sigV4EscapePath(tt.in); got != tt.want {
+			t.Errorf("sigV4EscapePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPutSigV4Path(t *testing.T) {
+	var requestURI string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+	}))
+	defer server.Close()
+
+	creds := s3Credentials{accessKey: "key", secretKey: "secret", region: "us-east-1"}
+	endpoint := server.URL + "/bucket/" + sigV4EscapePath("reports/DC (1)!/x#y.json")
+	if err := putSigV4(endpoint, creds, "application/json", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	// the path has to arrive the way it was signed
+	if want := "/bucket/reports/DC%20%281%29%21/x%23y.json"; requestURI != want {
+		t.Errorf("request URI = %q, want %q", requestURI, want)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+// ResourceUsageInfo is the current CPU and memory usage of a cluster and the used space of its
+// datastores, each with its status against the warning and critical percentage
+type ResourceUsageInfo struct {
+	HostCount int `json:"host_count"`
+	// CPUUsage and CPUCapacity are in MHz
+	CPUUsage       int64          `json:"cpu_usage_mhz"`
+	CPUCapacity    int64          `json:"cpu_capacity_mhz"`
+	CPUUsedPct     float64        `json:"cpu_used_pct"`
+	CPUStatus      string         `json:"cpu_status"`
+	MemoryUsage    float64        `json:"memory_usage_gb"`
+	MemoryCapacity float64        `json:"memory_capacity_gb"`
+	MemoryUsedPct  float64        `json:"memory_used_pct"`
+	MemoryStatus   string         `json:"memory_status"`
+	Storage        CapacityTotals `json:"storage"`
+	StorageStatus  string         `json:"storage_status"`
+}
+
+type ClusterUsageInfo struct {
+	Name string `json:"name"`
+	ResourceUsageInfo
+}
+
+type UsageReport struct {
+	Datacenter string             `json:"datacenter"`
+	Clusters   []ClusterUsageInfo `json:"clusters"`
+	// Totals counts datastores shared between clusters once
+	Totals ResourceUsageInfo `json:"totals"`
+}
+
+// reportUsage shows the current CPU and memory usage of every cluster from the quick stats of
+// its hosts next to the used space of its datastores, with the datacenter totals, as a one
+// screen overview. The thresholds of the config file apply to all three.
+func reportUsage(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := UsageReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterUsageInfo, 0, len(clusters)),
+	}
+	var cpuUsage, cpuCapacity, memoryUsageMB, memoryCapacity int64
+	var hostCount int
+	all := make(map[string]mo.Datastore)
+
+	for _, cluster := range clusters {
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"summary", "host", "datastore"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting cluster %s: %s", cluster.Name(), err)
+		}
+
+		var hosts []mo.HostSystem
+		if len(clusterMo.Host) > 0 {
+			err = pc.Retrieve(ctx, clusterMo.Host, []string{"summary.quickStats"}, &hosts)
+			if err != nil {
+				return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+			}
+		}
+
+		var datastores []mo.Datastore
+		if len(clusterMo.Datastore) > 0 {
+			err = pc.Retrieve(ctx, clusterMo.Datastore, []string{"name", "summary", "host"}, &datastores)
+			if err != nil {
+				return fmt.Errorf("retrieving datastores of cluster %s: %s", cluster.Name(), err)
+			}
+		}
+		if cfg.ExcludeLocal {
+			datastores = withoutLocalDatastores(datastores)
+		}
+		datastores = withoutExcludedDatastores(cfg, datastores)
+
+		info := ClusterUsageInfo{Name: cluster.Name()}
+		// the capacity DRS can hand out to VMs, the hosts in maintenance mode left out
+		var capacityMemory int64
+		if clusterMo.Summary != nil {
+			summary := clusterMo.Summary.GetComputeResourceSummary()
+			info.HostCount = int(summary.NumEffectiveHosts)
+			info.CPUCapacity = int64(summary.EffectiveCpu)
+			capacityMemory = summary.EffectiveMemory * 1024 * 1024
+		}
+		var usageMB int64
+		for _, host := range hosts {
+			info.CPUUsage += int64(host.Summary.QuickStats.OverallCpuUsage)
+			usageMB += int64(host.Summary.QuickStats.OverallMemoryUsage)
+		}
+		info.MemoryUsage = bytesToGB(usageMB * 1024 * 1024)
+		info.MemoryCapacity = bytesToGB(capacityMemory)
+		info.Storage = capacityTotals(datastores)
+		info.setStatus(cfg)
+
+		hostCount += info.HostCount
+		cpuUsage += info.CPUUsage
+		cpuCapacity += info.CPUCapacity
+		memoryUsageMB += usageMB
+		memoryCapacity += capacityMemory
+		for _, ds := range datastores {
+			all[ds.Self.Value] = ds
+		}
+
+		report.Clusters = append(report.Clusters, info)
+	}
+	sort.Slice(report.Clusters, func(i, j int) bool {
+		return report.Clusters[i].Name < report.Clusters[j].Name
+	})
+
+	datastores := make([]mo.Datastore, 0, len(all))
+	for _, ds := range all {
+		datastores = append(datastores, ds)
+	}
+	report.Totals = ResourceUsageInfo{
+		HostCount:      hostCount,
+		CPUUsage:       cpuUsage,
+		CPUCapacity:    cpuCapacity,
+		MemoryUsage:    bytesToGB(memoryUsageMB * 1024 * 1024),
+		MemoryCapacity: bytesToGB(memoryCapacity),
+		Storage:        capacityTotals(datastores),
+	}
+	report.Totals.setStatus(cfg)
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", cluster.Name)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		printResourceUsage(cluster.ResourceUsageInfo)
+	}
+	fmt.Println("\nDatacenter total:")
+	printResourceUsage(report.Totals)
+
+	return nil
+}
+
+// setStatus fills in the used percentages and their status
+func (u *ResourceUsageInfo) setStatus(cfg *Config) {
+	u.CPUUsedPct = usedPct(float64(u.CPUCapacity), float64(u.CPUCapacity-u.CPUUsage))
+	u.MemoryUsedPct = usedPct(u.MemoryCapacity, u.MemoryCapacity-u.MemoryUsage)
+	u.CPUStatus = cfg.Thresholds.status("", nil, u.CPUUsedPct)
+	u.MemoryStatus = cfg.Thresholds.status("", nil, u.MemoryUsedPct)
+	u.StorageStatus = cfg.Thresholds.status("", nil, u.Storage.UsedPct)
+}
+
+func printResourceUsage(u ResourceUsageInfo) {
+	fmt.Printf("  CPU:     %s used (%d of %d MHz on %d hosts)%s\n",
+		formatPct(u.CPUUsedPct), u.CPUUsage, u.CPUCapacity, u.HostCount, usageStatus(u.CPUStatus))
+	fmt.Printf("  Memory:  %s used (%s of %s)%s\n",
+		formatPct(u.MemoryUsedPct), formatGB(u.MemoryUsage), formatGB(u.MemoryCapacity), usageStatus(u.MemoryStatus))
+	fmt.Printf("  Storage: %s used (%s free of %s on %d datastores)%s\n",
+		formatPct(u.Storage.UsedPct), formatGB(u.Storage.FreeSpace), formatGB(u.Storage.Capacity), u.Storage.DatastoreCount, usageStatus(u.StorageStatus))
+}
+
+// usageStatus returns the status suffix of a usage line, nothing when the usage is ok
+func usageStatus(status string) string {
+	if status == "" || status == statusOK {
+		return ""
+	}
+	return " " + strings.ToUpper(status)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// VAppAllocationInfo is the CPU (MHz) or memory (MB) allocation of a vApp
+type VAppAllocationInfo struct {
+	Reservation int64 `json:"reservation"`
+	Expandable  bool  `json:"expandable_reservation"`
+	// Limit is -1 for unlimited
+	Limit  int64  `json:"limit"`
+	Shares string `json:"shares"`
+}
+
+type VAppMemberInfo struct {
+	Name string `json:"name"`
+	// Type is VirtualMachine or VirtualApp for nested vApps
+	Type       string `json:"type"`
+	PowerState string `json:"power_state,omitempty"`
+	// StartOrder is the start group of the member, members of lower groups start first and
+	// stop last; nil for members without start settings
+	StartOrder  *int32 `json:"start_order,omitempty"`
+	StartDelay  int32  `json:"start_delay,omitempty"`
+	StartAction string `json:"start_action,omitempty"`
+	StopDelay   int32  `json:"stop_delay,omitempty"`
+	StopAction  string `json:"stop_action,omitempty"`
+	// WaitForTools delays starting the next group until VMware Tools runs in the member
+	WaitForTools bool `json:"wait_for_tools,omitempty"`
+}
+
+type VAppInfo struct {
+	Name    string `json:"name"`
+	Cluster string `json:"cluster"`
+	// Parent is the vApp a nested vApp belongs to
+	Parent  string             `json:"parent,omitempty"`
+	Product string             `json:"product,omitempty"`
+	CPU     VAppAllocationInfo `json:"cpu_mhz"`
+	Memory  VAppAllocationInfo `json:"memory_mb"`
+	Members []VAppMemberInfo   `json:"members"`
+}
+
+type VAppsReport struct {
+	Datacenter string     `json:"datacenter"`
+	VApps      []VAppInfo `json:"vapps"`
+}
+
+// reportVApps lists the vApps of every cluster with their member VMs and nested vApps in
+// start order and their CPU and memory allocations, since the VMs of vApps live outside the
+// resource pools and folders most reports walk
+func reportVApps(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	report := VAppsReport{
+		Datacenter: dc.Name(),
+		VApps:      make([]VAppInfo, 0),
+	}
+
+	m := view.NewManager(client.Client)
+	for _, cluster := range clusters {
+		v, err := m.CreateContainerView(ctx, cluster.Reference(), []string{"VirtualApp"}, true)
+		if err != nil {
+			return fmt.Errorf("getting vApps of cluster %s: %s", cluster.Name(), err)
+		}
+		var vapps []mo.VirtualApp
+		err = v.Retrieve(ctx, []string{"VirtualApp"}, []string{"name", "parentVApp", "vm", "resourcePool", "config", "vAppConfig"}, &vapps)
+		v.Destroy(ctx)
+		if err != nil {
+			return fmt.Errorf("getting vApps of cluster %s: %s", cluster.Name(), err)
+		}
+		if len(vapps) == 0 {
+			continue
+		}
+
+		var vms []mo.VirtualMachine
+		err = retrieveClusterVMs(ctx, client, cluster, []string{"name", "runtime.powerState"}, &vms)
+		if err != nil {
+			return fmt.Errorf("getting VMs of cluster %s: %s", cluster.Name(), err)
+		}
+		names := make(map[string]string, len(vms)+len(vapps))
+		powerStates := make(map[string]string, len(vms))
+		for _, vm := range vms {
+			names[vm.Self.Value] = vm.Name
+			powerStates[vm.Self.Value] = string(vm.Runtime.PowerState)
+		}
+		for _, vapp := range vapps {
+			names[vapp.Self.Value] = vapp.Name
+		}
+
+		for _, vapp := range vapps {
+			info := VAppInfo{
+				Name:    vapp.Name,
+				Cluster: cluster.Name(),
+				CPU:     vAppAllocation(vapp.Config.CpuAllocation),
+				Memory:  vAppAllocation(vapp.Config.MemoryAllocation),
+				Members: make([]VAppMemberInfo, 0, len(vapp.Vm)+len(vapp.ResourcePool.ResourcePool)),
+			}
+			if vapp.ParentVApp != nil {
+				info.Parent = names[vapp.ParentVApp.Value]
+			}
+
+			entities := make(map[string]types.VAppEntityConfigInfo)
+			if vapp.VAppConfig != nil {
+				for _, entity := range vapp.VAppConfig.EntityConfig {
+					if entity.Key != nil {
+						entities[entity.Key.Value] = entity
+					}
+				}
+				if len(vapp.VAppConfig.Product) > 0 {
+					product := vapp.VAppConfig.Product[0]
+					info.Product = strings.TrimSpace(product.Name + " " + product.Version)
+				}
+			}
+
+			members := append(append([]types.ManagedObjectReference{}, vapp.Vm...), vapp.ResourcePool.ResourcePool...)
+			for _, ref := range members {
+				member := VAppMemberInfo{
+					Name:       names[ref.Value],
+					Type:       ref.Type,
+					PowerState: powerStates[ref.Value],
+				}
+				if member.Name == "" {
+					member.Name = ref.Value
+				}
+				if entity, ok := entities[ref.Value]; ok {
+					order := entity.StartOrder
+					member.StartOrder = &order
+					member.StartDelay = entity.StartDelay
+					member.StartAction = entity.StartAction
+					member.StopDelay = entity.StopDelay
+					member.StopAction = entity.StopAction
+					if entity.WaitingForGuest != nil {
+						member.WaitForTools = *entity.WaitingForGuest
+					}
+				}
+				info.Members = append(info.Members, member)
+			}
+			// members without start settings start last
+			sort.Slice(info.Members, func(i, j int) bool {
+				a, b := info.Members[i], info.Members[j]
+				if (a.StartOrder == nil) != (b.StartOrder == nil) {
+					return a.StartOrder != nil
+				}
+				if a.StartOrder != nil && *a.StartOrder != *b.StartOrder {
+					return *a.StartOrder < *b.StartOrder
+				}
+				return a.Name < b.Name
+			})
+
+			report.VApps = append(report.VApps, info)
+		}
+	}
+	sort.Slice(report.VApps, func(i, j int) bool {
+		a, b := report.VApps[i], report.VApps[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		return a.Name < b.Name
+	})
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	fmt.Printf("\nvApps: %d\n", len(report.VApps))
+	for _, vapp := range report.VApps {
+		fmt.Printf("\n  %s (cluster %s)\n", vapp.Name, vapp.Cluster)
+		if vapp.Parent != "" {
+			fmt.Printf("    Parent vApp: %s\n", vapp.Parent)
+		}
+		if vapp.Product != "" {
+			fmt.Printf("    Product: %s\n", vapp.Product)
+		}
+		fmt.Printf("    CPU: %s\n", formatVAppAllocation(vapp.CPU, "MHz"))
+		fmt.Printf("    Memory: %s\n", formatVAppAllocation(vapp.Memory, "MB"))
+		fmt.Printf("    Members: %d\n", len(vapp.Members))
+		for _, member := range vapp.Members {
+			start := "no start order"
+			if member.StartOrder != nil {
+				start = fmt.Sprintf("start group %d, %s after %ds, %s after %ds", *member.StartOrder,
+					valueOrNone(member.StartAction), member.StartDelay, valueOrNone(member.StopAction), member.StopDelay)
+				if member.WaitForTools {
+					start += ", waits for Tools"
+				}
+			}
+			kind := valueOrNone(member.PowerState)
+			if member.Type == "VirtualApp" {
+				kind = "vApp"
+			}
+			fmt.Printf("      %s (%s): %s\n", member.Name, kind, start)
+		}
+	}
+
+	return nil
+}
+
+// vAppAllocation converts the CPU or memory allocation of a vApp
+func vAppAllocation(allocation types.ResourceAllocationInfo) VAppAllocationInfo {
+	info := VAppAllocationInfo{Limit: -1}
+	if allocation.Reservation != nil {
+		info.Reservation = *allocation.Reservation
+	}
+	if allocation.ExpandableReservation != nil {
+		info.Expandable = *allocation.ExpandableReservation
+	}
+	if allocation.Limit != nil {
+		info.Limit = *allocation.Limit
+	}
+	if allocation.Shares != nil {
+		info.Shares = string(allocation.Shares.Level)
+		if allocation.Shares.Level == types.SharesLevelCustom {
+			info.Shares = fmt.Sprint(allocation.Shares.Shares)
+		}
+	}
+	return info
+}
+
+func formatVAppAllocation(allocation VAppAllocationInfo, unit string) string {
+	limit := "unlimited"
+	if allocation.Limit >= 0 {
+		limit = fmt.Sprintf("%d %s", allocation.Limit, unit)
+	}
+	s := fmt.Sprintf("reservation %d %s", allocation.Reservation, unit)
+	if allocation.Expandable {
+		s += " (expandable)"
+	}
+	return fmt.Sprintf("%s, limit %s, shares %s", s, limit, valueOrNone(allocation.Shares))
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+)
+
+type VMkernelAdapterInfo struct {
+	Device     string `json:"device"`
+	IPAddress  string `json:"ip_address"`
+	SubnetMask string `json:"subnet_mask"`
+	DHCP       bool   `json:"dhcp"`
+	MTU        int32  `json:"mtu"`
+	// Services are the traffic types enabled on the adapter, like vmotion, vsan or management
+	Services  []string `json:"services"`
+	Portgroup string   `json:"portgroup"`
+	// Switch is the dvSwitch of a distributed port, empty for standard switch portgroups
+	Switch string `json:"switch,omitempty"`
+}
+
+type HostVMkernelInfo struct {
+	Name     string                `json:"name"`
+	Adapters []VMkernelAdapterInfo `json:"adapters"`
+}
+
+type ClusterVMkernelInfo struct {
+	Name  string             `json:"name"`
+	Hosts []HostVMkernelInfo `json:"hosts"`
+	// Warnings flag services whose adapters use different MTUs across the hosts
+	Warnings []string `json:"warnings"`
+}
+
+type VMkernelReport struct {
+	Datacenter string                `json:"datacenter"`
+	Clusters   []ClusterVMkernelInfo `json:"clusters"`
+}
+
+// reportVMkernelAdapters lists the vmkernel interfaces of every host with their addressing,
+// MTU, enabled services and backing portgroup, storage connectivity problems usually start
+// with a misconfigured vmkernel adapter
+func reportVMkernelAdapters(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return fmt.Errorf("getting clusters: %s", err)
+	}
+
+	switches, portgroups, err := distributedNetworkNames(ctx, client, dc)
+	if err != nil {
+		return fmt.Errorf("retrieving distributed switches: %s", err)
+	}
+
+	pc := property.DefaultCollector(client.Client)
+
+	report := VMkernelReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterVMkernelInfo, 0, len(clusters)),
+	}
+
+	for _, cluster := range clusters {
+		clusterInfo := ClusterVMkernelInfo{
+			Name:     cluster.Name(),
+			Hosts:    make([]HostVMkernelInfo, 0),
+			Warnings: make([]string, 0),
+		}
+
+		var clusterMo mo.ClusterComputeResource
+		err = pc.RetrieveOne(ctx, cluster.Reference(), []string{"host"}, &clusterMo)
+		if err != nil {
+			return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+		}
+
+		var hosts []mo.HostSystem
+		if len(clusterMo.Host) > 0 {
+			err = pc.Retrieve(ctx, clusterMo.Host, []string{"name", "config.network.vnic", "config.virtualNicManagerInfo"}, &hosts)
+			if err != nil {
+				return fmt.Errorf("getting hosts of cluster %s: %s", cluster.Name(), err)
+			}
+		}
+		sort.Slice(hosts, func(i, j int) bool {
+			return hosts[i].Name < hosts[j].Name
+		})
+
+		// MTUs per service across the hosts of the cluster
+		mtus := make(map[string]map[int32]bool)
+
+		for _, host := range hosts {
+			hostInfo := HostVMkernelInfo{
+				Name:     host.Name,
+				Adapters: make([]VMkernelAdapterInfo, 0),
+			}
+			if host.Config == nil {
+				clusterInfo.Hosts = append(clusterInfo.Hosts, hostInfo)
+				continue
+			}
+
+			// the services enabled per vmkernel device
+			services := make(map[string][]string)
+			if nm := host.Config.VirtualNicManagerInfo; nm != nil {
+				for _, nc := range nm.NetConfig {
+					devices := make(map[string]string)
+					for _, vnic := range nc.CandidateVnic {
+						devices[vnic.Key] = vnic.Device
+					}
+					for _, key := range nc.SelectedVnic {
+						if device, ok := devices[key]; ok {
+							services[device] = append(services[device], nc.NicType)
+						}
+					}
+				}
+			}
+
+			if host.Config.Network != nil {
+				for _, vnic := range host.Config.Network.Vnic {
+					adapter := VMkernelAdapterInfo{
+						Device:    vnic.Device,
+						MTU:       vnic.Spec.Mtu,
+						Services:  services[vnic.Device],
+						Portgroup: vnic.Portgroup,
+					}
+					if adapter.Services == nil {
+						adapter.Services = make([]string, 0)
+					}
+					sort.Strings(adapter.Services)
+					if ip := vnic.Spec.Ip; ip != nil {
+						adapter.IPAddress = ip.IpAddress
+						adapter.SubnetMask = ip.SubnetMask
+						adapter.DHCP = ip.Dhcp
+					}
+					if port := vnic.Spec.DistributedVirtualPort; port != nil {
+						adapter.Switch = switches[port.SwitchUuid]
+						adapter.Portgroup = portgroups[port.PortgroupKey]
+					}
+
+					for _, service := range adapter.Services {
+						if mtus[service] == nil {
+							mtus[service] = make(map[int32]bool)
+						}
+						mtus[service][adapter.MTU] = true
+					}
+
+					hostInfo.Adapters = append(hostInfo.Adapters, adapter)
+				}
+			}
+			sort.Slice(hostInfo.Adapters, func(i, j int) bool {
+				return hostInfo.Adapters[i].Device < hostInfo.Adapters[j].Device
+			})
+
+			clusterInfo.Hosts = append(clusterInfo.Hosts, hostInfo)
+		}
+
+		serviceNames := make([]string, 0, len(mtus))
+		for service := range mtus {
+			serviceNames = append(serviceNames, service)
+		}
+		sort.Strings(serviceNames)
+		for _, service := range serviceNames {
+			if len(mtus[service]) < 2 {
+				continue
+			}
+			var values []int32
+			for mtu := range mtus[service] {
+				values = append(values, mtu)
+			}
+			sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+			clusterInfo.Warnings = append(clusterInfo.Warnings, fmt.Sprintf("%s adapters use different MTUs: %s", service, strings.Trim(fmt.Sprint(values), "[]")))
+		}
+
+		report.Clusters = append(report.Clusters, clusterInfo)
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", cluster.Name)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		for _, host := range cluster.Hosts {
+			fmt.Printf("  Host: %s\n", host.Name)
+			if len(host.Adapters) == 0 {
+				fmt.Println("    No vmkernel adapters")
+			}
+			for _, a := range host.Adapters {
+				address := a.IPAddress
+				if a.SubnetMask != "" {
+					address += "/" + a.SubnetMask
+				}
+				if a.DHCP {
+					address += " (DHCP)"
+				}
+				portgroup := a.Portgroup
+				if a.Switch != "" {
+					portgroup += " on " + a.Switch
+				}
+				fmt.Printf("    %s: %s, MTU %d, portgroup %s, services: %s\n",
+					a.Device, valueOrNone(address), a.MTU, valueOrNone(portgroup), valueOrNone(strings.Join(a.Services, ", ")))
+			}
+		}
+		for _, warning := range cluster.Warnings {
+			fmt.Printf("  WARNING: %s\n", warning)
+		}
+	}
+
+	return nil
+}
+
+// distributedNetworkNames returns the names of the distributed switches by UUID and of the
+// distributed portgroups by key
+func distributedNetworkNames(ctx context.Context, client *govmomi.Client, dc *object.Datacenter) (map[string]string, map[string]string, error) {
+	var switches []mo.DistributedVirtualSwitch
+	err := retrieveAll(ctx, client, dc, "DistributedVirtualSwitch", []string{"name", "uuid"}, &switches)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var portgroups []mo.DistributedVirtualPortgroup
+	err = retrieveAll(ctx, client, dc, "DistributedVirtualPortgroup", []string{"name", "key"}, &portgroups)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switchNames := make(map[string]string, len(switches))
+	for _, s := range switches {
+		switchNames[s.Uuid] = s.Name
+	}
+	portgroupNames := make(map[string]string, len(portgroups))
+	for _, pg := range portgroups {
+		portgroupNames[pg.Key] = pg.Name
+	}
+	return switchNames, portgroupNames, nil
+}
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"github.com/vmware/govmomi/vsan"
+	vsanmethods "github.com/vmware/govmomi/vsan/methods"
+	vsantypes "github.com/vmware/govmomi/vsan/types"
+)
+
+// vsanHealthSystem and vsanSpaceReportSystem are vSAN services of vCenter that govmomi has no
+// instances for
+var (
+	vsanHealthSystem = types.ManagedObjectReference{
+		Type:  "VsanVcClusterHealthSystem",
+		Value: "vsan-cluster-health-system",
+	}
+	vsanSpaceReportSystem = types.ManagedObjectReference{
+		Type:  "VsanSpaceReportSystem",
+		Value: "vsan-cluster-space-report-system",
+	}
+)
+
+// vSAN health check results
+const (
+	vsanHealthGreen  = "green"
+	vsanHealthYellow = "yellow"
+	vsanHealthRed    = "red"
+)
+
+// vsanMode reports on the vSAN enabled clusters of the datacenter
+type vsanMode func(ctx context.Context, client *govmomi.Client, vc *vsan.Client, dc *object.Datacenter, clusters []mo.ClusterComputeResource, cfg *Config) error
+
+var vsanModes = map[string]vsanMode{
+	"health":   reportVSANHealth,
+	"resync":   reportVSANResync,
+	"capacity": reportVSANCapacity,
+}
+
+// vsanResyncObjects is the number of syncing objects fetched per cluster to break the resync
+// traffic down by reason
+const vsanResyncObjects = 500
+
+type VSANHealthCheckInfo struct {
+	Group  string `json:"group"`
+	Name   string `json:"name"`
+	Health string `json:"health"`
+}
+
+type ClusterVSANHealthInfo struct {
+	Name          string `json:"name"`
+	OverallHealth string `json:"overall_health"`
+	Description   string `json:"description,omitempty"`
+	Passed        int    `json:"passed"`
+	Warnings      int    `json:"warnings"`
+	Failed        int    `json:"failed"`
+	// Checks are the health checks with a warning (yellow) or failed (red) result
+	Checks []VSANHealthCheckInfo `json:"checks"`
+}
+
+type VSANHealthReport struct {
+	Datacenter string                  `json:"datacenter"`
+	Clusters   []ClusterVSANHealthInfo `json:"clusters"`
+}
+
+// VSANResyncReasonInfo is the resync traffic caused by one reason, like repair after a
+// host failure, rebalance, evacuate for maintenance mode or a policy reconfigure
+type VSANResyncReasonInfo struct {
+	Reason      string  `json:"reason"`
+	Objects     int     `json:"objects"`
+	BytesToSync float64 `json:"bytes_to_sync_gb"`
+}
+
+type ClusterVSANResyncInfo struct {
+	Name        string  `json:"name"`
+	Objects     int64   `json:"objects_to_sync"`
+	BytesToSync float64 `json:"bytes_to_sync_gb"`
+	// ETA is the estimated time until the resync completes in seconds
+	ETA       int64 `json:"eta_seconds"`
+	Active    int64 `json:"active_objects"`
+	Queued    int64 `json:"queued_objects"`
+	Suspended int64 `json:"suspended_objects"`
+	// Reasons covers the first vsanResyncObjects syncing objects of the cluster
+	Reasons []VSANResyncReasonInfo `json:"reasons"`
+}
+
+type VSANResyncReport struct {
+	Datacenter string                  `json:"datacenter"`
+	Clusters   []ClusterVSANResyncInfo `json:"clusters"`
+}
+
+// vsanObjectTypeTitles names the vSAN object types like the capacity view of the vSphere
+// Client, other types are shown as reported by vSAN
+var vsanObjectTypeTitles = map[string]string{
+	"namespace":           "VM home objects",
+	"vdisk":               "VMDKs",
+	"vmswap":              "Swap objects",
+	"vmem":                "VM memory snapshots",
+	"improvedVirtualDisk": "First class disks",
+	"attachedCnsVolBlock": "Block container volumes (attached)",
+	"detachedCnsVolBlock": "Block container volumes (detached)",
+	"cnsVolFile":          "File container volumes",
+	"fileShare":           "File shares",
+	"iscsiLun":            "iSCSI LUNs",
+	"iscsiTarget":         "iSCSI targets",
+	"hbrDisk":             "vSphere Replication disks",
+	"hbrCfg":              "vSphere Replication configuration",
+	"hbrPersist":          "vSphere Replication persistent state",
+	"statsdb":             "Performance management objects",
+	"checksumOverhead":    "Checksum overhead",
+	"dedupOverhead":       "Deduplication and compression overhead",
+	"fileSystemOverhead":  "File system overhead",
+	"haMetadataObject":    "HA metadata",
+	"transientSpace":      "Transient space",
+	"other":               "Other",
+}
+
+// vsanSystemObjectTypes are the object types making up the system overhead
+var vsanSystemObjectTypes = map[string]bool{
+	"statsdb":            true,
+	"checksumOverhead":   true,
+	"dedupOverhead":      true,
+	"fileSystemOverhead": true,
+	"haMetadataObject":   true,
+}
+
+type VSANObjectTypeUsageInfo struct {
+	Type  string  `json:"type"`
+	Title string  `json:"title"`
+	Used  float64 `json:"used_gb"`
+	// Overhead is the replica and parity space of the objects on top of their primary data
+	Overhead float64 `json:"overhead_gb"`
+	System   bool    `json:"system"`
+}
+
+type ClusterVSANCapacityInfo struct {
+	Name           string                    `json:"name"`
+	Capacity       float64                   `json:"capacity_gb"`
+	FreeSpace      float64                   `json:"free_space_gb"`
+	UsedPct        float64                   `json:"used_pct"`
+	SystemOverhead float64                   `json:"system_overhead_gb"`
+	ObjectTypes    []VSANObjectTypeUsageInfo `json:"object_types"`
+}
+
+type VSANCapacityReport struct {
+	Datacenter string                    `json:"datacenter"`
+	Clusters   []ClusterVSANCapacityInfo `json:"clusters"`
+}
+
+// reportVSAN runs the vSAN report given as argument, e.g. "vsan health", for the vSAN
+// enabled clusters of the datacenter
+func reportVSAN(ctx context.Context, client *govmomi.Client, finder *find.Finder, dc *object.Datacenter, cfg *Config) error {
+	if len(cfg.Args) != 1 {
+		return fmt.Errorf("vsan needs a mode, one of %s", vsanModeNames())
+	}
+	mode, ok := vsanModes[cfg.Args[0]]
+	if !ok {
+		return fmt.Errorf("unknown vsan mode %s, use one of %s", cfg.Args[0], vsanModeNames())
+	}
+
+	clusters, err := vsanClusters(ctx, client, finder, cfg)
+	if err != nil {
+		return err
+	}
+
+	vc, err := connectToVSAN(ctx, client, cfg)
+	if err != nil {
+		return fmt.Errorf("connecting to the vSAN management API: %s", err)
+	}
+
+	return mode(ctx, client, vc, dc, clusters, cfg)
+}
+
+func vsanModeNames() string {
+	names := make([]string, 0, len(vsanModes))
+	for name := range vsanModes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// vsanClusters returns the selected clusters that have vSAN enabled, sorted by name
+func vsanClusters(ctx context.Context, client *govmomi.Client, finder *find.Finder, cfg *Config) ([]mo.ClusterComputeResource, error) {
+	clusters, err := listClusters(ctx, finder, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("getting clusters: %s", err)
+	}
+	if len(clusters) == 0 {
+		return nil, nil
+	}
+
+	refs := make([]types.ManagedObjectReference, 0, len(clusters))
+	for _, cluster := range clusters {
+		refs = append(refs, cluster.Reference())
+	}
+
+	var all []mo.ClusterComputeResource
+	pc := property.DefaultCollector(client.Client)
+	err = pc.Retrieve(ctx, refs, []string{"name", "configurationEx"}, &all)
+	if err != nil {
+		return nil, fmt.Errorf("getting cluster configuration: %s", err)
+	}
+
+	enabled := make([]mo.ClusterComputeResource, 0, len(all))
+	for _, cluster := range all {
+		config, ok := cluster.ConfigurationEx.(*types.ClusterConfigInfoEx)
+		if !ok || config.VsanConfigInfo == nil || config.VsanConfigInfo.Enabled == nil || !*config.VsanConfigInfo.Enabled {
+			continue
+		}
+		enabled = append(enabled, cluster)
+	}
+	sort.Slice(enabled, func(i, j int) bool {
+		return enabled[i].Name < enabled[j].Name
+	})
+	return enabled, nil
+}
+
+// reportVSANHealth runs the vSAN health checks of every cluster and lists the checks that
+// failed or warn, grouped like Skyline Health in the vSphere Client
+func reportVSANHealth(ctx context.Context, client *govmomi.Client, vc *vsan.Client, dc *object.Datacenter, clusters []mo.ClusterComputeResource, cfg *Config) error {
+	report := VSANHealthReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterVSANHealthInfo, 0, len(clusters)),
+	}
+
+	for _, cluster := range clusters {
+		ref := cluster.Self
+		req := vsantypes.VsanQueryVcClusterHealthSummary{
+			This:           vsanHealthSystem,
+			Cluster:        &ref,
+			FetchFromCache: types.NewBool(false),
+		}
+		res, err := vsanmethods.VsanQueryVcClusterHealthSummary(ctx, vc, &req)
+		if err != nil {
+			return fmt.Errorf("running vSAN health checks of cluster %s: %s", cluster.Name, err)
+		}
+		summary := res.Returnval
+
+		info := ClusterVSANHealthInfo{
+			Name:          cluster.Name,
+			OverallHealth: summary.OverallHealth,
+			Description:   summary.OverallHealthDescription,
+			Checks:        make([]VSANHealthCheckInfo, 0),
+		}
+		for _, group := range summary.Groups {
+			for _, test := range group.GroupTests {
+				switch test.TestHealth {
+				case vsanHealthGreen:
+					info.Passed++
+					continue
+				case vsanHealthYellow:
+					info.Warnings++
+				case vsanHealthRed:
+					info.Failed++
+				default:
+					continue
+				}
+				info.Checks = append(info.Checks, VSANHealthCheckInfo{Group: group.GroupName, Name: test.TestName, Health: test.TestHealth})
+			}
+		}
+		// failed checks first
+		sort.SliceStable(info.Checks, func(i, j int) bool {
+			return info.Checks[i].Health == vsanHealthRed && info.Checks[j].Health != vsanHealthRed
+		})
+
+		report.Clusters = append(report.Clusters, info)
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	if len(report.Clusters) == 0 {
+		fmt.Println("No vSAN enabled clusters found in the selected datacenter.")
+		return nil
+	}
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", cluster.Name)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		fmt.Printf("  Overall health: %s", valueOrNone(cluster.OverallHealth))
+		if cluster.Description != "" {
+			fmt.Printf(" (%s)", cluster.Description)
+		}
+		fmt.Println()
+		fmt.Printf("  Checks: %d passed, %d warnings, %d failed\n", cluster.Passed, cluster.Warnings, cluster.Failed)
+		for _, check := range cluster.Checks {
+			label := "WARNING"
+			if check.Health == vsanHealthRed {
+				label = "FAILED"
+			}
+			fmt.Printf("    %s: %s: %s\n", label, check.Group, check.Name)
+		}
+	}
+
+	return nil
+}
+
+// reportVSANResync shows the objects vSAN is resyncing per cluster with the bytes left and
+// the estimated time to completion, the free space of a vSAN datastore is not final until
+// the resync is done
+func reportVSANResync(ctx context.Context, client *govmomi.Client, vc *vsan.Client, dc *object.Datacenter, clusters []mo.ClusterComputeResource, cfg *Config) error {
+	report := VSANResyncReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterVSANResyncInfo, 0, len(clusters)),
+	}
+
+	for _, cluster := range clusters {
+		req := vsantypes.QuerySyncingVsanObjectsSummary{
+			This:                vsan.VsanQueryObjectIdentitiesInstance,
+			Cluster:             cluster.Self,
+			SyncingObjectFilter: &vsantypes.VsanSyncingObjectFilter{NumberOfObjects: vsanResyncObjects},
+		}
+		res, err := vsanmethods.QuerySyncingVsanObjectsSummary(ctx, vc, &req)
+		if err != nil {
+			return fmt.Errorf("querying vSAN resync of cluster %s: %s", cluster.Name, err)
+		}
+		result := res.Returnval
+
+		info := ClusterVSANResyncInfo{
+			Name:        cluster.Name,
+			Objects:     result.TotalObjectsToSync,
+			BytesToSync: bytesToGB(result.TotalBytesToSync),
+			ETA:         result.TotalRecoveryETA,
+			Reasons:     make([]VSANResyncReasonInfo, 0),
+		}
+		if details := result.SyncingObjectRecoveryDetails; details != nil {
+			info.Active = details.ActiveObjectsToSync
+			info.Queued = details.QueuedObjectsToSync
+			info.Suspended = details.SuspendedObjectsToSync
+		}
+
+		objects := make(map[string]int)
+		bytes := make(map[string]int64)
+		for _, obj := range result.Objects {
+			// an object counts once per reason of its components
+			seen := make(map[string]bool)
+			for _, component := range obj.Components {
+				for _, reason := range component.Reasons {
+					bytes[reason] += component.BytesToSync
+					if !seen[reason] {
+						seen[reason] = true
+						objects[reason]++
+					}
+				}
+			}
+		}
+		for reason, count := range objects {
+			info.Reasons = append(info.Reasons, VSANResyncReasonInfo{Reason: reason, Objects: count, BytesToSync: bytesToGB(bytes[reason])})
+		}
+		sort.Slice(info.Reasons, func(i, j int) bool {
+			return info.Reasons[i].Reason < info.Reasons[j].Reason
+		})
+
+		report.Clusters = append(report.Clusters, info)
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	if len(report.Clusters) == 0 {
+		fmt.Println("No vSAN enabled clusters found in the selected datacenter.")
+		return nil
+	}
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", cluster.Name)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		if cluster.Objects == 0 {
+			fmt.Println("  No objects resyncing")
+			continue
+		}
+		fmt.Printf("  Objects resyncing: %d (%d active, %d queued, %d suspended)\n", cluster.Objects, cluster.Active, cluster.Queued, cluster.Suspended)
+		fmt.Printf("  Remaining: %s\n", formatGB(cluster.BytesToSync))
+		fmt.Printf("  ETA: %s\n", formatUptime(int32(cluster.ETA)))
+		for _, reason := range cluster.Reasons {
+			fmt.Printf("    %s: %d objects, %s\n", reason.Reason, reason.Objects, formatGB(reason.BytesToSync))
+		}
+		fmt.Println("  WARNING: the vSAN datastore free space is not final until the resync completes")
+	}
+
+	return nil
+}
+
+// reportVSANCapacity breaks the vSAN datastore usage of each cluster down by object type,
+// VM home objects, VMDKs, swap, snapshots and the system overhead
+func reportVSANCapacity(ctx context.Context, client *govmomi.Client, vc *vsan.Client, dc *object.Datacenter, clusters []mo.ClusterComputeResource, cfg *Config) error {
+	report := VSANCapacityReport{
+		Datacenter: dc.Name(),
+		Clusters:   make([]ClusterVSANCapacityInfo, 0, len(clusters)),
+	}
+
+	for _, cluster := range clusters {
+		req := vsantypes.VsanQuerySpaceUsage{
+			This:    vsanSpaceReportSystem,
+			Cluster: cluster.Self,
+		}
+		res, err := vsanmethods.VsanQuerySpaceUsage(ctx, vc, &req)
+		if err != nil {
+			return fmt.Errorf("querying vSAN space usage of cluster %s: %s", cluster.Name, err)
+		}
+		usage := res.Returnval
+
+		info := ClusterVSANCapacityInfo{
+			Name:        cluster.Name,
+			Capacity:    bytesToGB(usage.TotalCapacityB),
+			FreeSpace:   bytesToGB(usage.FreeCapacityB),
+			ObjectTypes: make([]VSANObjectTypeUsageInfo, 0),
+		}
+		info.UsedPct = usedPct(info.Capacity, info.FreeSpace)
+
+		if usage.SpaceDetail != nil {
+			for _, summary := range usage.SpaceDetail.SpaceUsageByObjectType {
+				// the reservations for rebuilds and operations report no used space
+				if summary.UsedB == 0 && summary.OverheadB == 0 {
+					continue
+				}
+				title := vsanObjectTypeTitles[summary.ObjType]
+				if title == "" {
+					title = summary.ObjType
+				}
+				typeInfo := VSANObjectTypeUsageInfo{
+					Type:     summary.ObjType,
+					Title:    title,
+					Used:     bytesToGB(summary.UsedB),
+					Overhead: bytesToGB(summary.OverheadB),
+					System:   vsanSystemObjectTypes[summary.ObjType],
+				}
+				if typeInfo.System {
+					info.SystemOverhead += typeInfo.Used
+				}
+				info.ObjectTypes = append(info.ObjectTypes, typeInfo)
+			}
+		}
+		sort.SliceStable(info.ObjectTypes, func(i, j int) bool {
+			a, b := info.ObjectTypes[i], info.ObjectTypes[j]
+			if a.System != b.System {
+				return !a.System
+			}
+			return a.Used > b.Used
+		})
+
+		report.Clusters = append(report.Clusters, info)
+	}
+
+	if cfg.OutputJSON {
+		return printJSON(report)
+	}
+
+	if len(report.Clusters) == 0 {
+		fmt.Println("No vSAN enabled clusters found in the selected datacenter.")
+		return nil
+	}
+	for _, cluster := range report.Clusters {
+		fmt.Printf("\nCluster: %s\n", cluster.Name)
+		fmt.Println(strings.Repeat("-", len(cluster.Name)+9))
+		fmt.Printf("  Capacity: %s, Free: %s (%s used)\n", formatGB(cluster.Capacity), formatGB(cluster.FreeSpace), formatPct(cluster.UsedPct))
+		fmt.Println("  Usage by object type:")
+		for _, t := range cluster.ObjectTypes {
+			if t.System {
+				continue
+			}
+			fmt.Printf("    %s: %s", t.Title, formatGB(t.Used))
+			if t.Overhead > 0 {
+				fmt.Printf(" (%s replica overhead)", formatGB(t.Overhead))
+			}
+			fmt.Println()
+		}
+		fmt.Printf("  System overhead: %s\n", formatGB(cluster.SystemOverhead))
+		for _, t := range cluster.ObjectTypes {
+			if t.System {
+				fmt.Printf("    %s: %s\n", t.Title, formatGB(t.Used))
+			}
+		}
+	}
+
+	return nil
+}
+
+// connectToVSAN returns a client of the vSAN management API of vCenter
+func connectToVSAN(ctx context.Context, client *govmomi.Client, cfg *Config) (*vsan.Client, error) {
+	c, err := vsan.NewClient(ctx, client.Client)
+	if err != nil {
+		return nil, err
+	}
+	c.Client.Transport = vcenterTransport(cfg, c.Client.Transport)
+	return c, nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// VVolInfo describes the storage behind a vVol datastore. Capacity and free
+// space of a vVol datastore only reflect the limits of its storage container.
+type VVolInfo struct {
+	StorageContainer  string                 `json:"storage_container"`
+	StorageArrays     []string               `json:"storage_arrays"`
+	VASAProviders     []VASAProviderInfo     `json:"vasa_providers"`
+	ProtocolEndpoints []ProtocolEndpointInfo `json:"protocol_endpoints"`
+}
+
+type VASAProviderInfo struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Active bool   `json:"active"`
+}
+
+type ProtocolEndpointInfo struct {
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	Backing string   `json:"backing"`
+	Hosts   []string `json:"hosts"`
+}
+
+// vvolVolume returns the vVol volume details of a datastore, if it has any
+func vvolVolume(ds mo.Datastore) *types.HostVvolVolume {
+	if info, ok := ds.Info.(*types.VvolDatastoreInfo); ok {
+		return info.VvolDS
+	}
+	return nil
+}
+
+// vvolHostNames resolves the names of all hosts referenced by vVol protocol endpoints
+func vvolHostNames(ctx context.Context, pc *property.Collector, datastores []mo.Datastore) (map[string]string, error) {
+	names := make(map[string]string)
+
+	var refs []types.ManagedObjectReference
+	for _, ds := range datastores {
+		volume := vvolVolume(ds)
+		if volume == nil {
+			continue
+		}
+		for _, pe := range volume.HostPE {
+			refs = append(refs, pe.Key)
+		}
+	}
+
+	if len(refs) == 0 {
+		return names, nil
+	}
+
+	var hosts []mo.HostSystem
+	if err := pc.Retrieve(ctx, refs, []string{"name"}, &hosts); err != nil {
+		return nil, err
+	}
+	for _, host := range hosts {
+		names[host.Reference().Value] = host.Name
+	}
+
+	return names, nil
+}
+
+// newVVolInfo collects the storage container, VASA providers and protocol endpoints of a vVol datastore
+func newVVolInfo(ds mo.Datastore, hostNames map[string]string) *VVolInfo {
+	info := &VVolInfo{
+		StorageArrays:     make([]string, 0),
+		VASAProviders:     make([]VASAProviderInfo, 0),
+		ProtocolEndpoints: make([]ProtocolEndpointInfo, 0),
+	}
+
+	volume := vvolVolume(ds)
+	if volume == nil {
+		return info
+	}
+
+	info.StorageContainer = volume.ScId
+
+	for _, array := range volume.StorageArray {
+		info.StorageArrays = append(info.StorageArrays, array.Name)
+	}
+
+	for _, vp := range volume.VasaProviderInfo {
+		active := false
+		for _, state := range vp.ArrayState {
+			if state.Active {
+				active = true
+				break
+			}
+		}
+		info.VASAProviders = append(info.VASAProviders, VASAProviderInfo{
+			Name:   vp.Provider.Name,
+			URL:    vp.Provider.Url,
+			Active: active,
+		})
+	}
+
+	// The same protocol endpoint is usually reported once per host, merge them
+	endpoints := make(map[string]int)
+	for _, hostPE := range volume.HostPE {
+		host := hostNames[hostPE.Key.Value]
+		if host == "" {
+			host = hostPE.Key.Value
+		}
+
+		for _, pe := range hostPE.ProtocolEndpoint {
+			i, exists := endpoints[pe.Uuid]
+			if !exists {
+				i = len(info.ProtocolEndpoints)
+				endpoints[pe.Uuid] = i
+				info.ProtocolEndpoints = append(info.ProtocolEndpoints, ProtocolEndpointInfo{
+					ID:      pe.Uuid,
+					Type:    protocolEndpointType(pe),
+					Backing: protocolEndpointBacking(pe),
+					Hosts:   make([]string, 0),
+				})
+			}
+			info.ProtocolEndpoints[i].Hosts = append(info.ProtocolEndpoints[i].Hosts, host)
+		}
+	}
+
+	return info
+}
+
+// protocolEndpointType returns the access protocol of a protocol endpoint
+func protocolEndpointType(pe types.HostProtocolEndpoint) string {
+	if pe.Type != "" {
+		return pe.Type
+	}
+	return pe.PeType
+}
+
+// protocolEndpointBacking describes the device or NFS export behind a protocol endpoint
+func protocolEndpointBacking(pe types.HostProtocolEndpoint) string {
+	if pe.NfsServer != "" {
+		return fmt.Sprintf("%s:%s", pe.NfsServer, pe.NfsDir)
+	}
+	if pe.DeviceId != "" {
+		return pe.DeviceId
+	}
+	return pe.StorageArray
+}
+
+// printVVol prints the vVol details below a datastore line in text output
+func printVVol(info *VVolInfo) {
+	fmt.Println("        vVol datastore (capacity reflects the storage container limit)")
+	fmt.Printf("        Storage container: %s\n", info.StorageContainer)
+	if len(info.StorageArrays) > 0 {
+		fmt.Printf("        Storage arrays: %s\n", strings.Join(info.StorageArrays, ", "))
+	}
+
+	for _, vp := range info.VASAProviders {
+		state := "standby"
+		if vp.Active {
+			state = "active"
+		}
+		fmt.Printf("        VASA provider: %s (%s, %s)\n", vp.Name, valueOrNone(vp.URL), state)
+	}
+
+	for _, pe := range info.ProtocolEndpoints {
+		fmt.Printf("        Protocol endpoint: %s (%s, %s) on %d host(s)\n",
+			pe.ID, pe.Type, pe.Backing, len(pe.Hosts))
+	}
+}